@@ -19,14 +19,23 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/controlpb"
 	"github.com/pingcap/tidb-lightning/lightning/kv"
 	"github.com/pingcap/tidb-lightning/lightning/restore"
+	"google.golang.org/grpc"
 )
 
+// controlTimeout limits each request to the control server of a running
+// Lightning.
+const controlTimeout = 10 * time.Second
+
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, errors.ErrorStack(err))
@@ -49,16 +58,42 @@ func run() error {
 	cpErrDestroy := fs.String("checkpoint-error-destroy", "", "deletes imported data with table which has an error before (value can be 'all' or '`db`.`table`')")
 	cpDump := fs.String("checkpoint-dump", "", "dump the checkpoint information as two CSV files in the given folder")
 
+	controlAddr := fs.String("control-addr", "", "address of the control server of the running Lightning, overriding lightning.control-addr of the config file")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: tidb-lightning-ctl [flags] [command]")
+		fmt.Fprintln(os.Stderr, "\nCommands sent to the control server of a running Lightning:")
+		fmt.Fprintln(os.Stderr, "  status <task-id>      show the state and the progress of the task")
+		fmt.Fprintln(os.Stderr, "  pause <task-id>       stop the task, keeping its checkpoints")
+		fmt.Fprintln(os.Stderr, "  resume <task-id>      queue the paused task again")
+		fmt.Fprintln(os.Stderr, "  cancel <task-id>      stop the task for good")
+		fmt.Fprintln(os.Stderr, "  log-level <level>     change the log level of the server (debug, info, warn, error or fatal)")
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
 	err := fs.Parse(os.Args[1:])
+	if err == nil && fs.NArg() > 0 && len(*controlAddr) != 0 {
+		// the control commands do not need the config file.
+		return errors.Trace(runControlCommand(*controlAddr, fs.Args()))
+	}
 	if err == nil {
 		err = cfg.Load()
 	}
+	if err == nil {
+		err = common.SetProxy(cfg.Proxy.URL, cfg.Proxy.NoProxy, cfg.Proxy.GRPC)
+	}
 	if err != nil {
 		return errors.Trace(err)
 	}
 
 	ctx := context.Background()
 
+	if fs.NArg() > 0 {
+		if len(cfg.App.ControlAddr) == 0 {
+			return errors.New("the control server address is unknown, please specify -control-addr or lightning.control-addr")
+		}
+		return errors.Trace(runControlCommand(cfg.App.ControlAddr, fs.Args()))
+	}
 	if *compact {
 		return errors.Trace(compactCluster(ctx, cfg))
 	}
@@ -157,6 +192,13 @@ func checkpointErrorDestroy(ctx context.Context, cfg *config.Config, tableName s
 		return errors.Trace(err)
 	}
 	defer importer.Close()
+	importer.SetShardByTable(cfg.TikvImporter.Shard == config.ShardByTable)
+
+	taskID, err := cpdb.TaskID(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	importer.SetTaskID(taskID)
 
 	targetTables, err := cpdb.DestroyErrorCheckpoint(ctx, tableName)
 	if err != nil {
@@ -233,3 +275,64 @@ func checkpointDump(ctx context.Context, cfg *config.Config, dumpFolder string)
 	}
 	return nil
 }
+
+// runControlCommand sends a command to the control server of a running
+// Lightning, and prints the result.
+func runControlCommand(addr string, args []string) error {
+	if len(args) != 2 {
+		return errors.Errorf("command %s needs exactly one argument, see -h for the usage", args[0])
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), controlTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure())
+	if err != nil {
+		return errors.Annotatef(err, "cannot connect to the control server %s", addr)
+	}
+	defer conn.Close()
+	client := controlpb.NewControlClient(conn)
+
+	if args[0] == "log-level" {
+		resp, err := client.SetLogLevel(ctx, &controlpb.SetLogLevelRequest{Level: args[1]})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		fmt.Printf("log level changed from %s to %s\n", resp.PreviousLevel, args[1])
+		return nil
+	}
+
+	taskID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return errors.Errorf("invalid task ID %s", args[1])
+	}
+	req := &controlpb.TaskRequest{TaskId: taskID}
+
+	var resp *controlpb.TaskResponse
+	switch args[0] {
+	case "status":
+		progress, err := client.GetProgress(ctx, req)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		fmt.Printf("task %d: %s\n", taskID, progress.State)
+		fmt.Printf("chunks: %.0f of %.0f finished\n", progress.FinishedChunks, progress.EstimatedChunks)
+		fmt.Printf("tables: %.0f of %.0f completed\n", progress.CompletedTables, progress.TotalTables)
+		if len(progress.Error) != 0 {
+			fmt.Printf("error: %s\n", progress.Error)
+		}
+		return nil
+	case "pause":
+		resp, err = client.PauseTask(ctx, req)
+	case "resume":
+		resp, err = client.ResumeTask(ctx, req)
+	case "cancel":
+		resp, err = client.CancelTask(ctx, req)
+	default:
+		return errors.Errorf("unknown command %s, see -h for the usage", args[0])
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Printf("task %d: %s\n", taskID, resp.State)
+	return nil
+}
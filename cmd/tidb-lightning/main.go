@@ -14,7 +14,9 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
@@ -42,7 +44,9 @@ func main() {
 	case flag.ErrHelp:
 		os.Exit(0)
 	default:
-		common.AppLogger.Fatalf("parse cmd flags error: %s", err)
+		common.AppLogger.Errorf("parse cmd flags error: %s", err)
+		printExitReport(lightning.NewExitReport(nil, nil, err))
+		os.Exit(1)
 	}
 
 	app := lightning.New(cfg)
@@ -63,8 +67,23 @@ func main() {
 	err = app.Run()
 	if err != nil {
 		common.AppLogger.Error("tidb lightning encountered error:", errors.ErrorStack(err))
+		fmt.Fprintln(os.Stderr, "tidb lightning encountered error:", err)
+		if hint := common.ErrorHint(err); len(hint) > 0 {
+			common.AppLogger.Error("hint: ", hint)
+			fmt.Fprintln(os.Stderr, "hint:", hint)
+		}
+		printExitReport(app.ExitReport(err))
 		os.Exit(1)
 	}
 
 	common.AppLogger.Info("tidb lightning exit.")
 }
+
+// printExitReport prints the report as the last line of stderr.
+func printExitReport(report *lightning.ExitReport) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(data))
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	sstpb "github.com/pingcap/kvproto/pkg/import_sstpb"
+	kvenc "github.com/pingcap/tidb/util/kvencoder"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+// Backend is the destination of the encoded KV pairs. The KV pairs of a table
+// are written into one or more engines, each identified by the table name and
+// the engine ID. An engine is opened, written, closed, imported as a whole and
+// finally cleaned up. Implementations must be goroutine safe.
+type Backend interface {
+	// Name is the name the backend is registered with.
+	Name() string
+	// Close releases the resources of the backend.
+	Close()
+
+	// OpenEngine opens the engine for writing. A fresh engine is expected to
+	// contain no data. Opening an engine which is already open keeps its
+	// data, which is needed to resume writing after the destination
+	// restarted.
+	OpenEngine(ctx context.Context, tableName string, engineID int, fresh bool) error
+	// WriteRows writes the KV pairs into the opened engine. The pairs are
+	// acknowledged by the destination once it returns nil. If it fails, the
+	// pairs may be partially written, and may be written again.
+	WriteRows(ctx context.Context, tableName string, engineID int, kvs []kvenc.KvPair) error
+	// CloseEngine closes the engine, after which it can no longer be
+	// written. Closing an engine not opened in this process is allowed when
+	// resuming from a checkpoint.
+	CloseEngine(ctx context.Context, tableName string, engineID int) error
+	// ImportEngine imports the closed engine into the target cluster.
+	ImportEngine(ctx context.Context, tableName string, engineID int) error
+	// CleanupEngine deletes the data of the engine from the backend.
+	CleanupEngine(ctx context.Context, tableName string, engineID int) error
+	// Checksum returns the checksum of the KV pairs successfully delivered to
+	// the engine since it is opened in this process, as counted by the client.
+	Checksum(tableName string, engineID int) verify.KVChecksum
+}
+
+// ClusterBackend is implemented by the backends ingesting into the TiKV
+// cluster directly, which needs to be switched into import mode during the
+// import, and compacted afterwards.
+type ClusterBackend interface {
+	Backend
+	SwitchMode(ctx context.Context, mode sstpb.SwitchMode) error
+	Compact(ctx context.Context, level int32) error
+}
+
+// Factory creates a backend from the config.
+type Factory func(ctx context.Context, cfg *config.Config) (Backend, error)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = make(map[string]Factory)
+)
+
+// Register adds a backend, which can then be selected by the name with
+// `tikv-importer.backend`. It panics if the name is registered twice.
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+	if _, ok := factories[name]; ok {
+		panic("backend: " + name + " is registered twice")
+	}
+	factories[name] = factory
+}
+
+// New creates the backend registered with the name.
+func New(ctx context.Context, name string, cfg *config.Config) (Backend, error) {
+	factoriesLock.RLock()
+	factory, ok := factories[name]
+	factoriesLock.RUnlock()
+	if !ok {
+		return nil, common.ErrInvalidConfig.Errorf("unknown tikv-importer.backend %q, must be one of %s", name, strings.Join(Names(), ", "))
+	}
+	return factory(ctx, cfg)
+}
+
+// Names returns the sorted names of the registered backends.
+func Names() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
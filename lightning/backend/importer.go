@@ -0,0 +1,234 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pingcap/errors"
+	sstpb "github.com/pingcap/kvproto/pkg/import_sstpb"
+	kvenc "github.com/pingcap/tidb/util/kvencoder"
+	"github.com/satori/go.uuid"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/kv"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+// ImporterBackendName is the name of the backend writing into tikv-importer.
+const ImporterBackendName = config.BackendImporter
+
+func init() {
+	Register(ImporterBackendName, newImporterBackend)
+}
+
+// importerBackend writes the KV pairs into tikv-importer, which sorts them
+// and ingests them into TiKV as SST files.
+type importerBackend struct {
+	importer *kv.Importer
+
+	lock   sync.Mutex
+	opened map[string]*kv.OpenedEngine
+	closed map[string]*kv.ClosedEngine
+}
+
+func newImporterBackend(ctx context.Context, cfg *config.Config) (Backend, error) {
+	importer, err := kv.NewImporter(ctx, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	importer.SetShardByTable(cfg.TikvImporter.Shard == config.ShardByTable)
+	importer.SetDiskQuota(cfg.TikvImporter.DiskQuota)
+
+	compression := cfg.TikvImporter.Compression
+	// skip the negotiation if explicitly turned off, trusting the config.
+	if cfg.App.CheckRequirements {
+		capabilities, err := importer.Capabilities(ctx)
+		if err != nil {
+			importer.Close()
+			return nil, errors.Trace(err)
+		}
+		if compression == config.CompressionGzip && !capabilities.Compression {
+			common.AppLogger.Warnf("tikv-importer does not support compression before %s, disabling tikv-importer.compression", kv.CompressionImporterVersion)
+			compression = config.CompressionNone
+		}
+	}
+	if compression == config.CompressionGzip {
+		if err := importer.SetCompression(config.CompressionGzip); err != nil {
+			importer.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	return &importerBackend{
+		importer: importer,
+		opened:   make(map[string]*kv.OpenedEngine),
+		closed:   make(map[string]*kv.ClosedEngine),
+	}, nil
+}
+
+func engineTag(tableName string, engineID int) string {
+	return fmt.Sprintf("%s:%d", tableName, engineID)
+}
+
+func (b *importerBackend) Name() string {
+	return ImporterBackendName
+}
+
+func (b *importerBackend) Close() {
+	b.importer.Close()
+}
+
+// SetTaskID binds the engines to the task, see kv.Importer.SetTaskID.
+func (b *importerBackend) SetTaskID(taskID uuid.UUID) {
+	b.importer.SetTaskID(taskID)
+}
+
+// WaitDiskQuota blocks until the disk usage of tikv-importer drops below the
+// quota, see kv.Importer.WaitDiskQuota.
+func (b *importerBackend) WaitDiskQuota(ctx context.Context, tag string) error {
+	return b.importer.WaitDiskQuota(ctx, tag)
+}
+
+func (b *importerBackend) SwitchMode(ctx context.Context, mode sstpb.SwitchMode) error {
+	return b.importer.SwitchMode(ctx, mode)
+}
+
+func (b *importerBackend) Compact(ctx context.Context, level int32) error {
+	return b.importer.Compact(ctx, level)
+}
+
+func (b *importerBackend) OpenEngine(ctx context.Context, tableName string, engineID int, fresh bool) error {
+	tag := engineTag(tableName, engineID)
+	b.lock.Lock()
+	engine, ok := b.opened[tag]
+	b.lock.Unlock()
+	if ok {
+		return errors.Trace(engine.Reopen(ctx))
+	}
+
+	var err error
+	if fresh {
+		engine, err = b.importer.OpenFreshEngine(ctx, tableName, engineID)
+	} else {
+		engine, err = b.importer.OpenEngine(ctx, tableName, engineID)
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.lock.Lock()
+	b.opened[tag] = engine
+	b.lock.Unlock()
+	return nil
+}
+
+func (b *importerBackend) openedEngine(tableName string, engineID int) (*kv.OpenedEngine, error) {
+	tag := engineTag(tableName, engineID)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	engine, ok := b.opened[tag]
+	if !ok {
+		return nil, errors.Errorf("[%s] engine is not opened", tag)
+	}
+	return engine, nil
+}
+
+func (b *importerBackend) WriteRows(ctx context.Context, tableName string, engineID int, kvs []kvenc.KvPair) error {
+	engine, err := b.openedEngine(tableName, engineID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	stream, err := engine.NewWriteStream(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = stream.Put(kvs)
+	// the stream must be closed even if the put failed.
+	if closeErr := stream.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Trace(err)
+}
+
+func (b *importerBackend) CloseEngine(ctx context.Context, tableName string, engineID int) error {
+	tag := engineTag(tableName, engineID)
+	b.lock.Lock()
+	engine, ok := b.opened[tag]
+	b.lock.Unlock()
+
+	var closedEngine *kv.ClosedEngine
+	var err error
+	if ok {
+		closedEngine, err = engine.Close(ctx)
+	} else {
+		closedEngine, err = b.importer.UnsafeCloseEngine(ctx, tableName, engineID)
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	b.lock.Lock()
+	delete(b.opened, tag)
+	b.closed[tag] = closedEngine
+	b.lock.Unlock()
+	return nil
+}
+
+func (b *importerBackend) closedEngine(ctx context.Context, tableName string, engineID int) (*kv.ClosedEngine, error) {
+	tag := engineTag(tableName, engineID)
+	b.lock.Lock()
+	engine, ok := b.closed[tag]
+	b.lock.Unlock()
+	if ok {
+		return engine, nil
+	}
+	if err := b.CloseEngine(ctx, tableName, engineID); err != nil {
+		return nil, errors.Trace(err)
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.closed[tag], nil
+}
+
+func (b *importerBackend) ImportEngine(ctx context.Context, tableName string, engineID int) error {
+	engine, err := b.closedEngine(ctx, tableName, engineID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(engine.Import(ctx))
+}
+
+func (b *importerBackend) CleanupEngine(ctx context.Context, tableName string, engineID int) error {
+	engine, err := b.closedEngine(ctx, tableName, engineID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := engine.Cleanup(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	b.lock.Lock()
+	delete(b.closed, engineTag(tableName, engineID))
+	b.lock.Unlock()
+	return nil
+}
+
+func (b *importerBackend) Checksum(tableName string, engineID int) verify.KVChecksum {
+	engine, err := b.openedEngine(tableName, engineID)
+	if err != nil {
+		return verify.KVChecksum{}
+	}
+	return engine.DeliveredChecksum()
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// SRVPrefix marks an address to be discovered from the DNS SRV records of the
+// name following it, e.g. "srv://_importer._tcp.example.com".
+const SRVPrefix = "srv://"
+
+// lookupSRV is replaced in tests.
+var lookupSRV = net.LookupSRV
+
+// JoinHostPort combines the host and port into an address, putting IPv6
+// literals into brackets. The host may already be bracketed.
+func JoinHostPort(host string, port int) string {
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), strconv.Itoa(port))
+}
+
+func lookupSRVAddrs(name string) ([]*net.SRV, error) {
+	_, records, err := lookupSRV("", "", name)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot look up SRV records of %s", name)
+	}
+	if len(records) == 0 {
+		return nil, errors.Errorf("no SRV records for %s", name)
+	}
+	return records, nil
+}
+
+// ResolveHostPort returns the host and port to connect to. If the host starts
+// with SRVPrefix, both are taken from the SRV record of the highest priority.
+// Otherwise the brackets around an IPv6 literal are removed.
+func ResolveHostPort(host string, port int) (string, int, error) {
+	if strings.HasPrefix(host, SRVPrefix) {
+		records, err := lookupSRVAddrs(host[len(SRVPrefix):])
+		if err != nil {
+			return "", 0, errors.Trace(err)
+		}
+		return strings.TrimSuffix(records[0].Target, "."), int(records[0].Port), nil
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), port, nil
+}
+
+// ResolveAddrs expands a comma-separated list of "host:port" addresses, where
+// IPv6 literals must be bracketed, and entries starting with SRVPrefix are
+// replaced by all targets of their SRV records, ordered by priority.
+func ResolveAddrs(addrs string) ([]string, error) {
+	var result []string
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		switch {
+		case len(addr) == 0:
+			continue
+		case strings.HasPrefix(addr, SRVPrefix):
+			records, err := lookupSRVAddrs(addr[len(SRVPrefix):])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			for _, record := range records {
+				result = append(result, JoinHostPort(strings.TrimSuffix(record.Target, "."), int(record.Port)))
+			}
+		default:
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return nil, errors.Annotatef(err, "invalid address %q, IPv6 addresses should be written like [::1]:2379", addr)
+			}
+			result = append(result, addr)
+		}
+	}
+	return result, nil
+}
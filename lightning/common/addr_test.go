@@ -0,0 +1,79 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+var _ = Suite(&addrSuite{})
+
+type addrSuite struct{}
+
+func (s *addrSuite) SetUpTest(c *C) {
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		switch name {
+		case "_importer._tcp.example.com":
+			return name, []*net.SRV{
+				{Target: "importer-1.example.com.", Port: 8287, Priority: 1},
+				{Target: "fd00::2", Port: 8288, Priority: 2},
+			}, nil
+		default:
+			return "", nil, errors.New("no such host")
+		}
+	}
+}
+
+func (s *addrSuite) TearDownTest(c *C) {
+	lookupSRV = net.LookupSRV
+}
+
+func (s *addrSuite) TestJoinHostPort(c *C) {
+	c.Assert(JoinHostPort("127.0.0.1", 4000), Equals, "127.0.0.1:4000")
+	c.Assert(JoinHostPort("::1", 4000), Equals, "[::1]:4000")
+	c.Assert(JoinHostPort("[::1]", 4000), Equals, "[::1]:4000")
+	c.Assert(ToDSN("fd00::1", 4000, "root", ""), Equals, "root:@tcp([fd00::1]:4000)/?charset=utf8")
+}
+
+func (s *addrSuite) TestResolveHostPort(c *C) {
+	host, port, err := ResolveHostPort("[::1]", 4000)
+	c.Assert(err, IsNil)
+	c.Assert(host, Equals, "::1")
+	c.Assert(port, Equals, 4000)
+
+	host, port, err = ResolveHostPort("srv://_importer._tcp.example.com", 4000)
+	c.Assert(err, IsNil)
+	c.Assert(host, Equals, "importer-1.example.com")
+	c.Assert(port, Equals, 8287)
+
+	_, _, err = ResolveHostPort("srv://_tidb._tcp.example.com", 4000)
+	c.Assert(err, ErrorMatches, "cannot look up SRV records of _tidb._tcp.example.com: no such host")
+}
+
+func (s *addrSuite) TestResolveAddrs(c *C) {
+	addrs, err := ResolveAddrs("127.0.0.1:8287, [fd00::1]:8287,srv://_importer._tcp.example.com")
+	c.Assert(err, IsNil)
+	c.Assert(addrs, DeepEquals, []string{
+		"127.0.0.1:8287",
+		"[fd00::1]:8287",
+		"importer-1.example.com:8287",
+		"[fd00::2]:8288",
+	})
+
+	_, err = ResolveAddrs("fd00::1:2379")
+	c.Assert(err, ErrorMatches, `invalid address "fd00::1:2379", IPv6 addresses should be written like \[::1\]:2379: .*`)
+}
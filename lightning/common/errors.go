@@ -0,0 +1,151 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pingcap/errors"
+)
+
+// ErrorClass classifies the errors which stop Lightning, and tells the user
+// how to resolve them.
+type ErrorClass struct {
+	code string
+	hint string
+}
+
+var (
+	ErrInvalidConfig = &ErrorClass{
+		code: "InvalidConfig",
+		hint: "please correct the configuration file or the command line arguments",
+	}
+	ErrInvalidSource = &ErrorClass{
+		code: "InvalidSource",
+		hint: "please check the files in mydumper.data-source-dir, and make sure they are complete and in a supported format",
+	}
+	ErrEncodeKV = &ErrorClass{
+		code: "EncodeKV",
+		hint: "please check that the data at the reported position matches the table schema and tidb.sql-mode",
+	}
+	ErrTiDBUnavailable = &ErrorClass{
+		code: "TiDBUnavailable",
+		hint: "please check that TiDB is running and reachable at tidb.host and tidb.port, then run Lightning again to resume from the checkpoint",
+	}
+	ErrImporterUnavailable = &ErrorClass{
+		code: "ImporterUnavailable",
+		hint: "please check that tikv-importer is running and reachable at tikv-importer.addr, then run Lightning again to resume from the checkpoint",
+	}
+	ErrImportEngine = &ErrorClass{
+		code: "ImportEngine",
+		hint: "please check the logs of tikv-importer and TiKV for the cause, then run Lightning again to resume from the checkpoint",
+	}
+	ErrChecksumMismatch = &ErrorClass{
+		code: "ChecksumMismatch",
+		hint: "the imported data differs from the data source. please make sure the target table was empty before the import, " +
+			"then clean it up with tidb-lightning-ctl --checkpoint-error-destroy='<table>' and import again",
+	}
+	ErrCheckpointInvalid = &ErrorClass{
+		code: "CheckpointInvalid",
+		hint: "the table has failed in a previous run. after fixing the cause, either resume with tidb-lightning-ctl --checkpoint-error-ignore='<table>', " +
+			"or start over with tidb-lightning-ctl --checkpoint-error-destroy='<table>'",
+	}
+)
+
+// Code returns the short name of the error class.
+func (c *ErrorClass) Code() string {
+	return c.code
+}
+
+// Hint returns the suggestion to resolve errors of this class.
+func (c *ErrorClass) Hint() string {
+	return c.hint
+}
+
+// Wrap classifies the error. An error which has already been classified keeps
+// its original class. Returns nil if err is nil.
+func (c *ErrorClass) Wrap(err error) error {
+	if err == nil || ClassOf(err) != nil {
+		return err
+	}
+	return &classifiedError{class: c, cause: err}
+}
+
+// Annotatef adds the context to the error and classifies it. Returns nil if
+// err is nil.
+func (c *ErrorClass) Annotatef(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return c.Wrap(errors.Annotatef(err, format, args...))
+}
+
+// Errorf creates a new error of this class.
+func (c *ErrorClass) Errorf(format string, args ...interface{}) error {
+	return c.Wrap(errors.Errorf(format, args...))
+}
+
+// Equal checks if the error belongs to this class.
+func (c *ErrorClass) Equal(err error) bool {
+	return ClassOf(err) == c
+}
+
+// ClassOf returns the class of the error, or nil if it is not classified.
+func ClassOf(err error) *ErrorClass {
+	for err != nil {
+		if e, ok := err.(*classifiedError); ok {
+			return e.class
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = causer.Cause()
+	}
+	return nil
+}
+
+// ErrorHint returns the suggestion to resolve the error, or an empty string if
+// the error is not classified. The hint may refer to the failed table as
+// "<table>", to be replaced by the caller if the table is known.
+func ErrorHint(err error) string {
+	if class := ClassOf(err); class != nil {
+		return class.hint
+	}
+	return ""
+}
+
+type classifiedError struct {
+	class *ErrorClass
+	cause error
+}
+
+func (e *classifiedError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.class.code, e.cause.Error())
+}
+
+// Cause returns the underlying error, so that `errors.Cause` still finds the
+// root cause of a classified error.
+func (e *classifiedError) Cause() error {
+	return e.cause
+}
+
+func (e *classifiedError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%+v\n[%s]", e.cause, e.class.code)
+		return
+	}
+	io.WriteString(s, e.Error())
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"io"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+var _ = Suite(&errorsSuite{})
+
+type errorsSuite struct{}
+
+func (s *errorsSuite) TestClassify(c *C) {
+	c.Assert(ErrInvalidConfig.Wrap(nil), IsNil)
+	c.Assert(ErrInvalidConfig.Annotatef(nil, "nothing"), IsNil)
+	c.Assert(ClassOf(io.EOF), IsNil)
+	c.Assert(ErrorHint(io.EOF), Equals, "")
+
+	err := ErrImporterUnavailable.Annotatef(io.EOF, "[`db`.`t`:0] cannot open engine")
+	c.Assert(err, ErrorMatches, "\\[ImporterUnavailable\\] \\[`db`.`t`:0\\] cannot open engine: EOF")
+
+	// the first class is kept through annotations and further classification.
+	err = ErrImportEngine.Wrap(errors.Annotate(err, "restore table failed"))
+	c.Assert(ClassOf(err), Equals, ErrImporterUnavailable)
+	c.Assert(ErrImporterUnavailable.Equal(err), IsTrue)
+	c.Assert(ErrImportEngine.Equal(err), IsFalse)
+	c.Assert(ErrorHint(err), Equals, ErrImporterUnavailable.Hint())
+	c.Assert(errors.Cause(err), Equals, io.EOF)
+	c.Assert(errors.ErrorStack(err), Matches, `(?s).*\[ImporterUnavailable\].*`)
+}
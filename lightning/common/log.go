@@ -16,9 +16,15 @@ package common
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/pingcap/errors"
@@ -33,13 +39,28 @@ const (
 	defaultLogLevel      = log.InfoLevel
 	defaultLogMaxDays    = 7
 	defaultLogMaxSize    = 512 // MB
+
+	// progressLogLevel is the log level name which shows only the progress of
+	// the tables, besides the warnings and errors.
+	progressLogLevel = "progress"
+)
+
+// The special log file names writing the log to the standard streams instead,
+// without rotation. Suitable for containers where the log driver collects the
+// output.
+const (
+	LogFileStdout      = "-"
+	LogFileStdoutAlias = "stdout"
+	LogFileStderr      = "stderr"
 )
 
 // LogConfig serializes log related config in toml/json.
 type LogConfig struct {
-	// Log level.
+	// Log level. "progress" only shows the progress of the tables besides the
+	// warnings and errors.
 	Level string `toml:"level" json:"level"`
-	// Log filename, leave empty to disable file log.
+	// Log filename, leave empty to disable file log. "-" or "stdout" writes
+	// to stdout, and "stderr" writes to stderr.
 	File string `toml:"file" json:"file"`
 	// Max size for a single file, in MB.
 	FileMaxSize int `toml:"max-size" json:"max-size"`
@@ -47,10 +68,31 @@ type LogConfig struct {
 	FileMaxDays int `toml:"max-days" json:"max-days"`
 	// Maximum number of old log files to retain.
 	FileMaxBackups int `toml:"max-backups" json:"max-backups"`
+	// Compress the rotated log files with gzip.
+	FileCompress bool `toml:"compress" json:"compress"`
+	// Max total size of the log file and the rotated ones, in MB. The oldest
+	// rotated files are deleted beyond it. 0 means no limit.
+	FileMaxTotalSize int `toml:"max-total-size" json:"max-total-size"`
+	// Levels overrides the log level of the packages, keyed by the package
+	// name, e.g. {mydump = "warn", kv = "debug"}.
+	Levels map[string]string `toml:"levels" json:"levels"`
+}
+
+// LogsToFile returns whether the log is written to a rotated file.
+func (cfg *LogConfig) LogsToFile() bool {
+	return len(cfg.File) > 0 && !isStdStreamLogFile(cfg.File)
+}
+
+func isStdStreamLogFile(file string) bool {
+	switch file {
+	case LogFileStdout, LogFileStdoutAlias, LogFileStderr:
+		return true
+	}
+	return false
 }
 
 func (cfg *LogConfig) Adjust() {
-	if len(cfg.File) > 0 {
+	if cfg.LogsToFile() {
 		if cfg.FileMaxSize == 0 {
 			cfg.FileMaxSize = defaultLogMaxSize
 		}
@@ -72,6 +114,8 @@ func stringToLogLevel(level string) log.Level {
 		return log.DebugLevel
 	case "info":
 		return log.InfoLevel
+	case progressLogLevel:
+		return log.WarnLevel
 	}
 	return defaultLogLevel
 }
@@ -79,6 +123,10 @@ func stringToLogLevel(level string) log.Level {
 type SimpleTextFormater struct{}
 
 func (f *SimpleTextFormater) Format(entry *log.Entry) ([]byte, error) {
+	if !levelEnabled(entry) {
+		return nil, nil
+	}
+
 	var b *bytes.Buffer
 	if entry.Buffer != nil {
 		b = entry.Buffer
@@ -95,12 +143,17 @@ func (f *SimpleTextFormater) Format(entry *log.Entry) ([]byte, error) {
 	// level + message
 	fmt.Fprintf(b, " [%s] %s", entry.Level.String(), entry.Message)
 
-	// others
-	for k, v := range entry.Data {
-		if k != "file" && k != "line" {
-			fmt.Fprintf(b, " %v=%v", k, v)
+	// others, sorted so the messages with the same fields look alike.
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		if k != "file" && k != "line" && k != "module" {
+			keys = append(keys, k)
 		}
 	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, " %v=%v", k, entry.Data[k])
+	}
 
 	b.WriteByte('\n')
 
@@ -118,7 +171,9 @@ func (hook *contextHook) Levels() []log.Level {
 // Fire implements logrus.Hook interface
 // https://github.com/sirupsen/logrus/issues/63
 func (hook *contextHook) Fire(entry *log.Entry) error {
-	pc := make([]uintptr, 3)
+	// the logrus frames are skipped below, whose depth differs between
+	// logging through a Logger and through an Entry with pre-bound fields.
+	pc := make([]uintptr, 8)
 	cnt := runtime.Callers(6, pc)
 
 	for i := 0; i < cnt; i++ {
@@ -126,14 +181,33 @@ func (hook *contextHook) Fire(entry *log.Entry) error {
 		name := fu.Name()
 		if !isSkippedPackageName(name) {
 			file, line := fu.FileLine(pc[i] - 1)
-			entry.Data["file"] = path.Base(file)
-			entry.Data["line"] = line
+			// the fields are shared by all messages of a logger with
+			// pre-bound fields, possibly across goroutines, so they are
+			// copied before being modified.
+			data := make(log.Fields, len(entry.Data)+3)
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+			data["file"] = path.Base(file)
+			data["line"] = line
+			data["module"] = packageName(name)
+			entry.Data = data
 			break
 		}
 	}
 	return nil
 }
 
+// packageName returns the name of the package of the function, e.g. "restore"
+// for "github.com/pingcap/tidb-lightning/lightning/restore.(*TableRestore).restoreEngine".
+func packageName(funcName string) string {
+	name := funcName[strings.LastIndexByte(funcName, '/')+1:]
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
 func isSkippedPackageName(name string) bool {
 	return strings.Contains(name, "github.com/sirupsen/logrus") ||
 		strings.Contains(name, "github.com/coreos/pkg/capnslog")
@@ -142,37 +216,184 @@ func isSkippedPackageName(name string) bool {
 // AppLogger is a logger for lightning, different from tidb logger.
 var AppLogger = log.New()
 
+// ProgressLogger logs the progress of the import, i.e. the tables starting and
+// finishing and the periodic summaries. Under the "progress" log level, these
+// are the only info messages shown, to keep the log small for huge imports.
+var ProgressLogger = log.New()
+
+var (
+	// baseLevel is the log level of the packages not in moduleLevels.
+	baseLevel = uint32(defaultLogLevel)
+	// moduleLevels are the log levels overridden by `log.levels`, keyed by
+	// the package name. It is only modified by InitLogger.
+	moduleLevels map[string]log.Level
+)
+
+// SetLevel sets the log level of the packages whose level is not overridden.
+// AppLogger itself lets through the most verbose level of all packages, and
+// the formatter drops the messages above the level of their package.
 func SetLevel(level log.Level) {
+	atomic.StoreUint32(&baseLevel, uint32(level))
+	for _, moduleLevel := range moduleLevels {
+		if moduleLevel > level {
+			level = moduleLevel
+		}
+	}
 	atomic.StoreUint32((*uint32)(&AppLogger.Level), uint32(level))
 }
 
 func GetLevel() (level log.Level) {
-	return log.Level(atomic.LoadUint32((*uint32)(&AppLogger.Level)))
+	return log.Level(atomic.LoadUint32(&baseLevel))
 }
 
-func InitLogger(cfg *LogConfig, tidbLoglevel string) error {
+// levelEnabled returns whether the message of AppLogger is within the level of
+// the package logging it.
+func levelEnabled(entry *log.Entry) bool {
+	if entry.Logger != AppLogger {
+		return true
+	}
+	level := GetLevel()
+	if module, ok := entry.Data["module"].(string); ok {
+		if moduleLevel, ok := moduleLevels[module]; ok {
+			level = moduleLevel
+		}
+	}
+	return entry.Level <= level
+}
+
+// InitLogger initializes the Lightning log, and the log of the TiDB library.
+// The TiDB library logs to stderr unless tidbLogFile is set.
+func InitLogger(cfg *LogConfig, tidbLoglevel string, tidbLogFile string) error {
+	moduleLevels = make(map[string]log.Level, len(cfg.Levels))
+	for module, level := range cfg.Levels {
+		moduleLevels[module] = stringToLogLevel(level)
+	}
 	SetLevel(stringToLogLevel(cfg.Level))
 	AppLogger.Hooks.Add(&contextHook{})
 	AppLogger.Formatter = &SimpleTextFormater{}
+	ProgressLogger.SetLevel(GetLevel())
+	if strings.ToLower(cfg.Level) == progressLogLevel {
+		ProgressLogger.SetLevel(log.InfoLevel)
+	}
+	ProgressLogger.Hooks.Add(&contextHook{})
+	ProgressLogger.Formatter = &SimpleTextFormater{}
 
 	logutil.InitLogger(&logutil.LogConfig{Level: tidbLoglevel})
-
-	if len(cfg.File) > 0 {
-		if IsDirExists(cfg.File) {
-			return errors.Errorf("can't use directory as log file name : %s", cfg.File)
+	if len(tidbLogFile) > 0 {
+		tidbCfg := LogConfig{File: tidbLogFile}
+		tidbCfg.Adjust()
+		output, err := openLogOutput(&tidbCfg)
+		if err != nil {
+			return errors.Trace(err)
 		}
+		log.SetOutput(output)
+	}
 
-		// use lumberjack to logrotate
-		output := &lumberjack.Logger{
-			Filename:   cfg.File,
-			MaxAge:     cfg.FileMaxDays,
-			MaxSize:    cfg.FileMaxSize,
-			MaxBackups: cfg.FileMaxBackups,
-			LocalTime:  true,
+	if len(cfg.File) > 0 {
+		output, err := openLogOutput(cfg)
+		if err != nil {
+			return errors.Trace(err)
 		}
-
 		AppLogger.Out = output
 	}
+	ProgressLogger.Out = AppLogger.Out
 
 	return nil
 }
+
+func openLogOutput(cfg *LogConfig) (io.Writer, error) {
+	switch cfg.File {
+	case LogFileStdout, LogFileStdoutAlias:
+		return os.Stdout, nil
+	case LogFileStderr:
+		return os.Stderr, nil
+	}
+
+	if IsDirExists(cfg.File) {
+		return nil, errors.Errorf("can't use directory as log file name : %s", cfg.File)
+	}
+
+	// use lumberjack to logrotate
+	logger := &lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxAge:     cfg.FileMaxDays,
+		MaxSize:    cfg.FileMaxSize,
+		MaxBackups: cfg.FileMaxBackups,
+		LocalTime:  true,
+		Compress:   cfg.FileCompress,
+	}
+	if cfg.FileMaxTotalSize <= 0 {
+		return logger, nil
+	}
+	capped := &totalSizeCappedLogger{
+		Logger:       logger,
+		maxTotalSize: int64(cfg.FileMaxTotalSize) * megabyte,
+		checkEvery:   int64(cfg.FileMaxSize) * megabyte,
+	}
+	capped.removeExcessFiles()
+	return capped, nil
+}
+
+const megabyte = 1024 * 1024
+
+// totalSizeCappedLogger deletes the oldest rotated log files when the total
+// size of the log files exceeds the limit. lumberjack only limits the count
+// and the age of the rotated files.
+type totalSizeCappedLogger struct {
+	*lumberjack.Logger
+	maxTotalSize int64
+	// checkEvery is the rotation size. The files are checked after writing
+	// this many bytes, since the log is rotated at most once in between.
+	checkEvery int64
+	written    int64
+	cleaning   sync.Mutex
+}
+
+func (l *totalSizeCappedLogger) Write(p []byte) (int, error) {
+	n, err := l.Logger.Write(p)
+	if atomic.AddInt64(&l.written, int64(n)) >= l.checkEvery {
+		atomic.StoreInt64(&l.written, 0)
+		// the rotated file may still be being compressed, so the total size
+		// is only approximately capped.
+		go l.removeExcessFiles()
+	}
+	return n, err
+}
+
+func (l *totalSizeCappedLogger) removeExcessFiles() {
+	l.cleaning.Lock()
+	defer l.cleaning.Unlock()
+
+	dir := filepath.Dir(l.Filename)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	// the rotated files are named "{name}-{timestamp}{ext}", optionally with
+	// the ".gz" suffix, and the timestamps sort chronologically.
+	base := filepath.Base(l.Filename)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	var totalSize int64
+	var backups []os.FileInfo
+	for _, file := range files {
+		name := file.Name()
+		switch {
+		case name == base:
+			totalSize += file.Size()
+		case strings.HasPrefix(name, prefix) && (strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz")):
+			totalSize += file.Size()
+			backups = append(backups, file)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name() < backups[j].Name() })
+	for _, file := range backups {
+		if totalSize <= l.maxTotalSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err == nil {
+			totalSize -= file.Size()
+		}
+	}
+}
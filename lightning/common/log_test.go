@@ -0,0 +1,140 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/pingcap/check"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+var _ = Suite(&logSuite{})
+
+type logSuite struct{}
+
+func (s *logSuite) TestLogsToFile(c *C) {
+	c.Assert((&common.LogConfig{}).LogsToFile(), IsFalse)
+	c.Assert((&common.LogConfig{File: "-"}).LogsToFile(), IsFalse)
+	c.Assert((&common.LogConfig{File: "stdout"}).LogsToFile(), IsFalse)
+	c.Assert((&common.LogConfig{File: "stderr"}).LogsToFile(), IsFalse)
+	c.Assert((&common.LogConfig{File: "tidb-lightning.log"}).LogsToFile(), IsTrue)
+
+	cfg := common.LogConfig{File: "-"}
+	cfg.Adjust()
+	c.Assert(cfg.FileMaxSize, Equals, 0)
+	cfg = common.LogConfig{File: "tidb-lightning.log"}
+	cfg.Adjust()
+	c.Assert(cfg.FileMaxSize, Equals, 512)
+}
+
+func (s *logSuite) TestProgressLevel(c *C) {
+	err := common.InitLogger(&common.LogConfig{Level: "progress"}, "error", "")
+	c.Assert(err, IsNil)
+	c.Assert(common.GetLevel(), Equals, log.WarnLevel)
+	c.Assert(common.ProgressLogger.Level, Equals, log.InfoLevel)
+
+	err = common.InitLogger(&common.LogConfig{Level: "error"}, "error", "")
+	c.Assert(err, IsNil)
+	c.Assert(common.GetLevel(), Equals, log.ErrorLevel)
+	c.Assert(common.ProgressLogger.Level, Equals, log.ErrorLevel)
+
+	err = common.InitLogger(&common.LogConfig{Level: "info"}, "error", "")
+	c.Assert(err, IsNil)
+}
+
+func (s *logSuite) TestMaxTotalSize(c *C) {
+	dir := c.MkDir()
+	backups := []string{
+		"lightning-2019-01-01T00-00-00.000.log.gz",
+		"lightning-2019-01-02T00-00-00.000.log.gz",
+		"lightning-2019-01-03T00-00-00.000.log",
+	}
+	for _, name := range append(backups, "lightning.log", "other.log") {
+		err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, 400*1024), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	cfg := &common.LogConfig{Level: "info", File: filepath.Join(dir, "lightning.log"), FileMaxTotalSize: 1}
+	cfg.Adjust()
+	c.Assert(common.InitLogger(cfg, "error", ""), IsNil)
+	defer func() {
+		common.AppLogger.Out = os.Stderr
+		common.ProgressLogger.Out = os.Stderr
+	}()
+
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+	// the oldest rotated files are removed until the log files fit in 1 MB,
+	// while the unrelated files are kept.
+	c.Assert(names, DeepEquals, []string{
+		"lightning-2019-01-03T00-00-00.000.log",
+		"lightning.log",
+		"other.log",
+	})
+}
+
+func (s *logSuite) TestFormatSortedFields(c *C) {
+	entry := log.NewEntry(log.New()).WithFields(log.Fields{
+		"table":  "`db`.`t`",
+		"engine": 0,
+		"path":   "db.t.sql",
+		"offset": 0,
+	})
+	entry.Level = log.InfoLevel
+	entry.Message = "restore chunk"
+	line, err := (&common.SimpleTextFormater{}).Format(entry)
+	c.Assert(err, IsNil)
+	c.Assert(string(line), Matches, `.* \[info\] restore chunk engine=0 offset=0 path=db.t.sql table=`+"`db`.`t`"+`\n`)
+}
+
+func (s *logSuite) TestModuleLevels(c *C) {
+	cfg := &common.LogConfig{Level: "warn", Levels: map[string]string{"common_test": "debug", "mydump": "error"}}
+	c.Assert(common.InitLogger(cfg, "error", ""), IsNil)
+	var buffer bytes.Buffer
+	common.AppLogger.Out = &buffer
+	defer func() {
+		common.AppLogger.Out = os.Stderr
+		c.Assert(common.InitLogger(&common.LogConfig{Level: "info"}, "error", ""), IsNil)
+	}()
+
+	// the base level is kept for the other packages.
+	c.Assert(common.GetLevel(), Equals, log.WarnLevel)
+	c.Assert(common.AppLogger.Level, Equals, log.DebugLevel)
+
+	common.AppLogger.Debugf("debug message")
+	c.Assert(buffer.String(), Matches, `(?s).*\[debug\] debug message\n`)
+
+	// the messages above the level of their package are dropped.
+	entry := common.AppLogger.WithField("module", "mydump")
+	entry.Level = log.WarnLevel
+	line, err := common.AppLogger.Formatter.Format(entry)
+	c.Assert(err, IsNil)
+	c.Assert(line, HasLen, 0)
+	entry.Level = log.ErrorLevel
+	line, err = common.AppLogger.Formatter.Format(entry)
+	c.Assert(err, IsNil)
+	c.Assert(string(line), Matches, `.*\[error\] \n`)
+}
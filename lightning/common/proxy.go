@@ -0,0 +1,287 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"google.golang.org/grpc"
+)
+
+var (
+	// proxyURL overrides the proxy from the environment variables if not nil.
+	proxyURL *url.URL
+	noProxy  []string
+	// proxyGRPC is whether the gRPC connections go through the proxy.
+	proxyGRPC bool
+)
+
+// SetProxy configures the proxy of the outbound connections. If proxyAddr is
+// empty, the proxy is taken from the environment variables HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY. The proxy may be an HTTP proxy supporting the
+// CONNECT method ("http://host:port") or a SOCKS5 proxy ("socks5://host:port").
+func SetProxy(proxyAddr string, noProxyHosts string, grpc bool) error {
+	proxyURL = nil
+	noProxy = nil
+	proxyGRPC = grpc
+
+	if len(proxyAddr) == 0 {
+		return nil
+	}
+	u, err := url.Parse(proxyAddr)
+	if err != nil {
+		return errors.Annotate(err, "invalid proxy")
+	}
+	switch u.Scheme {
+	case "http", "socks5":
+	default:
+		return errors.Errorf("unsupported proxy scheme %q, must be \"http\" or \"socks5\"", u.Scheme)
+	}
+	proxyURL = u
+	for _, host := range strings.Split(noProxyHosts, ",") {
+		if host = strings.TrimSpace(host); len(host) > 0 {
+			noProxy = append(noProxy, strings.ToLower(host))
+		}
+	}
+	return nil
+}
+
+// proxyFor returns the proxy to connect to addr, or nil to connect directly.
+func proxyFor(scheme string, addr string) (*url.URL, error) {
+	if proxyURL == nil {
+		return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: scheme, Host: addr}})
+	}
+
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range noProxy {
+		if pattern == "*" || host == pattern || strings.HasSuffix(host, "."+strings.TrimPrefix(pattern, ".")) {
+			return nil, nil
+		}
+	}
+	return proxyURL, nil
+}
+
+var httpTransport = &http.Transport{
+	Proxy: func(req *http.Request) (*url.URL, error) {
+		return proxyFor(req.URL.Scheme, req.URL.Host)
+	},
+	DialContext: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:          100,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
+}
+
+// NewHTTPClient creates an HTTP client using the configured proxy.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: httpTransport, Timeout: timeout}
+}
+
+// GRPCDialOption returns the option to connect through the proxy if the
+// proxy is enabled for gRPC, or to connect directly otherwise.
+func GRPCDialOption() grpc.DialOption {
+	return grpc.WithDialer(dialGRPC)
+}
+
+func dialGRPC(addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	var proxy *url.URL
+	if proxyGRPC {
+		var err error
+		if proxy, err = proxyFor("https", addr); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if proxy == nil {
+		return dialer.Dial("tcp", addr)
+	}
+
+	proxyAddr := proxy.Host
+	if len(proxy.Port()) == 0 {
+		if proxy.Scheme == "socks5" {
+			proxyAddr = net.JoinHostPort(proxy.Hostname(), "1080")
+		} else {
+			proxyAddr = net.JoinHostPort(proxy.Hostname(), "80")
+		}
+	}
+	conn, err := dialer.Dial("tcp", proxyAddr)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot connect to proxy %s", proxyAddr)
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if proxy.Scheme == "socks5" {
+		err = socks5Connect(conn, proxy, addr)
+	} else {
+		conn, err = httpConnect(conn, proxy, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.Annotatef(err, "cannot connect to %s through proxy %s", addr, proxyAddr)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes have been read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// httpConnect opens a tunnel to addr through an HTTP proxy with the CONNECT
+// method.
+func httpConnect(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, errors.Trace(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return conn, errors.Trace(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return conn, errors.Errorf("proxy responded %s", resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// socks5Connect opens a tunnel to addr through a SOCKS5 proxy (RFC 1928),
+// with username/password authentication (RFC 1929) if the proxy URL contains
+// the user info.
+func socks5Connect(conn net.Conn, proxy *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return errors.Annotatef(err, "invalid port in %s", addr)
+	}
+
+	// 1. negotiate the authentication method
+	greeting := []byte{5, 1, 0}
+	if proxy.User != nil {
+		greeting = []byte{5, 2, 0, 2}
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return errors.Trace(err)
+	}
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:2]); err != nil {
+		return errors.Trace(err)
+	}
+	switch {
+	case reply[0] != 5:
+		return errors.New("not a SOCKS5 proxy")
+	case reply[1] == 0:
+	case reply[1] == 2 && proxy.User != nil:
+		username := proxy.User.Username()
+		password, _ := proxy.User.Password()
+		auth := []byte{1, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := io.ReadFull(conn, reply[:2]); err != nil {
+			return errors.Trace(err)
+		}
+		if reply[1] != 0 {
+			return errors.New("SOCKS5 authentication failed")
+		}
+	default:
+		return errors.Errorf("unsupported SOCKS5 authentication method %d", reply[1])
+	}
+
+	// 2. request to connect
+	request := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		request = append(request, 3, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, 1)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, 4)
+		request = append(request, ip.To16()...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return errors.Trace(err)
+	}
+
+	// 3. read the reply, and skip the bound address
+	if _, err := io.ReadFull(conn, reply[:4]); err != nil {
+		return errors.Trace(err)
+	}
+	if reply[1] != 0 {
+		return errors.Errorf("SOCKS5 proxy failed to connect, reply code %d", reply[1])
+	}
+	var boundAddrLen int
+	switch reply[3] {
+	case 1:
+		boundAddrLen = net.IPv4len
+	case 4:
+		boundAddrLen = net.IPv6len
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return errors.Trace(err)
+		}
+		boundAddrLen = int(n[0])
+	default:
+		return errors.Errorf("invalid SOCKS5 address type %d", reply[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, boundAddrLen+2))
+	return errors.Trace(err)
+}
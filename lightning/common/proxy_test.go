@@ -0,0 +1,124 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&proxySuite{})
+
+type proxySuite struct{}
+
+func (s *proxySuite) TearDownTest(c *C) {
+	SetProxy("", "", false)
+}
+
+func (s *proxySuite) TestProxyFor(c *C) {
+	c.Assert(SetProxy("socks5://10.0.0.1:1080", "pd.internal, .example.com", true), IsNil)
+
+	// a leading dot only matches the subdomains, like NO_PROXY.
+	for _, addr := range []string{"10.0.1.1:2379", "example.com:8287"} {
+		proxy, err := proxyFor("http", addr)
+		c.Assert(err, IsNil)
+		c.Assert(proxy.String(), Equals, "socks5://10.0.0.1:1080", Commentf("addr = %s", addr))
+	}
+	for _, addr := range []string{"pd.internal:2379", "tikv.pd.internal:20160", "importer.example.com:8287"} {
+		proxy, err := proxyFor("http", addr)
+		c.Assert(err, IsNil)
+		c.Assert(proxy, IsNil, Commentf("addr = %s", addr))
+	}
+
+	c.Assert(SetProxy("ftp://10.0.0.1", "", false), ErrorMatches, `unsupported proxy scheme "ftp".*`)
+}
+
+// serveProxy runs the handshake of a fake proxy on a single connection, and
+// then echoes the data.
+func serveProxy(c *C, handshake func(conn net.Conn, reader *bufio.Reader)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		handshake(conn, reader)
+		io.Copy(conn, reader)
+	}()
+	return listener.Addr().String()
+}
+
+func assertEcho(c *C, conn net.Conn) {
+	_, err := conn.Write([]byte("ping"))
+	c.Assert(err, IsNil)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "ping")
+}
+
+func (s *proxySuite) TestHTTPConnect(c *C) {
+	proxyAddr := serveProxy(c, func(conn net.Conn, reader *bufio.Reader) {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect || req.Host != "importer:8287" || req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			conn.Write([]byte("HTTP/1.1 403 Forbidden\r\n\r\n"))
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	})
+
+	c.Assert(SetProxy("http://user:pass@"+proxyAddr, "", true), IsNil)
+	conn, err := dialGRPC("importer:8287", time.Second)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	assertEcho(c, conn)
+}
+
+func (s *proxySuite) TestSOCKS5Connect(c *C) {
+	proxyAddr := serveProxy(c, func(conn net.Conn, reader *bufio.Reader) {
+		buf := make([]byte, 4)
+		// greeting: no auth
+		if _, err := io.ReadFull(reader, buf[:3]); err != nil {
+			return
+		}
+		conn.Write([]byte{5, 0})
+		// request: connect to a domain name
+		if _, err := io.ReadFull(reader, buf[:4]); err != nil || buf[3] != 3 {
+			return
+		}
+		n, _ := reader.ReadByte()
+		if _, err := io.ReadFull(reader, make([]byte, int(n)+2)); err != nil {
+			return
+		}
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 80})
+	})
+
+	c.Assert(SetProxy("socks5://"+proxyAddr, "", true), IsNil)
+	conn, err := dialGRPC("importer:8287", time.Second)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	assertEcho(c, conn)
+}
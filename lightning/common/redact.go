@@ -0,0 +1,47 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RedactInfoLog indicates whether the row data should be removed from the
+// logs and error messages. It is set from `security.redact-info-log`.
+var RedactInfoLog bool
+
+// quotedValueRegexp matches the quoted literals in an error message. Column
+// names are matched together with the preceding "column " so they are kept.
+var quotedValueRegexp = regexp.MustCompile(`(?i:column\s+)?'(?:[^'\\]|\\.|'')*'`)
+
+// RedactValues replaces the quoted values in the message by "?" if
+// RedactInfoLog is enabled, e.g.
+//
+//	Incorrect datetime value: '2019-13-01' for column 'c' at row 2
+//
+// becomes
+//
+//	Incorrect datetime value: ? for column 'c' at row 2
+func RedactValues(msg string) string {
+	if !RedactInfoLog {
+		return msg
+	}
+	return quotedValueRegexp.ReplaceAllStringFunc(msg, func(s string) string {
+		if strings.HasPrefix(s, "'") {
+			return "?"
+		}
+		return s
+	})
+}
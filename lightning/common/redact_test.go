@@ -0,0 +1,37 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common_test
+
+import (
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+var _ = Suite(&redactSuite{})
+
+type redactSuite struct{}
+
+func (s *redactSuite) TestRedactValues(c *C) {
+	msg := `Incorrect datetime value: '2019-13-01' for column 'c' at row 2`
+
+	common.RedactInfoLog = false
+	c.Assert(common.RedactValues(msg), Equals, msg)
+
+	common.RedactInfoLog = true
+	defer func() { common.RedactInfoLog = false }()
+	c.Assert(common.RedactValues(msg), Equals, `Incorrect datetime value: ? for column 'c' at row 2`)
+	c.Assert(common.RedactValues(`Duplicate entry 'a\'b''c' for key 'PRIMARY'`), Equals, `Duplicate entry ? for key ?`)
+	c.Assert(common.RedactValues(`Data too long for column 'name' at row 1`), Equals, `Data too long for column 'name' at row 1`)
+}
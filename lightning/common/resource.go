@@ -0,0 +1,89 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ResourceUsage is a snapshot of the resources used by this process.
+type ResourceUsage struct {
+	Time       time.Time
+	RSS        uint64 // resident set size in bytes, or the peak RSS if the current one is unavailable.
+	HeapInuse  uint64
+	Goroutines int
+	CPUTime    time.Duration // user + system time
+}
+
+// ReadResourceUsage takes a snapshot of the resources used by this process.
+func ReadResourceUsage() ResourceUsage {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	usage := ResourceUsage{
+		Time:       time.Now(),
+		HeapInuse:  memStats.HeapInuse,
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	var rusage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &rusage); err == nil {
+		usage.CPUTime = time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano())
+		usage.RSS = uint64(rusage.Maxrss) * 1024 // in KiB on Linux
+	}
+	if rss, ok := readCurrentRSS(); ok {
+		usage.RSS = rss
+	}
+	return usage
+}
+
+// readCurrentRSS reads the current RSS from procfs, only available on Linux.
+func readCurrentRSS() (uint64, bool) {
+	content, err := ioutil.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return pages * uint64(os.Getpagesize()), true
+}
+
+// CPUPercent returns the CPU usage between the two snapshots, where 100%
+// means a fully occupied core.
+func (usage *ResourceUsage) CPUPercent(prev *ResourceUsage) float64 {
+	wallTime := usage.Time.Sub(prev.Time)
+	if wallTime <= 0 {
+		return 0
+	}
+	return float64(usage.CPUTime-prev.CPUTime) / float64(wallTime) * 100
+}
+
+// String formats the snapshot for the progress log.
+func (usage *ResourceUsage) String() string {
+	return fmt.Sprintf("rss %.1f MiB, heap-inuse %.1f MiB, goroutines %d",
+		float64(usage.RSS)/1048576, float64(usage.HeapInuse)/1048576, usage.Goroutines)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+)
+
+// RegisterMySQLTLSConfig loads the CA certificate, and optionally the client
+// certificate and key, into a TLS config of the MySQL driver, which a DSN
+// selects by the parameter `tls=name`. The server certificate is verified
+// against the CA and the server name.
+func RegisterMySQLTLSConfig(name string, caPath string, certPath string, keyPath string, serverName string) error {
+	caPEM, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return errors.Annotate(err, "cannot read the CA certificate")
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPEM) {
+		return errors.Errorf("no certificate found in %s", caPath)
+	}
+
+	config := &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: serverName,
+	}
+	if len(certPath) > 0 || len(keyPath) > 0 {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return errors.Annotate(err, "cannot load the client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return errors.Trace(mysql.RegisterTLSConfig(name, config))
+}
+
+// RegisterMySQLServerPubKey loads the PEM-encoded RSA public key of the server
+// into the MySQL driver, which a DSN selects by the parameter
+// `serverPubKey=name`. With it the password of a sha256_password or
+// caching_sha2_password account is sent encrypted without TLS.
+func RegisterMySQLServerPubKey(name string, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Annotate(err, "cannot read the server public key")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return errors.Errorf("no public key found in %s", path)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return errors.Annotatef(err, "invalid public key in %s", path)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return errors.Errorf("the public key in %s is not an RSA key", path)
+	}
+	mysql.RegisterServerPubKey(name, rsaPub)
+	return nil
+}
@@ -14,6 +14,7 @@
 package common
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -39,16 +40,50 @@ const (
 	defaultMaxRetry = 3
 )
 
+// SlowSQLThreshold is the duration beyond which a statement executed through
+// QueryRowWithRetry, TransactWithRetry or ExecWithRetry is logged as slow, or
+// 0 to log nothing. It is set from `tidb.slow-sql-threshold`.
+var SlowSQLThreshold time.Duration
+
+// watchSlowSQL logs the statement if it is still running after
+// SlowSQLThreshold, e.g. a DDL waiting for the schema lock, so a hang can be
+// told apart from a hang in Lightning itself. The returned function must be
+// called once the statement completes, which logs the duration and the number
+// of retries of a slow statement.
+func watchSlowSQL(statement string) func(retries int, err error) {
+	threshold := SlowSQLThreshold
+	if threshold <= 0 {
+		return func(int, error) {}
+	}
+	statement = RedactValues(strings.Join(strings.Fields(statement), " "))
+	start := time.Now()
+	timer := time.AfterFunc(threshold, func() {
+		AppLogger.Warnf("[slow-sql] still running after %v: %s", threshold, statement)
+	})
+	return func(retries int, err error) {
+		timer.Stop()
+		if dur := time.Since(start); dur >= threshold {
+			AppLogger.Warnf("[slow-sql] takes %v (retries: %d, error: %v): %s", dur, retries, err, statement)
+		}
+	}
+}
+
 func Percent(a int, b int) string {
 	return fmt.Sprintf("%.2f %%", float64(a)/float64(b)*100)
 }
 
-func ToDSN(host string, port int, user string, psw string) string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8", user, psw, host, port)
+// ToDSN returns the DSN connecting to the MySQL server. The extra parameters
+// in the form "key=value" are appended to the DSN.
+func ToDSN(host string, port int, user string, psw string, params ...string) string {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/?charset=utf8", user, psw, JoinHostPort(host, port))
+	for _, param := range params {
+		dsn += "&" + param
+	}
+	return dsn
 }
 
-func ConnectDB(host string, port int, user string, psw string) (*sql.DB, error) {
-	dbDSN := ToDSN(host, port, user, psw)
+func ConnectDB(host string, port int, user string, psw string, params ...string) (*sql.DB, error) {
+	dbDSN := ToDSN(host, port, user, psw, params...)
 	db, err := sql.Open("mysql", dbDSN)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -68,7 +103,10 @@ func IsDirExists(name string) bool {
 
 func QueryRowWithRetry(ctx context.Context, db *sql.DB, query string, dest ...interface{}) (err error) {
 	maxRetry := defaultMaxRetry
-	for i := 0; i < maxRetry; i++ {
+	i := 0
+	done := watchSlowSQL(query)
+	defer func() { done(i, err) }()
+	for ; i < maxRetry; i++ {
 		if i > 0 {
 			AppLogger.Warnf("query %s retry %d", query, i)
 			time.Sleep(retryTimeout)
@@ -92,10 +130,16 @@ func QueryRowWithRetry(ctx context.Context, db *sql.DB, query string, dest ...in
 // TransactWithRetry executes an action in a transaction, and retry if the
 // action failed with a retryable error.
 func TransactWithRetry(ctx context.Context, db *sql.DB, purpose string, action func(context.Context, *sql.Tx) error) error {
-	maxRetry := defaultMaxRetry
+	return transactWithRetry(ctx, db, purpose, purpose, action)
+}
 
-	var err error
-	for i := 0; i < maxRetry; i++ {
+// transactWithRetry is TransactWithRetry logging the statement if it is slow.
+func transactWithRetry(ctx context.Context, db *sql.DB, purpose string, statement string, action func(context.Context, *sql.Tx) error) (err error) {
+	maxRetry := defaultMaxRetry
+	i := 0
+	done := watchSlowSQL(statement)
+	defer func() { done(i, err) }()
+	for ; i < maxRetry; i++ {
 		if i > 0 {
 			AppLogger.Warnf("transaction %s retry %d", purpose, i)
 			time.Sleep(retryTimeout)
@@ -146,7 +190,7 @@ func transactImpl(ctx context.Context, db *sql.DB, purpose string, action func(c
 
 // ExecWithRetry executes a single SQL with optional retry.
 func ExecWithRetry(ctx context.Context, db *sql.DB, purpose string, query string, args ...interface{}) error {
-	return errors.Trace(TransactWithRetry(ctx, db, purpose, func(c context.Context, tx *sql.Tx) error {
+	return errors.Trace(transactWithRetry(ctx, db, purpose, query, func(c context.Context, tx *sql.Tx) error {
 		_, err := tx.ExecContext(c, query, args...)
 		return errors.Trace(err)
 	}))
@@ -184,6 +228,13 @@ func IsRetryableError(err error) bool {
 	}
 }
 
+// IsUnavailableError returns whether the error is caused by the remote gRPC
+// server being unavailable, e.g. tikv-importer is restarting. This function
+// returns `false` if `err == nil`.
+func IsUnavailableError(err error) bool {
+	return status.Code(errors.Cause(err)) == codes.Unavailable
+}
+
 // IsContextCanceledError returns whether the error is caused by context
 // cancellation. This function returns `false` (not a context-canceled error) if
 // `err == nil`.
@@ -249,3 +300,26 @@ func GetJSON(client *http.Client, url string, v interface{}) error {
 
 	return errors.Trace(json.NewDecoder(resp.Body).Decode(v))
 }
+
+// PostJSON sends `v` encoded as JSON to the URL, and expects the status code
+// 200 in the response.
+func PostJSON(client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Errorf("post %s http status code != 200, message %s", url, string(body))
+	}
+	return nil
+}
@@ -18,10 +18,13 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"regexp"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/go-sql-driver/mysql"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-tools/pkg/filter"
@@ -43,6 +46,24 @@ type DBStore struct {
 	PdAddr     string `toml:"pd-addr" json:"pd-addr"`
 	SQLMode    string `toml:"sql-mode" json:"sql-mode"`
 	LogLevel   string `toml:"log-level" json:"log-level"`
+	// PauseSchedulers pauses the balance schedulers and the region merge of
+	// PD while the tables are being restored.
+	PauseSchedulers bool `toml:"pause-schedulers" json:"pause-schedulers"`
+	// LogFile is where the TiDB library writes its log, separated from the
+	// Lightning log. Leave empty to log to stderr without any file.
+	LogFile string `toml:"log-file" json:"log-file"`
+	// SlowSQLThreshold logs the statements running longer than it, e.g.
+	// DDLs waiting for the schema lock. 0 disables the logging.
+	SlowSQLThreshold Duration `toml:"slow-sql-threshold" json:"slow-sql-threshold"`
+	// TLS is how the connections to TiDB are secured, see the TLSXxx
+	// constants.
+	TLS string `toml:"tls" json:"tls"`
+	// ServerPublicKey is the PEM file of the RSA public key of TiDB, to log
+	// in as a sha256_password or caching_sha2_password account without TLS.
+	ServerPublicKey string `toml:"server-public-key" json:"server-public-key"`
+	// AllowCleartextPasswords enables the mysql_clear_password plugin used by
+	// e.g. LDAP and PAM authentication, which sends the password as is.
+	AllowCleartextPasswords bool `toml:"allow-cleartext-passwords" json:"allow-cleartext-passwords"`
 
 	DistSQLScanConcurrency     int `toml:"distsql-scan-concurrency" json:"distsql-scan-concurrency"`
 	BuildStatsConcurrency      int `toml:"build-stats-concurrency" json:"build-stats-concurrency"`
@@ -50,6 +71,42 @@ type DBStore struct {
 	ChecksumTableConcurrency   int `toml:"checksum-table-concurrency" json:"checksum-table-concurrency"`
 }
 
+const (
+	// TLSNone connects to TiDB without TLS.
+	TLSNone = "false"
+	// TLSSkipVerify connects to TiDB with TLS, without verifying the server
+	// certificate.
+	TLSSkipVerify = "skip-verify"
+	// TLSCluster connects to TiDB with TLS, verifying the server certificate
+	// against security.ca-path, and presenting the client certificate
+	// security.cert-path if set, e.g. for accounts REQUIRE X509.
+	TLSCluster = "cluster"
+
+	// the names of the TLS config and the server public key registered into
+	// the MySQL driver.
+	tidbTLSConfigName    = "lightning-tidb"
+	tidbServerPubKeyName = "lightning-tidb"
+)
+
+// DSNParams returns the extra DSN parameters connecting to TiDB.
+func (cfg *DBStore) DSNParams() []string {
+	var params []string
+	switch cfg.TLS {
+	case "", TLSNone:
+	case TLSCluster:
+		params = append(params, "tls="+tidbTLSConfigName)
+	default:
+		params = append(params, "tls="+cfg.TLS)
+	}
+	if len(cfg.ServerPublicKey) > 0 {
+		params = append(params, "serverPubKey="+tidbServerPubKeyName)
+	}
+	if cfg.AllowCleartextPasswords {
+		params = append(params, "allowCleartextPasswords=true")
+	}
+	return params
+}
+
 type Config struct {
 	*flag.FlagSet `json:"-"`
 
@@ -59,17 +116,29 @@ type Config struct {
 	// not implemented yet.
 	// ProgressStore DBStore `toml:"progress-store" json:"progress-store"`
 	Checkpoint   Checkpoint      `toml:"checkpoint" json:"checkpoint"`
+	History      History         `toml:"history" json:"history"`
 	Mydumper     MydumperRuntime `toml:"mydumper" json:"mydumper"`
 	BWList       *filter.Rules   `toml:"black-white-list" json:"black-white-list"`
 	TikvImporter TikvImporter    `toml:"tikv-importer" json:"tikv-importer"`
 	PostRestore  PostRestore     `toml:"post-restore" json:"post-restore"`
 	Cron         Cron            `toml:"cron" json:"cron"`
+	Notify       Notify          `toml:"notify" json:"notify"`
+	Security     Security        `toml:"security" json:"security"`
+	Watchdog     Watchdog        `toml:"watchdog" json:"watchdog"`
+	SLO          SLO             `toml:"slo" json:"slo"`
+	Proxy        Proxy           `toml:"proxy" json:"proxy"`
+	Offline      Offline         `toml:"offline" json:"offline"`
+
+	TableDependencies []*TableDependency `toml:"table-dependency" json:"table-dependency"`
 
 	// command line flags
 	ConfigFile   string `json:"config-file"`
 	DoCompact    bool   `json:"-"`
 	SwitchMode   string `json:"-"`
 	printVersion bool
+	// KeepEnginesOnFailure keeps the engines in the importer when importing
+	// them fails, so that the data can be inspected before retrying.
+	KeepEnginesOnFailure bool `json:"keep-engines-on-failure"`
 }
 
 func (c *Config) String() string {
@@ -85,8 +154,25 @@ type Lightning struct {
 	TableConcurrency  int  `toml:"table-concurrency" json:"table-concurrency"`
 	RegionConcurrency int  `toml:"region-concurrency" json:"region-concurrency"`
 	IOConcurrency     int  `toml:"io-concurrency" json:"io-concurrency"`
+	SchemaConcurrency int  `toml:"schema-concurrency" json:"schema-concurrency"`
+	ImportConcurrency int  `toml:"import-concurrency" json:"import-concurrency"`
+	NUMAAware         bool `toml:"numa-aware" json:"numa-aware"`
 	ProfilePort       int  `toml:"pprof-port" json:"pprof-port"`
 	CheckRequirements bool `toml:"check-requirements" json:"check-requirements"`
+	ProgressUI        bool `toml:"progress-ui" json:"progress-ui"`
+	// ControlAddr is the address of the gRPC control server. If set, Lightning
+	// runs as a server importing the tasks submitted through the control API,
+	// instead of the task in this config.
+	ControlAddr string `toml:"control-addr" json:"control-addr"`
+	// TableRetry is the number of times a table failing at the import or
+	// checksum phase is imported again from scratch.
+	TableRetry int `toml:"table-retry" json:"table-retry"`
+	// ChunkRetry is the number of times a chunk failing to be encoded or
+	// delivered is restored again from its checkpoint.
+	ChunkRetry int `toml:"chunk-retry" json:"chunk-retry"`
+	// QuarantineChunks skips the chunks still failing after ChunkRetry
+	// retries, instead of failing the whole table.
+	QuarantineChunks bool `toml:"quarantine-chunks" json:"quarantine-chunks"`
 }
 
 // PostRestore has some options which will be executed after kv restored.
@@ -94,19 +180,330 @@ type PostRestore struct {
 	Compact  bool `toml:"compact" json:"compact"`
 	Checksum bool `toml:"checksum" json:"checksum"`
 	Analyze  bool `toml:"analyze" json:"analyze"`
+	// DeferIndex creates the tables without the secondary indexes, and adds
+	// them after the data are imported.
+	DeferIndex bool `toml:"defer-index" json:"defer-index"`
+	// IndexConcurrency is the maximum number of ADD INDEX statements executed
+	// at the same time.
+	IndexConcurrency int `toml:"index-concurrency" json:"index-concurrency"`
+	// ChecksumConcurrency is the maximum number of ADMIN CHECKSUM TABLE
+	// statements executed at the same time.
+	ChecksumConcurrency int `toml:"checksum-concurrency" json:"checksum-concurrency"`
+	// ChecksumMaxReadFlow delays starting a checksum while any TiKV store
+	// reads more bytes per second than this. Zero disables the check.
+	ChecksumMaxReadFlow int64 `toml:"checksum-max-read-flow" json:"checksum-max-read-flow"`
+	// ChecksumMethod is how the checksum of the imported tables is computed,
+	// "admin" or "coprocessor".
+	ChecksumMethod string `toml:"checksum-method" json:"checksum-method"`
+	// ValidateRows is the number of random rows of each table read again
+	// from the data source and compared with TiDB by the primary key after
+	// the checksum. Zero disables the validation.
+	ValidateRows int `toml:"validate-rows" json:"validate-rows"`
+	// TiFlashReplica is how to handle the target tables having TiFlash replicas.
+	TiFlashReplica string `toml:"tiflash-replica" json:"tiflash-replica"`
+	// Privileges is how to restore the privilege tables in the mysql schema
+	// of the dump.
+	Privileges string `toml:"privileges" json:"privileges"`
+	// PrivilegeConflict is how to handle the accounts in the dump which
+	// already exist in the target.
+	PrivilegeConflict string `toml:"privilege-conflict" json:"privilege-conflict"`
 }
 
+const (
+	// ChecksumAdmin computes the checksums by ADMIN CHECKSUM TABLE.
+	ChecksumAdmin = "admin"
+	// ChecksumCoprocessor computes the checksums by sending the checksum
+	// coprocessor requests to TiKV directly.
+	ChecksumCoprocessor = "coprocessor"
+)
+
+const (
+	// TiFlashReplicaIgnore imports into tables with TiFlash replicas as usual.
+	TiFlashReplicaIgnore = "ignore"
+	// TiFlashReplicaWait waits until the TiFlash replicas have caught up after
+	// importing a table.
+	TiFlashReplicaWait = "wait"
+	// TiFlashReplicaReset removes the TiFlash replicas of a table before the
+	// import, and adds them back afterwards.
+	TiFlashReplicaReset = "reset"
+)
+
+const (
+	// PrivilegesSkip ignores the privilege tables in the dump.
+	PrivilegesSkip = "skip"
+	// PrivilegesDryRun logs the accounts which would be added or replaced,
+	// without changing the target.
+	PrivilegesDryRun = "dry-run"
+	// PrivilegesMerge merges the accounts and their privileges into the
+	// target.
+	PrivilegesMerge = "merge"
+
+	// PrivilegeConflictKeep keeps the existing accounts untouched.
+	PrivilegeConflictKeep = "keep"
+	// PrivilegeConflictReplace replaces the existing accounts and all their
+	// privileges by those in the dump.
+	PrivilegeConflictReplace = "replace"
+)
+
 type MydumperRuntime struct {
 	ReadBlockSize    int64   `toml:"read-block-size" json:"read-block-size"`
 	BatchSize        int64   `toml:"batch-size" json:"batch-size"`
 	BatchImportRatio float64 `toml:"batch-import-ratio" json:"batch-import-ratio"`
 	SourceDir        string  `toml:"data-source-dir" json:"data-source-dir"`
-	NoSchema         bool    `toml:"no-schema" json:"no-schema"`
-	CharacterSet     string  `toml:"character-set" json:"character-set"`
+	// SplitDir is where a single mysqldump file given as the data source is
+	// split into, defaulting to the path of the file with a ".split" suffix.
+	SplitDir     string `toml:"split-dir" json:"split-dir"`
+	NoSchema     bool   `toml:"no-schema" json:"no-schema"`
+	CharacterSet string `toml:"character-set" json:"character-set"`
+	// ReadAhead is the number of bytes of each data file read ahead in the
+	// background while encoding. Zero disables reading ahead.
+	ReadAhead int64 `toml:"read-ahead" json:"read-ahead"`
+	// SampleRows is the number of rows of each table encoded before the
+	// import to estimate the size of the KV pairs. Zero disables sampling.
+	SampleRows int `toml:"sample-rows" json:"sample-rows"`
+	// OnDuplicate is how rows with duplicated unique keys in the same batch
+	// are encoded, "error", "replace" or "ignore". The REPLACE and INSERT
+	// IGNORE statements in the SQL dumps are imported with this policy too.
+	OnDuplicate string `toml:"on-duplicate" json:"on-duplicate"`
+
+	FixedWidth   []*FixedWidthRule `toml:"fixed-width" json:"fixed-width"`
+	Projection   []*ProjectionRule `toml:"projection" json:"projection"`
+	Filter       []*FilterRule     `toml:"filter" json:"filter"`
+	Transform    []*TransformRule  `toml:"transform" json:"transform"`
+	Mask         []*MaskRule       `toml:"mask" json:"mask"`
+	DDLDowngrade DDLDowngrade      `toml:"ddl-downgrade" json:"ddl-downgrade"`
+	Remote       RemoteSource      `toml:"remote" json:"remote"`
+}
+
+// RemoteSource limits the requests reading the data source from an HTTP(S)
+// or HDFS URL, e.g. an S3 bucket, to avoid being throttled.
+type RemoteSource struct {
+	// Concurrency is the maximum number of requests sent at the same time,
+	// zero for unlimited.
+	Concurrency int `toml:"concurrency" json:"concurrency"`
+	// Retry is the number of times a request failing with a 5xx or 429
+	// status, or a broken connection, is retried with exponential backoff.
+	Retry int `toml:"retry" json:"retry"`
+	// MaxBandwidth is the total bytes per second read from the data source,
+	// zero for unlimited.
+	MaxBandwidth int64 `toml:"max-bandwidth" json:"max-bandwidth"`
+}
+
+// DDLDowngrade rewrites the clauses in the schema files which TiDB does not
+// support before creating the tables.
+type DDLDowngrade struct {
+	// StripEngine removes the ENGINE table option other than InnoDB, so the
+	// table uses the default storage engine.
+	StripEngine bool `toml:"strip-engine" json:"strip-engine"`
+	// StripOptions are the names of other table options to remove, e.g.
+	// "ROW_FORMAT".
+	StripOptions      []string `toml:"strip-options" json:"strip-options"`
+	DropFulltextIndex bool     `toml:"drop-fulltext-index" json:"drop-fulltext-index"`
+	// StripInvisible removes the INVISIBLE attribute of the columns and
+	// indexes in dumps from MySQL 8.0, making them visible.
+	StripInvisible bool `toml:"strip-invisible" json:"strip-invisible"`
+	// CollationMapping replaces the collations unsupported by TiDB, e.g.
+	// "utf8mb4_0900_ai_ci" of MySQL 8.0, by the mapped collations.
+	CollationMapping map[string]string `toml:"collation-mapping" json:"collation-mapping"`
+	// Rules are regular expression replacements applied in order to the whole
+	// statement, after the options above.
+	Rules []*DDLRewriteRule `toml:"rule" json:"rule"`
+	// UnsupportedTypes are the column types which TiDB cannot store, e.g. the
+	// spatial types.
+	UnsupportedTypes []string `toml:"unsupported-types" json:"unsupported-types"`
+	// OnUnsupportedType decides what to do with the tables having columns of
+	// the unsupported types.
+	OnUnsupportedType string `toml:"on-unsupported-type" json:"on-unsupported-type"`
+}
+
+const (
+	// UnsupportedTypeError fails the task.
+	UnsupportedTypeError = "error"
+	// UnsupportedTypeSkipTable neither creates nor imports the table.
+	UnsupportedTypeSkipTable = "skip-table"
+	// UnsupportedTypeNull creates the columns as nullable LONGBLOB columns,
+	// and imports NULL into them.
+	UnsupportedTypeNull = "null"
+)
+
+type DDLRewriteRule struct {
+	Pattern     string `toml:"pattern" json:"pattern"`
+	Replacement string `toml:"replacement" json:"replacement"`
+}
+
+// FixedWidthRule describes the layout of the fixed-width data files of a
+// table, where each line is a record and each column takes a fixed number of
+// bytes.
+type FixedWidthRule struct {
+	Schema      string   `toml:"schema" json:"schema"`
+	Table       string   `toml:"table" json:"table"`
+	Widths      []int    `toml:"widths" json:"widths"`
+	Columns     []string `toml:"columns" json:"columns"`
+	NullIfBlank bool     `toml:"null-if-blank" json:"null-if-blank"`
+}
+
+// FindFixedWidthRule returns the fixed-width layout of the table, or nil if
+// there is none.
+func (m *MydumperRuntime) FindFixedWidthRule(schema string, table string) *FixedWidthRule {
+	for _, rule := range m.FixedWidth {
+		if strings.EqualFold(rule.Schema, schema) && strings.EqualFold(rule.Table, table) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// ProjectionRule lists the source columns of a table to import. The values of
+// the other columns in the data files are dropped while parsing.
+type ProjectionRule struct {
+	Schema  string   `toml:"schema" json:"schema"`
+	Table   string   `toml:"table" json:"table"`
+	Columns []string `toml:"columns" json:"columns"`
+	// SourceColumns are the columns of the values in the data files which do
+	// not list the columns themselves, e.g. INSERT statements without the
+	// column list.
+	SourceColumns []string `toml:"source-columns" json:"source-columns"`
+}
+
+// FindProjectionRule returns the projection of the table, or nil if there is
+// none.
+func (m *MydumperRuntime) FindProjectionRule(schema string, table string) *ProjectionRule {
+	for _, rule := range m.Projection {
+		if strings.EqualFold(rule.Schema, schema) && strings.EqualFold(rule.Table, table) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// FilterRule imports only the rows of a table matching the WHERE-like
+// predicate, e.g. `created_at >= '2019-01-01'`.
+type FilterRule struct {
+	Schema string `toml:"schema" json:"schema"`
+	Table  string `toml:"table" json:"table"`
+	Where  string `toml:"where" json:"where"`
+}
+
+// FindFilterRule returns the row filter of the table, or nil if there is
+// none.
+func (m *MydumperRuntime) FindFilterRule(schema string, table string) *FilterRule {
+	for _, rule := range m.Filter {
+		if strings.EqualFold(rule.Schema, schema) && strings.EqualFold(rule.Table, table) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// TransformRule replaces the values of a column by an SQL expression computed
+// from them while encoding, where `?` stands for the original value, e.g.
+// `LOWER(TRIM(?))`, or a constant to mask the column.
+type TransformRule struct {
+	Schema string `toml:"schema" json:"schema"`
+	Table  string `toml:"table" json:"table"`
+	Column string `toml:"column" json:"column"`
+	Expr   string `toml:"expr" json:"expr"`
+}
+
+// FindTransformRules returns the transforms of the columns of the table,
+// including those masking the columns.
+func (m *MydumperRuntime) FindTransformRules(schema string, table string) []*TransformRule {
+	var rules []*TransformRule
+	for _, rule := range m.Transform {
+		if strings.EqualFold(rule.Schema, schema) && strings.EqualFold(rule.Table, table) {
+			rules = append(rules, rule)
+		}
+	}
+	for _, rule := range m.Mask {
+		if strings.EqualFold(rule.Schema, schema) && strings.EqualFold(rule.Table, table) {
+			rules = append(rules, rule.transformRule())
+		}
+	}
+	return rules
+}
+
+const (
+	// MaskHash replaces the values by the hex SHA-256 digests of the salted
+	// values, which keeps equal values equal, e.g. for joins.
+	MaskHash = "hash"
+	// MaskRedact replaces the characters of the values by `*`, except for the
+	// first and last few.
+	MaskRedact = "redact"
+	// MaskNullify replaces the values by NULL.
+	MaskNullify = "nullify"
+)
+
+// MaskRule anonymizes a sensitive column while importing.
+type MaskRule struct {
+	Schema string `toml:"schema" json:"schema"`
+	Table  string `toml:"table" json:"table"`
+	Column string `toml:"column" json:"column"`
+	Method string `toml:"method" json:"method"`
+	// Salt is prepended to the values before hashing.
+	Salt string `toml:"salt" json:"-"`
+	// KeepPrefix and KeepSuffix are the numbers of characters not redacted.
+	KeepPrefix int `toml:"keep-prefix" json:"keep-prefix"`
+	KeepSuffix int `toml:"keep-suffix" json:"keep-suffix"`
+}
+
+// transformRule returns the transform implementing the mask. The salt is
+// written as a hex literal, which is read the same under every SQL mode.
+func (r *MaskRule) transformRule() *TransformRule {
+	var expr string
+	switch r.Method {
+	case MaskHash:
+		if len(r.Salt) > 0 {
+			expr = fmt.Sprintf("SHA2(CONCAT(X'%x', ?), 256)", r.Salt)
+		} else {
+			expr = "SHA2(?, 256)"
+		}
+	case MaskRedact:
+		keep := r.KeepPrefix + r.KeepSuffix
+		expr = fmt.Sprintf(
+			"IF(CHAR_LENGTH(?) <= %[1]d, REPEAT('*', CHAR_LENGTH(?)), CONCAT(LEFT(?, %[2]d), REPEAT('*', CHAR_LENGTH(?) - %[1]d), RIGHT(?, %[3]d)))",
+			keep, r.KeepPrefix, r.KeepSuffix,
+		)
+	case MaskNullify:
+		expr = "NULL"
+	}
+	return &TransformRule{Schema: r.Schema, Table: r.Table, Column: r.Column, Expr: expr}
 }
 
+const (
+	// ShardByEngine spreads the engines individually across the importers.
+	ShardByEngine = "engine"
+	// ShardByTable places all engines of a table on the same importer.
+	ShardByTable = "table"
+)
+
+const (
+	// CompressionNone sends the KV pairs to the importer uncompressed.
+	CompressionNone = "none"
+	// CompressionGzip compresses the KV pairs sent to the importer with gzip.
+	CompressionGzip = "gzip"
+)
+
+// BackendImporter is the default backend, writing the KV pairs into
+// tikv-importer.
+const BackendImporter = "importer"
+
 type TikvImporter struct {
-	Addr string `toml:"addr" json:"addr"`
+	// Addr is a comma-separated list of tikv-importer addresses.
+	Addr  string `toml:"addr" json:"addr"`
+	Shard string `toml:"shard" json:"shard"`
+	// MaxKVSize is the maximum size of a single KV pair, which should not
+	// exceed the raft-entry-max-size of TiKV. Rows producing larger pairs are
+	// rejected while encoding, since TiKV cannot ingest them.
+	MaxKVSize int64 `toml:"max-kv-size" json:"max-kv-size"`
+	// Compression is the gRPC compression of the KV pairs sent to the
+	// importer, "none" or "gzip".
+	Compression string `toml:"compression" json:"compression"`
+	// DiskQuota pauses opening new engines while the estimated disk usage of
+	// the importers exceeds this many bytes. 0 means unlimited.
+	DiskQuota int64 `toml:"disk-quota" json:"disk-quota"`
+	// Backend is the name of the backend the KV pairs are written into, see
+	// the backend package. Defaults to "importer".
+	Backend string `toml:"backend" json:"backend"`
 }
 
 type Checkpoint struct {
@@ -115,6 +512,43 @@ type Checkpoint struct {
 	DSN              string `toml:"dsn" json:"-"` // DSN may contain password, don't expose this to JSON.
 	Driver           string `toml:"driver" json:"driver"`
 	KeepAfterSuccess bool   `toml:"keep-after-success" json:"keep-after-success"`
+	// DeltaImport imports the tables completed in a previous run again if
+	// their data files have changed since, and skips them otherwise.
+	DeltaImport bool `toml:"delta-import" json:"delta-import"`
+	// FlushInterval is the minimum interval between two checkpoint writes.
+	FlushInterval Duration `toml:"flush-interval" json:"flush-interval"`
+	// TablePrefix is prepended to the names of the checkpoint tables of the
+	// "mysql" driver.
+	TablePrefix string `toml:"table-prefix" json:"table-prefix"`
+	// OnMissingFile is how to handle the unfinished chunks whose data files
+	// no longer exist when resuming, "abort" or "skip".
+	OnMissingFile string `toml:"on-missing-file" json:"on-missing-file"`
+}
+
+const (
+	// MissingFileAbort stops the table when resuming from a checkpoint which
+	// references missing data files.
+	MissingFileAbort = "abort"
+	// MissingFileSkip logs a warning and skips the chunks of the missing data
+	// files.
+	MissingFileSkip = "skip"
+)
+
+const (
+	// DupeError encodes the rows with INSERT, failing on duplicated keys.
+	DupeError = "error"
+	// DupeReplace encodes the rows with REPLACE, keeping the last row.
+	DupeReplace = "replace"
+	// DupeIgnore encodes the rows with INSERT IGNORE, keeping the first row.
+	DupeIgnore = "ignore"
+)
+
+var checkpointTablePrefixRegexp = regexp.MustCompile(`^\w*$`)
+
+// History configures recording the import history into the target TiDB.
+type History struct {
+	Enable bool   `toml:"enable" json:"enable"`
+	Schema string `toml:"schema" json:"schema"`
 }
 
 type Cron struct {
@@ -122,6 +556,76 @@ type Cron struct {
 	LogProgress Duration `toml:"log-progress" json:"log-progress"`
 }
 
+// Notify configures the webhook receiving the events of the restore task.
+type Notify struct {
+	WebhookURL string   `toml:"webhook-url" json:"webhook-url"`
+	Timeout    Duration `toml:"timeout" json:"timeout"`
+}
+
+// Watchdog configures the detection of engines making no progress.
+type Watchdog struct {
+	StallTimeout       Duration `toml:"stall-timeout" json:"stall-timeout"`
+	DumpGoroutines     bool     `toml:"dump-goroutines" json:"dump-goroutines"`
+	RetryStalledEngine bool     `toml:"retry-stalled-engine" json:"retry-stalled-engine"`
+	// DeliverBlockedWarn logs a warning when encoding a chunk has been
+	// blocked for longer than it waiting for the KV pairs to be delivered,
+	// i.e. the importer is the bottleneck. 0 disables the warning.
+	DeliverBlockedWarn Duration `toml:"deliver-blocked-warn" json:"deliver-blocked-warn"`
+}
+
+// SLO configures lowering the import concurrency while the latency of the
+// online workload of the cluster exceeds its objective.
+type SLO struct {
+	// PrometheusAddr is the Prometheus server monitoring the cluster. Empty
+	// disables the governor.
+	PrometheusAddr string `toml:"prometheus-addr" json:"prometheus-addr"`
+	// LatencyQuery is the PromQL query of the latency in seconds.
+	LatencyQuery string   `toml:"latency-query" json:"latency-query"`
+	MaxLatency   Duration `toml:"max-latency" json:"max-latency"`
+	Interval     Duration `toml:"interval" json:"interval"`
+}
+
+// TableDependency declares that a table is only imported after the tables it
+// depends on have been completed.
+type TableDependency struct {
+	Schema string `toml:"schema" json:"schema"`
+	Table  string `toml:"table" json:"table"`
+	// After lists the tables depended on, in the form "schema.table".
+	After []string `toml:"after" json:"after"`
+}
+
+// Proxy overrides the proxy from the environment variables HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY.
+type Proxy struct {
+	URL     string `toml:"url" json:"-"`
+	NoProxy string `toml:"no-proxy" json:"no-proxy"`
+	// GRPC is whether the connections to tikv-importer go through the proxy.
+	GRPC bool `toml:"grpc" json:"grpc"`
+}
+
+// Security configures how Lightning handles the sensitive data.
+type Security struct {
+	// RedactInfoLog removes the row data from the logs and error messages.
+	RedactInfoLog bool `toml:"redact-info-log" json:"redact-info-log"`
+	// CAPath, CertPath and KeyPath are the PEM files of the CA certificate,
+	// and the client certificate and key, used by `tidb.tls = "cluster"`.
+	CAPath   string `toml:"ca-path" json:"ca-path"`
+	CertPath string `toml:"cert-path" json:"cert-path"`
+	KeyPath  string `toml:"key-path" json:"key-path"`
+}
+
+// Offline configures encoding the data source into KV files without
+// connecting to TiDB, PD or tikv-importer, so the files can be ingested later.
+type Offline struct {
+	// OutputDir is the local directory receiving the KV files. The offline
+	// mode is enabled if it is not empty.
+	OutputDir string `toml:"output-dir" json:"output-dir"`
+	// TableIDBase is the table ID encoded into the keys of the first table.
+	// The following tables use the next IDs, and the ingester rewrites them
+	// into the IDs of the target tables.
+	TableIDBase int64 `toml:"table-id-base" json:"table-id-base"`
+}
+
 // A duration which can be deserialized from a TOML string.
 // Implemented as https://github.com/BurntSushi/toml#using-the-encodingtextunmarshaler-interface
 type Duration struct {
@@ -144,7 +648,10 @@ func NewConfig() *Config {
 			RegionConcurrency: runtime.NumCPU(),
 			TableConcurrency:  8,
 			IOConcurrency:     5,
+			SchemaConcurrency: 16,
+			ImportConcurrency: 8,
 			CheckRequirements: true,
+			ProgressUI:        true,
 		},
 		TiDB: DBStore{
 			SQLMode:                    "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION",
@@ -153,10 +660,41 @@ func NewConfig() *Config {
 			IndexSerialScanConcurrency: 20,
 			ChecksumTableConcurrency:   16,
 		},
+		Checkpoint: Checkpoint{
+			FlushInterval: Duration{Duration: time.Second},
+		},
+		PostRestore: PostRestore{
+			IndexConcurrency: 1,
+		},
 		Cron: Cron{
 			SwitchMode:  Duration{Duration: 5 * time.Minute},
 			LogProgress: Duration{Duration: 5 * time.Minute},
 		},
+		Notify: Notify{
+			Timeout: Duration{Duration: 10 * time.Second},
+		},
+		Mydumper: MydumperRuntime{
+			DDLDowngrade: DDLDowngrade{
+				UnsupportedTypes: []string{
+					"GEOMETRY", "POINT", "LINESTRING", "POLYGON",
+					"MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON", "GEOMETRYCOLLECTION", "GEOMCOLLECTION",
+				},
+				OnUnsupportedType: UnsupportedTypeError,
+			},
+			Remote: RemoteSource{
+				Retry: 3,
+			},
+		},
+		Offline: Offline{
+			TableIDBase: 1,
+		},
+		Watchdog: Watchdog{
+			DeliverBlockedWarn: Duration{Duration: time.Minute},
+		},
+		SLO: SLO{
+			LatencyQuery: "histogram_quantile(0.99, sum(rate(tidb_server_handle_query_duration_seconds_bucket[1m])) by (le))",
+			Interval:     Duration{Duration: 30 * time.Second},
+		},
 	}
 }
 
@@ -174,17 +712,32 @@ func LoadConfig(args []string) (*Config, error) {
 	fs.BoolVar(&cfg.DoCompact, "compact", false, "do manual compaction on the target cluster, run then exit")
 	fs.StringVar(&cfg.SwitchMode, "switch-mode", "", "switch tikv into import mode or normal mode, values can be ['import', 'normal'], run then exit")
 	fs.BoolVar(&cfg.printVersion, "V", false, "print version of lightning")
+	fs.BoolVar(&cfg.KeepEnginesOnFailure, "keep-engines-on-failure", false, "do not clean up the engines in tikv-importer when importing them fails, for debugging")
+	logFile := fs.String("log-file", "", "log file path, overriding the config file. \"-\" logs to stdout without rotation")
 
 	if err := fs.Parse(args); err != nil {
-		return nil, errors.Trace(err)
+		return nil, common.ErrInvalidConfig.Wrap(err)
 	}
 
 	if err := cfg.Load(); err != nil {
 		return nil, errors.Trace(err)
 	}
+	if len(*logFile) > 0 {
+		cfg.App.File = *logFile
+	}
 	return cfg, nil
 }
 
+// CheckpointsInTarget returns whether the checkpoints are stored in the
+// target TiDB, which is the default of the "mysql" driver.
+func (cfg *Config) CheckpointsInTarget() bool {
+	if !cfg.Checkpoint.Enable || cfg.Checkpoint.Driver != "mysql" {
+		return false
+	}
+	dsn, err := mysql.ParseDSN(cfg.Checkpoint.DSN)
+	return err == nil && dsn.Addr == common.JoinHostPort(cfg.TiDB.Host, cfg.TiDB.Port)
+}
+
 func (cfg *Config) Load() error {
 	if cfg.printVersion {
 		fmt.Println(common.GetRawInfo())
@@ -193,25 +746,321 @@ func (cfg *Config) Load() error {
 
 	data, err := ioutil.ReadFile(cfg.ConfigFile)
 	if err != nil {
-		return errors.Trace(err)
+		return common.ErrInvalidConfig.Annotatef(err, "cannot read config file %s", cfg.ConfigFile)
 	}
 	if err = toml.Unmarshal(data, cfg); err != nil {
+		return common.ErrInvalidConfig.Annotatef(err, "cannot parse config file %s", cfg.ConfigFile)
+	}
+	return cfg.adjust()
+}
+
+// LoadFromTOML parses the config from the content of a config file, e.g. a
+// task submitted to the control server, and fills in the defaults.
+func (cfg *Config) LoadFromTOML(data []byte) error {
+	if err := toml.Unmarshal(data, cfg); err != nil {
+		return common.ErrInvalidConfig.Annotatef(err, "cannot parse config")
+	}
+	return cfg.adjust()
+}
+
+// adjustTiDBAuth validates the TLS and authentication settings of TiDB, and
+// registers the certificates and the server public key into the MySQL driver.
+func (cfg *Config) adjustTiDBAuth() error {
+	switch cfg.TiDB.TLS {
+	case "", TLSNone, TLSSkipVerify:
+	case TLSCluster:
+		if len(cfg.Security.CAPath) == 0 {
+			return common.ErrInvalidConfig.Errorf("tidb.tls = %q needs security.ca-path", TLSCluster)
+		}
+		if (len(cfg.Security.CertPath) == 0) != (len(cfg.Security.KeyPath) == 0) {
+			return common.ErrInvalidConfig.Errorf("security.cert-path and security.key-path must be set together")
+		}
+		err := common.RegisterMySQLTLSConfig(tidbTLSConfigName, cfg.Security.CAPath, cfg.Security.CertPath, cfg.Security.KeyPath, cfg.TiDB.Host)
+		if err != nil {
+			return common.ErrInvalidConfig.Annotatef(err, "invalid TLS settings of tidb")
+		}
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid tidb.tls %q, must be %q, %q or %q",
+			cfg.TiDB.TLS, TLSNone, TLSSkipVerify, TLSCluster,
+		)
+	}
+	if len(cfg.TiDB.ServerPublicKey) > 0 {
+		if err := common.RegisterMySQLServerPubKey(tidbServerPubKeyName, cfg.TiDB.ServerPublicKey); err != nil {
+			return common.ErrInvalidConfig.Annotatef(err, "invalid tidb.server-public-key")
+		}
+	}
+	return nil
+}
+
+// adjust fills in the defaults and validates the config.
+func (cfg *Config) adjust() error {
+	var err error
+
+	// handle concurrency. every worker pool must have at least one worker,
+	// otherwise the tasks waiting on it would never be scheduled.
+	if cfg.App.RegionConcurrency <= 0 {
+		cfg.App.RegionConcurrency = runtime.NumCPU()
+	}
+	if cfg.App.TableConcurrency <= 0 {
+		cfg.App.TableConcurrency = 8
+	}
+	if cfg.App.IOConcurrency <= 0 {
+		cfg.App.IOConcurrency = 5
+	}
+	if cfg.App.SchemaConcurrency <= 0 {
+		cfg.App.SchemaConcurrency = 16
+	}
+	if cfg.App.ImportConcurrency <= 0 {
+		cfg.App.ImportConcurrency = 8
+	}
+	if cfg.App.TableRetry < 0 {
+		return common.ErrInvalidConfig.Errorf("lightning.table-retry must not be negative")
+	}
+	if cfg.App.ChunkRetry < 0 {
+		return common.ErrInvalidConfig.Errorf("lightning.chunk-retry must not be negative")
+	}
+
+	// resolve the addresses, which may be IPv6 literals or SRV records.
+	if cfg.TiDB.Host, cfg.TiDB.Port, err = common.ResolveHostPort(cfg.TiDB.Host, cfg.TiDB.Port); err != nil {
+		return common.ErrInvalidConfig.Annotatef(err, "invalid tidb.host")
+	}
+	pdAddrs, err := common.ResolveAddrs(cfg.TiDB.PdAddr)
+	if err != nil {
+		return common.ErrInvalidConfig.Annotatef(err, "invalid tidb.pd-addr")
+	}
+	if err := cfg.adjustTiDBAuth(); err != nil {
 		return errors.Trace(err)
 	}
+	if len(pdAddrs) > 0 {
+		cfg.TiDB.PdAddr = pdAddrs[0]
+	}
+	importerAddrs, err := common.ResolveAddrs(cfg.TikvImporter.Addr)
+	if err != nil {
+		return common.ErrInvalidConfig.Annotatef(err, "invalid tikv-importer.addr")
+	}
+	cfg.TikvImporter.Addr = strings.Join(importerAddrs, ",")
+
+	if cfg.TikvImporter.MaxKVSize <= 0 {
+		cfg.TikvImporter.MaxKVSize = 8 * _M
+	}
+
+	switch cfg.TikvImporter.Compression {
+	case "":
+		cfg.TikvImporter.Compression = CompressionNone
+	case CompressionNone, CompressionGzip:
+	default:
+		// tikv-importer is built on the gRPC C core, which only decompresses
+		// gzip and deflate, so e.g. snappy is not an option.
+		return common.ErrInvalidConfig.Errorf("invalid tikv-importer.compression %q, must be %q or %q", cfg.TikvImporter.Compression, CompressionNone, CompressionGzip)
+	}
+
+	if cfg.TikvImporter.Backend == "" {
+		cfg.TikvImporter.Backend = BackendImporter
+	}
+
+	if cfg.TikvImporter.DiskQuota < 0 {
+		return common.ErrInvalidConfig.Errorf("tikv-importer.disk-quota must not be negative")
+	}
+
+	switch cfg.TikvImporter.Shard {
+	case "":
+		cfg.TikvImporter.Shard = ShardByEngine
+	case ShardByEngine, ShardByTable:
+	default:
+		return common.ErrInvalidConfig.Errorf("invalid tikv-importer.shard %q, must be %q or %q", cfg.TikvImporter.Shard, ShardByEngine, ShardByTable)
+	}
+
+	if cfg.PostRestore.IndexConcurrency <= 0 {
+		cfg.PostRestore.IndexConcurrency = 1
+	}
+	if cfg.PostRestore.ChecksumConcurrency <= 0 {
+		cfg.PostRestore.ChecksumConcurrency = cfg.App.TableConcurrency
+	}
+	if cfg.PostRestore.ChecksumMaxReadFlow < 0 {
+		return common.ErrInvalidConfig.Errorf("post-restore.checksum-max-read-flow must not be negative")
+	}
+	if cfg.PostRestore.ValidateRows < 0 {
+		return common.ErrInvalidConfig.Errorf("post-restore.validate-rows must not be negative")
+	}
+	switch cfg.PostRestore.ChecksumMethod {
+	case "":
+		cfg.PostRestore.ChecksumMethod = ChecksumAdmin
+	case ChecksumAdmin, ChecksumCoprocessor:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid post-restore.checksum-method %q, must be %q or %q",
+			cfg.PostRestore.ChecksumMethod, ChecksumAdmin, ChecksumCoprocessor,
+		)
+	}
+
+	switch cfg.PostRestore.TiFlashReplica {
+	case "":
+		cfg.PostRestore.TiFlashReplica = TiFlashReplicaIgnore
+	case TiFlashReplicaIgnore, TiFlashReplicaWait, TiFlashReplicaReset:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid post-restore.tiflash-replica %q, must be %q, %q or %q",
+			cfg.PostRestore.TiFlashReplica, TiFlashReplicaIgnore, TiFlashReplicaWait, TiFlashReplicaReset,
+		)
+	}
+
+	switch cfg.PostRestore.Privileges {
+	case "":
+		cfg.PostRestore.Privileges = PrivilegesSkip
+	case PrivilegesSkip, PrivilegesDryRun, PrivilegesMerge:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid post-restore.privileges %q, must be %q, %q or %q",
+			cfg.PostRestore.Privileges, PrivilegesSkip, PrivilegesDryRun, PrivilegesMerge,
+		)
+	}
+	switch cfg.PostRestore.PrivilegeConflict {
+	case "":
+		cfg.PostRestore.PrivilegeConflict = PrivilegeConflictKeep
+	case PrivilegeConflictKeep, PrivilegeConflictReplace:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid post-restore.privilege-conflict %q, must be %q or %q",
+			cfg.PostRestore.PrivilegeConflict, PrivilegeConflictKeep, PrivilegeConflictReplace,
+		)
+	}
 
 	// handle mydumper
 	if cfg.Mydumper.BatchSize <= 0 {
 		cfg.Mydumper.BatchSize = 100 * _G
 	}
+	if cfg.Mydumper.SampleRows < 0 {
+		return common.ErrInvalidConfig.Errorf("invalid mydumper.sample-rows %d", cfg.Mydumper.SampleRows)
+	}
 	if cfg.Mydumper.BatchImportRatio < 0.0 || cfg.Mydumper.BatchImportRatio >= 1.0 {
 		cfg.Mydumper.BatchImportRatio = 0.75
 	}
 	if cfg.Mydumper.ReadBlockSize <= 0 {
 		cfg.Mydumper.ReadBlockSize = ReadBlockSize
 	}
+	if cfg.Mydumper.ReadAhead < 0 {
+		return common.ErrInvalidConfig.Errorf("invalid mydumper.read-ahead %d", cfg.Mydumper.ReadAhead)
+	}
 	if len(cfg.Mydumper.CharacterSet) == 0 {
 		cfg.Mydumper.CharacterSet = "auto"
 	}
+	switch cfg.Mydumper.OnDuplicate {
+	case "":
+		cfg.Mydumper.OnDuplicate = DupeError
+	case DupeError, DupeReplace, DupeIgnore:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid mydumper.on-duplicate %q, must be %q, %q or %q",
+			cfg.Mydumper.OnDuplicate, DupeError, DupeReplace, DupeIgnore,
+		)
+	}
+	for _, rule := range cfg.Mydumper.FixedWidth {
+		if len(rule.Widths) == 0 {
+			return common.ErrInvalidConfig.Errorf("mydumper.fixed-width of %s.%s has no widths", rule.Schema, rule.Table)
+		}
+		for _, width := range rule.Widths {
+			if width <= 0 {
+				return common.ErrInvalidConfig.Errorf("mydumper.fixed-width of %s.%s has a non-positive width %d", rule.Schema, rule.Table, width)
+			}
+		}
+		if len(rule.Columns) != 0 && len(rule.Columns) != len(rule.Widths) {
+			return common.ErrInvalidConfig.Errorf("mydumper.fixed-width of %s.%s has %d columns but %d widths", rule.Schema, rule.Table, len(rule.Columns), len(rule.Widths))
+		}
+	}
+
+	for _, rule := range cfg.Mydumper.Projection {
+		if len(rule.Columns) == 0 {
+			return common.ErrInvalidConfig.Errorf("mydumper.projection of %s.%s has no columns", rule.Schema, rule.Table)
+		}
+	}
+
+	for _, rule := range cfg.Mydumper.Filter {
+		if len(strings.TrimSpace(rule.Where)) == 0 {
+			return common.ErrInvalidConfig.Errorf("mydumper.filter of %s.%s has no where", rule.Schema, rule.Table)
+		}
+	}
+	for _, rule := range cfg.Mydumper.Transform {
+		if len(rule.Column) == 0 || len(strings.TrimSpace(rule.Expr)) == 0 {
+			return common.ErrInvalidConfig.Errorf("mydumper.transform of %s.%s needs both column and expr", rule.Schema, rule.Table)
+		}
+	}
+
+	transformed := make(map[string]struct{})
+	for _, rule := range cfg.Mydumper.Transform {
+		transformed[strings.ToLower(rule.Schema+"."+rule.Table+"."+rule.Column)] = struct{}{}
+	}
+	for _, rule := range cfg.Mydumper.Mask {
+		switch rule.Method {
+		case MaskHash, MaskRedact, MaskNullify:
+		default:
+			return common.ErrInvalidConfig.Errorf(
+				"invalid mydumper.mask method %q of %s.%s.%s, must be %q, %q or %q",
+				rule.Method, rule.Schema, rule.Table, rule.Column, MaskHash, MaskRedact, MaskNullify,
+			)
+		}
+		if len(rule.Column) == 0 || rule.KeepPrefix < 0 || rule.KeepSuffix < 0 {
+			return common.ErrInvalidConfig.Errorf("invalid mydumper.mask of %s.%s.%s", rule.Schema, rule.Table, rule.Column)
+		}
+		key := strings.ToLower(rule.Schema + "." + rule.Table + "." + rule.Column)
+		if _, ok := transformed[key]; ok {
+			return common.ErrInvalidConfig.Errorf("the column %s.%s.%s is both transformed and masked", rule.Schema, rule.Table, rule.Column)
+		}
+		transformed[key] = struct{}{}
+	}
+
+	for _, rule := range cfg.Mydumper.DDLDowngrade.Rules {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return common.ErrInvalidConfig.Annotatef(err, "invalid mydumper.ddl-downgrade.rule pattern %q", rule.Pattern)
+		}
+	}
+	if remote := &cfg.Mydumper.Remote; remote.Concurrency < 0 || remote.Retry < 0 || remote.MaxBandwidth < 0 {
+		return common.ErrInvalidConfig.Errorf(
+			"invalid mydumper.remote, concurrency %d, retry %d and max-bandwidth %d must not be negative",
+			remote.Concurrency, remote.Retry, remote.MaxBandwidth,
+		)
+	}
+	switch cfg.Mydumper.DDLDowngrade.OnUnsupportedType {
+	case "":
+		cfg.Mydumper.DDLDowngrade.OnUnsupportedType = UnsupportedTypeError
+	case UnsupportedTypeError, UnsupportedTypeSkipTable, UnsupportedTypeNull:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid mydumper.ddl-downgrade.on-unsupported-type %q, must be %q, %q or %q",
+			cfg.Mydumper.DDLDowngrade.OnUnsupportedType, UnsupportedTypeError, UnsupportedTypeSkipTable, UnsupportedTypeNull,
+		)
+	}
+
+	for _, dep := range cfg.TableDependencies {
+		if len(dep.Schema) == 0 || len(dep.Table) == 0 {
+			return common.ErrInvalidConfig.Errorf("table-dependency must specify both schema and table")
+		}
+		for _, after := range dep.After {
+			if !strings.Contains(after, ".") {
+				return common.ErrInvalidConfig.Errorf("table-dependency of %s.%s has invalid table %q, must be in the form \"schema.table\"", dep.Schema, dep.Table, after)
+			}
+		}
+	}
+
+	if strings.Contains(cfg.Offline.OutputDir, "://") {
+		return common.ErrInvalidConfig.Errorf("invalid offline.output-dir %q, only local directories are supported", cfg.Offline.OutputDir)
+	}
+	if cfg.Offline.TableIDBase <= 0 {
+		return common.ErrInvalidConfig.Errorf("invalid offline.table-id-base %d, must be positive", cfg.Offline.TableIDBase)
+	}
+
+	if len(cfg.SLO.PrometheusAddr) > 0 {
+		if cfg.SLO.MaxLatency.Duration <= 0 {
+			return common.ErrInvalidConfig.Errorf("slo.max-latency must be positive when slo.prometheus-addr is set")
+		}
+		if cfg.SLO.Interval.Duration <= 0 {
+			return common.ErrInvalidConfig.Errorf("slo.interval must be positive when slo.prometheus-addr is set")
+		}
+	}
+
+	if len(cfg.History.Schema) == 0 {
+		cfg.History.Schema = "lightning_metadata"
+	}
 
 	if len(cfg.Checkpoint.Schema) == 0 {
 		cfg.Checkpoint.Schema = "tidb_lightning_checkpoint"
@@ -222,11 +1071,32 @@ func (cfg *Config) Load() error {
 	if len(cfg.Checkpoint.DSN) == 0 {
 		switch cfg.Checkpoint.Driver {
 		case "mysql":
-			cfg.Checkpoint.DSN = common.ToDSN(cfg.TiDB.Host, cfg.TiDB.Port, cfg.TiDB.User, cfg.TiDB.Psw)
+			cfg.Checkpoint.DSN = common.ToDSN(cfg.TiDB.Host, cfg.TiDB.Port, cfg.TiDB.User, cfg.TiDB.Psw, cfg.TiDB.DSNParams()...)
 		case "file":
 			cfg.Checkpoint.DSN = "/tmp/" + cfg.Checkpoint.Schema + ".pb"
 		}
 	}
+	if cfg.Checkpoint.DeltaImport && (!cfg.Checkpoint.Enable || !cfg.Checkpoint.KeepAfterSuccess) {
+		return common.ErrInvalidConfig.Errorf("checkpoint.delta-import needs both checkpoint.enable and checkpoint.keep-after-success")
+	}
+	if !checkpointTablePrefixRegexp.MatchString(cfg.Checkpoint.TablePrefix) {
+		return common.ErrInvalidConfig.Errorf("invalid checkpoint.table-prefix %q, must only contain letters, digits and underscores", cfg.Checkpoint.TablePrefix)
+	}
+	switch cfg.Checkpoint.OnMissingFile {
+	case "":
+		cfg.Checkpoint.OnMissingFile = MissingFileAbort
+	case MissingFileAbort, MissingFileSkip:
+	default:
+		return common.ErrInvalidConfig.Errorf(
+			"invalid checkpoint.on-missing-file %q, must be %q or %q",
+			cfg.Checkpoint.OnMissingFile, MissingFileAbort, MissingFileSkip,
+		)
+	}
+	if cfg.Checkpoint.Driver == "mysql" {
+		if _, err := mysql.ParseDSN(cfg.Checkpoint.DSN); err != nil {
+			return common.ErrInvalidConfig.Annotatef(err, "invalid checkpoint.dsn")
+		}
+	}
 
 	return nil
 }
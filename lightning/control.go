@@ -0,0 +1,333 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lightning
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/controlpb"
+	"github.com/pingcap/tidb-lightning/lightning/metric"
+)
+
+// taskProgress is a reading of the progress counters. The counters are
+// replaced when each task starts (see metric.ResetForTask), and the progress
+// of a task is the difference from the reading when it started.
+type taskProgress struct {
+	finishedChunks  float64
+	estimatedChunks float64
+	completedTables float64
+	totalTables     float64
+}
+
+func readTaskProgress() taskProgress {
+	return taskProgress{
+		finishedChunks:  metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished)),
+		estimatedChunks: metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated)),
+		completedTables: metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStateCompleted, metric.TableResultSuccess)),
+		totalTables:     metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess)),
+	}
+}
+
+func (p taskProgress) sub(base taskProgress) taskProgress {
+	return taskProgress{
+		finishedChunks:  p.finishedChunks - base.finishedChunks,
+		estimatedChunks: p.estimatedChunks - base.estimatedChunks,
+		completedTables: p.completedTables - base.completedTables,
+		totalTables:     p.totalTables - base.totalTables,
+	}
+}
+
+type controlTask struct {
+	id    int64
+	cfg   *config.Config
+	state controlpb.TaskState
+	err   error
+	// instance is the running Lightning, or nil if the task is not running.
+	instance *Lightning
+	// progress is the progress reading when the task started if it is
+	// running, otherwise the progress made in the last run.
+	progress taskProgress
+}
+
+// controlServer implements the gRPC control API. The tasks are run one at a
+// time in the order submitted, since the progress counters and the import
+// mode of the cluster are shared by the whole process.
+type controlServer struct {
+	lightning *Lightning
+
+	mu     sync.Mutex
+	tasks  map[int64]*controlTask
+	queue  []*controlTask
+	nextID int64
+	wakeCh chan struct{}
+}
+
+func newControlServer(l *Lightning) *controlServer {
+	return &controlServer{
+		lightning: l,
+		tasks:     make(map[int64]*controlTask),
+		nextID:    1,
+		wakeCh:    make(chan struct{}, 1),
+	}
+}
+
+// runControlServer serves the control API on app.control-addr, and runs the
+// submitted tasks until Lightning is stopped.
+func (l *Lightning) runControlServer() error {
+	listener, err := net.Listen("tcp", l.cfg.App.ControlAddr)
+	if err != nil {
+		return errors.Annotatef(err, "cannot listen on lightning.control-addr %s", l.cfg.App.ControlAddr)
+	}
+	server := newControlServer(l)
+	grpcServer := grpc.NewServer()
+	controlpb.RegisterControlServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	common.AppLogger.Infof("control server listening on %s", listener.Addr())
+
+	server.runTasks(l.ctx)
+	grpcServer.Stop()
+	return nil
+}
+
+func (s *controlServer) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// runTasks runs the queued tasks one by one until the context is canceled.
+func (s *controlServer) runTasks(ctx context.Context) {
+	for {
+		task := s.startNextTask(ctx)
+		if task == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wakeCh:
+				continue
+			}
+		}
+
+		common.AppLogger.Infof("[task %d] start", task.id)
+		registerTask(task.id, task.instance)
+		err := task.instance.run()
+		unregisterTask(task.id)
+
+		s.mu.Lock()
+		task.progress = readTaskProgress().sub(task.progress)
+		task.instance = nil
+		if task.state == controlpb.TaskState_RUNNING {
+			switch {
+			case ctx.Err() != nil:
+				// stopped with the server, the checkpoints allow resuming.
+				task.state = controlpb.TaskState_PAUSED
+			case err != nil:
+				task.state = controlpb.TaskState_FAILED
+				task.err = err
+			default:
+				task.state = controlpb.TaskState_FINISHED
+			}
+		}
+		state := task.state
+		s.mu.Unlock()
+
+		if err != nil {
+			common.AppLogger.Errorf("[task %d] %s: %s", task.id, state, errors.ErrorStack(err))
+		} else {
+			common.AppLogger.Infof("[task %d] %s", task.id, state)
+		}
+	}
+}
+
+// startNextTask marks the first queued task as running, or returns nil if
+// the queue is empty.
+func (s *controlServer) startNextTask(ctx context.Context) *controlTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 || ctx.Err() != nil {
+		return nil
+	}
+	task := s.queue[0]
+	s.queue = s.queue[1:]
+
+	taskCtx, shutdown := context.WithCancel(ctx)
+	task.instance = &Lightning{
+		cfg:      task.cfg,
+		glue:     s.lightning.glue,
+		ctx:      taskCtx,
+		shutdown: shutdown,
+	}
+	task.state = controlpb.TaskState_RUNNING
+	task.err = nil
+	// label the metrics by the task, so the tasks are not blended in Grafana.
+	metric.ResetForTask(strconv.FormatInt(task.id, 10))
+	task.progress = readTaskProgress()
+	return task
+}
+
+func (s *controlServer) removeFromQueue(task *controlTask) {
+	for i, queued := range s.queue {
+		if queued == task {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *controlServer) getTask(id int64) (*controlTask, error) {
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "task %d not found", id)
+	}
+	return task, nil
+}
+
+// SubmitTask queues a new task.
+func (s *controlServer) SubmitTask(_ context.Context, req *controlpb.SubmitTaskRequest) (*controlpb.SubmitTaskResponse, error) {
+	cfg := config.NewConfig()
+	if err := cfg.LoadFromTOML([]byte(req.Config)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	task := &controlTask{id: s.nextID, cfg: cfg, state: controlpb.TaskState_QUEUED}
+	s.nextID++
+	s.tasks[task.id] = task
+	s.queue = append(s.queue, task)
+	s.mu.Unlock()
+
+	common.AppLogger.Infof("[task %d] submitted", task.id)
+	s.wake()
+	return &controlpb.SubmitTaskResponse{TaskId: task.id}, nil
+}
+
+// GetProgress returns the state and the progress of a task.
+func (s *controlServer) GetProgress(_ context.Context, req *controlpb.TaskRequest) (*controlpb.ProgressResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.getTask(req.TaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := task.progress
+	if task.instance != nil {
+		progress = readTaskProgress().sub(task.progress)
+	}
+	resp := &controlpb.ProgressResponse{
+		State:           task.state,
+		FinishedChunks:  progress.finishedChunks,
+		EstimatedChunks: progress.estimatedChunks,
+		CompletedTables: progress.completedTables,
+		TotalTables:     progress.totalTables,
+	}
+	if task.err != nil {
+		resp.Error = task.err.Error()
+	}
+	return resp, nil
+}
+
+// PauseTask stops a queued or running task. The checkpoints are kept, so the
+// task continues from where it stopped when resumed.
+func (s *controlServer) PauseTask(_ context.Context, req *controlpb.TaskRequest) (*controlpb.TaskResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.getTask(req.TaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch task.state {
+	case controlpb.TaskState_QUEUED:
+		s.removeFromQueue(task)
+	case controlpb.TaskState_RUNNING:
+		task.instance.shutdown()
+	default:
+		return nil, status.Errorf(codes.FailedPrecondition, "task %d is %s", task.id, task.state)
+	}
+	task.state = controlpb.TaskState_PAUSED
+	common.AppLogger.Infof("[task %d] pausing", task.id)
+	return &controlpb.TaskResponse{State: task.state}, nil
+}
+
+// ResumeTask queues a paused task again.
+func (s *controlServer) ResumeTask(_ context.Context, req *controlpb.TaskRequest) (*controlpb.TaskResponse, error) {
+	s.mu.Lock()
+	task, err := s.getTask(req.TaskId)
+	if err == nil && (task.state != controlpb.TaskState_PAUSED || task.instance != nil) {
+		// a task being paused is only resumable after it has stopped.
+		err = status.Errorf(codes.FailedPrecondition, "task %d is %s", task.id, task.state)
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	task.state = controlpb.TaskState_QUEUED
+	s.queue = append(s.queue, task)
+	s.mu.Unlock()
+
+	common.AppLogger.Infof("[task %d] resumed", task.id)
+	s.wake()
+	return &controlpb.TaskResponse{State: controlpb.TaskState_QUEUED}, nil
+}
+
+// CancelTask stops a task for good.
+func (s *controlServer) CancelTask(_ context.Context, req *controlpb.TaskRequest) (*controlpb.TaskResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.getTask(req.TaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch task.state {
+	case controlpb.TaskState_QUEUED:
+		s.removeFromQueue(task)
+	case controlpb.TaskState_PAUSED, controlpb.TaskState_RUNNING:
+	default:
+		return nil, status.Errorf(codes.FailedPrecondition, "task %d is %s", task.id, task.state)
+	}
+	if task.instance != nil {
+		// the task is running, or still stopping after being paused.
+		task.instance.shutdown()
+	}
+	task.state = controlpb.TaskState_CANCELED
+	common.AppLogger.Infof("[task %d] canceled", task.id)
+	return &controlpb.TaskResponse{State: task.state}, nil
+}
+
+// SetLogLevel changes the log level of the server, which is shared by all
+// tasks.
+func (s *controlServer) SetLogLevel(_ context.Context, req *controlpb.SetLogLevelRequest) (*controlpb.SetLogLevelResponse, error) {
+	level, err := log.ParseLevel(req.Level)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	previous := common.GetLevel()
+	common.SetLevel(level)
+	common.AppLogger.Infof("log level changed from %s to %s", previous, level)
+	return &controlpb.SetLogLevelResponse{PreviousLevel: previous.String()}, nil
+}
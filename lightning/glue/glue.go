@@ -0,0 +1,115 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glue
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+// Glue is how Lightning accesses the target TiDB. An embedder, e.g. running
+// Lightning inside tidb-server, may supply its own implementation to reuse its
+// sessions and schema instead of connecting to TiDB through the network.
+type Glue interface {
+	// ExecuteWithLog executes the statement, retrying on retryable errors. The
+	// purpose describes the statement in the logs.
+	ExecuteWithLog(ctx context.Context, query string, purpose string, args ...interface{}) error
+	// ObtainStringWithLog executes the query which returns a single string
+	// value, retrying on retryable errors.
+	ObtainStringWithLog(ctx context.Context, query string, purpose string) (string, error)
+	// GetTables returns the infos of all tables in the schema.
+	GetTables(ctx context.Context, schema string) ([]*model.TableInfo, error)
+	// GetDB returns a connection pool to the target, for the queries whose
+	// results cannot be obtained through the other methods.
+	GetDB() *sql.DB
+	// Close releases the connections.
+	Close()
+}
+
+// ExternalTiDBGlue accesses a TiDB server through the MySQL protocol and its
+// status port.
+type ExternalTiDBGlue struct {
+	db      *sql.DB
+	client  *http.Client
+	baseURL *url.URL
+}
+
+// NewExternalTiDBGlue creates a glue from the connection pool and the base URL
+// of the status port of TiDB.
+func NewExternalTiDBGlue(db *sql.DB, statusURL *url.URL) *ExternalTiDBGlue {
+	return &ExternalTiDBGlue{
+		db:      db,
+		client:  common.NewHTTPClient(0),
+		baseURL: statusURL,
+	}
+}
+
+// OpenExternalTiDBGlue connects to the TiDB server in the config.
+func OpenExternalTiDBGlue(cfg config.DBStore) (*ExternalTiDBGlue, error) {
+	params := cfg.DSNParams()
+	if len(cfg.SQLMode) > 0 {
+		// the schemas are created under the SQL mode of the task as well,
+		// e.g. to accept the zero dates as the column defaults.
+		params = append(params, "sql_mode="+url.QueryEscape("'"+cfg.SQLMode+"'"))
+	}
+	db, err := common.ConnectDB(cfg.Host, cfg.Port, cfg.User, cfg.Psw, params...)
+	if err != nil {
+		return nil, common.ErrTiDBUnavailable.Annotatef(err, "cannot connect to TiDB %s", common.JoinHostPort(cfg.Host, cfg.Port))
+	}
+	statusURL := &url.URL{Scheme: "http", Host: common.JoinHostPort(cfg.Host, cfg.StatusPort)}
+	return NewExternalTiDBGlue(db, statusURL), nil
+}
+
+func (g *ExternalTiDBGlue) ExecuteWithLog(ctx context.Context, query string, purpose string, args ...interface{}) error {
+	return errors.Trace(common.ExecWithRetry(ctx, g.db, purpose, query, args...))
+}
+
+func (g *ExternalTiDBGlue) ObtainStringWithLog(ctx context.Context, query string, purpose string) (string, error) {
+	var s string
+	err := common.QueryRowWithRetry(ctx, g.db, query, &s)
+	if err != nil && !common.IsContextCanceledError(err) {
+		common.AppLogger.Errorf("query %s [error] %v", purpose, err)
+	}
+	return s, errors.Trace(err)
+}
+
+// GetTables fetches the table infos from the status port.
+func (g *ExternalTiDBGlue) GetTables(ctx context.Context, schema string) ([]*model.TableInfo, error) {
+	schemaURL := *g.baseURL
+	schemaURL.Path = "schema/" + schema
+
+	var tables []*model.TableInfo
+	if err := common.GetJSON(g.client, schemaURL.String(), &tables); err != nil {
+		return nil, errors.Annotatef(err, "get table infos of `%s`", schema)
+	}
+	return tables, nil
+}
+
+func (g *ExternalTiDBGlue) GetDB() *sql.DB {
+	return g.db
+}
+
+func (g *ExternalTiDBGlue) Close() {
+	if g.db != nil {
+		g.db.Close()
+	}
+}
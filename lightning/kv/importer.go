@@ -16,24 +16,32 @@ package kv
 import (
 	"context"
 	"fmt"
+	"hash/crc32"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/satori/go.uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
 
 	kv "github.com/pingcap/kvproto/pkg/import_kvpb"
 	sst "github.com/pingcap/kvproto/pkg/import_sstpb"
 	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-lightning/lightning/metric"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
 	kvec "github.com/pingcap/tidb/util/kvencoder"
 )
 
 const (
 	maxRetryTimes    int = 3 // tikv-importer has done retry internally. so we don't retry many times.
 	retryBackoffTime     = time.Second * 3
+
+	// diskQuotaCheckInterval is how often the disk usage is checked again
+	// while waiting for it to drop below the quota.
+	diskQuotaCheckInterval = 5 * time.Second
 )
 
 /*
@@ -66,32 +74,187 @@ Usual workflow:
 
 */
 
-// Importer represents a gRPC connection to tikv-importer. This type is
-// goroutine safe: you can share this instance and execute any method anywhere.
+// Importer represents gRPC connections to one or more tikv-importer
+// instances. This type is goroutine safe: you can share this instance and
+// execute any method anywhere.
+//
+// Each engine lives on exactly one of the importers, chosen by hashing the
+// engine tag (or the table name if sharded by table). The choice only depends
+// on the list of addresses, so the same engine is found again when resuming.
 type Importer struct {
-	conn   *grpc.ClientConn
-	cli    kv.ImportKVClient
-	pdAddr string
+	addrs        []string
+	conns        []*grpc.ClientConn
+	clis         []kv.ImportKVClient
+	pdAddr       string
+	taskID       uuid.UUID
+	shardByTable bool
+	// writeOpts are the call options of the write streams.
+	writeOpts []grpc.CallOption
+
+	// tikv-importer does not report its disk usage, so it is estimated from
+	// the size of the KV pairs written into the engines not yet cleaned up.
+	diskQuota   int64
+	diskLock    sync.Mutex
+	diskUsage   int64
+	engineSizes map[uuid.UUID]int64
 }
 
-// NewImporter creates a new connection to tikv-importer. A single connection
-// per tidb-lightning instance is enough.
+// NewImporter creates new connections to tikv-importer. The importServerAddr
+// is a comma-separated list of addresses. A single connection per
+// tikv-importer instance is enough.
 func NewImporter(ctx context.Context, importServerAddr string, pdAddr string) (*Importer, error) {
-	conn, err := grpc.DialContext(ctx, importServerAddr, grpc.WithInsecure())
-	if err != nil {
-		return nil, errors.Trace(err)
+	importer := &Importer{pdAddr: pdAddr, engineSizes: make(map[uuid.UUID]int64)}
+	for _, addr := range strings.Split(importServerAddr, ",") {
+		addr = strings.TrimSpace(addr)
+		if len(addr) == 0 {
+			continue
+		}
+		conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), common.GRPCDialOption())
+		if err != nil {
+			importer.Close()
+			return nil, common.ErrImporterUnavailable.Annotatef(err, "cannot connect to tikv-importer %s", addr)
+		}
+		importer.addrs = append(importer.addrs, addr)
+		importer.conns = append(importer.conns, conn)
+		importer.clis = append(importer.clis, kv.NewImportKVClient(conn))
 	}
-
-	return &Importer{
-		conn:   conn,
-		cli:    kv.NewImportKVClient(conn),
-		pdAddr: pdAddr,
-	}, nil
+	if len(importer.clis) == 0 {
+		return nil, common.ErrInvalidConfig.Errorf("no tikv-importer address in %q", importServerAddr)
+	}
+	return importer, nil
 }
 
-// Close the importer connection.
+// Close the importer connections.
 func (importer *Importer) Close() {
-	importer.conn.Close()
+	for _, conn := range importer.conns {
+		conn.Close()
+	}
+}
+
+// SetShardByTable places all engines of the same table on the same importer,
+// instead of spreading the engines individually.
+//
+// This method must be called before any engine is opened.
+func (importer *Importer) SetShardByTable(shardByTable bool) {
+	importer.shardByTable = shardByTable
+}
+
+// SetCompression compresses the KV pairs sent through the write streams with
+// the named gRPC compressor, which must be understood by tikv-importer. An
+// empty name disables the compression.
+//
+// This method must be called before any engine is opened.
+func (importer *Importer) SetCompression(name string) error {
+	switch name {
+	case "":
+		importer.writeOpts = nil
+	case gzip.Name:
+		importer.writeOpts = []grpc.CallOption{grpc.UseCompressor(gzip.Name)}
+	default:
+		return errors.Errorf("unsupported compression %q", name)
+	}
+	return nil
+}
+
+// SetDiskQuota limits the estimated disk usage of the importers, see
+// WaitDiskQuota. A non-positive quota means unlimited.
+//
+// This method must be called before any engine is opened.
+func (importer *Importer) SetDiskQuota(quota int64) {
+	importer.diskQuota = quota
+}
+
+// DiskUsage returns the estimated disk usage of the importers, which is the
+// size of the KV pairs written into the engines not yet cleaned up. Engines
+// written by a previous run are not included.
+func (importer *Importer) DiskUsage() int64 {
+	importer.diskLock.Lock()
+	defer importer.diskLock.Unlock()
+	return importer.diskUsage
+}
+
+// WaitDiskQuota blocks until the estimated disk usage drops below the quota,
+// as the imported engines are cleaned up. It should be called before opening
+// a new engine, so that the engines being written can still be finished.
+func (importer *Importer) WaitDiskQuota(ctx context.Context, tag string) error {
+	if importer.diskQuota <= 0 {
+		return nil
+	}
+	var timer time.Time
+	for {
+		usage := importer.DiskUsage()
+		if usage < importer.diskQuota {
+			if !timer.IsZero() {
+				common.AppLogger.Infof("[%s] importer disk usage dropped to %d bytes, resuming after waiting %v", tag, usage, time.Since(timer))
+			}
+			return nil
+		}
+		if timer.IsZero() {
+			timer = time.Now()
+			common.AppLogger.Warnf("[%s] importer disk usage %d bytes exceeds the quota %d bytes, waiting for the imported engines to be cleaned up",
+				tag, usage, importer.diskQuota)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(diskQuotaCheckInterval):
+		}
+	}
+}
+
+func (importer *Importer) addDiskUsage(engineUUID uuid.UUID, size int64) {
+	importer.diskLock.Lock()
+	importer.engineSizes[engineUUID] += size
+	importer.diskUsage += size
+	importer.diskLock.Unlock()
+}
+
+func (importer *Importer) releaseDiskUsage(engineUUID uuid.UUID) {
+	importer.diskLock.Lock()
+	importer.diskUsage -= importer.engineSizes[engineUUID]
+	delete(importer.engineSizes, engineUUID)
+	importer.diskLock.Unlock()
+}
+
+// clientOf returns the importer holding the engine. The engine files are
+// local to the importer, so there is no failover: the engine fails with
+// ErrImporterUnavailable while its importer is down, and is redelivered or
+// retried by the caller once it is back.
+func (importer *Importer) clientOf(tableName string, tag string) (kv.ImportKVClient, string) {
+	if len(importer.clis) == 1 {
+		return importer.clis[0], importer.addrs[0]
+	}
+	key := tag
+	if importer.shardByTable {
+		key = tableName
+	}
+	i := crc32.ChecksumIEEE([]byte(key)) % uint32(len(importer.clis))
+	return importer.clis[i], importer.addrs[i]
+}
+
+// importerError adds the context to an error returned by tikv-importer, and
+// classifies it as ErrImporterUnavailable if the importer cannot be reached,
+// so the user is not left with a bare gRPC status.
+func importerError(err error, format string, args ...interface{}) error {
+	if common.IsUnavailableError(err) {
+		return common.ErrImporterUnavailable.Annotatef(err, format, args...)
+	}
+	return errors.Annotatef(err, format, args...)
+}
+
+// eachClient runs the cluster-wide action on the importers in order until
+// one of them succeeds. Any importer can serve such requests, so an
+// unavailable importer is skipped.
+func (importer *Importer) eachClient(action func(kv.ImportKVClient) error) error {
+	var err error
+	for i, cli := range importer.clis {
+		err = action(cli)
+		if err == nil || !common.IsUnavailableError(err) {
+			return err
+		}
+		common.AppLogger.Warnf("tikv-importer %s is unavailable, trying the next one: %v", importer.addrs[i], err)
+	}
+	return err
 }
 
 // SwitchMode switches the TiKV cluster to another operation mode.
@@ -104,12 +267,15 @@ func (importer *Importer) SwitchMode(ctx context.Context, mode sst.SwitchMode) e
 	}
 	timer := time.Now()
 
-	_, err := importer.cli.SwitchMode(ctx, req)
+	err := importer.eachClient(func(cli kv.ImportKVClient) error {
+		_, e := cli.SwitchMode(ctx, req)
+		return e
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "status: Unimplemented") {
 			fmt.Fprintln(os.Stderr, "Error: The TiKV instance does not support mode switching. Please make sure the TiKV version is 2.0.4 or above.")
 		}
-		return errors.Trace(err)
+		return importerError(err, "cannot switch TiKV to %s mode", mode)
 	}
 
 	common.AppLogger.Infof("switch to tikv %s mode takes %v", mode, time.Since(timer))
@@ -128,19 +294,35 @@ func (importer *Importer) Compact(ctx context.Context, level int32) error {
 		},
 	}
 	timer := time.Now()
-	_, err := importer.cli.CompactCluster(ctx, req)
+	err := importer.eachClient(func(cli kv.ImportKVClient) error {
+		_, e := cli.CompactCluster(ctx, req)
+		return e
+	})
 	common.AppLogger.Infof("compact level %d takes %v", level, time.Since(timer))
 
-	return errors.Trace(err)
+	return importerError(err, "cannot compact level %d", level)
 }
 
 // OpenedEngine is an opened importer engine file, allowing data to be written
 // to it via WriteStream instances.
 type OpenedEngine struct {
 	importer *Importer
+	cli      kv.ImportKVClient
 	tag      string
 	uuid     uuid.UUID
 	ts       uint64
+
+	// delivered is the checksum of the KV pairs in the write streams which
+	// were closed without error.
+	delivered verify.SyncKVChecksum
+}
+
+// DeliveredChecksum returns the checksum of all KV pairs in the write streams
+// closed without error since the engine is opened. It is computed by the
+// client, since the importer does not report what it has received, so it only
+// accounts for the deliveries and cannot detect data corrupted in transit.
+func (engine *OpenedEngine) DeliveredChecksum() verify.KVChecksum {
+	return engine.delivered.Load()
 }
 
 // isIgnorableOpenCloseEngineError checks if the error from
@@ -159,8 +341,46 @@ func makeTag(tableName string, engineID int) string {
 	return fmt.Sprintf("%s:%d", tableName, engineID)
 }
 
+// engineNamespace is the UUID namespace of engines not bound to any task.
 var engineNamespace = uuid.Must(uuid.FromString("d68d6abe-c59e-45d6-ade8-e2b0ceb7bedf"))
 
+// SetTaskID binds the importer to a task. The UUIDs of all engines opened
+// afterwards are derived from the task ID, so an engine left on the importer
+// by a previous run of the same task can be recognized. Setting `uuid.Nil`
+// keeps the engine UUIDs which do not belong to any task.
+//
+// This method must be called before any engine is opened.
+func (importer *Importer) SetTaskID(taskID uuid.UUID) {
+	importer.taskID = taskID
+}
+
+func (importer *Importer) engineUUID(tag string) uuid.UUID {
+	return engineUUIDOf(importer.taskID, tag)
+}
+
+func engineUUIDOf(taskID uuid.UUID, tag string) uuid.UUID {
+	if uuid.Equal(taskID, uuid.Nil) {
+		return uuid.NewV5(engineNamespace, tag)
+	}
+	return uuid.NewV5(taskID, tag)
+}
+
+// EngineUUID returns the UUID of the engine of the given task, the same as
+// the one opened by an importer bound to the task through SetTaskID. The UUID
+// is derived from the task ID, the schema and table names and the engine ID,
+// so two tasks importing tables of the same names never share an engine.
+func EngineUUID(taskID uuid.UUID, tableName string, engineID int) uuid.UUID {
+	return engineUUIDOf(taskID, makeTag(tableName, engineID))
+}
+
+func sendOpenEngine(ctx context.Context, cli kv.ImportKVClient, engineUUID uuid.UUID) error {
+	req := &kv.OpenEngineRequest{
+		Uuid: engineUUID.Bytes(),
+	}
+	_, err := cli.OpenEngine(ctx, req)
+	return err
+}
+
 // OpenEngine opens an engine with the given table name and engine ID. This type
 // is goroutine safe: you can share this instance and execute any method anywhere.
 func (importer *Importer) OpenEngine(
@@ -169,18 +389,52 @@ func (importer *Importer) OpenEngine(
 	engineID int,
 ) (*OpenedEngine, error) {
 	tag := makeTag(tableName, engineID)
-	engineUUID := uuid.NewV5(engineNamespace, tag)
-	req := &kv.OpenEngineRequest{
-		Uuid: engineUUID.Bytes(),
-	}
-	_, err := importer.cli.OpenEngine(ctx, req)
+	engineUUID := importer.engineUUID(tag)
+	cli, addr := importer.clientOf(tableName, tag)
+	err := sendOpenEngine(ctx, cli, engineUUID)
 	if !isIgnorableOpenCloseEngineError(err) {
-		return nil, errors.Trace(err)
+		return nil, importerError(err, "[%s] cannot open engine %s on %s", tag, engineUUID, addr)
+	}
+	return importer.newOpenedEngine(cli, addr, tag, engineUUID), nil
+}
+
+// OpenFreshEngine opens an engine which is not expected to contain any data.
+//
+// If the importer reports that the engine already exists, it must be a stale
+// engine left behind by a crashed run. When the importer is bound to a task,
+// the matching UUID proves the stale engine belongs to the same task, and it
+// is cleaned up before opening again. Otherwise the engine may belong to
+// another task and an error is returned.
+func (importer *Importer) OpenFreshEngine(
+	ctx context.Context,
+	tableName string,
+	engineID int,
+) (*OpenedEngine, error) {
+	tag := makeTag(tableName, engineID)
+	engineUUID := importer.engineUUID(tag)
+	cli, addr := importer.clientOf(tableName, tag)
+	err := sendOpenEngine(ctx, cli, engineUUID)
+	if err != nil && isIgnorableOpenCloseEngineError(err) {
+		if uuid.Equal(importer.taskID, uuid.Nil) {
+			return nil, errors.Annotatef(err, "[%s] engine %s already exists and cannot be verified to belong to this task, please clean it up with tidb-lightning-ctl", tag, engineUUID)
+		}
+		common.AppLogger.Warnf("[%s] engine %s is left by a previous run of task %s, cleaning up", tag, engineUUID, importer.taskID)
+		staleEngine := &ClosedEngine{importer: importer, cli: cli, tag: tag, uuid: engineUUID}
+		if err = staleEngine.Cleanup(ctx); err != nil {
+			return nil, errors.Trace(err)
+		}
+		err = sendOpenEngine(ctx, cli, engineUUID)
+	}
+	if err != nil {
+		return nil, importerError(err, "[%s] cannot open engine %s on %s", tag, engineUUID, addr)
 	}
+	return importer.newOpenedEngine(cli, addr, tag, engineUUID), nil
+}
 
+func (importer *Importer) newOpenedEngine(cli kv.ImportKVClient, addr string, tag string, engineUUID uuid.UUID) *OpenedEngine {
 	openCounter := metric.EngineCounter.WithLabelValues("open")
 	openCounter.Inc()
-	common.AppLogger.Infof("[%s] open engine %s", tag, engineUUID)
+	common.AppLogger.Infof("[%s] open engine %s on %s", tag, engineUUID, addr)
 
 	// gofail: var FailIfEngineCountExceeds int
 	// {
@@ -197,10 +451,22 @@ func (importer *Importer) OpenEngine(
 
 	return &OpenedEngine{
 		importer: importer,
+		cli:      cli,
 		tag:      tag,
 		ts:       uint64(time.Now().Unix()), // TODO ... set outside ? from pd ?
 		uuid:     engineUUID,
-	}, nil
+	}
+}
+
+// Reopen sends the OpenEngine request again for this engine. This is needed
+// when tikv-importer has been restarted after the engine was opened.
+func (engine *OpenedEngine) Reopen(ctx context.Context) error {
+	err := sendOpenEngine(ctx, engine.cli, engine.uuid)
+	if !isIgnorableOpenCloseEngineError(err) {
+		return importerError(err, "[%s] cannot reopen engine %s", engine.tag, engine.uuid)
+	}
+	common.AppLogger.Infof("[%s] reopen engine %s", engine.tag, engine.uuid)
+	return nil
 }
 
 // WriteStream is a single write stream into an opened engine. This type is
@@ -209,13 +475,19 @@ func (importer *Importer) OpenEngine(
 type WriteStream struct {
 	engine  *OpenedEngine
 	wstream kv.ImportKV_WriteEngineClient
+
+	// sent is the checksum of the KV pairs sent through the stream. It is
+	// added to the engine when the stream is closed without error, unless any
+	// send has failed.
+	sent       verify.KVChecksum
+	sendFailed bool
 }
 
 // NewWriteStream creates a new write engine associated with
 func (engine *OpenedEngine) NewWriteStream(ctx context.Context) (*WriteStream, error) {
-	wstream, err := engine.importer.cli.WriteEngine(ctx)
+	wstream, err := engine.cli.WriteEngine(ctx, engine.importer.writeOpts...)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, importerError(err, "[%s] cannot write to engine %s", engine.tag, engine.uuid)
 	}
 
 	// Bind uuid for this write request
@@ -231,7 +503,7 @@ func (engine *OpenedEngine) NewWriteStream(ctx context.Context) (*WriteStream, e
 			// just log the close error, we need to propagate the send error instead
 			common.AppLogger.Warnf("[%s] close write stream cause failed : %v", engine.tag, closeErr)
 		}
-		return nil, errors.Trace(err)
+		return nil, importerError(err, "[%s] cannot write to engine %s", engine.tag, engine.uuid)
 	}
 
 	return &WriteStream{
@@ -270,7 +542,15 @@ func (stream *WriteStream) Put(kvs []kvec.KvPair) error {
 		common.AppLogger.Errorf("[%s] write stream failed to send: %s", stream.engine.tag, sendErr.Error())
 		time.Sleep(retryBackoffTime)
 	}
-	return errors.Trace(sendErr)
+	if sendErr != nil {
+		stream.sendFailed = true
+		return importerError(sendErr, "[%s] cannot write to engine %s", stream.engine.tag, stream.engine.uuid)
+	}
+
+	// computed after sending from the same memory as the mutations, so any
+	// change to the KV pairs since they are encoded shows up as a mismatch.
+	stream.sent.Update(kvs)
+	return nil
 }
 
 // Close the write stream.
@@ -279,7 +559,11 @@ func (stream *WriteStream) Close() error {
 		if !common.IsContextCanceledError(err) {
 			common.AppLogger.Errorf("[%s] close write stream cause failed : %v", stream.engine.tag, err)
 		}
-		return errors.Trace(err)
+		return importerError(err, "[%s] cannot close the write stream of engine %s", stream.engine.tag, stream.engine.uuid)
+	}
+	if !stream.sendFailed {
+		stream.engine.delivered.Add(&stream.sent)
+		stream.engine.importer.addDiskUsage(stream.engine.uuid, int64(stream.sent.SumSize()))
 	}
 	return nil
 }
@@ -289,6 +573,7 @@ func (stream *WriteStream) Close() error {
 // method anywhere.
 type ClosedEngine struct {
 	importer *Importer
+	cli      kv.ImportKVClient
 	tag      string
 	uuid     uuid.UUID
 }
@@ -298,7 +583,7 @@ type ClosedEngine struct {
 func (engine *OpenedEngine) Close(ctx context.Context) (*ClosedEngine, error) {
 	common.AppLogger.Infof("[%s] [%s] engine close", engine.tag, engine.uuid)
 	timer := time.Now()
-	closedEngine, err := engine.importer.unsafeCloseEngine(ctx, engine.tag, engine.uuid)
+	closedEngine, err := engine.importer.unsafeCloseEngine(ctx, engine.cli, engine.tag, engine.uuid)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -314,21 +599,23 @@ func (engine *OpenedEngine) Close(ctx context.Context) (*ClosedEngine, error) {
 // resuming from a checkpoint.
 func (importer *Importer) UnsafeCloseEngine(ctx context.Context, tableName string, engineID int) (*ClosedEngine, error) {
 	tag := makeTag(tableName, engineID)
-	engineUUID := uuid.NewV5(engineNamespace, tag)
-	return importer.unsafeCloseEngine(ctx, tag, engineUUID)
+	engineUUID := importer.engineUUID(tag)
+	cli, _ := importer.clientOf(tableName, tag)
+	return importer.unsafeCloseEngine(ctx, cli, tag, engineUUID)
 }
 
-func (importer *Importer) unsafeCloseEngine(ctx context.Context, tag string, engineUUID uuid.UUID) (*ClosedEngine, error) {
+func (importer *Importer) unsafeCloseEngine(ctx context.Context, cli kv.ImportKVClient, tag string, engineUUID uuid.UUID) (*ClosedEngine, error) {
 	req := &kv.CloseEngineRequest{
 		Uuid: engineUUID.Bytes(),
 	}
-	_, err := importer.cli.CloseEngine(ctx, req)
+	_, err := cli.CloseEngine(ctx, req)
 	if !isIgnorableOpenCloseEngineError(err) {
-		return nil, errors.Trace(err)
+		return nil, importerError(err, "[%s] cannot close engine %s", tag, engineUUID)
 	}
 
 	return &ClosedEngine{
 		importer: importer,
+		cli:      cli,
 		tag:      tag,
 		uuid:     engineUUID,
 	}, nil
@@ -345,12 +632,13 @@ func (engine *ClosedEngine) Import(ctx context.Context) error {
 			PdAddr: engine.importer.pdAddr,
 		}
 		timer := time.Now()
-		_, err = engine.importer.cli.ImportEngine(ctx, req)
+		_, err = engine.cli.ImportEngine(ctx, req)
 		if !common.IsRetryableError(err) {
 			if err == nil {
 				common.AppLogger.Infof("[%s] [%s] import takes %v", engine.tag, engine.uuid, time.Since(timer))
 			} else if !common.IsContextCanceledError(err) {
 				common.AppLogger.Errorf("[%s] [%s] import failed and cannot retry, err %v", engine.tag, engine.uuid, err)
+				return common.ErrImportEngine.Annotatef(err, "[%s] [%s] import failed", engine.tag, engine.uuid)
 			}
 			return errors.Trace(err)
 		}
@@ -358,7 +646,10 @@ func (engine *ClosedEngine) Import(ctx context.Context) error {
 		time.Sleep(retryBackoffTime)
 	}
 
-	return errors.Annotatef(err, "[%s] [%s] import reach max retry %d and still failed", engine.tag, engine.uuid, maxRetryTimes)
+	if common.IsUnavailableError(err) {
+		return common.ErrImporterUnavailable.Annotatef(err, "[%s] [%s] import reach max retry %d and still failed", engine.tag, engine.uuid, maxRetryTimes)
+	}
+	return common.ErrImportEngine.Annotatef(err, "[%s] [%s] import reach max retry %d and still failed", engine.tag, engine.uuid, maxRetryTimes)
 }
 
 // Cleanup deletes the imported data from importer.
@@ -368,7 +659,10 @@ func (engine *ClosedEngine) Cleanup(ctx context.Context) error {
 		Uuid: engine.uuid.Bytes(),
 	}
 	timer := time.Now()
-	_, err := engine.importer.cli.CleanupEngine(ctx, req)
+	_, err := engine.cli.CleanupEngine(ctx, req)
 	common.AppLogger.Infof("[%s] [%s] cleanup takes %v", engine.tag, engine.uuid, time.Since(timer))
-	return errors.Trace(err)
+	if err == nil {
+		engine.importer.releaseDiskUsage(engine.uuid)
+	}
+	return importerError(err, "[%s] cannot clean up engine %s", engine.tag, engine.uuid)
 }
@@ -85,7 +85,7 @@ func (kvcodec *TableKVEncoder) SQL2KV(sql string) ([]kvec.KvPair, uint64, error)
 	// via sql execution
 	kvPairs, rowsAffected, err := kvcodec.encoder.Encode(sql, kvcodec.tableID)
 	if err != nil {
-		common.AppLogger.Errorf("[sql2kv] sql encode error = %v", err)
+		common.AppLogger.Errorf("[sql2kv] sql encode error = %s", common.RedactValues(err.Error()))
 		return nil, 0, errors.Trace(err)
 	}
 
@@ -0,0 +1,113 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+var (
+	// MinImporterVersion is the oldest tikv-importer supported.
+	MinImporterVersion = *semver.New("2.1.0")
+	// CompressionImporterVersion is the oldest tikv-importer accepting the
+	// gzip-compressed write streams.
+	CompressionImporterVersion = *semver.New("3.0.0")
+)
+
+// getVersionMethod is the RPC reporting the version of tikv-importer. It is
+// not included in the vendored kvproto, and older importers do not implement
+// it, so the messages are declared here.
+const getVersionMethod = "/import_kvpb.ImportKV/GetVersion"
+
+type getVersionRequest struct{}
+
+func (m *getVersionRequest) Reset()         { *m = getVersionRequest{} }
+func (m *getVersionRequest) String() string { return "{}" }
+func (*getVersionRequest) ProtoMessage()    {}
+
+type getVersionResponse struct {
+	Version string `protobuf:"bytes,1,opt,name=version,proto3"`
+	Commit  string `protobuf:"bytes,2,opt,name=commit,proto3"`
+}
+
+func (m *getVersionResponse) Reset() { *m = getVersionResponse{} }
+func (m *getVersionResponse) String() string {
+	return fmt.Sprintf("{version:%q commit:%q}", m.Version, m.Commit)
+}
+func (*getVersionResponse) ProtoMessage() {}
+
+// Capabilities are the features supported by all the tikv-importer
+// instances.
+type Capabilities struct {
+	// Version is the oldest version of the importers, or nil if any of them
+	// is too old to report its version (i.e. before 3.0).
+	Version *semver.Version
+	// Compression is whether the write streams can be compressed.
+	Compression bool
+}
+
+func capabilitiesOf(version *semver.Version) Capabilities {
+	return Capabilities{
+		Version:     version,
+		Compression: version != nil && !version.LessThan(CompressionImporterVersion),
+	}
+}
+
+// Capabilities queries the versions of the importers and returns the
+// features supported by all of them. Returns an error if any importer is
+// older than MinImporterVersion.
+func (importer *Importer) Capabilities(ctx context.Context) (Capabilities, error) {
+	var oldest *semver.Version
+	unknown := false
+	for i, conn := range importer.conns {
+		resp := new(getVersionResponse)
+		err := conn.Invoke(ctx, getVersionMethod, new(getVersionRequest), resp)
+		if status.Code(err) == codes.Unimplemented {
+			common.AppLogger.Infof("tikv-importer %s does not report its version, assuming it is older than 3.0", importer.addrs[i])
+			unknown = true
+			continue
+		}
+		if err != nil {
+			return Capabilities{}, common.ErrImporterUnavailable.Annotatef(err, "cannot get the version of tikv-importer %s", importer.addrs[i])
+		}
+
+		version, err := semver.NewVersion(strings.TrimPrefix(resp.Version, "v"))
+		if err != nil {
+			return Capabilities{}, errors.Annotatef(err, "invalid version of tikv-importer %s", importer.addrs[i])
+		}
+		common.AppLogger.Infof("tikv-importer %s is version %s (commit %s)", importer.addrs[i], version, resp.Commit)
+		if version.LessThan(MinImporterVersion) {
+			return Capabilities{}, errors.Errorf(
+				"tikv-importer (at %s) version too old, expected '>=%s', found '%s'",
+				importer.addrs[i], MinImporterVersion, version,
+			)
+		}
+		if oldest == nil || version.LessThan(*oldest) {
+			oldest = version
+		}
+	}
+	if unknown {
+		oldest = nil
+	}
+	return capabilitiesOf(oldest), nil
+}
@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"runtime"
 	"sync"
 
@@ -26,6 +27,7 @@ import (
 
 	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/glue"
 	"github.com/pingcap/tidb-lightning/lightning/kv"
 	"github.com/pingcap/tidb-lightning/lightning/mydump"
 	"github.com/pingcap/tidb-lightning/lightning/restore"
@@ -33,20 +35,33 @@ import (
 
 type Lightning struct {
 	cfg      *config.Config
+	glue     glue.Glue
 	ctx      context.Context
 	shutdown context.CancelFunc
 
 	wg sync.WaitGroup
+	// failedTables are the tables which failed in the last run.
+	failedTables []restore.FailedTable
+
+	controllerLock sync.Mutex
+	// controller is the running restore controller, or nil.
+	controller *restore.RestoreController
 }
 
 func initEnv(cfg *config.Config) error {
-	if err := common.InitLogger(&cfg.App.LogConfig, cfg.TiDB.LogLevel); err != nil {
+	common.RedactInfoLog = cfg.Security.RedactInfoLog
+	common.SlowSQLThreshold = cfg.TiDB.SlowSQLThreshold.Duration
+	if err := common.SetProxy(cfg.Proxy.URL, cfg.Proxy.NoProxy, cfg.Proxy.GRPC); err != nil {
+		return errors.Trace(err)
+	}
+	if err := common.InitLogger(&cfg.App.LogConfig, cfg.TiDB.LogLevel, cfg.TiDB.LogFile); err != nil {
 		return errors.Trace(err)
 	}
 
 	if cfg.App.ProfilePort > 0 {
 		go func() {
 			http.Handle("/metrics", promhttp.Handler())
+			http.HandleFunc("/tasks/", serveTaskState)
 			common.AppLogger.Info(http.ListenAndServe(fmt.Sprintf(":%d", cfg.App.ProfilePort), nil))
 		}()
 	}
@@ -55,12 +70,20 @@ func initEnv(cfg *config.Config) error {
 }
 
 func New(cfg *config.Config) *Lightning {
+	return NewWithGlue(cfg, nil)
+}
+
+// NewWithGlue creates a Lightning instance accessing the target TiDB through
+// the glue supplied by the embedder. If the glue is nil, Lightning connects to
+// the TiDB in the config.
+func NewWithGlue(cfg *config.Config, g glue.Glue) *Lightning {
 	initEnv(cfg)
 
 	ctx, shutdown := context.WithCancel(context.Background())
 
 	return &Lightning{
 		cfg:      cfg,
+		glue:     g,
 		ctx:      ctx,
 		shutdown: shutdown,
 	}
@@ -80,7 +103,13 @@ func (l *Lightning) Run() error {
 	var err error
 	go func() {
 		defer l.wg.Done()
-		err = l.run()
+		if len(l.cfg.App.ControlAddr) > 0 {
+			err = l.runControlServer()
+		} else {
+			registerTask(standaloneTaskID, l)
+			defer unregisterTask(standaloneTaskID)
+			err = l.run()
+		}
 	}()
 	l.wg.Wait()
 	return errors.Trace(err)
@@ -115,25 +144,65 @@ func (l *Lightning) handleCommandFlagsAndExits() (exits bool) {
 }
 
 func (l *Lightning) run() error {
+	remote := &l.cfg.Mydumper.Remote
+	mydump.SetRemoteLimits(remote.Concurrency, remote.Retry, remote.MaxBandwidth)
+
+	if err := l.splitDumpFile(); err != nil {
+		common.AppLogger.Errorf("failed to split the dump file : %s", errors.ErrorStack(err))
+		return common.ErrInvalidSource.Wrap(err)
+	}
+
 	mdl, err := mydump.NewMyDumpLoader(l.cfg)
 	if err != nil {
 		common.AppLogger.Errorf("failed to load mydumper source : %s", errors.ErrorStack(err))
-		return errors.Trace(err)
+		return common.ErrInvalidSource.Wrap(err)
 	}
 
 	dbMetas := mdl.GetDatabases()
-	procedure, err := restore.NewRestoreController(l.ctx, dbMetas, l.cfg)
+	if len(l.cfg.Offline.OutputDir) > 0 {
+		return errors.Trace(restore.RunOffline(l.ctx, dbMetas, l.cfg))
+	}
+
+	procedure, err := restore.NewRestoreController(l.ctx, dbMetas, l.cfg, l.glue)
 	if err != nil {
 		common.AppLogger.Errorf("failed to restore : %s", errors.ErrorStack(err))
 		return errors.Trace(err)
 	}
 	defer procedure.Close()
+	procedure.SetPrivilegeTables(mdl.GetPrivilegeTables())
+	l.setController(procedure)
+	defer l.setController(nil)
 
 	err = procedure.Run(l.ctx)
 	procedure.Wait()
+	l.failedTables = procedure.FailedTables()
 	return errors.Trace(err)
 }
 
+// splitDumpFile splits the data source into a directory if it is a single
+// file produced by mysqldump, and uses the directory as the data source.
+func (l *Lightning) splitDumpFile() error {
+	source := l.cfg.Mydumper.SourceDir
+	if mydump.IsRemotePath(source) {
+		return nil
+	}
+	info, err := os.Stat(source)
+	if err != nil || info.IsDir() {
+		// a missing directory is reported by the loader.
+		return nil
+	}
+
+	splitDir := l.cfg.Mydumper.SplitDir
+	if len(splitDir) == 0 {
+		splitDir = source + ".split"
+	}
+	if err := mydump.SplitDumpFile(source, splitDir); err != nil {
+		return errors.Trace(err)
+	}
+	l.cfg.Mydumper.SourceDir = splitDir
+	return nil
+}
+
 func (l *Lightning) doCompact() error {
 	ctx := context.Background()
 
@@ -43,40 +43,68 @@ const (
 )
 
 var (
+	EngineCounter                        *prometheus.CounterVec
+	IdleWorkersGauge                     *prometheus.GaugeVec
+	KvEncoderCounter                     *prometheus.CounterVec
+	TableCounter                         *prometheus.CounterVec
+	ChunkCounter                         *prometheus.CounterVec
+	ImportSecondsHistogram               prometheus.Histogram
+	BlockReadSecondsHistogram            prometheus.Histogram
+	BlockReadBytesHistogram              prometheus.Histogram
+	ChunkParserReadBlockSecondsHistogram prometheus.Histogram
+	ChunkParserReadRowSecondsHistogram   prometheus.Histogram
+	ApplyWorkerSecondsHistogram          *prometheus.HistogramVec
+	BlockEncodeSecondsHistogram          prometheus.Histogram
+	BlockDeliverSecondsHistogram         prometheus.Histogram
+	BlockDeliverBytesHistogram           prometheus.Histogram
+	BlockDeliverWaitSecondsHistogram     prometheus.Histogram
+	DeliverQueueKVsGauge                 prometheus.Gauge
+	ChecksumSecondsHistogram             prometheus.Histogram
+	TableStepSecondsHistogram            *prometheus.HistogramVec
+)
+
+// initMetrics creates all the metrics, with the constant labels attached to
+// each of them.
+func initMetrics(constLabels prometheus.Labels) {
 	EngineCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "lightning",
-			Name:      "importer_engine",
-			Help:      "counting open and closed importer engines",
+			Namespace:   "lightning",
+			Name:        "importer_engine",
+			Help:        "counting open and closed importer engines",
+			ConstLabels: constLabels,
 		}, []string{"type"})
 
 	IdleWorkersGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Namespace: "lightning",
-			Name:      "idle_workers",
-			Help:      "counting idle workers",
+			Namespace:   "lightning",
+			Name:        "idle_workers",
+			Help:        "counting idle workers",
+			ConstLabels: constLabels,
 		}, []string{"name"})
 
 	KvEncoderCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "lightning",
-			Name:      "kv_encoder",
-			Help:      "counting kv open and closed kv encoder",
+			Namespace:   "lightning",
+			Name:        "kv_encoder",
+			Help:        "counting kv open and closed kv encoder",
+			ConstLabels: constLabels,
 		}, []string{"type"},
 	)
 
 	TableCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "lightning",
-			Name:      "tables",
-			Help:      "count number of tables processed",
+			Namespace:   "lightning",
+			Name:        "tables",
+			Help:        "count number of tables processed",
+			ConstLabels: constLabels,
 		}, []string{"state", "result"})
 
 	ChunkCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "lightning",
-			Name:      "chunks",
-			Help:      "count number of chunks processed",
+			Namespace:   "lightning",
+			Name:        "chunks",
+			Help:        "count number of chunks processed",
+			ConstLabels: constLabels,
 		}, []string{"state"})
 	// state can be one of:
 	//  - estimated (an estimation derived from the file size)
@@ -87,102 +115,166 @@ var (
 
 	ImportSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "import_seconds",
-			Help:      "time needed to import a table",
-			Buckets:   prometheus.ExponentialBuckets(0.125, 2, 6),
+			Namespace:   "lightning",
+			Name:        "import_seconds",
+			Help:        "time needed to import a table",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.125, 2, 6),
 		},
 	)
 	BlockReadSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "block_read_seconds",
-			Help:      "time needed to read a block",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 7),
+			Namespace:   "lightning",
+			Name:        "block_read_seconds",
+			Help:        "time needed to read a block",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 7),
 		},
 	)
 	BlockReadBytesHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "block_read_bytes",
-			Help:      "number of bytes being read out from data source",
-			Buckets:   prometheus.ExponentialBuckets(1024, 2, 8),
+			Namespace:   "lightning",
+			Name:        "block_read_bytes",
+			Help:        "number of bytes being read out from data source",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1024, 2, 8),
 		},
 	)
 	ChunkParserReadBlockSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "chunk_parser_read_block_seconds",
-			Help:      "time needed for chunk parser read a block",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
+			Namespace:   "lightning",
+			Name:        "chunk_parser_read_block_seconds",
+			Help:        "time needed for chunk parser read a block",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
 		},
 	)
 	ChunkParserReadRowSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "chunk_parser_read_row_seconds",
-			Help:      "time needed for chunk parser read a row",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
+			Namespace:   "lightning",
+			Name:        "chunk_parser_read_row_seconds",
+			Help:        "time needed for chunk parser read a row",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
 		},
 	)
 	ApplyWorkerSecondsHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "apply_worker_seconds",
-			Help:      "time needed to apply a worker",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
+			Namespace:   "lightning",
+			Name:        "apply_worker_seconds",
+			Help:        "time needed to apply a worker",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
 		}, []string{"name"},
 	)
 	BlockEncodeSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "block_encode_seconds",
-			Help:      "time needed to encode a block",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
+			Namespace:   "lightning",
+			Name:        "block_encode_seconds",
+			Help:        "time needed to encode a block",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
 		},
 	)
 	BlockDeliverSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "block_deliver_seconds",
-			Help:      "time needed to deliver a block",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
+			Namespace:   "lightning",
+			Name:        "block_deliver_seconds",
+			Help:        "time needed to deliver a block",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
 		},
 	)
 	BlockDeliverBytesHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "block_deliver_bytes",
-			Help:      "number of bytes being sent out to importer",
-			Buckets:   prometheus.ExponentialBuckets(512, 2, 10),
+			Namespace:   "lightning",
+			Name:        "block_deliver_bytes",
+			Help:        "number of bytes being sent out to importer",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(512, 2, 10),
+		},
+	)
+	BlockDeliverWaitSecondsHistogram = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace:   "lightning",
+			Name:        "block_deliver_wait_seconds",
+			Help:        "time an encoded block waits for the deliverer to catch up before being queued",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 3.1622776601683795, 10),
+		},
+	)
+	DeliverQueueKVsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   "lightning",
+			Name:        "deliver_queue_kvs",
+			Help:        "number of encoded KV pairs waiting to be delivered",
+			ConstLabels: constLabels,
 		},
 	)
 	ChecksumSecondsHistogram = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
-			Namespace: "lightning",
-			Name:      "checksum_seconds",
-			Help:      "time needed to complete the checksum stage",
-			Buckets:   prometheus.ExponentialBuckets(1, 2.2679331552660544, 10),
+			Namespace:   "lightning",
+			Name:        "checksum_seconds",
+			Help:        "time needed to complete the checksum stage",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(1, 2.2679331552660544, 10),
 		},
 	)
-)
+	TableStepSecondsHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   "lightning",
+			Name:        "table_step_seconds",
+			Help:        "time spent in each step of restoring a table, summed over its chunks",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.1, 3.1622776601683795, 12),
+		}, []string{"step"},
+	)
+}
+
+// collectors returns all the metrics, in the order they are registered.
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		IdleWorkersGauge,
+		EngineCounter,
+		KvEncoderCounter,
+		TableCounter,
+		ChunkCounter,
+		ImportSecondsHistogram,
+		BlockReadSecondsHistogram,
+		BlockReadBytesHistogram,
+		BlockEncodeSecondsHistogram,
+		BlockDeliverSecondsHistogram,
+		BlockDeliverBytesHistogram,
+		BlockDeliverWaitSecondsHistogram,
+		DeliverQueueKVsGauge,
+		ChecksumSecondsHistogram,
+		ChunkParserReadRowSecondsHistogram,
+		ChunkParserReadBlockSecondsHistogram,
+		ApplyWorkerSecondsHistogram,
+		TableStepSecondsHistogram,
+	}
+}
 
 func init() {
-	prometheus.MustRegister(IdleWorkersGauge)
-	prometheus.MustRegister(EngineCounter)
-	prometheus.MustRegister(KvEncoderCounter)
-	prometheus.MustRegister(TableCounter)
-	prometheus.MustRegister(ChunkCounter)
-	prometheus.MustRegister(ImportSecondsHistogram)
-	prometheus.MustRegister(BlockReadSecondsHistogram)
-	prometheus.MustRegister(BlockReadBytesHistogram)
-	prometheus.MustRegister(BlockEncodeSecondsHistogram)
-	prometheus.MustRegister(BlockDeliverSecondsHistogram)
-	prometheus.MustRegister(BlockDeliverBytesHistogram)
-	prometheus.MustRegister(ChecksumSecondsHistogram)
-	prometheus.MustRegister(ChunkParserReadRowSecondsHistogram)
-	prometheus.MustRegister(ChunkParserReadBlockSecondsHistogram)
-	prometheus.MustRegister(ApplyWorkerSecondsHistogram)
+	initMetrics(nil)
+	for _, c := range collectors() {
+		prometheus.MustRegister(c)
+	}
+}
+
+// ResetForTask replaces all the metrics by new ones labelled by the task ID,
+// so that the metrics of the tasks run by a server one after another are not
+// blended together. The metrics of the previous task are unregistered.
+//
+// This function must not be called while a task is running.
+func ResetForTask(taskID string) {
+	for _, c := range collectors() {
+		prometheus.Unregister(c)
+	}
+	initMetrics(prometheus.Labels{"task": taskID})
+	for _, c := range collectors() {
+		prometheus.MustRegister(c)
+	}
 }
 
 func RecordTableCount(status string, err error) {
@@ -0,0 +1,845 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+/*
+	Avro object container files
+
+The file starts with a header containing the schema, followed by blocks of
+records. Each block is `count, size, data, sync`, where `data` may be
+compressed. See <https://avro.apache.org/docs/1.8.2/spec.html#Object+Container+Files>.
+
+The records inside a block cannot be located without decoding the whole
+block, therefore the position reported by the parser is a "virtual" offset:
+`blockStart + i` after reading the i-th record of a block (`blockEnd` after
+the last one). This keeps the position increasing and less than the next
+block, and the parser can resume from it by skipping the blocks before it and
+then the records before it.
+*/
+
+const avroSuffix = ".avro"
+
+// IsAvroFile returns whether the data file is an Avro object container file.
+func IsAvroFile(path string) bool {
+	return strings.HasSuffix(path, avroSuffix)
+}
+
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+const avroSyncSize = 16
+
+// avroSchema is a parsed Avro schema. Named types are resolved when parsing.
+type avroSchema struct {
+	kind     string
+	logical  string
+	scale    int
+	size     int
+	fields   []avroField
+	symbols  []string
+	items    *avroSchema
+	branches []*avroSchema
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+func parseAvroSchema(data []byte) (*avroSchema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Annotate(err, "invalid avro schema")
+	}
+	return (&avroSchemaParser{named: make(map[string]*avroSchema)}).parse(raw, "")
+}
+
+type avroSchemaParser struct {
+	named map[string]*avroSchema
+}
+
+func (p *avroSchemaParser) register(obj map[string]interface{}, namespace string, schema *avroSchema) string {
+	name, _ := obj["name"].(string)
+	if ns, ok := obj["namespace"].(string); ok {
+		namespace = ns
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		namespace = name[:i]
+	}
+	p.named[name] = schema
+	if len(namespace) > 0 && !strings.Contains(name, ".") {
+		p.named[namespace+"."+name] = schema
+	}
+	return namespace
+}
+
+func (p *avroSchemaParser) parse(raw interface{}, namespace string) (*avroSchema, error) {
+	switch v := raw.(type) {
+	case string:
+		switch v {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{kind: v}, nil
+		}
+		if schema, ok := p.named[v]; ok {
+			return schema, nil
+		}
+		if schema, ok := p.named[namespace+"."+v]; ok {
+			return schema, nil
+		}
+		return nil, errors.Errorf("unknown avro type %q", v)
+
+	case []interface{}:
+		schema := &avroSchema{kind: "union"}
+		for _, branch := range v {
+			s, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			schema.branches = append(schema.branches, s)
+		}
+		return schema, nil
+
+	case map[string]interface{}:
+		kind, ok := v["type"].(string)
+		if !ok {
+			// e.g. {"type": {"type": "array", ...}}
+			return p.parse(v["type"], namespace)
+		}
+		schema := &avroSchema{kind: kind}
+		schema.logical, _ = v["logicalType"].(string)
+		if scale, ok := v["scale"].(float64); ok {
+			schema.scale = int(scale)
+		}
+
+		switch kind {
+		case "record", "error":
+			schema.kind = "record"
+			ns := p.register(v, namespace, schema)
+			fields, _ := v["fields"].([]interface{})
+			for _, f := range fields {
+				field, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("invalid avro record field")
+				}
+				fieldSchema, err := p.parse(field["type"], ns)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				name, _ := field["name"].(string)
+				schema.fields = append(schema.fields, avroField{name: name, schema: fieldSchema})
+			}
+		case "enum":
+			p.register(v, namespace, schema)
+			symbols, _ := v["symbols"].([]interface{})
+			for _, symbol := range symbols {
+				s, _ := symbol.(string)
+				schema.symbols = append(schema.symbols, s)
+			}
+		case "fixed":
+			p.register(v, namespace, schema)
+			size, _ := v["size"].(float64)
+			schema.size = int(size)
+		case "array":
+			items, err := p.parse(v["items"], namespace)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			schema.items = items
+		case "map":
+			values, err := p.parse(v["values"], namespace)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			schema.items = values
+		default:
+			// primitive types with logical types, e.g. {"type": "long", "logicalType": "timestamp-millis"}
+			base, err := p.parse(kind, namespace)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if base.kind != kind {
+				return base, nil
+			}
+		}
+		return schema, nil
+
+	default:
+		return nil, errors.Errorf("invalid avro schema %v", raw)
+	}
+}
+
+// avroNumber is a decoded value which should be written as a numeric literal,
+// e.g. a decimal.
+type avroNumber string
+
+func (n avroNumber) MarshalJSON() ([]byte, error) {
+	return []byte(n), nil
+}
+
+// avroDecoder decodes the binary encoded values of a block.
+type avroDecoder struct {
+	data []byte
+	pos  int
+}
+
+var errAvroTruncated = errors.New("avro data is truncated")
+
+func (d *avroDecoder) readLong() (int64, error) {
+	var u uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if d.pos >= len(d.data) {
+			return 0, errAvroTruncated
+		}
+		b := d.data[d.pos]
+		d.pos++
+		u |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, errors.New("avro varint overflows")
+}
+
+func (d *avroDecoder) readN(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errAvroTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *avroDecoder) readBytes() ([]byte, error) {
+	n, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	return d.readN(int(n))
+}
+
+// readBlockCount reads the item count of a block of an array or map.
+func (d *avroDecoder) readBlockCount() (int64, error) {
+	count, err := d.readLong()
+	if err != nil || count >= 0 {
+		return count, err
+	}
+	// a negative count is followed by the byte size of the block.
+	_, err = d.readLong()
+	return -count, err
+}
+
+func (d *avroDecoder) decode(s *avroSchema) (interface{}, error) {
+	switch s.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.readN(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		return convertAvroInteger(s.logical, v), nil
+	case "float":
+		b, err := d.readN(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := d.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes", "fixed":
+		var b []byte
+		var err error
+		if s.kind == "fixed" {
+			b, err = d.readN(s.size)
+		} else {
+			b, err = d.readBytes()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.logical == "decimal" {
+			return avroDecimal(b, s.scale), nil
+		}
+		return append([]byte(nil), b...), nil
+	case "string":
+		b, err := d.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case "enum":
+		i, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.symbols) {
+			return nil, errors.Errorf("avro enum index %d out of range", i)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.branches) {
+			return nil, errors.Errorf("avro union index %d out of range", i)
+		}
+		return d.decode(s.branches[i])
+	case "array":
+		items := []interface{}{}
+		for {
+			count, err := d.readBlockCount()
+			if err != nil || count == 0 {
+				return items, err
+			}
+			for ; count > 0; count-- {
+				item, err := d.decode(s.items)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+		}
+	case "map":
+		values := make(map[string]interface{})
+		for {
+			count, err := d.readBlockCount()
+			if err != nil || count == 0 {
+				return values, err
+			}
+			for ; count > 0; count-- {
+				key, err := d.readBytes()
+				if err != nil {
+					return nil, err
+				}
+				value, err := d.decode(s.items)
+				if err != nil {
+					return nil, err
+				}
+				values[string(key)] = value
+			}
+		}
+	case "record":
+		values := make(map[string]interface{}, len(s.fields))
+		for _, field := range s.fields {
+			value, err := d.decode(field.schema)
+			if err != nil {
+				return nil, err
+			}
+			values[field.name] = value
+		}
+		return values, nil
+	default:
+		return nil, errors.Errorf("unsupported avro type %q", s.kind)
+	}
+}
+
+// convertAvroInteger applies the logical types based on int or long. Dates and
+// times are converted to strings in the MySQL format.
+func convertAvroInteger(logical string, v int64) interface{} {
+	switch logical {
+	case "date":
+		return time.Unix(v*86400, 0).UTC().Format("2006-01-02")
+	case "time-millis":
+		return formatAvroTime(time.Duration(v) * time.Millisecond)
+	case "time-micros":
+		return formatAvroTime(time.Duration(v) * time.Microsecond)
+	case "timestamp-millis":
+		return time.Unix(0, v*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04:05.000")
+	case "timestamp-micros":
+		return time.Unix(0, v*int64(time.Microsecond)).UTC().Format("2006-01-02 15:04:05.000000")
+	default:
+		return v
+	}
+}
+
+func formatAvroTime(d time.Duration) string {
+	micros := int64(d / time.Microsecond)
+	return strings.TrimSuffix(strings.TrimRight(
+		strconv.FormatInt(micros/3600e6, 10)+":"+
+			twoDigits(micros/60e6%60)+":"+
+			twoDigits(micros/1e6%60)+"."+
+			strconv.FormatInt(1e6+micros%1e6, 10)[1:],
+		"0"), ".")
+}
+
+func twoDigits(v int64) string {
+	return strconv.FormatInt(100+v, 10)[1:]
+}
+
+// avroDecimal converts the big-endian two's complement unscaled value into a
+// decimal literal.
+func avroDecimal(b []byte, scale int) avroNumber {
+	unscaled := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	digits := unscaled.String()
+	if scale <= 0 {
+		return avroNumber(digits)
+	}
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return avroNumber(sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:])
+}
+
+// writeAvroValue writes a decoded value as an SQL literal.
+func writeAvroValue(buf *bytes.Buffer, value interface{}, noBackslashEscapes bool) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("NULL")
+	case bool:
+		if v {
+			buf.WriteByte('1')
+		} else {
+			buf.WriteByte('0')
+		}
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return errors.Errorf("cannot import float value %v", v)
+		}
+		buf.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.Errorf("cannot import double value %v", v)
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case avroNumber:
+		buf.WriteString(string(v))
+	case string:
+		writeSQLString(buf, v, noBackslashEscapes)
+	case []byte:
+		buf.WriteString("X'")
+		buf.WriteString(hex.EncodeToString(v))
+		buf.WriteByte('\'')
+	default:
+		// arrays, maps and records are stored as JSON text.
+		text, err := json.Marshal(v)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		writeSQLString(buf, string(text), noBackslashEscapes)
+	}
+	return nil
+}
+
+// avroFileReader reads the header and blocks of an object container file,
+// keeping track of the file offset.
+type avroFileReader struct {
+	r      *bufio.Reader
+	offset int64
+	schema *avroSchema
+	codec  string
+	sync   [avroSyncSize]byte
+	data   []byte
+}
+
+func (r *avroFileReader) readByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+func (r *avroFileReader) readFull(buf []byte) error {
+	n, err := io.ReadFull(r.r, buf)
+	r.offset += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (r *avroFileReader) readLong() (int64, error) {
+	var u uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		u |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, errors.New("avro varint overflows")
+}
+
+func (r *avroFileReader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.Errorf("invalid avro length %d", n)
+	}
+	b := make([]byte, n)
+	return b, r.readFull(b)
+}
+
+func (r *avroFileReader) readHeader() error {
+	var magic [4]byte
+	if err := r.readFull(magic[:]); err != nil {
+		return errors.Annotate(err, "cannot read avro header")
+	}
+	if !bytes.Equal(magic[:], avroMagic) {
+		return errors.New("not an avro object container file")
+	}
+
+	meta := make(map[string][]byte)
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			count = -count
+			if _, err := r.readLong(); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		for ; count > 0; count-- {
+			key, err := r.readBytes()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			value, err := r.readBytes()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			meta[string(key)] = value
+		}
+	}
+	if err := r.readFull(r.sync[:]); err != nil {
+		return errors.Trace(err)
+	}
+
+	schema, err := parseAvroSchema(meta["avro.schema"])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if schema.kind != "record" {
+		return errors.Errorf("the avro schema must be a record, not %s", schema.kind)
+	}
+	r.schema = schema
+
+	r.codec = string(meta["avro.codec"])
+	switch r.codec {
+	case "", "null", "deflate":
+	default:
+		return errors.Errorf("unsupported avro codec %q", r.codec)
+	}
+	return nil
+}
+
+// readBlockHeader reads the record count and data size of the next block, or
+// returns io.EOF if there are no more blocks.
+func (r *avroFileReader) readBlockHeader() (count int64, size int64, err error) {
+	if _, err = r.r.Peek(1); err == io.EOF {
+		return 0, 0, io.EOF
+	}
+	if count, err = r.readLong(); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if size, err = r.readLong(); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if count < 0 || size < 0 {
+		return 0, 0, errors.Errorf("invalid avro block at offset %d", r.offset)
+	}
+	return count, size, nil
+}
+
+func (r *avroFileReader) checkSync() error {
+	var sync [avroSyncSize]byte
+	if err := r.readFull(sync[:]); err != nil {
+		return errors.Trace(err)
+	}
+	if sync != r.sync {
+		return errors.Errorf("avro sync marker mismatch at offset %d", r.offset-avroSyncSize)
+	}
+	return nil
+}
+
+// readBlockData reads and decompresses the data of the current block.
+func (r *avroFileReader) readBlockData(size int64) ([]byte, error) {
+	if int64(cap(r.data)) < size {
+		r.data = make([]byte, size)
+	}
+	data := r.data[:size]
+	if err := r.readFull(data); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := r.checkSync(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if r.codec == "deflate" {
+		inflated, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot inflate avro block")
+		}
+		return inflated, nil
+	}
+	return data, nil
+}
+
+func (r *avroFileReader) skipBlockData(size int64) error {
+	n, err := r.r.Discard(int(size))
+	r.offset += int64(n)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(r.checkSync())
+}
+
+// CountAvroRows returns the number of records in the Avro file, by reading
+// only the block headers.
+func CountAvroRows(path string) (int64, error) {
+	file, err := OpenDataFile(path)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer file.Close()
+
+	r := &avroFileReader{r: bufio.NewReader(file)}
+	if err := r.readHeader(); err != nil {
+		return 0, errors.Trace(err)
+	}
+	var rows int64
+	for {
+		count, size, err := r.readBlockHeader()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		if err := r.skipBlockData(size); err != nil {
+			return 0, errors.Trace(err)
+		}
+		rows += count
+	}
+}
+
+// AvroParser is a parser of the Avro object container files. The top-level
+// record fields are mapped to the table columns by name.
+type AvroParser struct {
+	reader io.Reader
+	file   avroFileReader
+
+	pos        int64
+	lastRow    Row
+	rowBuf     bytes.Buffer
+	columns    []byte
+	headerRead bool
+
+	blockStart int64
+	blockEnd   int64
+	blockCount int64
+	blockIndex int64
+	decoder    avroDecoder
+
+	noBackslashEscapes bool
+}
+
+// NewAvroParser creates a new parser of an Avro file. The reader must be
+// positioned at the start of the file, since the header is always needed.
+func NewAvroParser(reader io.Reader, blockBufSize int64, ioWorkers *worker.Pool) *AvroParser {
+	return &AvroParser{
+		reader: reader,
+		file: avroFileReader{
+			r: bufio.NewReaderSize(&ioWorkerReader{reader: reader, ioWorkers: ioWorkers}, int(blockBufSize)),
+		},
+	}
+}
+
+// SetSQLMode changes how the strings are escaped, which must match the SQL
+// mode used to encode the rows.
+func (parser *AvroParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.noBackslashEscapes = mode.HasNoBackslashEscapesMode()
+}
+
+// Reader returns the underlying reader of this parser.
+func (parser *AvroParser) Reader() io.Reader {
+	return parser.reader
+}
+
+// SetPos changes the reported position and row ID. The parser skips to the
+// position when reading the next row.
+func (parser *AvroParser) SetPos(pos int64, rowID int64) {
+	parser.pos = pos
+	parser.lastRow.RowID = rowID
+}
+
+// Pos returns the (virtual) offset of the next row.
+func (parser *AvroParser) Pos() int64 {
+	return parser.pos
+}
+
+// LastRow is the row parsed by the last call to ReadRow(). The content is
+// only valid until the next call to ReadRow().
+func (parser *AvroParser) LastRow() Row {
+	return parser.lastRow
+}
+
+// Columns is the list of the top-level record fields. It is only available
+// after the first call to ReadRow().
+func (parser *AvroParser) Columns() []byte {
+	return parser.columns
+}
+
+func (parser *AvroParser) readHeader() error {
+	if err := parser.file.readHeader(); err != nil {
+		return errors.Trace(err)
+	}
+	parser.headerRead = true
+
+	var columns strings.Builder
+	columns.WriteByte('(')
+	for i, field := range parser.file.schema.fields {
+		if i > 0 {
+			columns.WriteByte(',')
+		}
+		common.WriteMySQLIdentifier(&columns, field.name)
+	}
+	columns.WriteByte(')')
+	parser.columns = []byte(columns.String())
+	return nil
+}
+
+// nextBlock moves to the block containing the position `parser.pos`.
+func (parser *AvroParser) nextBlock() error {
+	for {
+		parser.blockStart = parser.file.offset
+		count, size, err := parser.file.readBlockHeader()
+		if err != nil {
+			return err
+		}
+		headerSize := parser.file.offset - parser.blockStart
+		parser.blockEnd = parser.file.offset + size + avroSyncSize
+		if count-1 >= headerSize+size+avroSyncSize {
+			return errors.Errorf("too many records in the avro block at offset %d", parser.blockStart)
+		}
+
+		if parser.pos >= parser.blockEnd {
+			if err := parser.file.skipBlockData(size); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+
+		data, err := parser.file.readBlockData(size)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		parser.decoder = avroDecoder{data: data}
+		parser.blockCount = count
+		parser.blockIndex = 0
+
+		// skip the records already read before resuming.
+		for ; parser.blockIndex < parser.pos-parser.blockStart && parser.blockIndex < count; parser.blockIndex++ {
+			if _, err := parser.decoder.decode(parser.file.schema); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if parser.blockIndex < count {
+			return nil
+		}
+	}
+}
+
+// ReadRow reads the next record from the data file.
+func (parser *AvroParser) ReadRow() error {
+	if !parser.headerRead {
+		if err := parser.readHeader(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if parser.blockIndex >= parser.blockCount {
+		if err := parser.nextBlock(); err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return errors.Trace(err)
+		}
+	}
+
+	parser.rowBuf.Reset()
+	parser.rowBuf.WriteByte('(')
+	for i, field := range parser.file.schema.fields {
+		if i > 0 {
+			parser.rowBuf.WriteByte(',')
+		}
+		value, err := parser.decoder.decode(field.schema)
+		if err != nil {
+			return errors.Annotatef(err, "cannot decode avro record at offset %d", parser.blockStart)
+		}
+		if err := writeAvroValue(&parser.rowBuf, value, parser.noBackslashEscapes); err != nil {
+			return errors.Annotatef(err, "field %s", field.name)
+		}
+	}
+	parser.rowBuf.WriteByte(')')
+
+	parser.blockIndex++
+	if parser.blockIndex < parser.blockCount {
+		parser.pos = parser.blockStart + parser.blockIndex
+	} else {
+		parser.pos = parser.blockEnd
+	}
+	parser.lastRow.RowID++
+	parser.lastRow.Row = parser.rowBuf.Bytes()
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testAvroParserSuite{})
+
+type testAvroParserSuite struct{}
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "t",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+		{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "note", "type": ["null", "string"]}
+	]
+}`
+
+var testAvroSync = []byte("0123456789abcdef")
+
+// avroEncoder writes the Avro binary encoding.
+type avroEncoder struct {
+	bytes.Buffer
+}
+
+func (e *avroEncoder) long(v int64) {
+	u := uint64(v<<1) ^ uint64(v>>63)
+	for u >= 0x80 {
+		e.WriteByte(byte(u) | 0x80)
+		u >>= 7
+	}
+	e.WriteByte(byte(u))
+}
+
+func (e *avroEncoder) bytes(b []byte) {
+	e.long(int64(len(b)))
+	e.Write(b)
+}
+
+func (e *avroEncoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+type testAvroBlock struct {
+	count int64
+	data  []byte
+}
+
+// makeAvroFile creates an object container file, returning its content and
+// the offset of each block.
+func makeAvroFile(c *C, codec string, blocks []testAvroBlock) ([]byte, []int64) {
+	var e avroEncoder
+	e.Write([]byte{'O', 'b', 'j', 1})
+	e.long(2)
+	e.string("avro.schema")
+	e.string(testAvroSchema)
+	e.string("avro.codec")
+	e.string(codec)
+	e.long(0)
+	e.Write(testAvroSync)
+
+	offsets := make([]int64, 0, len(blocks))
+	for _, block := range blocks {
+		data := block.data
+		if codec == "deflate" {
+			var compressed bytes.Buffer
+			w, err := flate.NewWriter(&compressed, flate.BestCompression)
+			c.Assert(err, IsNil)
+			w.Write(data)
+			c.Assert(w.Close(), IsNil)
+			data = compressed.Bytes()
+		}
+		offsets = append(offsets, int64(e.Len()))
+		e.long(block.count)
+		e.bytes(data)
+		e.Write(testAvroSync)
+	}
+	return e.Bytes(), offsets
+}
+
+func makeTestAvroRecords() []testAvroBlock {
+	var first, second avroEncoder
+
+	first.long(1)
+	first.string("it's")
+	first.bytes([]byte{0x30, 0x39})
+	first.long(1546300800123)
+	first.long(2)
+	first.string("a")
+	first.string("b")
+	first.long(0)
+	first.long(0)
+
+	first.long(-2)
+	first.string(`C:\tmp`)
+	first.bytes([]byte{0xff, 0x85})
+	first.long(0)
+	first.long(0)
+	first.long(1)
+	first.string("x")
+
+	second.long(3)
+	second.string("")
+	second.bytes([]byte{0x05})
+	second.long(-1)
+	second.long(0)
+	second.long(0)
+
+	return []testAvroBlock{
+		{count: 2, data: first.Bytes()},
+		{count: 1, data: second.Bytes()},
+	}
+}
+
+func (s *testAvroParserSuite) TestReadRow(c *C) {
+	for _, codec := range []string{"null", "deflate"} {
+		data, offsets := makeAvroFile(c, codec, makeTestAvroRecords())
+
+		ioWorkers := worker.NewPool(context.Background(), 5, "test")
+		parser := mydump.NewAvroParser(bytes.NewReader(data), config.ReadBlockSize, ioWorkers)
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.Columns(), DeepEquals, []byte("(`id`,`name`,`price`,`ts`,`tags`,`note`)"))
+		c.Assert(parser.LastRow().RowID, Equals, int64(1))
+		c.Assert(string(parser.LastRow().Row), Equals, `(1,'it''s',123.45,'2019-01-01 00:00:00.123','["a","b"]',NULL)`)
+		c.Assert(parser.Pos(), Equals, offsets[0]+1)
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.LastRow().RowID, Equals, int64(2))
+		c.Assert(string(parser.LastRow().Row), Equals, `(-2,'C:\\tmp',-1.23,'1970-01-01 00:00:00.000','[]','x')`)
+		c.Assert(parser.Pos(), Equals, offsets[1])
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.LastRow().RowID, Equals, int64(3))
+		c.Assert(string(parser.LastRow().Row), Equals, `(3,'',0.05,'1969-12-31 23:59:59.999','[]',NULL)`)
+		c.Assert(parser.Pos(), Equals, int64(len(data)))
+
+		c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+	}
+}
+
+func (s *testAvroParserSuite) TestResume(c *C) {
+	data, offsets := makeAvroFile(c, "deflate", makeTestAvroRecords())
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+
+	parser := mydump.NewAvroParser(bytes.NewReader(data), config.ReadBlockSize, ioWorkers)
+	parser.SetPos(offsets[0]+1, 1)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().RowID, Equals, int64(2))
+	c.Assert(string(parser.LastRow().Row), Matches, `\(-2,.*`)
+	c.Assert(parser.Pos(), Equals, offsets[1])
+
+	parser = mydump.NewAvroParser(bytes.NewReader(data), config.ReadBlockSize, ioWorkers)
+	parser.SetPos(offsets[1], 2)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().RowID, Equals, int64(3))
+	c.Assert(string(parser.LastRow().Row), Matches, `\(3,.*`)
+
+	parser = mydump.NewAvroParser(bytes.NewReader(data), config.ReadBlockSize, ioWorkers)
+	parser.SetPos(int64(len(data)), 3)
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testAvroParserSuite) TestCountRows(c *C) {
+	data, _ := makeAvroFile(c, "deflate", makeTestAvroRecords())
+	path := filepath.Join(c.MkDir(), "db.t.avro")
+	c.Assert(ioutil.WriteFile(path, data, 0644), IsNil)
+
+	c.Assert(mydump.IsAvroFile(path), IsTrue)
+	rows, err := mydump.CountAvroRows(path)
+	c.Assert(err, IsNil)
+	c.Assert(rows, Equals, int64(3))
+}
+
+func (s *testAvroParserSuite) TestInvalidFile(c *C) {
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+
+	parser := mydump.NewAvroParser(bytes.NewReader([]byte("INSERT INTO t VALUES (1);")), config.ReadBlockSize, ioWorkers)
+	c.Assert(parser.ReadRow(), ErrorMatches, "not an avro object container file")
+
+	data, _ := makeAvroFile(c, "null", makeTestAvroRecords())
+	data[len(data)-1] ^= 0xff
+	parser = mydump.NewAvroParser(bytes.NewReader(data), config.ReadBlockSize, ioWorkers)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.ReadRow(), ErrorMatches, "avro sync marker mismatch.*")
+}
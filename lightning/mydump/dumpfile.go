@@ -0,0 +1,490 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+// splitDoneFileName is created in the split directory after the dump file is
+// completely split, so an interrupted import can reuse the split files.
+const splitDoneFileName = ".lightning-split-done"
+
+// mysqldump writes the database name in the header when dumping a single
+// database, which contains no CREATE DATABASE or USE statements.
+var dumpHeaderDatabaseRegexp = regexp.MustCompile(`(?m)^-- Host: .*\bDatabase: (\S+)\s*$`)
+
+// SplitDumpFile splits a single SQL file produced by mysqldump into the
+// mydumper directory layout under dir, with one schema file per database and
+// table, and one data file per table. Statements other than CREATE DATABASE,
+// USE, CREATE TABLE, INSERT and REPLACE are skipped. If dir has already been
+// completely split from the file, it is reused as-is.
+func SplitDumpFile(path string, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, splitDoneFileName)); err == nil {
+		common.AppLogger.Infof("[mydump] reusing the split dump file in %s", dir)
+		return nil
+	}
+	// remove the partial result of an interrupted split.
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer file.Close()
+
+	splitter := &dumpSplitter{
+		reader:    newDumpReader(file),
+		dir:       dir,
+		defaultDB: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		dbs:       make(map[string]struct{}),
+	}
+	if err := splitter.split(); err != nil {
+		splitter.closeData()
+		return errors.Annotatef(err, "failed to split %s", path)
+	}
+	if err := splitter.closeData(); err != nil {
+		return errors.Trace(err)
+	}
+	common.AppLogger.Infof("[mydump] split %s into %d databases and %d tables in %s", path, len(splitter.dbs), splitter.tables, dir)
+
+	done, err := os.Create(filepath.Join(dir, splitDoneFileName))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(done.Close())
+}
+
+type dumpSplitter struct {
+	reader    *dumpReader
+	dir       string
+	defaultDB string
+
+	db     string
+	dbs    map[string]struct{}
+	tables int
+
+	// the data file currently written. mysqldump writes the rows of each
+	// table together, so only one file is open at a time.
+	dataPath   string
+	dataFile   *os.File
+	dataWriter *bufio.Writer
+}
+
+func (s *dumpSplitter) split() error {
+	for first := true; ; first = false {
+		stmt, err := s.reader.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if first {
+			if m := dumpHeaderDatabaseRegexp.FindSubmatch(stmt); m != nil {
+				s.defaultDB = string(m[1])
+			}
+		}
+
+		words, start := statementWords(stmt, 6)
+		if len(words) == 0 {
+			continue
+		}
+		switch strings.ToUpper(words[0]) {
+		case "CREATE":
+			if len(words) < 3 {
+				continue
+			}
+			switch strings.ToUpper(words[1]) {
+			case "DATABASE", "SCHEMA":
+				s.db = unquoteIdentifier(lastName(words[2:]))[0]
+				err = s.writeDatabaseSchema(s.db, stmt[start:])
+			case "TABLE":
+				db, table := s.qualify(unquoteIdentifier(lastName(words[2:])))
+				if err = s.ensureDatabase(db); err == nil {
+					s.tables++
+					err = s.writeFile(fmt.Sprintf("%s.%s-schema.sql", db, table), stmt[start:])
+				}
+			default:
+				common.AppLogger.Warnf("[mydump] skipping unsupported statement CREATE %s in the dump file", words[1])
+			}
+		case "USE":
+			if len(words) < 2 {
+				continue
+			}
+			s.db = unquoteIdentifier(words[1])[0]
+			err = s.ensureDatabase(s.db)
+		case "INSERT", "REPLACE":
+			if len(words) < 2 {
+				continue
+			}
+			db, table := s.qualify(unquoteIdentifier(insertTableName(words[1:])))
+			if err = s.ensureDatabase(db); err == nil {
+				err = s.writeData(fmt.Sprintf("%s.%s.sql", db, table), stmt[start:])
+			}
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// qualify returns the database and table of the (possibly qualified) name.
+func (s *dumpSplitter) qualify(name []string) (string, string) {
+	if len(name) >= 2 {
+		return name[0], name[1]
+	}
+	if len(s.db) == 0 {
+		s.db = s.defaultDB
+	}
+	return s.db, name[0]
+}
+
+func (s *dumpSplitter) ensureDatabase(db string) error {
+	if _, ok := s.dbs[db]; ok {
+		return nil
+	}
+	var stmt strings.Builder
+	stmt.WriteString("CREATE DATABASE IF NOT EXISTS ")
+	common.WriteMySQLIdentifier(&stmt, db)
+	return s.writeDatabaseSchema(db, []byte(stmt.String()))
+}
+
+func (s *dumpSplitter) writeDatabaseSchema(db string, stmt []byte) error {
+	s.dbs[db] = struct{}{}
+	return s.writeFile(db+"-schema-create.sql", stmt)
+}
+
+func (s *dumpSplitter) writeFile(name string, stmt []byte) error {
+	if err := checkSplitFileName(name); err != nil {
+		return errors.Trace(err)
+	}
+	var buf bytes.Buffer
+	buf.Write(stmt)
+	buf.WriteString(";\n")
+	return errors.Trace(ioutil.WriteFile(filepath.Join(s.dir, name), buf.Bytes(), 0644))
+}
+
+func (s *dumpSplitter) writeData(name string, stmt []byte) error {
+	path := filepath.Join(s.dir, name)
+	if path != s.dataPath {
+		if err := checkSplitFileName(name); err != nil {
+			return errors.Trace(err)
+		}
+		if err := s.closeData(); err != nil {
+			return errors.Trace(err)
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		s.dataPath = path
+		s.dataFile = file
+		s.dataWriter = bufio.NewWriter(file)
+	}
+	if _, err := s.dataWriter.Write(stmt); err != nil {
+		return errors.Trace(err)
+	}
+	_, err := s.dataWriter.WriteString(";\n")
+	return errors.Trace(err)
+}
+
+func (s *dumpSplitter) closeData() error {
+	if s.dataFile == nil {
+		return nil
+	}
+	err := s.dataWriter.Flush()
+	if closeErr := s.dataFile.Close(); err == nil {
+		err = closeErr
+	}
+	s.dataPath = ""
+	s.dataFile = nil
+	s.dataWriter = nil
+	return errors.Trace(err)
+}
+
+func checkSplitFileName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return errors.Errorf("cannot split the dump file: unsupported database or table name in %q", name)
+	}
+	return nil
+}
+
+// lastName returns the name after the optional `IF NOT EXISTS`.
+func lastName(words []string) string {
+	if len(words) >= 4 && strings.EqualFold(words[0], "IF") {
+		return words[3]
+	}
+	return words[0]
+}
+
+// insertTableName returns the table name after the optional IGNORE and INTO.
+func insertTableName(words []string) string {
+	for _, word := range words {
+		if !strings.EqualFold(word, "IGNORE") && !strings.EqualFold(word, "INTO") {
+			return word
+		}
+	}
+	return words[len(words)-1]
+}
+
+// unquoteIdentifier splits a possibly qualified identifier like `db`.`tbl`
+// into its unquoted parts.
+func unquoteIdentifier(name string) []string {
+	var (
+		parts  []string
+		part   strings.Builder
+		quoted bool
+	)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '`' && quoted && i+1 < len(name) && name[i+1] == '`':
+			part.WriteByte('`')
+			i++
+		case c == '`':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// statementWords returns at most n leading words of the statement, skipping
+// the comments, and the position of the first word. A backquoted identifier
+// counts as part of a word, so `db`.`tbl` is a single word.
+func statementWords(stmt []byte, n int) ([]string, int) {
+	var words []string
+	start := len(stmt)
+	p := 0
+	for p < len(stmt) && len(words) < n {
+		switch c := stmt[p]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == ',':
+			p++
+		case bytes.HasPrefix(stmt[p:], []byte("/*")):
+			end := bytes.Index(stmt[p+2:], []byte("*/"))
+			if end < 0 {
+				return words, start
+			}
+			p += end + 4
+		case bytes.HasPrefix(stmt[p:], []byte("--")) || c == '#':
+			end := bytes.IndexByte(stmt[p:], '\n')
+			if end < 0 {
+				return words, start
+			}
+			p += end + 1
+		default:
+			if len(words) == 0 {
+				start = p
+			}
+			ws := p
+		word:
+			for p < len(stmt) {
+				switch stmt[p] {
+				case '`':
+					end := bytes.IndexByte(stmt[p+1:], '`')
+					for end >= 0 && p+end+2 < len(stmt) && stmt[p+end+2] == '`' {
+						next := bytes.IndexByte(stmt[p+end+3:], '`')
+						if next < 0 {
+							end = -1
+							break
+						}
+						end += next + 2
+					}
+					if end < 0 {
+						p = len(stmt)
+						break word
+					}
+					p += end + 2
+				case ' ', '\t', '\n', '\r', '(', ')', ',':
+					break word
+				default:
+					p++
+				}
+			}
+			words = append(words, string(stmt[ws:p]))
+		}
+	}
+	return words, start
+}
+
+// dumpReader reads the statements of a mysqldump file one by one, honoring
+// the quoted strings, comments and the DELIMITER command of the mysql client.
+type dumpReader struct {
+	reader    *bufio.Reader
+	delimiter []byte
+	buf       bytes.Buffer
+}
+
+func newDumpReader(r io.Reader) *dumpReader {
+	return &dumpReader{
+		reader:    bufio.NewReaderSize(r, 1<<20),
+		delimiter: []byte{';'},
+	}
+}
+
+// next returns the next statement without the delimiter. The content is only
+// valid until the next call.
+func (r *dumpReader) next() ([]byte, error) {
+	r.buf.Reset()
+	atStart := true
+	for {
+		c, err := r.reader.ReadByte()
+		if err == io.EOF {
+			// the last statement may lack the delimiter.
+			if stmt := bytes.TrimRight(r.buf.Bytes(), " \t\r\n"); len(stmt) > 0 {
+				return stmt, nil
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if atStart && (c == ' ' || c == '\t' || c == '\n' || c == '\r') {
+			continue
+		}
+		if atStart && (c == 'D' || c == 'd') {
+			ok, err := r.readDelimiter(c)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if ok {
+				continue
+			}
+		}
+		atStart = false
+
+		if c == r.delimiter[0] {
+			rest, _ := r.reader.Peek(len(r.delimiter) - 1)
+			if bytes.Equal(rest, r.delimiter[1:]) {
+				r.reader.Discard(len(rest))
+				return r.buf.Bytes(), nil
+			}
+		}
+
+		r.buf.WriteByte(c)
+		switch c {
+		case '\'', '"', '`':
+			err = r.copyQuoted(c)
+		case '-':
+			if next, _ := r.reader.Peek(1); len(next) == 1 && next[0] == '-' {
+				err = r.copyLine()
+			}
+		case '#':
+			err = r.copyLine()
+		case '/':
+			if next, _ := r.reader.Peek(1); len(next) == 1 && next[0] == '*' {
+				err = r.copyBlockComment()
+			}
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+}
+
+// readDelimiter handles the `DELIMITER ;;` command at the start of a
+// statement, whose first byte c has been read.
+func (r *dumpReader) readDelimiter(c byte) (bool, error) {
+	const keyword = "DELIMITER"
+	peek, _ := r.reader.Peek(len(keyword))
+	if len(peek) < len(keyword) || !strings.EqualFold(string(c)+string(peek[:len(keyword)-1]), keyword) {
+		return false, nil
+	}
+	if sep := peek[len(keyword)-1]; sep != ' ' && sep != '\t' {
+		return false, nil
+	}
+	line, err := r.reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Trace(err)
+	}
+	delimiter := strings.TrimSpace(line[len(keyword)-1:])
+	if len(delimiter) == 0 {
+		return false, errors.New("empty DELIMITER")
+	}
+	r.delimiter = []byte(delimiter)
+	return true, nil
+}
+
+func (r *dumpReader) copyQuoted(quote byte) error {
+	for {
+		c, err := r.reader.ReadByte()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		r.buf.WriteByte(c)
+		switch {
+		case c == quote:
+			return nil
+		case c == '\\' && quote != '`':
+			c, err = r.reader.ReadByte()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			r.buf.WriteByte(c)
+		}
+	}
+}
+
+func (r *dumpReader) copyLine() error {
+	line, err := r.reader.ReadBytes('\n')
+	r.buf.Write(line)
+	if err == io.EOF {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+func (r *dumpReader) copyBlockComment() error {
+	// consume the '*' after '/' first, so "/*/" is not taken as closed.
+	c, err := r.reader.ReadByte()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	r.buf.WriteByte(c)
+	var prev byte
+	for {
+		c, err := r.reader.ReadByte()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		r.buf.WriteByte(c)
+		if prev == '*' && c == '/' {
+			return nil
+		}
+		prev = c
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	md "github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&testDumpFileSuite{})
+
+type testDumpFileSuite struct{}
+
+// the dumps below use "~" in place of the backquote.
+const testMysqldumpFile = `-- MySQL dump 10.13  Distrib 5.7.26, for Linux (x86_64)
+--
+-- Host: localhost    Database: shop
+-- ------------------------------------------------------
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET NAMES utf8mb4 */;
+
+--
+-- Table structure for table ~items~
+--
+
+DROP TABLE IF EXISTS ~items~;
+CREATE TABLE ~items~ (
+  ~id~ int(11) NOT NULL,
+  ~name~ varchar(20) DEFAULT 'x;y',
+  PRIMARY KEY (~id~)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+LOCK TABLES ~items~ WRITE;
+/*!40000 ALTER TABLE ~items~ DISABLE KEYS */;
+INSERT INTO ~items~ VALUES (1,'a;b'),(2,'it\'s; "x"');
+INSERT INTO ~items~ VALUES (3,'c');
+/*!40000 ALTER TABLE ~items~ ENABLE KEYS */;
+UNLOCK TABLES;
+
+CREATE TABLE IF NOT EXISTS ~we~~ird~ (~a~ int);
+INSERT IGNORE INTO ~we~~ird~ VALUES (1);
+
+DELIMITER ;;
+CREATE PROCEDURE p() BEGIN INSERT INTO ~items~ VALUES (9,'x'); END ;;
+DELIMITER ;
+
+CREATE DATABASE /*!32312 IF NOT EXISTS*/ ~other~ /*!40100 DEFAULT CHARACTER SET utf8mb4 */;
+USE ~other~;
+CREATE TABLE ~t~ (~x~ int);
+REPLACE INTO ~other~.~t~ VALUES (1),(2)
+`
+
+func writeTestDump(c *C, path string, content string) {
+	err := ioutil.WriteFile(path, []byte(strings.Replace(content, "~", "`", -1)), 0644)
+	c.Assert(err, IsNil)
+}
+
+func readSplitFiles(c *C, dir string) map[string]string {
+	infos, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	files := make(map[string]string)
+	for _, info := range infos {
+		content, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		c.Assert(err, IsNil)
+		files[strings.Replace(info.Name(), "`", "~", -1)] = strings.Replace(string(content), "`", "~", -1)
+	}
+	return files
+}
+
+func (s *testDumpFileSuite) TestSplitDumpFile(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "dump.sql")
+	writeTestDump(c, path, testMysqldumpFile)
+	splitDir := filepath.Join(dir, "split")
+
+	c.Assert(md.SplitDumpFile(path, splitDir), IsNil)
+
+	files := readSplitFiles(c, splitDir)
+	c.Assert(files, HasLen, 9)
+	c.Assert(files[".lightning-split-done"], Equals, "")
+	c.Assert(files["shop-schema-create.sql"], Equals, "CREATE DATABASE IF NOT EXISTS ~shop~;\n")
+	c.Assert(files["shop.items-schema.sql"], Equals, `CREATE TABLE ~items~ (
+  ~id~ int(11) NOT NULL,
+  ~name~ varchar(20) DEFAULT 'x;y',
+  PRIMARY KEY (~id~)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`)
+	// the INSERT statement inside the procedure is not taken as data.
+	c.Assert(files["shop.items.sql"], Equals, `INSERT INTO ~items~ VALUES (1,'a;b'),(2,'it\'s; "x"');
+INSERT INTO ~items~ VALUES (3,'c');
+`)
+	c.Assert(files["shop.we~ird-schema.sql"], Equals, "CREATE TABLE IF NOT EXISTS ~we~~ird~ (~a~ int);\n")
+	c.Assert(files["shop.we~ird.sql"], Equals, "INSERT IGNORE INTO ~we~~ird~ VALUES (1);\n")
+	c.Assert(files["other-schema-create.sql"], Equals, "CREATE DATABASE /*!32312 IF NOT EXISTS*/ ~other~ /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n")
+	c.Assert(files["other.t-schema.sql"], Equals, "CREATE TABLE ~t~ (~x~ int);\n")
+	c.Assert(files["other.t.sql"], Equals, "REPLACE INTO ~other~.~t~ VALUES (1),(2);\n")
+
+	// the split directory is a valid data source.
+	cfg := &config.Config{Mydumper: config.MydumperRuntime{SourceDir: splitDir}}
+	mdl, err := md.NewMyDumpLoader(cfg)
+	c.Assert(err, IsNil)
+	dbMetas := mdl.GetDatabases()
+	c.Assert(dbMetas, HasLen, 2)
+	c.Assert(dbMetas[0].Name, Equals, "other")
+	c.Assert(dbMetas[0].Tables, HasLen, 1)
+	c.Assert(dbMetas[1].Name, Equals, "shop")
+	c.Assert(dbMetas[1].Tables, HasLen, 2)
+}
+
+func (s *testDumpFileSuite) TestSplitDumpFileReuse(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "dump.sql")
+	writeTestDump(c, path, testMysqldumpFile)
+	splitDir := filepath.Join(dir, "split")
+	c.Assert(md.SplitDumpFile(path, splitDir), IsNil)
+
+	// a completely split directory is reused without reading the dump.
+	c.Assert(os.Remove(path), IsNil)
+	c.Assert(md.SplitDumpFile(path, splitDir), IsNil)
+	c.Assert(readSplitFiles(c, splitDir), HasLen, 9)
+
+	// an interrupted split is redone from scratch.
+	writeTestDump(c, path, "CREATE TABLE ~t~ (~x~ int);\nINSERT INTO ~t~ VALUES (1);\n")
+	c.Assert(os.Remove(filepath.Join(splitDir, ".lightning-split-done")), IsNil)
+	c.Assert(md.SplitDumpFile(path, splitDir), IsNil)
+	files := readSplitFiles(c, splitDir)
+	c.Assert(files, HasLen, 4)
+	// without a database in the header, the dump file name is used.
+	c.Assert(files["dump-schema-create.sql"], Equals, "CREATE DATABASE IF NOT EXISTS ~dump~;\n")
+	c.Assert(files["dump.t.sql"], Equals, "INSERT INTO ~t~ VALUES (1);\n")
+}
+
+func (s *testDumpFileSuite) TestSplitDumpFileUnsupportedName(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "dump.sql")
+	writeTestDump(c, path, "CREATE TABLE ~a/b~ (~x~ int);\n")
+	err := md.SplitDumpFile(path, filepath.Join(dir, "split"))
+	c.Assert(err, ErrorMatches, ".*unsupported database or table name.*")
+}
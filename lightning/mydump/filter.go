@@ -0,0 +1,483 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/opcode"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+// FilterParser wraps another parser, and skips the rows not matching the
+// WHERE-like predicate of the table. The predicate supports comparisons,
+// AND/OR/XOR/NOT, IS [NOT] NULL, [NOT] IN and [NOT] BETWEEN on the columns and
+// literals. The values are compared as numbers if either side is a number,
+// otherwise as binary strings, so dates should be written in ISO format.
+type FilterParser struct {
+	Parser
+
+	rule  *config.FilterRule
+	where ast.ExprNode
+	// tableColumns are the columns of the values if the data file does not
+	// list the columns.
+	tableColumns []string
+
+	sqlParser *parser.Parser
+	sc        *stmtctx.StatementContext
+
+	sourceColumns []byte
+	sourceCount   int
+	columnIndex   map[string]int
+	resolved      bool
+
+	stmtBuf bytes.Buffer
+	values  []types.Datum
+}
+
+// NewFilterParser creates a parser skipping the rows not matching the filter.
+func NewFilterParser(inner Parser, rule *config.FilterRule, tableColumns []string) (*FilterParser, error) {
+	sqlParser := parser.New()
+	stmt, err := sqlParser.ParseOneStmt("SELECT 1 FROM t WHERE "+rule.Where, "", "")
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid mydumper.filter of %s.%s", rule.Schema, rule.Table)
+	}
+	where := stmt.(*ast.SelectStmt).Where
+	if err := checkFilterExpr(where); err != nil {
+		return nil, errors.Annotatef(err, "invalid mydumper.filter of %s.%s", rule.Schema, rule.Table)
+	}
+	return &FilterParser{
+		Parser:       inner,
+		rule:         rule,
+		where:        where,
+		tableColumns: tableColumns,
+		sqlParser:    sqlParser,
+		sc:           &stmtctx.StatementContext{},
+	}, nil
+}
+
+// SetSQLMode changes how the values in the rows are parsed, for both this and
+// the inner parser.
+func (parser *FilterParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.sqlParser.SetSQLMode(mode)
+	if inner, ok := parser.Parser.(interface{ SetSQLMode(mysql.SQLMode) }); ok {
+		inner.SetSQLMode(mode)
+	}
+}
+
+// ReadRow reads the next row matching the filter from the inner parser.
+func (parser *FilterParser) ReadRow() error {
+	for {
+		if err := parser.Parser.ReadRow(); err != nil {
+			return err
+		}
+
+		if !parser.resolved || !bytes.Equal(parser.sourceColumns, parser.Parser.Columns()) {
+			if err := parser.resolveColumns(parser.Parser.Columns()); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err := parser.parseValues(parser.Parser.LastRow().Row); err != nil {
+			return errors.Annotatef(err, "cannot filter row at offset %d", parser.Pos())
+		}
+		if len(parser.values) != parser.sourceCount {
+			return errors.Errorf("row at offset %d has %d values but there are %d columns", parser.Pos(), len(parser.values), parser.sourceCount)
+		}
+		res, err := parser.eval(parser.where)
+		if err != nil {
+			return errors.Annotatef(err, "cannot filter row at offset %d", parser.Pos())
+		}
+		if match, err := isTrue(parser.sc, res); err != nil || match {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// resolveColumns finds the value positions of the columns in the filter.
+func (parser *FilterParser) resolveColumns(sourceColumns []byte) error {
+	names := parser.tableColumns
+	if len(sourceColumns) > 0 {
+		var quoting sqlQuoting
+		var err error
+		if names, err = quoting.splitColumns(sourceColumns); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	columnIndex := make(map[string]int, len(names))
+	for i, name := range names {
+		columnIndex[strings.ToLower(name)] = i
+	}
+	var missing error
+	parser.where.Accept(&columnVisitor{visit: func(name string) {
+		if _, ok := columnIndex[strings.ToLower(name)]; !ok && missing == nil {
+			missing = errors.Errorf("filtered column %s is not in the data file of %s.%s", name, parser.rule.Schema, parser.rule.Table)
+		}
+	}})
+	if missing != nil {
+		return missing
+	}
+
+	parser.sourceColumns = append(parser.sourceColumns[:0], sourceColumns...)
+	parser.sourceCount = len(names)
+	parser.columnIndex = columnIndex
+	parser.resolved = true
+	return nil
+}
+
+// parseValues parses the literals in the row tuple with the SQL parser.
+func (parser *FilterParser) parseValues(row []byte) error {
+	trimmed := bytes.TrimSpace(row)
+	if len(trimmed) < 2 || trimmed[0] != '(' || trimmed[len(trimmed)-1] != ')' {
+		return errors.New("malformed row")
+	}
+	parser.stmtBuf.Reset()
+	parser.stmtBuf.WriteString("SELECT ")
+	parser.stmtBuf.Write(trimmed[1 : len(trimmed)-1])
+	stmt, err := parser.sqlParser.ParseOneStmt(parser.stmtBuf.String(), "", "")
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	fields := stmt.(*ast.SelectStmt).Fields.Fields
+	parser.values = parser.values[:0]
+	for _, field := range fields {
+		value, err := literalValue(field.Expr)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		parser.values = append(parser.values, value)
+	}
+	return nil
+}
+
+func literalValue(expr ast.ExprNode) (types.Datum, error) {
+	switch e := expr.(type) {
+	case *driver.ValueExpr:
+		return e.Datum, nil
+	case *ast.UnaryOperationExpr:
+		value, err := literalValue(e.V)
+		if err != nil {
+			return value, err
+		}
+		switch e.Op {
+		case opcode.Plus:
+			return value, nil
+		case opcode.Minus:
+			return negate(value)
+		}
+	case *ast.ParenthesesExpr:
+		return literalValue(e.Expr)
+	}
+	return types.Datum{}, errors.New("value is not a literal")
+}
+
+func negate(value types.Datum) (types.Datum, error) {
+	switch value.Kind() {
+	case types.KindNull:
+		return value, nil
+	case types.KindInt64:
+		if value.GetInt64() != 0 {
+			return types.NewIntDatum(-value.GetInt64()), nil
+		}
+		return value, nil
+	case types.KindFloat64:
+		return types.NewFloat64Datum(-value.GetFloat64()), nil
+	case types.KindUint64:
+		return negateDecimal(strconv.FormatUint(value.GetUint64(), 10))
+	case types.KindMysqlDecimal:
+		return negateDecimal(value.GetMysqlDecimal().String())
+	}
+	return types.Datum{}, errors.New("cannot negate a non-numeric value")
+}
+
+func negateDecimal(s string) (types.Datum, error) {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else {
+		s = "-" + s
+	}
+	dec := new(types.MyDecimal)
+	if err := dec.FromString([]byte(s)); err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewDecimalDatum(dec), nil
+}
+
+// eval evaluates the filter with SQL three-valued logic, where NULL stands
+// for unknown.
+func (parser *FilterParser) eval(expr ast.ExprNode) (types.Datum, error) {
+	switch e := expr.(type) {
+	case *ast.ColumnNameExpr:
+		return parser.values[parser.columnIndex[e.Name.Name.L]], nil
+	case *ast.ParenthesesExpr:
+		return parser.eval(e.Expr)
+	case *ast.UnaryOperationExpr:
+		if e.Op != opcode.Not {
+			return literalValue(e)
+		}
+		v, err := parser.evalBool(e.V)
+		if err != nil || v.IsNull() {
+			return v, err
+		}
+		return boolDatum(v.GetInt64() == 0), nil
+	case *ast.BinaryOperationExpr:
+		switch e.Op {
+		case opcode.LogicAnd, opcode.LogicOr, opcode.LogicXor:
+			return parser.evalLogic(e)
+		}
+		l, err := parser.eval(e.L)
+		if err != nil {
+			return l, err
+		}
+		r, err := parser.eval(e.R)
+		if err != nil {
+			return r, err
+		}
+		return parser.compare(e.Op, l, r)
+	case *ast.IsNullExpr:
+		v, err := parser.eval(e.Expr)
+		if err != nil {
+			return v, err
+		}
+		return boolDatum(v.IsNull() != e.Not), nil
+	case *ast.BetweenExpr:
+		v, err := parser.eval(e.Expr)
+		if err != nil {
+			return v, err
+		}
+		left, err := parser.eval(e.Left)
+		if err != nil {
+			return left, err
+		}
+		right, err := parser.eval(e.Right)
+		if err != nil {
+			return right, err
+		}
+		ge, err := parser.compare(opcode.GE, v, left)
+		if err != nil {
+			return ge, err
+		}
+		le, err := parser.compare(opcode.LE, v, right)
+		if err != nil {
+			return le, err
+		}
+		res := and(ge, le)
+		if e.Not {
+			return not(res), nil
+		}
+		return res, nil
+	case *ast.PatternInExpr:
+		v, err := parser.eval(e.Expr)
+		if err != nil {
+			return v, err
+		}
+		res := boolDatum(false)
+		for _, item := range e.List {
+			itemValue, err := parser.eval(item)
+			if err != nil {
+				return itemValue, err
+			}
+			eq, err := parser.compare(opcode.EQ, v, itemValue)
+			if err != nil {
+				return eq, err
+			}
+			res = or(res, eq)
+		}
+		if e.Not {
+			return not(res), nil
+		}
+		return res, nil
+	}
+	return literalValue(expr)
+}
+
+func (parser *FilterParser) evalBool(expr ast.ExprNode) (types.Datum, error) {
+	v, err := parser.eval(expr)
+	if err != nil || v.IsNull() {
+		return v, err
+	}
+	b, err := v.ToBool(parser.sc)
+	return boolDatum(b != 0), errors.Trace(err)
+}
+
+func (parser *FilterParser) evalLogic(e *ast.BinaryOperationExpr) (types.Datum, error) {
+	l, err := parser.evalBool(e.L)
+	if err != nil {
+		return l, err
+	}
+	r, err := parser.evalBool(e.R)
+	if err != nil {
+		return r, err
+	}
+	switch e.Op {
+	case opcode.LogicAnd:
+		return and(l, r), nil
+	case opcode.LogicOr:
+		return or(l, r), nil
+	default:
+		if l.IsNull() || r.IsNull() {
+			return types.Datum{}, nil
+		}
+		return boolDatum((l.GetInt64() != 0) != (r.GetInt64() != 0)), nil
+	}
+}
+
+func (parser *FilterParser) compare(op opcode.Op, l types.Datum, r types.Datum) (types.Datum, error) {
+	if l.IsNull() || r.IsNull() {
+		if op == opcode.NullEQ {
+			return boolDatum(l.IsNull() && r.IsNull()), nil
+		}
+		return types.Datum{}, nil
+	}
+	cmp, err := l.CompareDatum(parser.sc, &r)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	switch op {
+	case opcode.EQ, opcode.NullEQ:
+		return boolDatum(cmp == 0), nil
+	case opcode.NE:
+		return boolDatum(cmp != 0), nil
+	case opcode.LT:
+		return boolDatum(cmp < 0), nil
+	case opcode.LE:
+		return boolDatum(cmp <= 0), nil
+	case opcode.GT:
+		return boolDatum(cmp > 0), nil
+	case opcode.GE:
+		return boolDatum(cmp >= 0), nil
+	}
+	return types.Datum{}, errors.Errorf("unsupported operator %s", op)
+}
+
+// checkFilterExpr ensures the filter only contains the supported expressions.
+func checkFilterExpr(expr ast.ExprNode) error {
+	switch e := expr.(type) {
+	case *ast.ColumnNameExpr:
+		if len(e.Name.Table.O) > 0 {
+			return errors.Errorf("column %s.%s must not be qualified", e.Name.Table.O, e.Name.Name.O)
+		}
+		return nil
+	case *driver.ValueExpr:
+		return nil
+	case *ast.ParenthesesExpr:
+		return checkFilterExpr(e.Expr)
+	case *ast.UnaryOperationExpr:
+		switch e.Op {
+		case opcode.Not, opcode.Minus, opcode.Plus:
+			return checkFilterExpr(e.V)
+		}
+	case *ast.BinaryOperationExpr:
+		switch e.Op {
+		case opcode.LogicAnd, opcode.LogicOr, opcode.LogicXor,
+			opcode.EQ, opcode.NE, opcode.LT, opcode.LE, opcode.GT, opcode.GE, opcode.NullEQ:
+			if err := checkFilterExpr(e.L); err != nil {
+				return err
+			}
+			return checkFilterExpr(e.R)
+		}
+	case *ast.IsNullExpr:
+		return checkFilterExpr(e.Expr)
+	case *ast.BetweenExpr:
+		for _, sub := range []ast.ExprNode{e.Expr, e.Left, e.Right} {
+			if err := checkFilterExpr(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ast.PatternInExpr:
+		if e.Sel != nil {
+			return errors.New("subqueries are not supported")
+		}
+		if err := checkFilterExpr(e.Expr); err != nil {
+			return err
+		}
+		for _, item := range e.List {
+			if err := checkFilterExpr(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("unsupported expression %T", expr)
+}
+
+// columnVisitor calls visit with the name of every column in the expression.
+type columnVisitor struct {
+	visit func(name string)
+}
+
+func (v *columnVisitor) Enter(n ast.Node) (ast.Node, bool) {
+	if column, ok := n.(*ast.ColumnNameExpr); ok {
+		v.visit(column.Name.Name.O)
+	}
+	return n, false
+}
+
+func (v *columnVisitor) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+func boolDatum(b bool) types.Datum {
+	if b {
+		return types.NewIntDatum(1)
+	}
+	return types.NewIntDatum(0)
+}
+
+func isTrue(sc *stmtctx.StatementContext, v types.Datum) (bool, error) {
+	if v.IsNull() {
+		return false, nil
+	}
+	b, err := v.ToBool(sc)
+	return b != 0, errors.Trace(err)
+}
+
+func and(l types.Datum, r types.Datum) types.Datum {
+	switch {
+	case !l.IsNull() && l.GetInt64() == 0, !r.IsNull() && r.GetInt64() == 0:
+		return boolDatum(false)
+	case l.IsNull() || r.IsNull():
+		return types.Datum{}
+	}
+	return boolDatum(true)
+}
+
+func or(l types.Datum, r types.Datum) types.Datum {
+	switch {
+	case !l.IsNull() && l.GetInt64() != 0, !r.IsNull() && r.GetInt64() != 0:
+		return boolDatum(true)
+	case l.IsNull() || r.IsNull():
+		return types.Datum{}
+	}
+	return boolDatum(false)
+}
+
+func not(v types.Datum) types.Datum {
+	if v.IsNull() {
+		return v
+	}
+	return boolDatum(v.GetInt64() == 0)
+}
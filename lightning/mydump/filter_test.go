@@ -0,0 +1,108 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testFilterParserSuite{})
+
+type testFilterParserSuite struct{}
+
+func (s *testFilterParserSuite) newParser(c *C, data string, where string, tableColumns []string) *mydump.FilterParser {
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	inner := mydump.NewChunkParser(strings.NewReader(data), config.ReadBlockSize, ioWorkers)
+	parser, err := mydump.NewFilterParser(inner, &config.FilterRule{Schema: "db", Table: "t", Where: where}, tableColumns)
+	c.Assert(err, IsNil)
+	return parser
+}
+
+func (s *testFilterParserSuite) readAll(c *C, parser *mydump.FilterParser) []string {
+	var rows []string
+	for {
+		err := parser.ReadRow()
+		if errors.Cause(err) == io.EOF {
+			return rows
+		}
+		c.Assert(err, IsNil)
+		rows = append(rows, string(parser.LastRow().Row))
+	}
+}
+
+func (s *testFilterParserSuite) TestFilter(c *C) {
+	data := "INSERT INTO `t` (`id`, `created_at`, `status`) VALUES " +
+		"(1, '2018-12-31 23:59:59', 1)," +
+		"(2, '2019-01-01 00:00:00', 2)," +
+		"(3, '2019-06-01 12:00:00', 3)," +
+		"(4, NULL, 1)," +
+		"(-5, '2020-01-01 00:00:00', NULL);"
+
+	testCases := []struct {
+		where string
+		ids   []string
+	}{
+		{"created_at >= '2019-01-01'", []string{"2", "3", "-5"}},
+		{"created_at >= '2019-01-01' AND status IN (1, 2)", []string{"2"}},
+		{"status NOT IN (1, 2)", []string{"3"}},
+		{"created_at IS NULL OR status IS NULL", []string{"4", "-5"}},
+		{"NOT (id BETWEEN 2 AND 4)", []string{"1", "-5"}},
+		{"id < -1", []string{"-5"}},
+		{"status <=> NULL", []string{"-5"}},
+		{"`Status` != 1 XOR id > 2", []string{"2", "4"}},
+	}
+
+	for _, tc := range testCases {
+		parser := s.newParser(c, data, tc.where, nil)
+		rows := s.readAll(c, parser)
+		ids := make([]string, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, strings.SplitN(row[1:], ",", 2)[0])
+		}
+		c.Assert(ids, DeepEquals, tc.ids, Commentf("where: %s", tc.where))
+	}
+}
+
+func (s *testFilterParserSuite) TestTableColumns(c *C) {
+	parser := s.newParser(c, "INSERT INTO `t` VALUES (1,'x'),(2,'y'),(3,'x');", "b = 'x'", []string{"a", "b"})
+	c.Assert(s.readAll(c, parser), DeepEquals, []string{"(1,'x')", "(3,'x')"})
+
+	parser = s.newParser(c, "INSERT INTO `t` VALUES (1,'x');", "b = 'x'", []string{"a", "c"})
+	c.Assert(parser.ReadRow(), ErrorMatches, ".*filtered column b is not in the data file of db.t")
+
+	parser = s.newParser(c, "INSERT INTO `t` VALUES (1,'x',3);", "b = 'x'", []string{"a", "b"})
+	c.Assert(parser.ReadRow(), ErrorMatches, ".*has 3 values but there are 2 columns")
+}
+
+func (s *testFilterParserSuite) TestInvalidFilter(c *C) {
+	for _, where := range []string{
+		"a = (SELECT 1)",
+		"LOWER(a) = 'x'",
+		"a LIKE 'x%'",
+		"t.a = 1",
+		"a = ",
+	} {
+		_, err := mydump.NewFilterParser(nil, &config.FilterRule{Schema: "db", Table: "t", Where: where}, nil)
+		c.Assert(err, ErrorMatches, "invalid mydumper.filter of db.t.*", Commentf("where: %s", where))
+	}
+}
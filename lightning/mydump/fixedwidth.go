@@ -0,0 +1,184 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+const fixedWidthSuffix = ".fwf"
+
+// IsFixedWidthFile returns whether the data file consists of fixed-width
+// records, whose layout is given by `[[mydumper.fixed-width]]`.
+func IsFixedWidthFile(path string) bool {
+	return strings.HasSuffix(path, fixedWidthSuffix)
+}
+
+// FixedWidthParser is a parser of the fixed-width data files exported from
+// mainframes. Each line is a record, which is sliced into fields by the byte
+// widths of the columns. Spaces padding the fields are removed, and the fields
+// are written as strings, leaving the conversion to the column types to TiDB.
+// Lines shorter than the record are padded with blank fields.
+type FixedWidthParser struct {
+	reader    io.Reader
+	bufReader *bufio.Reader
+
+	pos     int64
+	lastRow Row
+	rowBuf  bytes.Buffer
+
+	columns     []byte
+	widths      []int
+	recordWidth int
+	nullIfBlank bool
+
+	noBackslashEscapes bool
+}
+
+// NewFixedWidthParser creates a new parser of a fixed-width file. The columns
+// are taken from the rule if specified, otherwise they are the columnNames,
+// which must have the same number of entries as the widths.
+func NewFixedWidthParser(
+	reader io.Reader,
+	blockBufSize int64,
+	rule *config.FixedWidthRule,
+	columnNames []string,
+	ioWorkers *worker.Pool,
+) (*FixedWidthParser, error) {
+	if len(rule.Columns) != 0 {
+		columnNames = rule.Columns
+	}
+	if len(columnNames) != len(rule.Widths) {
+		return nil, errors.Errorf("fixed-width layout has %d widths but the table has %d columns", len(rule.Widths), len(columnNames))
+	}
+
+	var columns strings.Builder
+	columns.WriteByte('(')
+	for i, name := range columnNames {
+		if i > 0 {
+			columns.WriteByte(',')
+		}
+		common.WriteMySQLIdentifier(&columns, name)
+	}
+	columns.WriteByte(')')
+
+	recordWidth := 0
+	for _, width := range rule.Widths {
+		recordWidth += width
+	}
+
+	return &FixedWidthParser{
+		reader:      reader,
+		bufReader:   bufio.NewReaderSize(&ioWorkerReader{reader: reader, ioWorkers: ioWorkers}, int(blockBufSize)),
+		columns:     []byte(columns.String()),
+		widths:      rule.Widths,
+		recordWidth: recordWidth,
+		nullIfBlank: rule.NullIfBlank,
+	}, nil
+}
+
+// SetSQLMode changes how the strings are escaped, which must match the SQL
+// mode used to encode the rows.
+func (parser *FixedWidthParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.noBackslashEscapes = mode.HasNoBackslashEscapesMode()
+}
+
+// Reader returns the underlying reader of this parser.
+func (parser *FixedWidthParser) Reader() io.Reader {
+	return parser.reader
+}
+
+// SetPos changes the reported position and row ID.
+func (parser *FixedWidthParser) SetPos(pos int64, rowID int64) {
+	parser.pos = pos
+	parser.lastRow.RowID = rowID
+}
+
+// Pos returns the current file offset.
+func (parser *FixedWidthParser) Pos() int64 {
+	return parser.pos
+}
+
+// LastRow is the row parsed by the last call to ReadRow(). The content is
+// only valid until the next call to ReadRow().
+func (parser *FixedWidthParser) LastRow() Row {
+	return parser.lastRow
+}
+
+// Columns is the list of columns in the order of the fields.
+func (parser *FixedWidthParser) Columns() []byte {
+	return parser.columns
+}
+
+// ReadRow reads the next non-empty line from the data file.
+func (parser *FixedWidthParser) ReadRow() error {
+	for {
+		line, err := parser.bufReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return errors.Trace(err)
+		}
+		if len(line) == 0 {
+			return io.EOF
+		}
+
+		offset := parser.pos
+		parser.pos += int64(len(line))
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) > parser.recordWidth {
+			return errors.Errorf("record at offset %d has %d bytes, longer than the fixed width %d", offset, len(line), parser.recordWidth)
+		}
+
+		parser.rowBuf.Reset()
+		parser.rowBuf.WriteByte('(')
+		start := 0
+		for i, width := range parser.widths {
+			if i > 0 {
+				parser.rowBuf.WriteByte(',')
+			}
+			var field []byte
+			if start < len(line) {
+				end := start + width
+				if end > len(line) {
+					end = len(line)
+				}
+				field = bytes.Trim(line[start:end], " ")
+			}
+			start += width
+
+			if len(field) == 0 && parser.nullIfBlank {
+				parser.rowBuf.WriteString("NULL")
+			} else {
+				writeSQLString(&parser.rowBuf, string(field), parser.noBackslashEscapes)
+			}
+		}
+		parser.rowBuf.WriteByte(')')
+
+		parser.lastRow.RowID++
+		parser.lastRow.Row = parser.rowBuf.Bytes()
+		return nil
+	}
+}
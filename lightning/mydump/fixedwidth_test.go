@@ -0,0 +1,73 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testFixedWidthParserSuite{})
+
+type testFixedWidthParserSuite struct{}
+
+func (s *testFixedWidthParserSuite) TestReadRow(c *C) {
+	reader := strings.NewReader("0001it's      C:\\tmp\r\n\n   2          \n03")
+
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	rule := &config.FixedWidthRule{Widths: []int{4, 10, 6}, NullIfBlank: true}
+	parser, err := mydump.NewFixedWidthParser(reader, config.ReadBlockSize, rule, []string{"id", "name", "path"}, ioWorkers)
+	c.Assert(err, IsNil)
+	c.Assert(parser.Columns(), DeepEquals, []byte("(`id`,`name`,`path`)"))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().RowID, Equals, int64(1))
+	c.Assert(string(parser.LastRow().Row), Equals, `('0001','it''s','C:\\tmp')`)
+	c.Assert(parser.Pos(), Equals, int64(22))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().RowID, Equals, int64(2))
+	c.Assert(string(parser.LastRow().Row), Equals, `('2',NULL,NULL)`)
+	c.Assert(parser.Pos(), Equals, int64(38))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, `('03',NULL,NULL)`)
+	c.Assert(parser.Pos(), Equals, int64(40))
+
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testFixedWidthParserSuite) TestLayout(c *C) {
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+
+	rule := &config.FixedWidthRule{Widths: []int{2, 3}, Columns: []string{"b", "a"}}
+	parser, err := mydump.NewFixedWidthParser(strings.NewReader("xy   \nabcdef\n"), config.ReadBlockSize, rule, []string{"a", "b", "c"}, ioWorkers)
+	c.Assert(err, IsNil)
+	c.Assert(parser.Columns(), DeepEquals, []byte("(`b`,`a`)"))
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, `('xy','')`)
+	c.Assert(parser.ReadRow(), ErrorMatches, "record at offset 6 has 6 bytes, longer than the fixed width 5")
+
+	rule = &config.FixedWidthRule{Widths: []int{2, 3}}
+	_, err = mydump.NewFixedWidthParser(strings.NewReader(""), config.ReadBlockSize, rule, []string{"a", "b", "c"}, ioWorkers)
+	c.Assert(err, ErrorMatches, "fixed-width layout has 2 widths but the table has 3 columns")
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+// The HDFS data source is accessed through the WebHDFS REST API of the
+// NameNode, so the host and port in a "hdfs://host:port/path" URL must be the
+// HTTP address of the NameNode (e.g. port 9870), not the RPC address. The
+// user is taken from the HADOOP_USER_NAME environment variable, if set.
+
+func isHDFSPath(path string) bool {
+	return strings.HasPrefix(path, "hdfs://")
+}
+
+// webHDFSURL returns the WebHDFS URL of the operation on the HDFS path.
+func webHDFSURL(path string, op string, params url.Values) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if params == nil {
+		params = make(url.Values)
+	}
+	params.Set("op", op)
+	if user := os.Getenv("HADOOP_USER_NAME"); len(user) > 0 {
+		params.Set("user.name", user)
+	}
+	webURL := url.URL{
+		Scheme:   "http",
+		Host:     u.Host,
+		Path:     "/webhdfs/v1" + u.Path,
+		RawQuery: params.Encode(),
+	}
+	return webURL.String(), nil
+}
+
+type hdfsFileStatus struct {
+	PathSuffix       string `json:"pathSuffix"`
+	Type             string `json:"type"`
+	Length           int64  `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+}
+
+// getWebHDFS runs the WebHDFS operation and parses the JSON result. A missing
+// path is reported as os.ErrNotExist.
+func getWebHDFS(path string, op string, v interface{}) error {
+	webURL, err := webHDFSURL(path, op, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, webURL, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := doRemoteRequest(common.NewHTTPClient(0), req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return errors.Trace(json.NewDecoder(resp.Body).Decode(v))
+	case http.StatusNotFound:
+		return &os.PathError{Op: strings.ToLower(op), Path: path, Err: os.ErrNotExist}
+	default:
+		var remote struct {
+			RemoteException struct {
+				Message string `json:"message"`
+			} `json:"RemoteException"`
+		}
+		json.NewDecoder(resp.Body).Decode(&remote)
+		return errors.Errorf("%s %s failed, status %s: %s", op, path, resp.Status, remote.RemoteException.Message)
+	}
+}
+
+func statHDFSFile(path string) (int64, time.Time, error) {
+	var result struct {
+		FileStatus hdfsFileStatus `json:"FileStatus"`
+	}
+	if err := getWebHDFS(path, "GETFILESTATUS", &result); err != nil {
+		return 0, time.Time{}, err
+	}
+	status := &result.FileStatus
+	if status.Type != "FILE" {
+		return 0, time.Time{}, errors.Errorf("%s is not a file", path)
+	}
+	modTime := time.Unix(0, status.ModificationTime*int64(time.Millisecond))
+	return status.Length, modTime, nil
+}
+
+// walkHDFS calls fn with the path and the name of every file under the HDFS
+// directory, recursively. WebHDFS lists each directory in lexicographical
+// order, so the order is the same as `filepath.Walk`.
+func walkHDFS(dir string, fn func(path string, name string) error) error {
+	var result struct {
+		FileStatuses struct {
+			FileStatus []hdfsFileStatus `json:"FileStatus"`
+		} `json:"FileStatuses"`
+	}
+	if err := getWebHDFS(dir, "LISTSTATUS", &result); err != nil {
+		return errors.Trace(err)
+	}
+	for _, status := range result.FileStatuses.FileStatus {
+		path := JoinPath(dir, status.PathSuffix)
+		var err error
+		switch status.Type {
+		case "DIRECTORY":
+			err = walkHDFS(path, fn)
+		case "FILE":
+			err = fn(path, status.PathSuffix)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// openHDFSRequest creates the request reading the HDFS file from the offset.
+// The NameNode redirects it to a DataNode, which the client follows.
+func openHDFSRequest(path string, offset int64) (*http.Request, error) {
+	webURL, err := webHDFSURL(path, "OPEN", url.Values{"offset": {fmt.Sprint(offset)}})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, webURL, nil)
+	return req, errors.Trace(err)
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&testHDFSSuite{})
+
+type testHDFSSuite struct{}
+
+// newWebHDFSServer serves the files through a minimal WebHDFS API.
+func newWebHDFSServer(c *C, files map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Assert(strings.HasPrefix(req.URL.Path, "/webhdfs/v1/"), IsTrue)
+		p := strings.TrimPrefix(req.URL.Path, "/webhdfs/v1")
+		switch req.URL.Query().Get("op") {
+		case "GETFILESTATUS":
+			content, ok := files[p]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"FileStatus": map[string]interface{}{"type": "FILE", "length": len(content), "modificationTime": 1556668800000},
+			})
+		case "LISTSTATUS":
+			children := make(map[string]string)
+			for name := range files {
+				if rel := strings.TrimPrefix(name, p+"/"); rel != name {
+					if i := strings.IndexByte(rel, '/'); i >= 0 {
+						children[rel[:i]] = "DIRECTORY"
+					} else {
+						children[rel] = "FILE"
+					}
+				}
+			}
+			names := make([]string, 0, len(children))
+			for name := range children {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			statuses := make([]map[string]interface{}, 0, len(names))
+			for _, name := range names {
+				statuses = append(statuses, map[string]interface{}{"pathSuffix": name, "type": children[name]})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"FileStatuses": map[string]interface{}{"FileStatus": statuses},
+			})
+		case "OPEN":
+			offset, err := strconv.Atoi(req.URL.Query().Get("offset"))
+			c.Assert(err, IsNil)
+			w.Write([]byte(files[p][offset:]))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func (s *testHDFSSuite) TestHDFSDataSource(c *C) {
+	const data = "INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);\n"
+	server := newWebHDFSServer(c, map[string]string{
+		"/dump/db-schema-create.sql": "CREATE DATABASE db;",
+		"/dump/db.t-schema.sql":      "CREATE TABLE t (a INT);",
+		"/dump/part/db.t.1.sql":      data,
+		"/dump/part/db.t.2.sql":      data,
+	})
+	defer server.Close()
+
+	dir := "hdfs://" + strings.TrimPrefix(server.URL, "http://") + "/dump"
+	mdl, err := mydump.NewMyDumpLoader(&config.Config{Mydumper: config.MydumperRuntime{SourceDir: dir}})
+	c.Assert(err, IsNil)
+	dbMetas := mdl.GetDatabases()
+	c.Assert(dbMetas, HasLen, 1)
+	c.Assert(dbMetas[0].Tables, HasLen, 1)
+	c.Assert(dbMetas[0].Tables[0].DataFiles, DeepEquals, []string{
+		dir + "/part/db.t.1.sql",
+		dir + "/part/db.t.2.sql",
+	})
+
+	p := dir + "/part/db.t.1.sql"
+	size, _, err := mydump.StatDataFile(p)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(data)))
+
+	file, err := mydump.OpenDataFile(p)
+	c.Assert(err, IsNil)
+	defer file.Close()
+	_, err = file.Seek(26, io.SeekStart)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, data[26:])
+}
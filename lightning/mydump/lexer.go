@@ -0,0 +1,303 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+// SetSQLMode changes how the quoted strings in the data file are interpreted.
+//
+// The generated lexer assumes the default SQL mode, where backslashes escape
+// the next character in both `'...'` and `"..."`. With NO_BACKSLASH_ESCAPES,
+// a backslash is an ordinary character, and with ANSI_QUOTES, `"..."` is an
+// identifier in which a backslash is also an ordinary character. Data files
+// dumped under these modes are scanned by `lexWithSQLMode` instead, which
+// accepts the same syntax as `parser.rl` except for the escaping rules.
+func (parser *ChunkParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.noBackslashEscapes = mode.HasNoBackslashEscapesMode()
+	parser.ansiQuotes = mode.HasANSIQuotesMode()
+}
+
+func (parser *ChunkParser) nextToken() (token, []byte, error) {
+	for {
+		var (
+			tok     token
+			content []byte
+			err     error
+		)
+		if parser.noBackslashEscapes || parser.ansiQuotes {
+			tok, content, err = parser.lexWithSQLMode()
+		} else {
+			tok, content, err = parser.lex()
+		}
+		if err != nil || tok != tokName || !isSkippedStatement(content) {
+			return tok, content, err
+		}
+
+		// statements like `SET NAMES utf8;` and `LOCK TABLES ... WRITE;` in
+		// the output of mysqldump are skipped as a whole.
+		stmt, err := parser.skipStatement()
+		if err != nil {
+			return tokNil, nil, errors.Trace(err)
+		}
+		if bytes.EqualFold(content, keywordSet) {
+			parser.checkSetNames(stmt)
+		}
+	}
+}
+
+// isSkippedStatement returns whether the name starts a statement which has
+// no effect on the data. These keywords are all reserved, so they cannot be
+// unquoted table names.
+func isSkippedStatement(name []byte) bool {
+	for _, keyword := range skippedStatementKeywords {
+		if bytes.EqualFold(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipStatement consumes the rest of the current statement up to (but not
+// including) the next `;` outside quoted strings, and returns its content.
+func (parser *ChunkParser) skipStatement() ([]byte, error) {
+	for {
+		end, ok := parser.findStatementEnd(parser.buf)
+		if !ok && parser.isLastChunk {
+			end, ok = len(parser.buf), true
+		}
+		if ok {
+			stmt := parser.buf[:end]
+			parser.buf = parser.buf[end:]
+			parser.pos += int64(end)
+			return stmt, nil
+		}
+		if err := parser.readBlock(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+}
+
+// findStatementEnd returns the position of the `;` ending the statement, or
+// false if it is not in the data yet.
+func (parser *ChunkParser) findStatementEnd(data []byte) (int, bool) {
+	for p := 0; p < len(data); {
+		switch data[p] {
+		case ';':
+			return p, true
+		case '\'', '"', '`':
+			end, ok := parser.scanQuoted(data, p)
+			if !ok {
+				return 0, false
+			}
+			p = end
+		default:
+			p++
+		}
+	}
+	return 0, false
+}
+
+// checkSetNames warns if a `SET NAMES` statement declares a character set
+// other than UTF-8. The data files are imported as-is without conversion, so
+// the strings would be garbled.
+func (parser *ChunkParser) checkSetNames(stmt []byte) {
+	fields := bytes.Fields(stmt)
+	if len(fields) < 2 || !bytes.EqualFold(fields[0], []byte("NAMES")) {
+		return
+	}
+	charset := string(bytes.ToLower(bytes.Trim(fields[1], "'\"`")))
+	switch charset {
+	case "utf8", "utf8mb4", "binary":
+	default:
+		common.AppLogger.Warnf("data file declares `SET NAMES %s` at byte %d, but the data files are imported as UTF-8 without conversion", charset, parser.pos)
+	}
+}
+
+func (parser *ChunkParser) lexWithSQLMode() (token, []byte, error) {
+	for {
+		tok, ts, te, err := parser.scan(parser.buf, parser.isLastChunk)
+		if err != nil {
+			common.AppLogger.Errorf("Syntax error near byte %d, content is «%s»", parser.pos+int64(ts), string(parser.buf))
+			return tokNil, nil, errors.Trace(err)
+		}
+
+		if tok != tokNil {
+			result := parser.buf[ts:te]
+			parser.buf = parser.buf[te:]
+			parser.pos += int64(te)
+			return tok, result, nil
+		}
+
+		if parser.isLastChunk {
+			return tokNil, nil, io.EOF
+		}
+
+		// the token starting at `ts` is incomplete, read more data.
+		parser.buf = parser.buf[ts:]
+		parser.pos += int64(ts)
+		if err := parser.readBlock(); err != nil {
+			return tokNil, nil, errors.Trace(err)
+		}
+	}
+}
+
+var (
+	keywordInsert = []byte("INSERT")
+	keywordInto   = []byte("INTO")
+	keywordValues = []byte("VALUES")
+
+	// REPLACE and IGNORE are scanned as names by both lexers and skipped by
+	// the parser.
+	keywordReplace = []byte("REPLACE")
+	keywordIgnore  = []byte("IGNORE")
+
+	keywordSet = []byte("SET")
+	// skippedStatementKeywords start the statements which mysqldump emits
+	// around the INSERT statements.
+	skippedStatementKeywords = [][]byte{keywordSet, []byte("LOCK"), []byte("UNLOCK"), []byte("USE")}
+)
+
+// scan finds the next token in data, returning its range `data[ts:te]`. If
+// the data ends before a complete token is found, it returns `tokNil` with
+// `ts` pointing at the start of the incomplete token (or `len(data)` if only
+// comments remain).
+func (parser *ChunkParser) scan(data []byte, atEOF bool) (tok token, ts int, te int, err error) {
+	p := 0
+	for p < len(data) {
+		ts = p
+		switch c := data[p]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v' || c == ',' || c == ';':
+			p++
+
+		case bytes.HasPrefix(data[p:], []byte("/*")):
+			end := bytes.Index(data[p+2:], []byte("*/"))
+			if end < 0 {
+				return incompleteToken(ts, atEOF)
+			}
+			p += end + 4
+
+		case bytes.HasPrefix(data[p:], []byte("--")):
+			end := bytes.IndexByte(data[p:], '\n')
+			if end < 0 {
+				return incompleteToken(ts, atEOF)
+			}
+			p += end + 1
+
+		case c == '(':
+			end, ok := parser.scanRow(data, p)
+			if !ok {
+				return incompleteToken(ts, atEOF)
+			}
+			return tokRow, ts, end, nil
+
+		case c == ')' || c == '\'':
+			return tokNil, ts, ts, errors.New("Syntax error")
+
+		default:
+			end, ok := parser.scanName(data, p, atEOF)
+			if !ok {
+				return incompleteToken(ts, atEOF)
+			}
+			name := data[ts:end]
+			switch {
+			case bytes.EqualFold(name, keywordInsert), bytes.EqualFold(name, keywordInto):
+				p = end
+			case bytes.EqualFold(name, keywordValues):
+				return tokValues, ts, end, nil
+			default:
+				return tokName, ts, end, nil
+			}
+		}
+	}
+	return tokNil, len(data), len(data), nil
+}
+
+func incompleteToken(ts int, atEOF bool) (token, int, int, error) {
+	if atEOF {
+		return tokNil, ts, ts, errors.New("Syntax error")
+	}
+	return tokNil, ts, ts, nil
+}
+
+// scanQuoted returns the position after the quoted string starting at
+// `data[p]`, or false if the string is not closed yet.
+func (parser *ChunkParser) scanQuoted(data []byte, p int) (int, bool) {
+	quote := data[p]
+	escapes := !parser.noBackslashEscapes && quote != '`' && !(quote == '"' && parser.ansiQuotes)
+	for p++; p < len(data); p++ {
+		switch data[p] {
+		case quote:
+			return p + 1, true
+		case '\\':
+			if escapes {
+				p++
+			}
+		}
+	}
+	return 0, false
+}
+
+// scanRow returns the position after the row `( ... )` starting at `data[p]`,
+// or false if the row is not closed yet.
+func (parser *ChunkParser) scanRow(data []byte, p int) (int, bool) {
+	depth := 0
+	for p < len(data) {
+		switch data[p] {
+		case '\'', '"', '`':
+			end, ok := parser.scanQuoted(data, p)
+			if !ok {
+				return 0, false
+			}
+			p = end
+			continue
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return p + 1, true
+			}
+		}
+		p++
+	}
+	return 0, false
+}
+
+// scanName returns the position after the (possibly qualified) name starting
+// at `data[p]`. The name may continue in the next block unless atEOF.
+func (parser *ChunkParser) scanName(data []byte, p int, atEOF bool) (int, bool) {
+	for p < len(data) {
+		switch data[p] {
+		case '"', '`':
+			end, ok := parser.scanQuoted(data, p)
+			if !ok {
+				return 0, false
+			}
+			p = end
+		case ' ', '\t', '\n', '\r', '\f', '\v', ',', ';', '(', ')', '\'':
+			return p, true
+		default:
+			p++
+		}
+	}
+	return p, atEOF
+}
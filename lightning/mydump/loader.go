@@ -17,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/pingcap/errors"
@@ -44,6 +45,11 @@ type MDTableMeta struct {
 	SchemaFile string
 	DataFiles  []string
 	charSet    string
+
+	// DataFileDigests are the expected SHA-256 digests of the data files,
+	// from the checksum manifest in the data source directory. It is nil if
+	// there is no manifest.
+	DataFileDigests map[string][]byte
 }
 
 func (m *MDTableMeta) GetSchema() string {
@@ -64,15 +70,38 @@ type MDLoader struct {
 	dbs      []*MDDatabaseMeta
 	filter   *filter.Filter
 	charSet  string
+
+	// privilegeTables are the privilege tables in the mysql schema, which
+	// are never restored as data, see PrivilegeTables.
+	privilegeTables []*MDTableMeta
+}
+
+// PrivilegeTables are the tables in the mysql schema holding the accounts and
+// their privileges, in the order they are restored. These are restored by
+// merging the accounts instead of importing the data.
+var PrivilegeTables = []string{"db", "tables_priv", "columns_priv", "user"}
+
+// IsPrivilegeTable returns whether the table is one of PrivilegeTables.
+func IsPrivilegeTable(schema string, table string) bool {
+	if !strings.EqualFold(schema, "mysql") {
+		return false
+	}
+	for _, name := range PrivilegeTables {
+		if strings.EqualFold(table, name) {
+			return true
+		}
+	}
+	return false
 }
 
 type mdLoaderSetup struct {
-	loader        *MDLoader
-	dbSchemas     []fileInfo
-	tableSchemas  []fileInfo
-	tableDatas    []fileInfo
-	dbIndexMap    map[string]int
-	tableIndexMap map[filter.Table]int
+	loader         *MDLoader
+	dbSchemas      []fileInfo
+	tableSchemas   []fileInfo
+	tableDatas     []fileInfo
+	privilegeDatas []fileInfo
+	dbIndexMap     map[string]int
+	tableIndexMap  map[filter.Table]int
 }
 
 func NewMyDumpLoader(cfg *config.Config) (*MDLoader, error) {
@@ -102,17 +131,25 @@ type fileType int
 const (
 	fileTypeDatabaseSchema fileType = iota
 	fileTypeTableSchema
-	fileTypeTableDataSQL
+	fileTypeTableData
 )
 
+const ndjsonSuffix = ".ndjson"
+
+// IsNDJSONFile returns whether the data file consists of JSON objects, one per
+// line, instead of INSERT statements.
+func IsNDJSONFile(path string) bool {
+	return strings.HasSuffix(path, ndjsonSuffix)
+}
+
 func (ftype fileType) String() string {
 	switch ftype {
 	case fileTypeDatabaseSchema:
 		return "database schema"
 	case fileTypeTableSchema:
 		return "table schema"
-	case fileTypeTableDataSQL:
-		return "table data SQL"
+	case fileTypeTableData:
+		return "table data"
 	default:
 		return "(unknown)"
 	}
@@ -141,8 +178,11 @@ func (s *mdLoaderSetup) setup(dir string) error {
 			db    —— {db}-schema-create.sql
 			table —— {db}.{table}-schema.sql
 			sql   —— {db}.{table}.{part}.sql / {db}.{table}.sql
+			json  —— {db}.{table}.{part}.ndjson / {db}.{table}.ndjson
+			avro  —— {db}.{table}.{part}.avro / {db}.{table}.avro
+			fwf   —— {db}.{table}.{part}.fwf / {db}.{table}.fwf
 	*/
-	if !common.IsDirExists(dir) {
+	if !IsRemotePath(dir) && !common.IsDirExists(dir) {
 		return errors.Annotatef(errDirNotExists, "dir %s", dir)
 	}
 
@@ -173,6 +213,11 @@ func (s *mdLoaderSetup) setup(dir string) error {
 		}
 	}
 
+	digests, err := readManifest(dir)
+	if err != nil {
+		return errors.Annotatef(err, "cannot read %s", ManifestFileName)
+	}
+
 	// Sql file for restore data
 	for _, fileInfo := range s.tableDatas {
 		tableMeta, dbExists, tableExists := s.insertTable(fileInfo.tableName, "")
@@ -184,12 +229,43 @@ func (s *mdLoaderSetup) setup(dir string) error {
 			}
 		}
 		tableMeta.DataFiles = append(tableMeta.DataFiles, fileInfo.path)
+		if digests != nil {
+			digest, ok := digests[fileInfo.path]
+			if !ok {
+				return errors.Errorf("data file %s is not listed in %s", fileInfo.path, ManifestFileName)
+			}
+			if tableMeta.DataFileDigests == nil {
+				tableMeta.DataFileDigests = make(map[string][]byte)
+			}
+			tableMeta.DataFileDigests[fileInfo.path] = digest
+		}
+	}
+
+	for _, name := range PrivilegeTables {
+		var tableMeta *MDTableMeta
+		for _, fileInfo := range s.privilegeDatas {
+			if !strings.EqualFold(fileInfo.tableName.Name, name) {
+				continue
+			}
+			if tableMeta == nil {
+				tableMeta = &MDTableMeta{DB: "mysql", Name: name, charSet: s.loader.charSet}
+				s.loader.privilegeTables = append(s.loader.privilegeTables, tableMeta)
+			}
+			tableMeta.DataFiles = append(tableMeta.DataFiles, fileInfo.path)
+		}
 	}
 
 	return nil
 }
 
 func (s *mdLoaderSetup) listFiles(dir string) error {
+	if isHDFSPath(dir) {
+		return errors.Trace(walkHDFS(dir, s.addFile))
+	}
+	if IsRemotePath(dir) {
+		return errors.Trace(s.listRemoteFiles(dir))
+	}
+
 	// `filepath.Walk` yields the paths in a deterministic (lexicographical) order,
 	// meaning the file and chunk orders will be the same everytime it is called
 	// (as long as the source is immutable).
@@ -201,63 +277,104 @@ func (s *mdLoaderSetup) listFiles(dir string) error {
 		if f == nil || f.IsDir() {
 			return nil
 		}
+		return s.addFile(path, f.Name())
+	})
 
-		fname := strings.TrimSpace(f.Name())
-		info := fileInfo{path: path}
-
-		var (
-			ftype         fileType
-			qualifiedName string
-		)
-		switch {
-		case strings.HasSuffix(fname, "-schema-create.sql"):
-			ftype = fileTypeDatabaseSchema
-			qualifiedName = fname[:len(fname)-18] + "."
-
-		case strings.HasSuffix(fname, "-schema.sql"):
-			ftype = fileTypeTableSchema
-			qualifiedName = fname[:len(fname)-11]
-
-			// ignore functionality :
-			// 		- view
-			//		- triggers
-		case strings.HasSuffix(fname, "-schema-view.sql"),
-			strings.HasSuffix(fname, "-schema-trigger.sql"),
-			strings.HasSuffix(fname, "-schema-post.sql"):
-			common.AppLogger.Warn("[loader] ignore unsupport view/trigger:", path)
-			return nil
-		case strings.HasSuffix(fname, ".sql"):
-			ftype = fileTypeTableDataSQL
-			qualifiedName = fname[:len(fname)-4]
-		default:
-			return nil
-		}
+	return errors.Trace(err)
+}
 
-		matchRes := tableNameRegexp.FindStringSubmatch(qualifiedName)
-		if len(matchRes) != 3 {
-			common.AppLogger.Debugf("[loader] ignore almost %s file: %s", ftype, path)
-			return nil
+// listRemoteFiles lists the files of an HTTP data source from its checksum
+// manifest, which must list all the files since HTTP has no directory
+// listing. The files are sorted by path to keep the order deterministic.
+func (s *mdLoaderSetup) listRemoteFiles(dir string) error {
+	digests, err := readManifest(dir)
+	if err != nil {
+		return errors.Annotatef(err, "cannot read %s", ManifestFileName)
+	}
+	if digests == nil {
+		return errors.Annotatef(errMissingFile, "remote data source %s must have %s listing all files", dir, ManifestFileName)
+	}
+
+	paths := make([]string, 0, len(digests))
+	for path := range digests {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := s.addFile(path, path[strings.LastIndexByte(path, '/')+1:]); err != nil {
+			return errors.Trace(err)
 		}
-		info.tableName.Schema = matchRes[1]
-		info.tableName.Name = matchRes[2]
+	}
+	return nil
+}
 
-		if s.loader.shouldSkip(&info.tableName) {
-			common.AppLogger.Infof("[filter] ignoring table file %s", path)
+// addFile classifies the file at path by its name.
+func (s *mdLoaderSetup) addFile(path string, name string) error {
+	fname := strings.TrimSpace(name)
+	info := fileInfo{path: path}
+
+	var (
+		ftype         fileType
+		qualifiedName string
+	)
+	switch {
+	case strings.HasSuffix(fname, "-schema-create.sql"):
+		ftype = fileTypeDatabaseSchema
+		qualifiedName = fname[:len(fname)-18] + "."
+
+	case strings.HasSuffix(fname, "-schema.sql"):
+		ftype = fileTypeTableSchema
+		qualifiedName = fname[:len(fname)-11]
+
+		// ignore functionality :
+		// 		- view
+		//		- triggers
+	case strings.HasSuffix(fname, "-schema-view.sql"),
+		strings.HasSuffix(fname, "-schema-trigger.sql"),
+		strings.HasSuffix(fname, "-schema-post.sql"):
+		common.AppLogger.Warn("[loader] ignore unsupport view/trigger:", path)
+		return nil
+	default:
+		reader := LookupSourceReader(fname)
+		if reader == nil {
 			return nil
 		}
+		ftype = fileTypeTableData
+		qualifiedName = fname[:len(fname)-len(reader.Suffix())]
+	}
+
+	matchRes := tableNameRegexp.FindStringSubmatch(qualifiedName)
+	if len(matchRes) != 3 {
+		common.AppLogger.Debugf("[loader] ignore almost %s file: %s", ftype, path)
+		return nil
+	}
+	info.tableName.Schema = matchRes[1]
+	info.tableName.Name = matchRes[2]
 
-		switch ftype {
-		case fileTypeDatabaseSchema:
-			s.dbSchemas = append(s.dbSchemas, info)
-		case fileTypeTableSchema:
-			s.tableSchemas = append(s.tableSchemas, info)
-		case fileTypeTableDataSQL:
-			s.tableDatas = append(s.tableDatas, info)
+	if IsPrivilegeTable(info.tableName.Schema, info.tableName.Name) {
+		if ftype == fileTypeTableData {
+			if !strings.HasSuffix(fname, ".sql") {
+				return errors.Errorf("privilege table data file %s must consist of INSERT statements", path)
+			}
+			s.privilegeDatas = append(s.privilegeDatas, info)
 		}
 		return nil
-	})
+	}
 
-	return errors.Trace(err)
+	if s.loader.shouldSkip(&info.tableName) {
+		common.AppLogger.Infof("[filter] ignoring table file %s", path)
+		return nil
+	}
+
+	switch ftype {
+	case fileTypeDatabaseSchema:
+		s.dbSchemas = append(s.dbSchemas, info)
+	case fileTypeTableSchema:
+		s.tableSchemas = append(s.tableSchemas, info)
+	case fileTypeTableData:
+		s.tableDatas = append(s.tableDatas, info)
+	}
+	return nil
 }
 
 func (l *MDLoader) shouldSkip(table *filter.Table) bool {
@@ -302,3 +419,10 @@ func (s *mdLoaderSetup) insertTable(tableName filter.Table, path string) (*MDTab
 func (l *MDLoader) GetDatabases() []*MDDatabaseMeta {
 	return l.dbs
 }
+
+// GetPrivilegeTables returns the privilege tables in the mysql schema found
+// in the data source, in the order of PrivilegeTables. Only the data files are
+// recorded.
+func (l *MDLoader) GetPrivilegeTables() []*MDTableMeta {
+	return l.privilegeTables
+}
@@ -14,6 +14,7 @@
 package mydump_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
@@ -256,3 +257,89 @@ func (s *testMydumpLoaderSuite) TestTablesWithDots(c *C) {
 		},
 	}})
 }
+
+func (s *testMydumpLoaderSuite) TestManifest(c *C) {
+	/*
+		path/
+			SHA256SUMS
+			db-schema-create.sql
+			db.tbl-schema.sql
+			db.tbl.1.sql
+			a/
+				db.tbl.2.sql
+	*/
+
+	dir := s.cfg.Mydumper.SourceDir
+	err := ioutil.WriteFile(path.Join(dir, "db-schema-create.sql"), nil, 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(path.Join(dir, "db.tbl-schema.sql"), nil, 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(path.Join(dir, "db.tbl.1.sql"), nil, 0644)
+	c.Assert(err, IsNil)
+	err = os.Mkdir(path.Join(dir, "a"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(path.Join(dir, "a", "db.tbl.2.sql"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	const digest1 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	const digest2 = "0000000000000000000000000000000000000000000000000000000000000002"
+	err = ioutil.WriteFile(path.Join(dir, md.ManifestFileName), []byte(digest1+"  db.tbl.1.sql\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = md.NewMyDumpLoader(s.cfg)
+	c.Assert(err, ErrorMatches, `data file .*/a/db\.tbl\.2\.sql is not listed in SHA256SUMS`)
+
+	err = ioutil.WriteFile(path.Join(dir, md.ManifestFileName), []byte(digest1+"  db.tbl.1.sql\n"+digest2+" *a/db.tbl.2.sql\n"), 0644)
+	c.Assert(err, IsNil)
+	mdl, err := md.NewMyDumpLoader(s.cfg)
+	c.Assert(err, IsNil)
+	tableMeta := mdl.GetDatabases()[0].Tables[0]
+	c.Assert(tableMeta.DataFiles, HasLen, 2)
+	c.Assert(fmt.Sprintf("%x", tableMeta.DataFileDigests[path.Join(dir, "db.tbl.1.sql")]), Equals, digest1)
+	c.Assert(fmt.Sprintf("%x", tableMeta.DataFileDigests[path.Join(dir, "a", "db.tbl.2.sql")]), Equals, digest2)
+}
+
+func (s *testMydumpLoaderSuite) TestPrivilegeTables(c *C) {
+	/*
+		path/
+			mysql-schema-create.sql
+			mysql.user-schema.sql
+			mysql.user.sql
+			mysql.db.1.sql
+			mysql.db.2.sql
+			mysql.stats_meta-schema.sql
+			mysql.stats_meta.sql
+	*/
+
+	dir := s.cfg.Mydumper.SourceDir
+	for _, name := range []string{
+		"mysql-schema-create.sql",
+		"mysql.user-schema.sql",
+		"mysql.user.sql",
+		"mysql.db.1.sql",
+		"mysql.db.2.sql",
+		"mysql.stats_meta-schema.sql",
+		"mysql.stats_meta.sql",
+	} {
+		err := ioutil.WriteFile(path.Join(dir, name), nil, 0644)
+		c.Assert(err, IsNil)
+	}
+
+	mdl, err := md.NewMyDumpLoader(s.cfg)
+	c.Assert(err, IsNil)
+
+	dbMetas := mdl.GetDatabases()
+	c.Assert(dbMetas, HasLen, 1)
+	c.Assert(dbMetas[0].Tables, HasLen, 1)
+	c.Assert(dbMetas[0].Tables[0].Name, Equals, "stats_meta")
+
+	privilegeTables := mdl.GetPrivilegeTables()
+	c.Assert(privilegeTables, HasLen, 2)
+	c.Assert(privilegeTables[0].Name, Equals, "db")
+	c.Assert(privilegeTables[0].DataFiles, DeepEquals, []string{path.Join(dir, "mysql.db.1.sql"), path.Join(dir, "mysql.db.2.sql")})
+	c.Assert(privilegeTables[1].Name, Equals, "user")
+	c.Assert(privilegeTables[1].DataFiles, DeepEquals, []string{path.Join(dir, "mysql.user.sql")})
+
+	c.Assert(md.IsPrivilegeTable("MySQL", "User"), IsTrue)
+	c.Assert(md.IsPrivilegeTable("mysql", "stats_meta"), IsFalse)
+	c.Assert(md.IsPrivilegeTable("db", "user"), IsFalse)
+}
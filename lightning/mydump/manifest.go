@@ -0,0 +1,119 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// ManifestFileName is the name of the checksum manifest in the data source
+// directory, in the format produced by `sha256sum`.
+const ManifestFileName = "SHA256SUMS"
+
+// readManifest parses the checksum manifest in the directory, which may be a
+// URL. The keys of the result are the paths of the files joined with the
+// directory. Returns nil if the manifest does not exist.
+func readManifest(dir string) (map[string][]byte, error) {
+	file, err := OpenDataFile(JoinPath(dir, ManifestFileName))
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer file.Close()
+
+	digests := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		// each line is "<hex digest> <mode><path>", where the mode is ' ' for
+		// text or '*' for binary.
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 || len(fields[1]) < 2 {
+			return nil, errors.Errorf("invalid %s line %d", ManifestFileName, lineNo)
+		}
+		digest, err := hex.DecodeString(fields[0])
+		if err != nil || len(digest) != sha256.Size {
+			return nil, errors.Errorf("invalid %s line %d: bad SHA-256 digest", ManifestFileName, lineNo)
+		}
+		name := fields[1][1:]
+		digests[JoinPath(dir, filepath.FromSlash(name))] = digest
+	}
+	return digests, errors.Trace(scanner.Err())
+}
+
+// DigestReader computes the SHA-256 digest of the data read through it, and
+// fails at the end of the file if the digest differs from the expected one.
+type DigestReader struct {
+	reader   io.Reader
+	path     string
+	hash     hash.Hash
+	expected []byte
+	err      error
+}
+
+// NewDigestReader creates a reader verifying the content of the file at path.
+// The reader must be positioned at the start of the file.
+func NewDigestReader(reader io.Reader, path string, expected []byte) *DigestReader {
+	return &DigestReader{
+		reader:   reader,
+		path:     path,
+		hash:     sha256.New(),
+		expected: expected,
+	}
+}
+
+func (r *DigestReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := r.hash.Sum(nil); !bytes.Equal(actual, r.expected) {
+			r.err = errors.Errorf("%s has SHA-256 digest %x, but %x is expected by %s", r.path, actual, r.expected, ManifestFileName)
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+// Skip reads and hashes the first n bytes, so the reader can continue from
+// the middle of the file.
+func (r *DigestReader) Skip(n int64) error {
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	return errors.Trace(err)
+}
+
+// Verify reads and hashes the rest of the file, and returns an error if the
+// digest does not match.
+func (r *DigestReader) Verify() error {
+	_, err := io.Copy(ioutil.Discard, r)
+	return errors.Trace(err)
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&testDigestReaderSuite{})
+
+type testDigestReaderSuite struct{}
+
+func (s *testDigestReaderSuite) TestVerify(c *C) {
+	const content = "INSERT INTO t VALUES (1);\n"
+	digest := sha256.Sum256([]byte(content))
+
+	reader := mydump.NewDigestReader(strings.NewReader(content), "db.t.sql", digest[:])
+	data, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, content)
+	c.Assert(reader.Verify(), IsNil)
+
+	reader = mydump.NewDigestReader(strings.NewReader(content), "db.t.sql", digest[:])
+	c.Assert(reader.Skip(12), IsNil)
+	c.Assert(reader.Verify(), IsNil)
+
+	reader = mydump.NewDigestReader(strings.NewReader(content+"\n"), "db.t.sql", digest[:])
+	buf := make([]byte, 4)
+	_, err = reader.Read(buf)
+	c.Assert(err, IsNil)
+	c.Assert(reader.Verify(), ErrorMatches, "db.t.sql has SHA-256 digest [0-9a-f]{64}, but [0-9a-f]{64} is expected by SHA256SUMS")
+	_, err = reader.Read(buf)
+	c.Assert(err, ErrorMatches, "db.t.sql has SHA-256 digest .*")
+}
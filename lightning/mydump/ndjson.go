@@ -0,0 +1,232 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+// ioWorkerReader limits the IO concurrency of the underlying reader.
+type ioWorkerReader struct {
+	reader    io.Reader
+	ioWorkers *worker.Pool
+}
+
+func (r *ioWorkerReader) Read(p []byte) (int, error) {
+	w := r.ioWorkers.Apply()
+	defer r.ioWorkers.Recycle(w)
+	return r.reader.Read(p)
+}
+
+// NDJSONParser is a parser of the newline-delimited JSON data files, where
+// each line is a JSON object keyed by the column names. Each object is turned
+// into a row of SQL literals in the table order, leaving the conversion to
+// the column types to TiDB. Columns missing from the object take their
+// default values.
+type NDJSONParser struct {
+	reader    io.Reader
+	bufReader *bufio.Reader
+
+	pos     int64
+	lastRow Row
+	rowBuf  bytes.Buffer
+
+	columns     []byte
+	columnIndex map[string]int
+	values      []json.RawMessage
+
+	noBackslashEscapes bool
+}
+
+// NewNDJSONParser creates a new parser of a NDJSON file. The columnNames are
+// the columns of the target table which can be assigned.
+func NewNDJSONParser(reader io.Reader, blockBufSize int64, columnNames []string, ioWorkers *worker.Pool) *NDJSONParser {
+	var columns strings.Builder
+	columnIndex := make(map[string]int, len(columnNames))
+	columns.WriteByte('(')
+	for i, name := range columnNames {
+		if i > 0 {
+			columns.WriteByte(',')
+		}
+		common.WriteMySQLIdentifier(&columns, name)
+		columnIndex[strings.ToLower(name)] = i
+	}
+	columns.WriteByte(')')
+
+	return &NDJSONParser{
+		reader:      reader,
+		bufReader:   bufio.NewReaderSize(&ioWorkerReader{reader: reader, ioWorkers: ioWorkers}, int(blockBufSize)),
+		columns:     []byte(columns.String()),
+		columnIndex: columnIndex,
+		values:      make([]json.RawMessage, len(columnNames)),
+	}
+}
+
+// SetSQLMode changes how the strings are escaped, which must match the SQL
+// mode used to encode the rows.
+func (parser *NDJSONParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.noBackslashEscapes = mode.HasNoBackslashEscapesMode()
+}
+
+// Reader returns the underlying reader of this parser.
+func (parser *NDJSONParser) Reader() io.Reader {
+	return parser.reader
+}
+
+// SetPos changes the reported position and row ID.
+func (parser *NDJSONParser) SetPos(pos int64, rowID int64) {
+	parser.pos = pos
+	parser.lastRow.RowID = rowID
+}
+
+// Pos returns the current file offset.
+func (parser *NDJSONParser) Pos() int64 {
+	return parser.pos
+}
+
+// LastRow is the row parsed by the last call to ReadRow(). The content is
+// only valid until the next call to ReadRow().
+func (parser *NDJSONParser) LastRow() Row {
+	return parser.lastRow
+}
+
+// Columns is the list of all assignable columns of the table.
+func (parser *NDJSONParser) Columns() []byte {
+	return parser.columns
+}
+
+// ReadRow reads the next non-empty line from the data file.
+func (parser *NDJSONParser) ReadRow() error {
+	for {
+		line, err := parser.bufReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return errors.Trace(err)
+		}
+		if len(line) == 0 {
+			return io.EOF
+		}
+
+		offset := parser.pos
+		parser.pos += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := parser.convertRow(line); err != nil {
+			return errors.Annotatef(err, "invalid JSON object at offset %d", offset)
+		}
+		parser.lastRow.RowID++
+		parser.lastRow.Row = parser.rowBuf.Bytes()
+		return nil
+	}
+}
+
+func (parser *NDJSONParser) convertRow(line []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(line, &object); err != nil {
+		return errors.Trace(err)
+	}
+
+	for i := range parser.values {
+		parser.values[i] = nil
+	}
+	for key, value := range object {
+		i, ok := parser.columnIndex[strings.ToLower(key)]
+		if !ok {
+			return errors.Errorf("unknown column %q", key)
+		}
+		parser.values[i] = value
+	}
+
+	parser.rowBuf.Reset()
+	parser.rowBuf.WriteByte('(')
+	for i, value := range parser.values {
+		if i > 0 {
+			parser.rowBuf.WriteByte(',')
+		}
+		if err := parser.writeValue(value); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	parser.rowBuf.WriteByte(')')
+	return nil
+}
+
+func (parser *NDJSONParser) writeValue(value json.RawMessage) error {
+	if value == nil {
+		parser.rowBuf.WriteString("DEFAULT")
+		return nil
+	}
+
+	switch value[0] {
+	case 'n':
+		parser.rowBuf.WriteString("NULL")
+	case 't':
+		parser.rowBuf.WriteByte('1')
+	case 'f':
+		parser.rowBuf.WriteByte('0')
+	case '"':
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return errors.Trace(err)
+		}
+		parser.writeString(s)
+	case '{', '[':
+		// nested objects and arrays are stored as JSON text.
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, value); err != nil {
+			return errors.Trace(err)
+		}
+		parser.writeString(compacted.String())
+	default:
+		// the JSON number syntax is also valid in SQL.
+		parser.rowBuf.Write(value)
+	}
+	return nil
+}
+
+func (parser *NDJSONParser) writeString(s string) {
+	writeSQLString(&parser.rowBuf, s, parser.noBackslashEscapes)
+}
+
+// writeSQLString writes s as a quoted SQL string literal.
+func writeSQLString(buf *bytes.Buffer, s string, noBackslashEscapes bool) {
+	buf.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'':
+			buf.WriteString("''")
+		case '\\':
+			if noBackslashEscapes {
+				buf.WriteByte(c)
+			} else {
+				buf.WriteString(`\\`)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('\'')
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testNDJSONParserSuite{})
+
+type testNDJSONParserSuite struct{}
+
+func (s *testNDJSONParserSuite) TestReadRow(c *C) {
+	reader := strings.NewReader(`{"id": 1, "name": "it's", "tags": ["a", "b"], "ok": true}
+
+{"ID": 2.5e1, "path": "C:\\tmp", "ok": null}
+{"id": 3}`)
+
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	parser := mydump.NewNDJSONParser(reader, config.ReadBlockSize, []string{"id", "name", "tags", "ok", "path"}, ioWorkers)
+	c.Assert(parser.Columns(), DeepEquals, []byte("(`id`,`name`,`tags`,`ok`,`path`)"))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().RowID, Equals, int64(1))
+	c.Assert(string(parser.LastRow().Row), Equals, `(1,'it''s','["a","b"]',1,DEFAULT)`)
+	c.Assert(parser.Pos(), Equals, int64(58))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().RowID, Equals, int64(2))
+	c.Assert(string(parser.LastRow().Row), Equals, `(2.5e1,DEFAULT,DEFAULT,NULL,'C:\\tmp')`)
+	c.Assert(parser.Pos(), Equals, int64(104))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, `(3,DEFAULT,DEFAULT,DEFAULT,DEFAULT)`)
+	c.Assert(parser.Pos(), Equals, int64(113))
+
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testNDJSONParserSuite) TestNoBackslashEscapes(c *C) {
+	reader := strings.NewReader(`{"path": "C:\\tmp"}` + "\n")
+
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	parser := mydump.NewNDJSONParser(reader, config.ReadBlockSize, []string{"path"}, ioWorkers)
+	parser.SetSQLMode(mysql.ModeNoBackslashEscapes)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, `('C:\tmp')`)
+}
+
+func (s *testNDJSONParserSuite) TestInvalidRow(c *C) {
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+
+	parser := mydump.NewNDJSONParser(strings.NewReader(`{"id": 1, "unknown": 2}`), config.ReadBlockSize, []string{"id"}, ioWorkers)
+	c.Assert(parser.ReadRow(), ErrorMatches, `.*unknown column "unknown".*`)
+
+	parser = mydump.NewNDJSONParser(strings.NewReader(`[1, 2]`), config.ReadBlockSize, []string{"id"}, ioWorkers)
+	c.Assert(parser.ReadRow(), ErrorMatches, `invalid JSON object at offset 0.*`)
+}
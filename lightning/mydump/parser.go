@@ -25,6 +25,23 @@ import (
 	"github.com/pingcap/tidb-lightning/lightning/worker"
 )
 
+// Parser reads the rows out of a data file.
+type Parser interface {
+	// Pos returns the current file offset.
+	Pos() int64
+	// SetPos changes the reported position and row ID.
+	SetPos(pos int64, rowID int64)
+	// ReadRow reads the next row, or returns io.EOF at the end of file.
+	ReadRow() error
+	// LastRow is the row parsed by the last call to ReadRow().
+	LastRow() Row
+	// Columns is the list of columns of the last row in the form `(a, b, c)`,
+	// or nil if the row contains all columns in the table order.
+	Columns() []byte
+	// Reader returns the underlying reader.
+	Reader() io.Reader
+}
+
 // ChunkParser is a parser of the data files (the file containing only INSERT
 // statements).
 type ChunkParser struct {
@@ -34,6 +51,10 @@ type ChunkParser struct {
 	blockBuf    []byte
 	isLastChunk bool
 
+	// quoting rules of the SQL mode the data file was dumped under
+	noBackslashEscapes bool
+	ansiQuotes         bool
+
 	lastRow Row
 	// Current file offset.
 	pos int64
@@ -42,7 +63,7 @@ type ChunkParser struct {
 	TableName []byte
 	// The list of columns in the form `(a, b, c)` in the last INSERT statement.
 	// Assumed to be constant throughout the entire file.
-	Columns []byte
+	columns []byte
 
 	// cache
 	remainBuf *bytes.Buffer
@@ -134,8 +155,11 @@ func (parser *ChunkParser) ReadRow() error {
 	//
 	// 		`tableName` (...) VALUES (...) (...) (...)
 	//
-	// Keywords like INSERT, INTO and separators like ',' and ';' are treated
-	// like comments and ignored. Therefore, this parser will accept some
+	// Keywords like INSERT, INTO, REPLACE, IGNORE and separators like ',' and
+	// ';' are treated like comments and ignored. How duplicated rows are
+	// handled is decided by the mydumper.on-duplicate config instead.
+	// Statements like SET, LOCK TABLES and UNLOCK TABLES which mysqldump emits
+	// around the INSERT statements are skipped by the lexer. Therefore, this parser will accept some
 	// nonsense input. The advantage is the parser becomes extremely simple,
 	// suitable for us where we just want to quickly and accurately split the
 	// file apart, not to validate the content.
@@ -153,7 +177,7 @@ func (parser *ChunkParser) ReadRow() error {
 	st := stateRow
 
 	for {
-		tok, content, err := parser.lex()
+		tok, content, err := parser.nextToken()
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -165,14 +189,17 @@ func (parser *ChunkParser) ReadRow() error {
 				row.Row = content
 				return nil
 			case stateColumns:
-				parser.Columns = content
+				parser.columns = content
 				continue
 			}
 
 		case tokName:
+			if bytes.EqualFold(content, keywordReplace) || bytes.EqualFold(content, keywordIgnore) {
+				continue
+			}
 			st = stateColumns
 			parser.TableName = content
-			parser.Columns = nil
+			parser.columns = nil
 			continue
 
 		case tokValues:
@@ -190,6 +217,11 @@ func (parser *ChunkParser) LastRow() Row {
 	return parser.lastRow
 }
 
+// Columns is the list of columns in the last INSERT statement.
+func (parser *ChunkParser) Columns() []byte {
+	return parser.columns
+}
+
 // ReadChunks parses the entire file and splits it into continuous chunks of
 // size >= minSize.
 func (parser *ChunkParser) ReadChunks(minSize int64) ([]Chunk, error) {
@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb-lightning/lightning/config"
 	"github.com/pingcap/tidb-lightning/lightning/mydump"
 	"github.com/pingcap/tidb-lightning/lightning/worker"
@@ -50,7 +51,7 @@ func (s *testMydumpParserSuite) TestReadRow(c *C) {
 		Row:   []byte("(1, 2, 3)"),
 	})
 	c.Assert(parser.TableName, DeepEquals, []byte("`namespaced`.`table`"))
-	c.Assert(parser.Columns, DeepEquals, []byte("(columns, more, columns)"))
+	c.Assert(parser.Columns(), DeepEquals, []byte("(columns, more, columns)"))
 	c.Assert(parser.Pos(), Equals, int64(97))
 
 	c.Assert(parser.ReadRow(), IsNil)
@@ -59,7 +60,7 @@ func (s *testMydumpParserSuite) TestReadRow(c *C) {
 		Row:   []byte("(4, 5, 6)"),
 	})
 	c.Assert(parser.TableName, DeepEquals, []byte("`namespaced`.`table`"))
-	c.Assert(parser.Columns, DeepEquals, []byte("(columns, more, columns)"))
+	c.Assert(parser.Columns(), DeepEquals, []byte("(columns, more, columns)"))
 	c.Assert(parser.Pos(), Equals, int64(108))
 
 	c.Assert(parser.ReadRow(), IsNil)
@@ -68,7 +69,7 @@ func (s *testMydumpParserSuite) TestReadRow(c *C) {
 		Row:   []byte("(7,8,9)"),
 	})
 	c.Assert(parser.TableName, DeepEquals, []byte("`namespaced`.`table`"))
-	c.Assert(parser.Columns, DeepEquals, []byte("(x,y,z)"))
+	c.Assert(parser.Columns(), DeepEquals, []byte("(x,y,z)"))
 	c.Assert(parser.Pos(), Equals, int64(159))
 
 	c.Assert(parser.ReadRow(), IsNil)
@@ -77,7 +78,7 @@ func (s *testMydumpParserSuite) TestReadRow(c *C) {
 		Row:   []byte("(10, 11, 12, '(13)', '(', 14, ')')"),
 	})
 	c.Assert(parser.TableName, DeepEquals, []byte("another_table"))
-	c.Assert(parser.Columns, IsNil)
+	c.Assert(parser.Columns(), IsNil)
 	c.Assert(parser.Pos(), Equals, int64(222))
 
 	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
@@ -157,3 +158,93 @@ func (s *testMydumpParserSuite) TestNestedRow(c *C) {
 		},
 	})
 }
+
+func (s *testMydumpParserSuite) TestNoBackslashEscapes(c *C) {
+	reader := strings.NewReader(`INSERT INTO t VALUES ('C:\', 'ten o''clock'),('\');`)
+
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	parser := mydump.NewChunkParser(reader, config.ReadBlockSize, ioWorkers)
+	parser.SetSQLMode(mysql.ModeNoBackslashEscapes)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []byte(`('C:\', 'ten o''clock')`))
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []byte(`('\')`))
+	c.Assert(parser.Pos(), Equals, int64(50))
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testMydumpParserSuite) TestANSIQuotes(c *C) {
+	reader := strings.NewReader(`
+		/* dumped with ANSI_QUOTES */
+		INSERT INTO "db"."a\" ("x", "y") VALUES ('a\'b', 1);
+	`)
+
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	parser := mydump.NewChunkParser(reader, config.ReadBlockSize, ioWorkers)
+	parser.SetSQLMode(mysql.ModeANSIQuotes)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.TableName, DeepEquals, []byte(`"db"."a\"`))
+	c.Assert(parser.Columns(), DeepEquals, []byte(`("x", "y")`))
+	c.Assert(parser.LastRow().Row, DeepEquals, []byte(`('a\'b', 1)`))
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testMydumpParserSuite) TestReplaceAndInsertIgnore(c *C) {
+	for _, sqlMode := range []mysql.SQLMode{0, mysql.ModeNoBackslashEscapes} {
+		reader := strings.NewReader(
+			"REPLACE INTO `a` (x, y) VALUES (1, 2);" +
+				"insert ignore into `b` values (3, 4);" +
+				"Replace `c` Values (5, 6);",
+		)
+
+		ioWorkers := worker.NewPool(context.Background(), 5, "test")
+		parser := mydump.NewChunkParser(reader, config.ReadBlockSize, ioWorkers)
+		parser.SetSQLMode(sqlMode)
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.TableName, DeepEquals, []byte("`a`"))
+		c.Assert(parser.Columns(), DeepEquals, []byte("(x, y)"))
+		c.Assert(parser.LastRow().Row, DeepEquals, []byte("(1, 2)"))
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.TableName, DeepEquals, []byte("`b`"))
+		c.Assert(parser.Columns(), IsNil)
+		c.Assert(parser.LastRow().Row, DeepEquals, []byte("(3, 4)"))
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.TableName, DeepEquals, []byte("`c`"))
+		c.Assert(parser.LastRow().Row, DeepEquals, []byte("(5, 6)"))
+		c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+	}
+}
+
+func (s *testMydumpParserSuite) TestMysqldumpStatements(c *C) {
+	for _, sqlMode := range []mysql.SQLMode{0, mysql.ModeNoBackslashEscapes} {
+		reader := strings.NewReader(`/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+SET NAMES utf8mb4;
+SET time_zone = '+00:00; still quoted';
+USE ` + "`db`" + `;
+LOCK TABLES ` + "`t`" + ` WRITE;
+/*!40000 ALTER TABLE ` + "`t`" + ` DISABLE KEYS */;
+INSERT INTO ` + "`t`" + ` VALUES (1,'a;b'),(2,'c');
+/*!40000 ALTER TABLE ` + "`t`" + ` ENABLE KEYS */;
+UNLOCK TABLES;
+SET SQL_MODE=@OLD_SQL_MODE
+`)
+
+		ioWorkers := worker.NewPool(context.Background(), 5, "test")
+		parser := mydump.NewChunkParser(reader, config.ReadBlockSize, ioWorkers)
+		parser.SetSQLMode(sqlMode)
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.TableName, DeepEquals, []byte("`t`"))
+		c.Assert(parser.Columns(), IsNil)
+		c.Assert(parser.LastRow().Row, DeepEquals, []byte("(1,'a;b')"))
+
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.LastRow().Row, DeepEquals, []byte("(2,'c')"))
+		c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"io"
+
+	"github.com/pingcap/errors"
+)
+
+// prefetchBlock is a block read ahead from the file, err is the error after
+// the data.
+type prefetchBlock struct {
+	data []byte
+	err  error
+}
+
+// prefetchReader reads a data file ahead in the background, keeping up to a
+// window of blocks buffered, so the parser does not wait for the latency of
+// every sequential read on slow storage such as NFS or HTTP.
+type prefetchReader struct {
+	file      DataFile
+	blockSize int
+	blocks    int
+
+	// pos is the position of the consumer, which is behind the position of
+	// the file by the blocks buffered.
+	pos     int64
+	block   []byte
+	current []byte
+	err     error
+
+	// the background filling, only running between the first Read and the
+	// next Seek or Close.
+	ch   chan prefetchBlock
+	free chan []byte
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPrefetchReader wraps the newly opened file to be read ahead in blocks
+// of blockSize bytes, up to window bytes. The reading starts from the first
+// Read and restarts after every Seek. The file is closed with the reader.
+func NewPrefetchReader(file DataFile, blockSize int64, window int64) DataFile {
+	blocks := int((window + blockSize - 1) / blockSize)
+	if blocks < 1 {
+		blocks = 1
+	}
+	return &prefetchReader{
+		file:      file,
+		blockSize: int(blockSize),
+		blocks:    blocks,
+		free:      make(chan []byte, blocks+1),
+	}
+}
+
+func (r *prefetchReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.ch == nil {
+			r.startFill()
+		}
+		r.recycle()
+		block := <-r.ch
+		r.block, r.current, r.err = block.data, block.data, block.err
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *prefetchReader) startFill() {
+	r.ch = make(chan prefetchBlock, r.blocks-1)
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.fill(r.ch, r.stop, r.done)
+}
+
+// fill reads the blocks until the end of the file, an error, or being
+// stopped. At most `blocks` blocks are read ahead: those buffered in the
+// channel plus the one waiting to be sent.
+func (r *prefetchReader) fill(ch chan<- prefetchBlock, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		var buf []byte
+		select {
+		case buf = <-r.free:
+		default:
+			buf = make([]byte, r.blockSize)
+		}
+		n, err := io.ReadFull(r.file, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		select {
+		case ch <- prefetchBlock{data: buf[:n], err: err}:
+		case <-stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// recycle returns the consumed block to be filled again.
+func (r *prefetchReader) recycle() {
+	if cap(r.block) == r.blockSize {
+		select {
+		case r.free <- r.block[:r.blockSize]:
+		default:
+		}
+	}
+	r.block, r.current = nil, nil
+}
+
+// stopFill waits for the background filling to stop, discarding the blocks
+// read ahead.
+func (r *prefetchReader) stopFill() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.ch, r.stop, r.done = nil, nil, nil
+}
+
+func (r *prefetchReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		r.stopFill()
+		end, err := r.file.Seek(offset, io.SeekEnd)
+		if err != nil {
+			return r.pos, errors.Trace(err)
+		}
+		r.reset(end)
+		return end, nil
+	default:
+		return r.pos, errors.Errorf("invalid whence %d", whence)
+	}
+	if pos < 0 {
+		return r.pos, errors.Errorf("negative position %d", pos)
+	}
+	// seeking forward inside the current block keeps the blocks read ahead.
+	if pos >= r.pos && pos-r.pos <= int64(len(r.current)) {
+		r.current = r.current[pos-r.pos:]
+		r.pos = pos
+		return pos, nil
+	}
+	r.stopFill()
+	if _, err := r.file.Seek(pos, io.SeekStart); err != nil {
+		return r.pos, errors.Trace(err)
+	}
+	r.reset(pos)
+	return pos, nil
+}
+
+func (r *prefetchReader) reset(pos int64) {
+	r.recycle()
+	r.pos = pos
+	r.err = nil
+}
+
+func (r *prefetchReader) Close() error {
+	r.stopFill()
+	return r.file.Close()
+}
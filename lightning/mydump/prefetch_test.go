@@ -0,0 +1,87 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&testPrefetchSuite{})
+
+type testPrefetchSuite struct{}
+
+func (s *testPrefetchSuite) openFile(c *C, content string) mydump.DataFile {
+	path := filepath.Join(c.MkDir(), "db.t.csv")
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	file, err := os.Open(path)
+	c.Assert(err, IsNil)
+	return file
+}
+
+func (s *testPrefetchSuite) TestReadAll(c *C) {
+	content := strings.Repeat("0123456789", 100)
+	for _, window := range []int64{1, 7, 64, 10000} {
+		reader := mydump.NewPrefetchReader(s.openFile(c, content), 7, window)
+		data, err := ioutil.ReadAll(reader)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, content)
+		c.Assert(reader.Close(), IsNil)
+	}
+}
+
+func (s *testPrefetchSuite) TestSeek(c *C) {
+	content := strings.Repeat("0123456789", 10)
+	reader := mydump.NewPrefetchReader(s.openFile(c, content), 8, 32)
+	defer reader.Close()
+
+	buf := make([]byte, 5)
+	_, err := io.ReadFull(reader, buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "01234")
+
+	// inside the current block.
+	pos, err := reader.Seek(2, io.SeekCurrent)
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(7))
+	_, err = io.ReadFull(reader, buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "78901")
+
+	// backward, discarding the blocks read ahead.
+	pos, err = reader.Seek(3, io.SeekStart)
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(3))
+	_, err = io.ReadFull(reader, buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "34567")
+
+	// far ahead.
+	pos, err = reader.Seek(-4, io.SeekEnd)
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(96))
+	data, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "6789")
+
+	_, err = reader.Seek(-1, io.SeekStart)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+// ProjectionParser wraps another parser, and drops the values of the source
+// columns which are not listed in the projection.
+type ProjectionParser struct {
+	Parser
+
+	rule    *config.ProjectionRule
+	quoting sqlQuoting
+
+	// sourceColumns is the column list of the last row from the inner parser,
+	// which the keep indices are computed from.
+	sourceColumns []byte
+	sourceCount   int
+	keep          []int
+	columns       []byte
+
+	lastRow Row
+	rowBuf  bytes.Buffer
+}
+
+// NewProjectionParser creates a parser keeping only the columns in the rule.
+func NewProjectionParser(inner Parser, rule *config.ProjectionRule) *ProjectionParser {
+	return &ProjectionParser{Parser: inner, rule: rule}
+}
+
+// SetSQLMode changes how the quoted strings in the rows are recognized, for
+// both this and the inner parser.
+func (parser *ProjectionParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.quoting.setSQLMode(mode)
+	if inner, ok := parser.Parser.(interface{ SetSQLMode(mysql.SQLMode) }); ok {
+		inner.SetSQLMode(mode)
+	}
+}
+
+// SetPos changes the reported position and row ID.
+func (parser *ProjectionParser) SetPos(pos int64, rowID int64) {
+	parser.Parser.SetPos(pos, rowID)
+	parser.lastRow.RowID = rowID
+}
+
+// LastRow is the projected row parsed by the last call to ReadRow(). The
+// content is only valid until the next call to ReadRow().
+func (parser *ProjectionParser) LastRow() Row {
+	return parser.lastRow
+}
+
+// Columns is the list of the projected columns.
+func (parser *ProjectionParser) Columns() []byte {
+	return parser.columns
+}
+
+// ReadRow reads the next row from the inner parser, and projects it.
+func (parser *ProjectionParser) ReadRow() error {
+	if err := parser.Parser.ReadRow(); err != nil {
+		return err
+	}
+	row := parser.Parser.LastRow()
+
+	if parser.keep == nil || !bytes.Equal(parser.sourceColumns, parser.Parser.Columns()) {
+		if err := parser.resolveColumns(parser.Parser.Columns()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	values, ok := parser.quoting.splitTuple(row.Row)
+	if !ok {
+		return errors.Errorf("cannot project malformed row at offset %d", parser.Pos())
+	}
+	if len(values) != parser.sourceCount {
+		return errors.Errorf("row at offset %d has %d values but there are %d source columns", parser.Pos(), len(values), parser.sourceCount)
+	}
+
+	parser.rowBuf.Reset()
+	parser.rowBuf.WriteByte('(')
+	for i, index := range parser.keep {
+		if i > 0 {
+			parser.rowBuf.WriteByte(',')
+		}
+		parser.rowBuf.Write(values[index])
+	}
+	parser.rowBuf.WriteByte(')')
+	parser.lastRow = Row{RowID: row.RowID, Row: parser.rowBuf.Bytes()}
+	return nil
+}
+
+// resolveColumns computes the positions of the projected columns among the
+// source columns, which are those in the INSERT statement, or the configured
+// source columns if the statement does not list them.
+func (parser *ProjectionParser) resolveColumns(sourceColumns []byte) error {
+	var names []string
+	if len(sourceColumns) == 0 {
+		if len(parser.rule.SourceColumns) == 0 {
+			return errors.Errorf("mydumper.projection of %s.%s needs source-columns, since the data file does not list the columns", parser.rule.Schema, parser.rule.Table)
+		}
+		names = parser.rule.SourceColumns
+	} else {
+		var err error
+		if names, err = parser.quoting.splitColumns(sourceColumns); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	keep := make([]int, 0, len(parser.rule.Columns))
+	var columns strings.Builder
+	columns.WriteByte('(')
+	for i, column := range parser.rule.Columns {
+		index := -1
+		for j, name := range names {
+			if strings.EqualFold(name, column) {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			return errors.Errorf("projected column %s is not in the source columns of %s.%s", column, parser.rule.Schema, parser.rule.Table)
+		}
+		keep = append(keep, index)
+		if i > 0 {
+			columns.WriteByte(',')
+		}
+		common.WriteMySQLIdentifier(&columns, column)
+	}
+	columns.WriteByte(')')
+
+	parser.sourceColumns = append(parser.sourceColumns[:0], sourceColumns...)
+	parser.sourceCount = len(names)
+	parser.keep = keep
+	parser.columns = []byte(columns.String())
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testProjectionParserSuite{})
+
+type testProjectionParserSuite struct{}
+
+func (s *testProjectionParserSuite) newParser(data string, rule *config.ProjectionRule) *mydump.ProjectionParser {
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	inner := mydump.NewChunkParser(strings.NewReader(data), config.ReadBlockSize, ioWorkers)
+	return mydump.NewProjectionParser(inner, rule)
+}
+
+func (s *testProjectionParserSuite) TestProjectListedColumns(c *C) {
+	parser := s.newParser(
+		"INSERT INTO `t` (`id`, `legacy`, \"Name\") VALUES (1, 'a,(b', 'x'), (2, f(3, 4), 'it''s');",
+		&config.ProjectionRule{Columns: []string{"name", "id"}},
+	)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{RowID: 1, Row: []byte("( 'x',1)")})
+	c.Assert(parser.Columns(), DeepEquals, []byte("(`name`,`id`)"))
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{RowID: 2, Row: []byte("( 'it''s',2)")})
+
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testProjectionParserSuite) TestProjectSourceColumns(c *C) {
+	rule := &config.ProjectionRule{Columns: []string{"b"}, SourceColumns: []string{"a", "b", "c"}}
+	parser := s.newParser("INSERT INTO `t` VALUES (1,'x\\'y',3),(4,'z');", rule)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, `('x\'y')`)
+	c.Assert(parser.Columns(), DeepEquals, []byte("(`b`)"))
+
+	c.Assert(parser.ReadRow(), ErrorMatches, "row at offset .* has 2 values but there are 3 source columns")
+
+	// with NO_BACKSLASH_ESCAPES, the backslash does not escape the quote.
+	parser = s.newParser("INSERT INTO `t` VALUES (1,'x\\',3);", rule)
+	parser.SetSQLMode(mysql.ModeNoBackslashEscapes)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, `('x\')`)
+}
+
+func (s *testProjectionParserSuite) TestMissingColumns(c *C) {
+	parser := s.newParser("INSERT INTO `t` VALUES (1);", &config.ProjectionRule{Schema: "db", Table: "t", Columns: []string{"a"}})
+	c.Assert(parser.ReadRow(), ErrorMatches, ".*mydumper.projection of db.t needs source-columns.*")
+
+	parser = s.newParser("INSERT INTO `t` (a) VALUES (1);", &config.ProjectionRule{Schema: "db", Table: "t", Columns: []string{"b"}})
+	c.Assert(parser.ReadRow(), ErrorMatches, ".*projected column b is not in the source columns of db.t")
+}
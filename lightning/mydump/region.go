@@ -15,7 +15,6 @@ package mydump
 
 import (
 	"math"
-	"os"
 
 	"github.com/pingcap/errors"
 )
@@ -26,6 +25,9 @@ type TableRegion struct {
 	DB    string
 	Table string
 	File  string
+	// FileSize and FileModTime (in Unix seconds) identify the content of File.
+	FileSize    int64
+	FileModTime int64
 
 	Chunk Chunk
 }
@@ -144,16 +146,27 @@ func MakeTableRegions(
 
 	prevRowIDMax := int64(0)
 	for _, dataFile := range meta.DataFiles {
-		dataFileInfo, err := os.Stat(dataFile)
+		dataFileSize, dataFileModTime, err := StatDataFile(dataFile)
 		if err != nil {
 			return nil, errors.Annotatef(err, "cannot stat %s", dataFile)
 		}
-		dataFileSize := dataFileInfo.Size()
-		rowIDMax := prevRowIDMax + dataFileSize/(int64(columns)+2)
+		reader := LookupSourceReader(dataFile)
+		if reader == nil {
+			return nil, errors.Errorf("unknown format of data file %s", dataFile)
+		}
+		rows, err := reader.EstimateRows(dataFile, dataFileSize, columns)
+		if err != nil {
+			return nil, errors.Annotatef(err, "cannot read %s", dataFile)
+		}
+		rowIDMax := prevRowIDMax + rows
 		filesRegions = append(filesRegions, &TableRegion{
 			DB:    meta.DB,
 			Table: meta.Name,
 			File:  dataFile,
+
+			FileSize:    dataFileSize,
+			FileModTime: dataFileModTime.Unix(),
+
 			Chunk: Chunk{
 				Offset:       0,
 				EndOffset:    dataFileSize,
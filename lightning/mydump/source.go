@@ -0,0 +1,187 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+// SourceReader is a format of the data files. Besides the built-in formats
+// (SQL, NDJSON, Avro and fixed-width), other formats can be supported by
+// registering a SourceReader with RegisterSourceReader, usually in an init
+// function of the package implementing it.
+type SourceReader interface {
+	// Name is the name of the format shown in the logs.
+	Name() string
+	// Suffix is the file name suffix of the data files in this format, e.g.
+	// ".sql". The file name without the suffix must be "{db}.{table}" or
+	// "{db}.{table}.{part}".
+	Suffix() string
+	// EstimateRows returns an upper bound of the number of rows in the data
+	// file, which reserves the row IDs of the rows when the table has no
+	// integer primary key.
+	EstimateRows(path string, size int64, columns int) (int64, error)
+	// Seekable returns whether the parser can start from any row boundary by
+	// seeking the reader to the offset. Otherwise the reader is always at the
+	// start of the file, and the parser skips to the position given to
+	// SetPos() by itself.
+	Seekable() bool
+	// NewParser creates a parser of the data file.
+	NewParser(reader io.Reader, options *SourceOptions) (Parser, error)
+}
+
+// SourceOptions are the settings for parsing a data file.
+type SourceOptions struct {
+	Path         string
+	BlockBufSize int64
+	// SQLMode is the SQL mode the rows are encoded under, which decides how
+	// the strings must be escaped.
+	SQLMode mysql.SQLMode
+	// ColumnNames are the columns of the table, for the formats which do not
+	// name the columns of each value.
+	ColumnNames []string
+	FixedWidth  *config.FixedWidthRule
+	IOWorkers   *worker.Pool
+}
+
+var (
+	sourceReadersLock sync.RWMutex
+	// sourceReaders are sorted by descending suffix length, so that the most
+	// specific suffix is matched first.
+	sourceReaders []SourceReader
+)
+
+// RegisterSourceReader adds a format of the data files. It panics if another
+// format has registered the same suffix.
+func RegisterSourceReader(reader SourceReader) {
+	sourceReadersLock.Lock()
+	defer sourceReadersLock.Unlock()
+	for _, r := range sourceReaders {
+		if r.Suffix() == reader.Suffix() {
+			panic("mydump: data file suffix " + reader.Suffix() + " is registered twice")
+		}
+	}
+	sourceReaders = append(sourceReaders, reader)
+	sort.SliceStable(sourceReaders, func(i, j int) bool {
+		return len(sourceReaders[i].Suffix()) > len(sourceReaders[j].Suffix())
+	})
+}
+
+// LookupSourceReader returns the format of the data file by its suffix, or nil
+// if the file is not a data file.
+func LookupSourceReader(path string) SourceReader {
+	sourceReadersLock.RLock()
+	defer sourceReadersLock.RUnlock()
+	for _, r := range sourceReaders {
+		if strings.HasSuffix(path, r.Suffix()) {
+			return r
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterSourceReader(sqlSourceReader{})
+	RegisterSourceReader(ndjsonSourceReader{})
+	RegisterSourceReader(avroSourceReader{})
+	RegisterSourceReader(fixedWidthSourceReader{})
+}
+
+// estimateRows assumes every row takes at least 2 bytes plus 1 byte per
+// column, e.g. `(1,2),`.
+func estimateRows(size int64, columns int) int64 {
+	return size / (int64(columns) + 2)
+}
+
+type sqlSourceReader struct{}
+
+func (sqlSourceReader) Name() string   { return "SQL" }
+func (sqlSourceReader) Suffix() string { return ".sql" }
+func (sqlSourceReader) Seekable() bool { return true }
+
+func (sqlSourceReader) EstimateRows(_ string, size int64, columns int) (int64, error) {
+	return estimateRows(size, columns), nil
+}
+
+func (sqlSourceReader) NewParser(reader io.Reader, options *SourceOptions) (Parser, error) {
+	parser := NewChunkParser(reader, options.BlockBufSize, options.IOWorkers)
+	parser.SetSQLMode(options.SQLMode)
+	return parser, nil
+}
+
+type ndjsonSourceReader struct{}
+
+func (ndjsonSourceReader) Name() string   { return "NDJSON" }
+func (ndjsonSourceReader) Suffix() string { return ndjsonSuffix }
+func (ndjsonSourceReader) Seekable() bool { return true }
+
+func (ndjsonSourceReader) EstimateRows(_ string, size int64, columns int) (int64, error) {
+	return estimateRows(size, columns), nil
+}
+
+func (ndjsonSourceReader) NewParser(reader io.Reader, options *SourceOptions) (Parser, error) {
+	parser := NewNDJSONParser(reader, options.BlockBufSize, options.ColumnNames, options.IOWorkers)
+	parser.SetSQLMode(options.SQLMode)
+	return parser, nil
+}
+
+type avroSourceReader struct{}
+
+func (avroSourceReader) Name() string   { return "Avro" }
+func (avroSourceReader) Suffix() string { return avroSuffix }
+
+// Seekable is false since the parser needs the file header.
+func (avroSourceReader) Seekable() bool { return false }
+
+// EstimateRows counts the rows exactly, since the blocks may be compressed.
+func (avroSourceReader) EstimateRows(path string, _ int64, _ int) (int64, error) {
+	return CountAvroRows(path)
+}
+
+func (avroSourceReader) NewParser(reader io.Reader, options *SourceOptions) (Parser, error) {
+	parser := NewAvroParser(reader, options.BlockBufSize, options.IOWorkers)
+	parser.SetSQLMode(options.SQLMode)
+	return parser, nil
+}
+
+type fixedWidthSourceReader struct{}
+
+func (fixedWidthSourceReader) Name() string   { return "fixed-width" }
+func (fixedWidthSourceReader) Suffix() string { return fixedWidthSuffix }
+func (fixedWidthSourceReader) Seekable() bool { return true }
+
+func (fixedWidthSourceReader) EstimateRows(_ string, size int64, columns int) (int64, error) {
+	return estimateRows(size, columns), nil
+}
+
+func (fixedWidthSourceReader) NewParser(reader io.Reader, options *SourceOptions) (Parser, error) {
+	if options.FixedWidth == nil {
+		return nil, errors.Errorf("no [[mydumper.fixed-width]] layout for %s", options.Path)
+	}
+	parser, err := NewFixedWidthParser(reader, options.BlockBufSize, options.FixedWidth, options.ColumnNames, options.IOWorkers)
+	if err != nil {
+		return nil, errors.Annotatef(err, "cannot read %s", options.Path)
+	}
+	parser.SetSQLMode(options.SQLMode)
+	return parser, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testSourceReaderSuite{})
+
+type testSourceReaderSuite struct{}
+
+// pipeSourceReader reads `a|b|c` lines by converting them into SQL tuples.
+type pipeSourceReader struct{}
+
+func (pipeSourceReader) Name() string   { return "pipe" }
+func (pipeSourceReader) Suffix() string { return ".pipe.sql" }
+func (pipeSourceReader) Seekable() bool { return true }
+
+func (pipeSourceReader) EstimateRows(_ string, size int64, _ int) (int64, error) {
+	return size, nil
+}
+
+func (pipeSourceReader) NewParser(reader io.Reader, options *mydump.SourceOptions) (mydump.Parser, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	sql := "INSERT INTO t VALUES ('" + strings.Replace(strings.TrimSpace(string(data)), "|", "','", -1) + "');"
+	return mydump.NewChunkParser(strings.NewReader(sql), options.BlockBufSize, options.IOWorkers), nil
+}
+
+func (s *testSourceReaderSuite) TestRegister(c *C) {
+	c.Assert(mydump.LookupSourceReader("db.t.1.sql").Name(), Equals, "SQL")
+	c.Assert(mydump.LookupSourceReader("db.t.avro").Name(), Equals, "Avro")
+	c.Assert(mydump.LookupSourceReader("db.t.csv"), IsNil)
+
+	if mydump.LookupSourceReader("db.t.pipe.sql").Name() != "pipe" {
+		mydump.RegisterSourceReader(pipeSourceReader{})
+	}
+	c.Assert(func() { mydump.RegisterSourceReader(pipeSourceReader{}) }, PanicMatches, ".*registered twice")
+
+	// the longer suffix takes precedence.
+	reader := mydump.LookupSourceReader("db.t.pipe.sql")
+	c.Assert(reader.Name(), Equals, "pipe")
+	c.Assert(mydump.LookupSourceReader("db.t.sql").Name(), Equals, "SQL")
+
+	parser, err := reader.NewParser(strings.NewReader("1|x\n"), &mydump.SourceOptions{
+		BlockBufSize: config.ReadBlockSize,
+		IOWorkers:    worker.NewPool(context.Background(), 1, "test"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, "('1','x')")
+}
+
+func (s *testSourceReaderSuite) TestFixedWidthNeedsLayout(c *C) {
+	_, err := mydump.LookupSourceReader("db.t.fwf").NewParser(strings.NewReader(""), &mydump.SourceOptions{Path: "db.t.fwf"})
+	c.Assert(err, ErrorMatches, `no \[\[mydumper.fixed-width\]\] layout for db.t.fwf`)
+}
@@ -0,0 +1,304 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+const (
+	remoteRetryBackoff    = time.Second
+	maxRemoteRetryBackoff = 30 * time.Second
+)
+
+var (
+	// remoteRequests limits the number of requests to the remote data source
+	// sent at the same time, nil for unlimited.
+	remoteRequests chan struct{}
+	// remoteRetry is the number of times a remote request is retried, and a
+	// remote file is resumed with a new range request after the connection
+	// broke.
+	remoteRetry = 3
+	// remoteBandwidth limits the bytes read from the remote data source, nil
+	// for unlimited.
+	remoteBandwidth *bandwidthLimiter
+)
+
+// SetRemoteLimits configures the requests reading the data source from an
+// HTTP(S) or HDFS URL, e.g. an S3 bucket, so many workers reading at once are
+// not throttled into failure. A non-positive concurrency or maxBandwidth
+// (bytes per second) means unlimited.
+func SetRemoteLimits(concurrency int, retry int, maxBandwidth int64) {
+	remoteRequests = nil
+	if concurrency > 0 {
+		remoteRequests = make(chan struct{}, concurrency)
+	}
+	remoteRetry = retry
+	remoteBandwidth = nil
+	if maxBandwidth > 0 {
+		remoteBandwidth = &bandwidthLimiter{rate: float64(maxBandwidth)}
+	}
+}
+
+// bandwidthLimiter paces the reads sharing the bandwidth, by delaying each
+// read until the bytes read before have been paid for.
+type bandwidthLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+func (l *bandwidthLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// doRemoteRequest sends the request without a body, retrying with exponential
+// backoff on the connection errors and the 5xx and 429 statuses, e.g. the 503
+// SlowDown of S3. The response of the last attempt is returned as is.
+func doRemoteRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	backoff := remoteRetryBackoff
+	for retry := 0; ; retry++ {
+		resp, err := sendRemoteRequest(client, req)
+		if err == nil && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		if retry >= remoteRetry {
+			return resp, errors.Trace(err)
+		}
+		if err == nil {
+			err = errors.Errorf("status %s", resp.Status)
+			resp.Body.Close()
+		}
+		// the query may contain credentials, e.g. a presigned S3 URL.
+		common.AppLogger.Warnf("%s %s%s failed, retrying in %v (%d/%d): %v", req.Method, req.URL.Host, req.URL.Path, backoff, retry+1, remoteRetry, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxRemoteRetryBackoff {
+			backoff = maxRemoteRetryBackoff
+		}
+	}
+}
+
+func sendRemoteRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	if remoteRequests != nil {
+		remoteRequests <- struct{}{}
+		defer func() { <-remoteRequests }()
+	}
+	return client.Do(req)
+}
+
+// DataFile is an opened file of the data source.
+type DataFile interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
+// IsRemotePath returns whether the path is an http://, https:// or hdfs://
+// URL.
+func IsRemotePath(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") || isHDFSPath(path)
+}
+
+// JoinPath joins the data source directory, which may be a URL, and a path
+// relative to it.
+func JoinPath(dir string, name string) string {
+	if IsRemotePath(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + filepath.ToSlash(name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// StatDataFile returns the size and the modification time of a data file,
+// which may be a URL. A missing remote file is reported as os.ErrNotExist.
+func StatDataFile(path string) (int64, time.Time, error) {
+	if !IsRemotePath(path) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		return info.Size(), info.ModTime(), nil
+	}
+	if isHDFSPath(path) {
+		return statHDFSFile(path)
+	}
+
+	req, err := http.NewRequest(http.MethodHead, path, nil)
+	if err != nil {
+		return 0, time.Time{}, errors.Trace(err)
+	}
+	resp, err := doRemoteRequest(common.NewHTTPClient(0), req)
+	if err != nil {
+		return 0, time.Time{}, errors.Trace(err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return 0, time.Time{}, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	case resp.StatusCode != http.StatusOK:
+		return 0, time.Time{}, errors.Errorf("head %s http status code != 200, status %s", path, resp.Status)
+	case resp.ContentLength < 0:
+		return 0, time.Time{}, errors.Errorf("head %s returns no Content-Length", path)
+	}
+	// the modification time is optional, it only helps to detect the files
+	// changed before resuming from the checkpoints.
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return resp.ContentLength, modTime, nil
+}
+
+// OpenDataFile opens a data file, which may be a URL. The remote files are
+// read with range requests (or the offset of WebHDFS), so seeking does not
+// download the skipped part.
+func OpenDataFile(path string) (DataFile, error) {
+	if !IsRemotePath(path) {
+		return os.Open(path)
+	}
+	size, _, err := StatDataFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &httpFile{
+		client: common.NewHTTPClient(0),
+		url:    path,
+		size:   size,
+		hdfs:   isHDFSPath(path),
+	}, nil
+}
+
+// httpFile reads a remote file from the current position with a range
+// request. The response body is kept open for the subsequent reads until
+// seeking elsewhere.
+type httpFile struct {
+	client *http.Client
+	url    string
+	size   int64
+	pos    int64
+	body   io.ReadCloser
+	// hdfs is whether the file is read through WebHDFS, which takes the
+	// offset as a parameter instead of the Range header.
+	hdfs bool
+}
+
+func (f *httpFile) open() error {
+	var req *http.Request
+	var err error
+	if f.hdfs {
+		req, err = openHDFSRequest(f.url, f.pos)
+	} else {
+		req, err = http.NewRequest(http.MethodGet, f.url, nil)
+		if err == nil {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", f.pos))
+		}
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := doRemoteRequest(f.client, req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && (f.pos == 0 || f.hdfs)) {
+		resp.Body.Close()
+		return errors.Errorf("get %s from offset %d http status code != 206, status %s", f.url, f.pos, resp.Status)
+	}
+	f.body = resp.Body
+	return nil
+}
+
+func (f *httpFile) closeBody() {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	var err error
+	for retry := 0; retry <= remoteRetry; retry++ {
+		if f.body == nil {
+			// the request itself has been retried.
+			if err = f.open(); err != nil {
+				break
+			}
+		}
+		var n int
+		n, err = f.body.Read(p)
+		f.pos += int64(n)
+		remoteBandwidth.wait(n)
+		if err == nil || (err == io.EOF && f.pos >= f.size) {
+			return n, err
+		}
+		// the connection broke, resume from the current position.
+		f.closeBody()
+		if n > 0 {
+			return n, nil
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		common.AppLogger.Warnf("reading %s at offset %d failed, retrying (%d/%d): %v", f.url, f.pos, retry+1, remoteRetry, err)
+	}
+	return 0, errors.Annotatef(err, "cannot read %s at offset %d", f.url, f.pos)
+}
+
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = f.pos + offset
+	case io.SeekEnd:
+		pos = f.size + offset
+	default:
+		return f.pos, errors.Errorf("invalid whence %d", whence)
+	}
+	if pos < 0 {
+		return f.pos, errors.Errorf("negative position %d", pos)
+	}
+	if pos != f.pos {
+		f.closeBody()
+		f.pos = pos
+	}
+	return pos, nil
+}
+
+func (f *httpFile) Close() error {
+	f.closeBody()
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&testStorageSuite{})
+
+type testStorageSuite struct{}
+
+func (s *testStorageSuite) TestJoinPath(c *C) {
+	c.Assert(mydump.JoinPath("/data/dump", "db.t.sql"), Equals, "/data/dump/db.t.sql")
+	c.Assert(mydump.JoinPath("https://example.com/dump/", "db.t.sql"), Equals, "https://example.com/dump/db.t.sql")
+	c.Assert(mydump.JoinPath("http://example.com/dump", "sub/db.t.sql"), Equals, "http://example.com/dump/sub/db.t.sql")
+}
+
+func (s *testStorageSuite) TestRemoteDataFile(c *C) {
+	const content = "INSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);\n"
+	modTime := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/dump/db.t.sql" {
+			http.NotFound(w, req)
+			return
+		}
+		http.ServeContent(w, req, "db.t.sql", modTime, bytes.NewReader([]byte(content)))
+	}))
+	defer server.Close()
+
+	path := server.URL + "/dump/db.t.sql"
+	size, actualModTime, err := mydump.StatDataFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(content)))
+	c.Assert(actualModTime.Equal(modTime), IsTrue)
+
+	_, _, err = mydump.StatDataFile(server.URL + "/dump/db.t.1.sql")
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	file, err := mydump.OpenDataFile(path)
+	c.Assert(err, IsNil)
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, content)
+
+	// seeking reads the rest with a range request.
+	pos, err := file.Seek(26, io.SeekStart)
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(26))
+	data, err = ioutil.ReadAll(file)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, content[26:])
+}
+
+func (s *testStorageSuite) TestRemoteRetry(c *C) {
+	const content = "INSERT INTO t VALUES (1);\n"
+	var requests int32
+	failures := int32(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			http.Error(w, "SlowDown", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, req, "db.t.sql", time.Time{}, bytes.NewReader([]byte(content)))
+	}))
+	defer server.Close()
+
+	mydump.SetRemoteLimits(1, 1, 1<<20)
+	defer mydump.SetRemoteLimits(0, 3, 0)
+
+	path := server.URL + "/dump/db.t.sql"
+	size, _, err := mydump.StatDataFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(len(content)))
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(2))
+
+	// the retry budget is exhausted by the persistent errors.
+	atomic.StoreInt32(&failures, 10)
+	_, _, err = mydump.StatDataFile(path)
+	c.Assert(err, ErrorMatches, ".*status code != 200.*")
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(4))
+}
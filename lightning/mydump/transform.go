@@ -0,0 +1,160 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+// TransformParser wraps another parser, and replaces the values of some
+// columns by SQL expressions computed from them, e.g. `LOWER(TRIM(?))`. The
+// expressions are evaluated by the KV encoder like any other value.
+type TransformParser struct {
+	Parser
+
+	rules []*config.TransformRule
+	// tableColumns are the columns of the values if the data file does not
+	// list the columns.
+	tableColumns []string
+	quoting      sqlQuoting
+
+	// exprs are the split expressions of each value position of the source
+	// columns, or nil if the value is kept as is.
+	sourceColumns []byte
+	exprs         [][]string
+	resolved      bool
+
+	lastRow Row
+	rowBuf  bytes.Buffer
+}
+
+// NewTransformParser creates a parser applying the transform rules of a table.
+func NewTransformParser(inner Parser, rules []*config.TransformRule, tableColumns []string) *TransformParser {
+	return &TransformParser{Parser: inner, rules: rules, tableColumns: tableColumns}
+}
+
+// SetSQLMode changes how the quoted strings in the rows are recognized, for
+// both this and the inner parser.
+func (parser *TransformParser) SetSQLMode(mode mysql.SQLMode) {
+	parser.quoting.setSQLMode(mode)
+	if inner, ok := parser.Parser.(interface{ SetSQLMode(mysql.SQLMode) }); ok {
+		inner.SetSQLMode(mode)
+	}
+}
+
+// SetPos changes the reported position and row ID.
+func (parser *TransformParser) SetPos(pos int64, rowID int64) {
+	parser.Parser.SetPos(pos, rowID)
+	parser.lastRow.RowID = rowID
+}
+
+// LastRow is the transformed row parsed by the last call to ReadRow(). The
+// content is only valid until the next call to ReadRow().
+func (parser *TransformParser) LastRow() Row {
+	return parser.lastRow
+}
+
+// ReadRow reads the next row from the inner parser, and transforms it.
+func (parser *TransformParser) ReadRow() error {
+	if err := parser.Parser.ReadRow(); err != nil {
+		return err
+	}
+	row := parser.Parser.LastRow()
+
+	if !parser.resolved || !bytes.Equal(parser.sourceColumns, parser.Parser.Columns()) {
+		if err := parser.resolveColumns(parser.Parser.Columns()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	values, ok := parser.quoting.splitTuple(row.Row)
+	if !ok {
+		return errors.Errorf("cannot transform malformed row at offset %d", parser.Pos())
+	}
+
+	parser.rowBuf.Reset()
+	parser.rowBuf.WriteByte('(')
+	for i, value := range values {
+		if i > 0 {
+			parser.rowBuf.WriteByte(',')
+		}
+		if i >= len(parser.exprs) || parser.exprs[i] == nil {
+			parser.rowBuf.Write(value)
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		for j, part := range parser.exprs[i] {
+			if j > 0 {
+				parser.rowBuf.Write(value)
+			}
+			parser.rowBuf.WriteString(part)
+		}
+	}
+	parser.rowBuf.WriteByte(')')
+	parser.lastRow = Row{RowID: row.RowID, Row: parser.rowBuf.Bytes()}
+	return nil
+}
+
+// resolveColumns finds the value positions of the transformed columns.
+func (parser *TransformParser) resolveColumns(sourceColumns []byte) error {
+	names := parser.tableColumns
+	if len(sourceColumns) > 0 {
+		var err error
+		if names, err = parser.quoting.splitColumns(sourceColumns); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	exprs := make([][]string, len(names))
+	for _, rule := range parser.rules {
+		index := -1
+		for i, name := range names {
+			if strings.EqualFold(name, rule.Column) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return errors.Errorf("transformed column %s is not in the data file of %s.%s", rule.Column, rule.Schema, rule.Table)
+		}
+		exprs[index] = parser.splitExpr(rule.Expr)
+	}
+
+	parser.sourceColumns = append(parser.sourceColumns[:0], sourceColumns...)
+	parser.exprs = exprs
+	parser.resolved = true
+	return nil
+}
+
+// splitExpr splits the expression at the `?` placeholders outside of quotes.
+func (parser *TransformParser) splitExpr(expr string) []string {
+	var parts []string
+	last := 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\'', '"', '`':
+			i = parser.quoting.skipQuoted([]byte(expr), i) - 1
+		case '?':
+			parts = append(parts, expr[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, expr[last:])
+}
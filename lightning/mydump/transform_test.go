@@ -0,0 +1,88 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&testTransformParserSuite{})
+
+type testTransformParserSuite struct{}
+
+func (s *testTransformParserSuite) newParser(data string, rules []*config.TransformRule, tableColumns []string) *mydump.TransformParser {
+	ioWorkers := worker.NewPool(context.Background(), 5, "test")
+	inner := mydump.NewChunkParser(strings.NewReader(data), config.ReadBlockSize, ioWorkers)
+	return mydump.NewTransformParser(inner, rules, tableColumns)
+}
+
+func (s *testTransformParserSuite) TestTransform(c *C) {
+	rules := []*config.TransformRule{
+		{Column: "email", Expr: "LOWER(TRIM(?))"},
+		{Column: "ssn", Expr: "'?**'"},
+		{Column: "born", Expr: "STR_TO_DATE(?, '%d/%m/%Y')"},
+	}
+	parser := s.newParser(
+		"INSERT INTO `t` (`id`, `Email`, `ssn`, `born`) VALUES (1, ' A@B.C ', '123', '01/02/2003');",
+		rules, nil,
+	)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{
+		RowID: 1,
+		Row:   []byte("(1,LOWER(TRIM(' A@B.C ')),'?**',STR_TO_DATE('01/02/2003', '%d/%m/%Y'))"),
+	})
+	c.Assert(parser.Columns(), DeepEquals, []byte("(`id`, `Email`, `ssn`, `born`)"))
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testTransformParserSuite) TestTableColumns(c *C) {
+	rules := []*config.TransformRule{{Schema: "db", Table: "t", Column: "b", Expr: "UPPER(?)"}}
+
+	parser := s.newParser("INSERT INTO `t` VALUES (1,'x'),(2,'y');", rules, []string{"a", "b"})
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, "(1,UPPER('x'))")
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, "(2,UPPER('y'))")
+
+	parser = s.newParser("INSERT INTO `t` VALUES (1,'x');", rules, []string{"a", "c"})
+	c.Assert(parser.ReadRow(), ErrorMatches, ".*transformed column b is not in the data file of db.t")
+}
+
+func (s *testTransformParserSuite) TestMask(c *C) {
+	cfg := config.MydumperRuntime{Mask: []*config.MaskRule{
+		{Schema: "db", Table: "t", Column: "a", Method: config.MaskHash, Salt: "s'"},
+		{Schema: "db", Table: "t", Column: "b", Method: config.MaskRedact, KeepSuffix: 2},
+		{Schema: "db", Table: "t", Column: "c", Method: config.MaskNullify},
+		{Schema: "db", Table: "other", Column: "d", Method: config.MaskNullify},
+	}}
+	rules := cfg.FindTransformRules("DB", "T")
+	c.Assert(rules, HasLen, 3)
+
+	parser := s.newParser("INSERT INTO `t` VALUES ('x','1234','z');", rules, []string{"a", "b", "c"})
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(string(parser.LastRow().Row), Equals, "("+
+		"SHA2(CONCAT(X'7327', 'x'), 256),"+
+		"IF(CHAR_LENGTH('1234') <= 2, REPEAT('*', CHAR_LENGTH('1234')), CONCAT(LEFT('1234', 0), REPEAT('*', CHAR_LENGTH('1234') - 2), RIGHT('1234', 2))),"+
+		"NULL)")
+}
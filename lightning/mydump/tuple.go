@@ -0,0 +1,133 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+)
+
+// sqlQuoting splits the tuples of the rows and column lists, following the
+// escaping rules of the SQL mode the data files were dumped under.
+type sqlQuoting struct {
+	noBackslashEscapes bool
+	ansiQuotes         bool
+}
+
+func (q *sqlQuoting) setSQLMode(mode mysql.SQLMode) {
+	q.noBackslashEscapes = mode.HasNoBackslashEscapesMode()
+	q.ansiQuotes = mode.HasANSIQuotesMode()
+}
+
+// splitTuple splits a tuple of the form `(a, b, c)` into the elements.
+// Returns false if the tuple is not enclosed in parentheses.
+func (q *sqlQuoting) splitTuple(tuple []byte) ([][]byte, bool) {
+	trimmed := bytes.TrimSpace(tuple)
+	if len(trimmed) < 2 || trimmed[0] != '(' || trimmed[len(trimmed)-1] != ')' {
+		return nil, false
+	}
+	return q.split(trimmed[1:len(trimmed)-1], ','), true
+}
+
+// splitColumns returns the unquoted names in a column list.
+func (q *sqlQuoting) splitColumns(columns []byte) ([]string, error) {
+	elements, ok := q.splitTuple(columns)
+	if !ok {
+		return nil, errors.Errorf("malformed column list %s", columns)
+	}
+	names := make([]string, 0, len(elements))
+	for _, element := range elements {
+		names = append(names, unquoteColumnName(string(bytes.TrimSpace(element))))
+	}
+	return names, nil
+}
+
+// split splits the text at the separators outside of quotes and parentheses.
+func (q *sqlQuoting) split(s []byte, sep byte) [][]byte {
+	var parts [][]byte
+	depth := 0
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = q.skipQuoted(s, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
+
+// skipQuoted returns the position after the quoted string starting at s[start].
+func (q *sqlQuoting) skipQuoted(s []byte, start int) int {
+	quote := s[start]
+	backslashEscapes := !q.noBackslashEscapes && quote != '`' && !(quote == '"' && q.ansiQuotes)
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// SplitRow splits a row in the form `(a, b, c)` as returned by
+// Parser.LastRow() into the SQL literals of the values, following the escaping
+// rules of the SQL mode.
+func SplitRow(row []byte, mode mysql.SQLMode) ([][]byte, error) {
+	var q sqlQuoting
+	q.setSQLMode(mode)
+	values, ok := q.splitTuple(row)
+	if !ok {
+		return nil, errors.Errorf("malformed row %s", row)
+	}
+	for i, value := range values {
+		values[i] = bytes.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// SplitColumns returns the unquoted names in a column list in the form
+// `(a, b, c)` as returned by Parser.Columns().
+func SplitColumns(columns []byte, mode mysql.SQLMode) ([]string, error) {
+	var q sqlQuoting
+	q.setSQLMode(mode)
+	return q.splitColumns(columns)
+}
+
+func unquoteColumnName(name string) string {
+	if len(name) >= 2 && (name[0] == '`' || name[0] == '"') && name[len(name)-1] == name[0] {
+		quote := name[:1]
+		return strings.Replace(name[1:len(name)-1], quote+quote, quote, -1)
+	}
+	return name
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&testTupleSuite{})
+
+type testTupleSuite struct{}
+
+func (s *testTupleSuite) TestSplitRow(c *C) {
+	values, err := mydump.SplitRow([]byte(` (1, 'a,\'(b', f(3, 4), "x""y") `), mysql.ModeNone)
+	c.Assert(err, IsNil)
+	c.Assert(values, DeepEquals, [][]byte{
+		[]byte("1"), []byte(`'a,\'(b'`), []byte("f(3, 4)"), []byte(`"x""y"`),
+	})
+
+	values, err = mydump.SplitRow([]byte(`('a\', 2)`), mysql.ModeNoBackslashEscapes)
+	c.Assert(err, IsNil)
+	c.Assert(values, DeepEquals, [][]byte{[]byte(`'a\'`), []byte("2")})
+
+	_, err = mydump.SplitRow([]byte("1, 2"), mysql.ModeNone)
+	c.Assert(err, ErrorMatches, "malformed row 1, 2")
+}
+
+func (s *testTupleSuite) TestSplitColumns(c *C) {
+	columns, err := mydump.SplitColumns([]byte("(`id`, `a``b`, \"Name\", c)"), mysql.ModeANSIQuotes)
+	c.Assert(err, IsNil)
+	c.Assert(columns, DeepEquals, []string{"id", "a`b", "Name", "c"})
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package numa
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/pingcap/errors"
+)
+
+const sysNodePath = "/sys/devices/system/node"
+
+// cpuMask is large enough for 1024 CPUs, same as the glibc `cpu_set_t`.
+type cpuMask [16]uint64
+
+// Nodes returns the NUMA nodes of the host which have at least one CPU.
+func Nodes() ([]Node, error) {
+	dirs, err := filepath.Glob(filepath.Join(sysNodePath, "node[0-9]*"))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	nodes := make([]Node, 0, len(dirs))
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		cpus, err := parseCPUList(string(content))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(cpus) > 0 {
+			nodes = append(nodes, Node{ID: id, CPUs: cpus})
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+func getAffinity(mask *cpuMask) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(*mask), uintptr(unsafe.Pointer(mask)))
+	if errno != 0 {
+		return errors.Trace(errno)
+	}
+	return nil
+}
+
+func setAffinity(mask *cpuMask) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(*mask), uintptr(unsafe.Pointer(mask)))
+	if errno != 0 {
+		return errors.Trace(errno)
+	}
+	return nil
+}
+
+// Pin locks the current goroutine to its OS thread and restricts the thread
+// to the CPUs of the node. The returned function restores the affinity and
+// unlocks the thread, and must be called on the same goroutine.
+func (node *Node) Pin() (func(), error) {
+	var oldMask, newMask cpuMask
+	for _, cpu := range node.CPUs {
+		if cpu < len(newMask)*64 {
+			newMask[cpu/64] |= 1 << uint(cpu%64)
+		}
+	}
+
+	runtime.LockOSThread()
+	if err := getAffinity(&oldMask); err != nil {
+		runtime.UnlockOSThread()
+		return nil, errors.Trace(err)
+	}
+	if err := setAffinity(&newMask); err != nil {
+		runtime.UnlockOSThread()
+		return nil, errors.Trace(err)
+	}
+
+	return func() {
+		if err := setAffinity(&oldMask); err != nil {
+			// keep the thread locked so the goroutine exits with it, and no
+			// other goroutine would be scheduled on the restricted thread.
+			return
+		}
+		runtime.UnlockOSThread()
+	}, nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package numa
+
+import (
+	"github.com/pingcap/errors"
+)
+
+// Nodes returns the NUMA nodes of the host. It is only supported on Linux.
+func Nodes() ([]Node, error) {
+	return nil, errors.New("NUMA topology discovery is only supported on Linux")
+}
+
+// Pin is a no-op on platforms other than Linux.
+func (node *Node) Pin() (func(), error) {
+	return func() {}, nil
+}
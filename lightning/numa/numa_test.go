@@ -0,0 +1,44 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package numa
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+)
+
+func TestNUMA(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&numaSuite{})
+
+type numaSuite struct{}
+
+func (s *numaSuite) TestParseCPUList(c *C) {
+	cpus, err := parseCPUList("0-3,8,10-11\n")
+	c.Assert(err, IsNil)
+	c.Assert(cpus, DeepEquals, []int{0, 1, 2, 3, 8, 10, 11})
+
+	cpus, err = parseCPUList("")
+	c.Assert(err, IsNil)
+	c.Assert(cpus, HasLen, 0)
+
+	_, err = parseCPUList("3-1")
+	c.Assert(err, ErrorMatches, `invalid cpu list "3-1"`)
+
+	_, err = parseCPUList("a-b")
+	c.Assert(err, ErrorMatches, `invalid cpu list "a-b".*`)
+}
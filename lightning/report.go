@@ -0,0 +1,77 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lightning
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/restore"
+)
+
+// ExitReport is the summary of a fatal failure, printed as a single line of
+// JSON on stderr at exit for the wrapper scripts.
+type ExitReport struct {
+	Code         string                `json:"code,omitempty"`
+	Error        string                `json:"error"`
+	Hint         string                `json:"hint,omitempty"`
+	FailedTables []restore.FailedTable `json:"failed-tables,omitempty"`
+	// Commands are the suggested commands to recover, in order.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// ExitReport summarizes the error returned by Run().
+func (l *Lightning) ExitReport(err error) *ExitReport {
+	return NewExitReport(l.cfg, l.failedTables, err)
+}
+
+// NewExitReport summarizes the error. The config may be nil if it cannot be
+// loaded.
+func NewExitReport(cfg *config.Config, failedTables []restore.FailedTable, err error) *ExitReport {
+	report := &ExitReport{
+		Error:        err.Error(),
+		Hint:         common.ErrorHint(err),
+		FailedTables: failedTables,
+	}
+	if class := common.ClassOf(err); class != nil {
+		report.Code = class.Code()
+	}
+	if cfg == nil || common.ErrInvalidConfig.Equal(err) {
+		return report
+	}
+
+	configArg := " -config " + shellQuote(cfg.ConfigFile)
+	if cfg.Checkpoint.Enable {
+		for _, table := range failedTables {
+			switch table.Code {
+			case common.ErrChecksumMismatch.Code(), common.ErrEncodeKV.Code(), common.ErrInvalidSource.Code():
+				// the imported data are wrong, and must be deleted before
+				// importing again.
+				report.Commands = append(report.Commands, fmt.Sprintf(
+					"tidb-lightning-ctl%s --checkpoint-error-destroy=%s", configArg, shellQuote(table.Table)))
+			default:
+				report.Commands = append(report.Commands, fmt.Sprintf(
+					"tidb-lightning-ctl%s --checkpoint-error-ignore=%s", configArg, shellQuote(table.Table)))
+			}
+		}
+	}
+	report.Commands = append(report.Commands, "tidb-lightning"+configArg)
+	return report
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -27,6 +28,7 @@ import (
 	"github.com/cznic/mathutil"
 	"github.com/joho/sqltocsv"
 	"github.com/pingcap/errors"
+	"github.com/satori/go.uuid"
 
 	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-lightning/lightning/mydump"
@@ -53,9 +55,10 @@ const nodeID = 0
 const (
 	// the table names to store each kind of checkpoint in the checkpoint database
 	// remember to increase the version number in case of incompatible change.
-	checkpointTableNameTable  = "table_v4"
-	checkpointTableNameEngine = "engine_v4"
-	checkpointTableNameChunk  = "chunk_v4"
+	checkpointTableNameTable  = "table_v8"
+	checkpointTableNameEngine = "engine_v8"
+	checkpointTableNameChunk  = "chunk_v8"
+	checkpointTableNameTask   = "task_v1"
 )
 
 func (status CheckpointStatus) MetricName() string {
@@ -105,17 +108,204 @@ type ChunkCheckpoint struct {
 	ShouldIncludeRowID bool
 	Chunk              mydump.Chunk
 	Checksum           verify.KVChecksum
+
+	// FileSize and FileModTime (in Unix seconds) identify the content of the
+	// data file when the checkpoint was created. Both are zero for the
+	// checkpoints created by older versions, which are not validated.
+	FileSize    int64
+	FileModTime int64
+
+	// Quarantined is set when the chunk kept failing after the retries, and
+	// the range [Chunk.Offset, Chunk.EndOffset) is left unimported.
+	Quarantined bool
+}
+
+// checkFile returns an error if the data file at path is not the one the
+// checkpoint was created from, e.g. a different dump is mounted at the data
+// source directory on the machine resuming the task.
+func (cp *ChunkCheckpoint) checkFile(path string) error {
+	if cp.FileSize == 0 && cp.FileModTime == 0 {
+		return nil
+	}
+	size, modTime, err := mydump.StatDataFile(path)
+	if err != nil {
+		return errors.Annotatef(err, "cannot resume %s from the checkpoint", &cp.Key)
+	}
+	if size != cp.FileSize || modTime.Unix() != cp.FileModTime {
+		return errors.Errorf(
+			"cannot resume %s from the checkpoint, the file was %d bytes modified at %s, but is now %d bytes modified at %s",
+			&cp.Key,
+			cp.FileSize, time.Unix(cp.FileModTime, 0).Format(time.RFC3339),
+			size, modTime.Format(time.RFC3339),
+		)
+	}
+	return nil
 }
 
 type EngineCheckpoint struct {
 	Status CheckpointStatus
 	Chunks []*ChunkCheckpoint // a sorted array
+	// UUID is the UUID of the engine on tikv-importer, derived from the task
+	// ID. It is `uuid.Nil` if the checkpoint was created by an older version.
+	UUID uuid.UUID
+}
+
+// isFresh returns whether no data has ever been written into the engine.
+func (cp *EngineCheckpoint) isFresh() bool {
+	if cp.Status > CheckpointStatusLoaded {
+		return false
+	}
+	for _, chunk := range cp.Chunks {
+		if chunk.Chunk.Offset > chunk.Key.Offset {
+			return false
+		}
+	}
+	return true
+}
+
+// checksum returns the checksum of the KV pairs written from the chunks of the
+// engine.
+func (cp *EngineCheckpoint) checksum() verify.KVChecksum {
+	var checksum verify.KVChecksum
+	for _, chunk := range cp.Chunks {
+		checksum.Add(&chunk.Checksum)
+	}
+	return checksum
+}
+
+// checksumSince returns the checksum of the KV pairs added into `after` since
+// it was `before`. Returns an error if `after` is smaller than `before`.
+func checksumSince(after verify.KVChecksum, before verify.KVChecksum) (verify.KVChecksum, error) {
+	err := after.Remove(&before)
+	return after, errors.Trace(err)
+}
+
+// largestChunksFirst returns the indices of the chunks ordered by the size of
+// their remaining data, largest first. Starting the largest chunks early keeps
+// a single big chunk from being the straggler at the end of the engine.
+func (cp *EngineCheckpoint) largestChunksFirst() []int {
+	indices := make([]int, len(cp.Chunks))
+	for i := range indices {
+		indices[i] = i
+	}
+	remaining := func(i int) int64 {
+		chunk := cp.Chunks[indices[i]].Chunk
+		return chunk.EndOffset - chunk.Offset
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return remaining(i) > remaining(j)
+	})
+	return indices
 }
 
 type TableCheckpoint struct {
 	Status    CheckpointStatus
 	AllocBase int64
 	Engines   []*EngineCheckpoint
+	// TiFlashReplicaCount and TiFlashLocationLabels are the TiFlash replica
+	// settings removed from the target table during the import, which should
+	// be restored afterwards. A zero count means nothing has been removed.
+	TiFlashReplicaCount   uint64
+	TiFlashLocationLabels string
+}
+
+// localChecksum returns the checksum of all KV pairs written from the chunks.
+func (cp *TableCheckpoint) localChecksum() verify.KVChecksum {
+	var checksum verify.KVChecksum
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			checksum.Add(&chunk.Checksum)
+		}
+	}
+	return checksum
+}
+
+// sourceSize returns the total size of the source data in the chunks.
+func (cp *TableCheckpoint) sourceSize() int64 {
+	size := int64(0)
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			size += chunk.Chunk.EndOffset - chunk.Chunk.Offset
+		}
+	}
+	return size
+}
+
+// resetProgress rewinds the checkpoint to before any data was written, so that
+// the table can be imported again from scratch with the same chunks.
+func (cp *TableCheckpoint) resetProgress() {
+	cp.Status = CheckpointStatusLoaded
+	var chunks []*ChunkCheckpoint
+	for _, engine := range cp.Engines {
+		engine.Status = CheckpointStatusLoaded
+		for _, chunk := range engine.Chunks {
+			chunk.Chunk.Offset = chunk.Key.Offset
+			chunk.Checksum = verify.KVChecksum{}
+			chunk.Quarantined = false
+			chunks = append(chunks, chunk)
+		}
+	}
+	// the row ID ranges of the chunks are consecutive, so each range starts
+	// where the previous one ends.
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Chunk.RowIDMax < chunks[j].Chunk.RowIDMax
+	})
+	prevRowIDMax := int64(0)
+	for _, chunk := range chunks {
+		chunk.Chunk.PrevRowIDMax = prevRowIDMax
+		prevRowIDMax = chunk.Chunk.RowIDMax
+	}
+}
+
+// changedDataFiles compares the data files recorded in the checkpoint with
+// the current data files of the table. Returns the reason if they differ, or
+// an empty string if they are the same.
+func (cp *TableCheckpoint) changedDataFiles(sourceDir string, dataFiles []string) string {
+	recorded := make(map[string]*ChunkCheckpoint)
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			// older checkpoints record the full path.
+			path := relativeDataFilePath(sourceDir, resolveDataFilePath(sourceDir, chunk.Key.Path))
+			recorded[path] = chunk
+		}
+	}
+	if len(recorded) != len(dataFiles) {
+		return fmt.Sprintf("the table had %d data files but now has %d", len(recorded), len(dataFiles))
+	}
+	for _, path := range dataFiles {
+		chunk, ok := recorded[relativeDataFilePath(sourceDir, path)]
+		if !ok {
+			return fmt.Sprintf("the data file %s is new", path)
+		}
+		if chunk.FileSize == 0 && chunk.FileModTime == 0 {
+			return fmt.Sprintf("the checkpoint of %s was created by an older version without the file size", path)
+		}
+		if err := chunk.checkFile(path); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// missingDataFiles returns the unfinished chunks whose data files no longer
+// exist, e.g. when the dump was partially re-generated.
+func (cp *TableCheckpoint) missingDataFiles(sourceDir string) ([]*ChunkCheckpoint, error) {
+	var missing []*ChunkCheckpoint
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			if chunk.Chunk.Offset >= chunk.Chunk.EndOffset {
+				continue
+			}
+			_, _, err := mydump.StatDataFile(resolveDataFilePath(sourceDir, chunk.Key.Path))
+			switch {
+			case os.IsNotExist(err):
+				missing = append(missing, chunk)
+			case err != nil:
+				return nil, errors.Trace(err)
+			}
+		}
+	}
+	return missing, nil
 }
 
 func (cp *TableCheckpoint) CountChunks() int {
@@ -127,9 +317,10 @@ func (cp *TableCheckpoint) CountChunks() int {
 }
 
 type chunkCheckpointDiff struct {
-	pos      int64
-	rowID    int64
-	checksum verify.KVChecksum
+	pos         int64
+	rowID       int64
+	checksum    verify.KVChecksum
+	quarantined bool
 }
 
 type engineCheckpointDiff struct {
@@ -139,11 +330,13 @@ type engineCheckpointDiff struct {
 }
 
 type TableCheckpointDiff struct {
-	hasStatus bool
-	hasRebase bool
-	status    CheckpointStatus
-	allocBase int64
-	engines   map[int]engineCheckpointDiff
+	hasStatus         bool
+	hasRebase         bool
+	hasTiFlashReplica bool
+	status            CheckpointStatus
+	allocBase         int64
+	tiflashReplica    tiflashReplica
+	engines           map[int]engineCheckpointDiff
 }
 
 func NewTableCheckpointDiff() *TableCheckpointDiff {
@@ -166,6 +359,44 @@ func (cpd *TableCheckpointDiff) insertEngineCheckpointDiff(engineID int, newDiff
 	cpd.engines[engineID] = newDiff
 }
 
+// mergeFrom merges an older diff into this one. Updates already in this diff
+// take precedence.
+func (cpd *TableCheckpointDiff) mergeFrom(older *TableCheckpointDiff) {
+	if older.hasStatus && !cpd.hasStatus {
+		cpd.hasStatus = true
+		cpd.status = older.status
+	}
+	if older.hasRebase {
+		if !cpd.hasRebase {
+			cpd.hasRebase = true
+			cpd.allocBase = older.allocBase
+		} else {
+			cpd.allocBase = mathutil.MaxInt64(cpd.allocBase, older.allocBase)
+		}
+	}
+	if older.hasTiFlashReplica && !cpd.hasTiFlashReplica {
+		cpd.hasTiFlashReplica = true
+		cpd.tiflashReplica = older.tiflashReplica
+	}
+	for engineID, olderDiff := range older.engines {
+		newDiff, ok := cpd.engines[engineID]
+		if !ok {
+			cpd.engines[engineID] = olderDiff
+			continue
+		}
+		if olderDiff.hasStatus && !newDiff.hasStatus {
+			newDiff.hasStatus = true
+			newDiff.status = olderDiff.status
+		}
+		for key, chunkDiff := range olderDiff.chunks {
+			if _, ok := newDiff.chunks[key]; !ok {
+				newDiff.chunks[key] = chunkDiff
+			}
+		}
+		cpd.engines[engineID] = newDiff
+	}
+}
+
 func (cpd *TableCheckpointDiff) String() string {
 	return fmt.Sprintf(
 		"{hasStatus:%v, hasRebase:%v, status:%d, allocBase:%d, engines:[%d]}",
@@ -206,20 +437,22 @@ func (merger *StatusCheckpointMerger) MergeInto(cpd *TableCheckpointDiff) {
 }
 
 type ChunkCheckpointMerger struct {
-	EngineID int
-	Key      ChunkCheckpointKey
-	Checksum verify.KVChecksum
-	Pos      int64
-	RowID    int64
+	EngineID    int
+	Key         ChunkCheckpointKey
+	Checksum    verify.KVChecksum
+	Pos         int64
+	RowID       int64
+	Quarantined bool
 }
 
 func (merger *ChunkCheckpointMerger) MergeInto(cpd *TableCheckpointDiff) {
 	cpd.insertEngineCheckpointDiff(merger.EngineID, engineCheckpointDiff{
 		chunks: map[ChunkCheckpointKey]chunkCheckpointDiff{
 			merger.Key: {
-				pos:      merger.Pos,
-				rowID:    merger.RowID,
-				checksum: merger.Checksum,
+				pos:         merger.Pos,
+				rowID:       merger.RowID,
+				checksum:    merger.Checksum,
+				quarantined: merger.Quarantined,
 			},
 		},
 	})
@@ -234,17 +467,33 @@ func (merger *RebaseCheckpointMerger) MergeInto(cpd *TableCheckpointDiff) {
 	cpd.allocBase = mathutil.MaxInt64(cpd.allocBase, merger.AllocBase)
 }
 
+type TiFlashReplicaCheckpointMerger struct {
+	Count          uint64
+	LocationLabels string
+}
+
+func (merger *TiFlashReplicaCheckpointMerger) MergeInto(cpd *TableCheckpointDiff) {
+	cpd.hasTiFlashReplica = true
+	cpd.tiflashReplica = tiflashReplica{count: merger.Count, locationLabels: merger.LocationLabels}
+}
+
 type DestroyedTableCheckpoint struct {
 	TableName    string
 	EnginesCount int
 }
 
 type CheckpointsDB interface {
-	Initialize(ctx context.Context, dbInfo map[string]*TidbDBInfo) error
+	Initialize(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta) error
+	// TaskID returns the ID of the task recorded by the checkpoints, or
+	// `uuid.Nil` if the task cannot be identified across runs.
+	TaskID(ctx context.Context) (uuid.UUID, error)
 	Get(ctx context.Context, tableName string) (*TableCheckpoint, error)
 	Close() error
 	InsertEngineCheckpoints(ctx context.Context, tableName string, checkpoints []*EngineCheckpoint) error
-	Update(checkpointDiffs map[string]*TableCheckpointDiff)
+	// Update applies the checkpoint diffs. If an error is returned, none or
+	// only part of the diffs may have been applied, and the caller should
+	// retry with the same diffs.
+	Update(checkpointDiffs map[string]*TableCheckpointDiff) error
 
 	RemoveCheckpoint(ctx context.Context, tableName string) error
 	IgnoreErrorCheckpoint(ctx context.Context, tableName string) error
@@ -261,9 +510,13 @@ func NewNullCheckpointsDB() *NullCheckpointsDB {
 	return &NullCheckpointsDB{}
 }
 
-func (*NullCheckpointsDB) Initialize(context.Context, map[string]*TidbDBInfo) error {
+func (*NullCheckpointsDB) Initialize(context.Context, []*mydump.MDDatabaseMeta) error {
 	return nil
 }
+
+func (*NullCheckpointsDB) TaskID(context.Context) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
 func (*NullCheckpointsDB) Close() error {
 	return nil
 }
@@ -278,15 +531,22 @@ func (*NullCheckpointsDB) InsertEngineCheckpoints(_ context.Context, _ string, _
 	return nil
 }
 
-func (*NullCheckpointsDB) Update(map[string]*TableCheckpointDiff) {}
+func (*NullCheckpointsDB) Update(map[string]*TableCheckpointDiff) error {
+	return nil
+}
 
 type MySQLCheckpointsDB struct {
 	db      *sql.DB
 	schema  string
 	session uint64
+	// tablePrefix is prepended to the names of the checkpoint tables.
+	tablePrefix string
 }
 
-func NewMySQLCheckpointsDB(ctx context.Context, db *sql.DB, schemaName string) (*MySQLCheckpointsDB, error) {
+// NewMySQLCheckpointsDB creates the checkpoint tables, named with the prefix,
+// in the schema. Tasks using different schemas or prefixes share the database
+// without interfering with each other.
+func NewMySQLCheckpointsDB(ctx context.Context, db *sql.DB, schemaName string, tablePrefix string) (*MySQLCheckpointsDB, error) {
 	var escapedSchemaName strings.Builder
 	common.WriteMySQLIdentifier(&escapedSchemaName, schemaName)
 	schema := escapedSchemaName.String()
@@ -306,11 +566,13 @@ func NewMySQLCheckpointsDB(ctx context.Context, db *sql.DB, schemaName string) (
 			hash binary(32) NOT NULL,
 			status tinyint unsigned DEFAULT 30,
 			alloc_base bigint NOT NULL DEFAULT 0,
+			tiflash_replica_count bigint unsigned NOT NULL DEFAULT 0,
+			tiflash_location_labels varchar(1024) NOT NULL DEFAULT '',
 			create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			INDEX(node_id, session)
 		);
-	`, schema, checkpointTableNameTable))
+	`, schema, tablePrefix+checkpointTableNameTable))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -320,11 +582,12 @@ func NewMySQLCheckpointsDB(ctx context.Context, db *sql.DB, schemaName string) (
 			table_name varchar(261) NOT NULL,
 			engine_id int unsigned NOT NULL,
 			status tinyint unsigned DEFAULT 30,
+			engine_uuid binary(16) NULL,
 			create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			PRIMARY KEY(table_name, engine_id DESC)
 		);
-	`, schema, checkpointTableNameEngine))
+	`, schema, tablePrefix+checkpointTableNameEngine))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -344,11 +607,25 @@ func NewMySQLCheckpointsDB(ctx context.Context, db *sql.DB, schemaName string) (
 			kvc_bytes bigint unsigned NOT NULL DEFAULT 0,
 			kvc_kvs bigint unsigned NOT NULL DEFAULT 0,
 			kvc_checksum bigint unsigned NOT NULL DEFAULT 0,
+			file_size bigint NOT NULL DEFAULT 0,
+			file_mtime bigint NOT NULL DEFAULT 0,
+			quarantined BOOL NOT NULL DEFAULT 0,
 			create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			PRIMARY KEY(table_name, engine_id, path(500), offset)
 		);
-	`, schema, checkpointTableNameChunk))
+	`, schema, tablePrefix+checkpointTableNameChunk))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = common.ExecWithRetry(ctx, db, "(create task checkpoints table)", fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s.%s (
+			node_id int unsigned NOT NULL PRIMARY KEY,
+			task_id binary(16) NOT NULL,
+			create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`, schema, tablePrefix+checkpointTableNameTask))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -357,13 +634,14 @@ func NewMySQLCheckpointsDB(ctx context.Context, db *sql.DB, schemaName string) (
 	session := uint64(time.Now().UnixNano())
 
 	return &MySQLCheckpointsDB{
-		db:      db,
-		schema:  schema,
-		session: session,
+		db:          db,
+		schema:      schema,
+		session:     session,
+		tablePrefix: tablePrefix,
 	}, nil
 }
 
-func (cpdb *MySQLCheckpointsDB) Initialize(ctx context.Context, dbInfo map[string]*TidbDBInfo) error {
+func (cpdb *MySQLCheckpointsDB) Initialize(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta) error {
 	// We can have at most 65535 placeholders https://stackoverflow.com/q/4922345/
 	// Since this step is not performance critical, we just insert the rows one-by-one.
 
@@ -380,13 +658,32 @@ func (cpdb *MySQLCheckpointsDB) Initialize(ctx context.Context, dbInfo map[strin
 				WHEN node_id = VALUES(node_id) AND hash = VALUES(hash)
 				THEN VALUES(session)
 			END;
-		`, cpdb.schema, checkpointTableNameTable))
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable))
 		if err != nil {
 			return errors.Trace(err)
 		}
 		defer stmt.Close()
 
-		for _, db := range dbInfo {
+		// A new task starts when there are no checkpoints of this node yet.
+		// Checkpoints created before the task table existed keep the nil ID, so
+		// the engines already written are still found after upgrade.
+		taskID := uuid.Nil
+		var tableCount int
+		tableCountQuery := fmt.Sprintf("SELECT count(*) FROM %s.%s WHERE node_id = ?;", cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
+		if err = tx.QueryRowContext(c, tableCountQuery, nodeID).Scan(&tableCount); err != nil {
+			return errors.Trace(err)
+		}
+		if tableCount == 0 {
+			taskID = uuid.NewV4()
+		}
+		_, err = tx.ExecContext(c, fmt.Sprintf(`
+			INSERT IGNORE INTO %s.%s (node_id, task_id) VALUES (?, ?);
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTask), nodeID, taskID.Bytes())
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		for _, db := range dbMetas {
 			for _, table := range db.Tables {
 				tableName := common.UniqueTable(db.Name, table.Name)
 				_, err = stmt.ExecContext(c, nodeID, cpdb.session, tableName, 0)
@@ -405,6 +702,25 @@ func (cpdb *MySQLCheckpointsDB) Initialize(ctx context.Context, dbInfo map[strin
 	return nil
 }
 
+func (cpdb *MySQLCheckpointsDB) TaskID(ctx context.Context) (uuid.UUID, error) {
+	taskID := uuid.Nil
+	query := fmt.Sprintf("SELECT task_id FROM %s.%s WHERE node_id = ?;", cpdb.schema, cpdb.tablePrefix+checkpointTableNameTask)
+	err := common.TransactWithRetry(ctx, cpdb.db, "(read task id)", func(c context.Context, tx *sql.Tx) error {
+		var rawTaskID []byte
+		switch err := tx.QueryRowContext(c, query, nodeID).Scan(&rawTaskID); err {
+		case nil:
+		case sql.ErrNoRows:
+			return nil
+		default:
+			return errors.Trace(err)
+		}
+		var err error
+		taskID, err = uuid.FromBytes(rawTaskID)
+		return errors.Trace(err)
+	})
+	return taskID, errors.Trace(err)
+}
+
 func (cpdb *MySQLCheckpointsDB) Close() error {
 	return errors.Trace(cpdb.db.Close())
 }
@@ -417,8 +733,8 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 		// 1. Populate the engines.
 
 		engineQuery := fmt.Sprintf(`
-			SELECT engine_id, status FROM %s.%s WHERE table_name = ? ORDER BY engine_id DESC;
-		`, cpdb.schema, checkpointTableNameEngine)
+			SELECT engine_id, status, engine_uuid FROM %s.%s WHERE table_name = ? ORDER BY engine_id DESC;
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameEngine)
 		engineRows, err := tx.QueryContext(c, engineQuery, tableName)
 		if err != nil {
 			return errors.Trace(err)
@@ -426,16 +742,22 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 		defer engineRows.Close()
 		for engineRows.Next() {
 			var (
-				engineID int
-				status   uint8
+				engineID   int
+				status     uint8
+				engineUUID []byte
 			)
-			if err := engineRows.Scan(&engineID, &status); err != nil {
+			if err := engineRows.Scan(&engineID, &status, &engineUUID); err != nil {
 				return errors.Trace(err)
 			}
 			for len(cp.Engines) <= engineID {
 				cp.Engines = append(cp.Engines, new(EngineCheckpoint))
 			}
 			cp.Engines[engineID].Status = CheckpointStatus(status)
+			if len(engineUUID) > 0 {
+				if cp.Engines[engineID].UUID, err = uuid.FromBytes(engineUUID); err != nil {
+					return errors.Annotatef(err, "invalid UUID of engine %d", engineID)
+				}
+			}
 		}
 		if err := engineRows.Err(); err != nil {
 			return errors.Trace(err)
@@ -447,10 +769,10 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 			SELECT
 				engine_id, path, offset, columns, should_include_row_id,
 				pos, end_offset, prev_rowid_max, rowid_max,
-				kvc_bytes, kvc_kvs, kvc_checksum
+				kvc_bytes, kvc_kvs, kvc_checksum, file_size, file_mtime, quarantined
 			FROM %s.%s WHERE table_name = ?
 			ORDER BY engine_id, path, offset;
-		`, cpdb.schema, checkpointTableNameChunk)
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameChunk)
 		chunkRows, err := tx.QueryContext(c, chunkQuery, tableName)
 		if err != nil {
 			return errors.Trace(err)
@@ -467,7 +789,7 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 			if err := chunkRows.Scan(
 				&engineID, &value.Key.Path, &value.Key.Offset, &value.Columns, &value.ShouldIncludeRowID,
 				&value.Chunk.Offset, &value.Chunk.EndOffset, &value.Chunk.PrevRowIDMax, &value.Chunk.RowIDMax,
-				&kvcBytes, &kvcKVs, &kvcChecksum,
+				&kvcBytes, &kvcKVs, &kvcChecksum, &value.FileSize, &value.FileModTime, &value.Quarantined,
 			); err != nil {
 				return errors.Trace(err)
 			}
@@ -481,12 +803,12 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 		// 3. Fill in the remaining table info
 
 		tableQuery := fmt.Sprintf(`
-			SELECT status, alloc_base FROM %s.%s WHERE table_name = ?
-		`, cpdb.schema, checkpointTableNameTable)
+			SELECT status, alloc_base, tiflash_replica_count, tiflash_location_labels FROM %s.%s WHERE table_name = ?
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
 		tableRow := tx.QueryRowContext(c, tableQuery, tableName)
 
 		var status uint8
-		if err := tableRow.Scan(&status, &cp.AllocBase); err != nil {
+		if err := tableRow.Scan(&status, &cp.AllocBase, &cp.TiFlashReplicaCount, &cp.TiFlashLocationLabels); err != nil {
 			return errors.Trace(err)
 		}
 		cp.Status = CheckpointStatus(status)
@@ -502,8 +824,8 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 func (cpdb *MySQLCheckpointsDB) InsertEngineCheckpoints(ctx context.Context, tableName string, checkpoints []*EngineCheckpoint) error {
 	err := common.TransactWithRetry(ctx, cpdb.db, "(update engine checkpoints for "+tableName+")", func(c context.Context, tx *sql.Tx) error {
 		engineStmt, err := tx.PrepareContext(c, fmt.Sprintf(`
-			REPLACE INTO %s.%s (table_name, engine_id, status) VALUES (?, ?, ?);
-		`, cpdb.schema, checkpointTableNameEngine))
+			REPLACE INTO %s.%s (table_name, engine_id, status, engine_uuid) VALUES (?, ?, ?, ?);
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameEngine))
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -514,21 +836,25 @@ func (cpdb *MySQLCheckpointsDB) InsertEngineCheckpoints(ctx context.Context, tab
 				table_name, engine_id,
 				path, offset, columns, should_include_row_id,
 				pos, end_offset, prev_rowid_max, rowid_max,
-				kvc_bytes, kvc_kvs, kvc_checksum
+				kvc_bytes, kvc_kvs, kvc_checksum, file_size, file_mtime, quarantined
 			) VALUES (
 				?, ?,
 				?, ?, ?, ?,
 				?, ?, ?, ?,
-				?, ?, ?
+				?, ?, ?, ?, ?, ?
 			);
-		`, cpdb.schema, checkpointTableNameChunk))
+		`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameChunk))
 		if err != nil {
 			return errors.Trace(err)
 		}
 		defer chunkStmt.Close()
 
 		for engineID, engine := range checkpoints {
-			_, err = engineStmt.ExecContext(c, tableName, engineID, engine.Status)
+			var engineUUID []byte
+			if !uuid.Equal(engine.UUID, uuid.Nil) {
+				engineUUID = engine.UUID.Bytes()
+			}
+			_, err = engineStmt.ExecContext(c, tableName, engineID, engine.Status, engineUUID)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -537,7 +863,7 @@ func (cpdb *MySQLCheckpointsDB) InsertEngineCheckpoints(ctx context.Context, tab
 					c, tableName, engineID,
 					value.Key.Path, value.Key.Offset, value.Columns, value.ShouldIncludeRowID,
 					value.Chunk.Offset, value.Chunk.EndOffset, value.Chunk.PrevRowIDMax, value.Chunk.RowIDMax,
-					value.Checksum.SumSize(), value.Checksum.SumKVS(), value.Checksum.Sum(),
+					value.Checksum.SumSize(), value.Checksum.SumKVS(), value.Checksum.Sum(), value.FileSize, value.FileModTime, value.Quarantined,
 				)
 				if err != nil {
 					return errors.Trace(err)
@@ -554,20 +880,23 @@ func (cpdb *MySQLCheckpointsDB) InsertEngineCheckpoints(ctx context.Context, tab
 	return nil
 }
 
-func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) {
+func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) error {
 	chunkQuery := fmt.Sprintf(`
-		UPDATE %s.%s SET pos = ?, prev_rowid_max = ?, kvc_bytes = ?, kvc_kvs = ?, kvc_checksum = ?
+		UPDATE %s.%s SET pos = ?, prev_rowid_max = ?, kvc_bytes = ?, kvc_kvs = ?, kvc_checksum = ?, quarantined = ?
 		WHERE (table_name, engine_id, path, offset) = (?, ?, ?, ?);
-	`, cpdb.schema, checkpointTableNameChunk)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameChunk)
 	checksumQuery := fmt.Sprintf(`
 		UPDATE %s.%s SET alloc_base = GREATEST(?, alloc_base) WHERE table_name = ?;
-	`, cpdb.schema, checkpointTableNameTable)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
 	tableStatusQuery := fmt.Sprintf(`
 		UPDATE %s.%s SET status = ? WHERE table_name = ?;
-	`, cpdb.schema, checkpointTableNameTable)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
+	tiflashReplicaQuery := fmt.Sprintf(`
+		UPDATE %s.%s SET tiflash_replica_count = ?, tiflash_location_labels = ? WHERE table_name = ?;
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
 	engineStatusQuery := fmt.Sprintf(`
 		UPDATE %s.%s SET status = ? WHERE (table_name, engine_id) = (?, ?);
-	`, cpdb.schema, checkpointTableNameEngine)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameEngine)
 
 	err := common.TransactWithRetry(context.Background(), cpdb.db, "(update checkpoints)", func(c context.Context, tx *sql.Tx) error {
 		chunkStmt, e := tx.PrepareContext(c, chunkQuery)
@@ -585,6 +914,11 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 			return errors.Trace(e)
 		}
 		defer tableStatusStmt.Close()
+		tiflashReplicaStmt, e := tx.PrepareContext(c, tiflashReplicaQuery)
+		if e != nil {
+			return errors.Trace(e)
+		}
+		defer tiflashReplicaStmt.Close()
 		engineStatusStmt, e := tx.PrepareContext(c, engineStatusQuery)
 		if e != nil {
 			return errors.Trace(e)
@@ -602,6 +936,11 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 					return errors.Trace(e)
 				}
 			}
+			if cpd.hasTiFlashReplica {
+				if _, e := tiflashReplicaStmt.ExecContext(c, cpd.tiflashReplica.count, cpd.tiflashReplica.locationLabels, tableName); e != nil {
+					return errors.Trace(e)
+				}
+			}
 			for engineID, engineDiff := range cpd.engines {
 				if engineDiff.hasStatus {
 					if _, e := engineStatusStmt.ExecContext(c, engineDiff.status, tableName, engineID); e != nil {
@@ -611,7 +950,7 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 				for key, diff := range engineDiff.chunks {
 					if _, e := chunkStmt.ExecContext(
 						c,
-						diff.pos, diff.rowID, diff.checksum.SumSize(), diff.checksum.SumKVS(), diff.checksum.Sum(),
+						diff.pos, diff.rowID, diff.checksum.SumSize(), diff.checksum.SumKVS(), diff.checksum.Sum(), diff.quarantined,
 						tableName, engineID, key.Path, key.Offset,
 					); e != nil {
 						return errors.Trace(e)
@@ -622,9 +961,7 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 
 		return nil
 	})
-	if err != nil {
-		common.AppLogger.Errorf("failed to save checkpoint: %v", err)
-	}
+	return errors.Trace(err)
 }
 
 type FileCheckpointsDB struct {
@@ -656,15 +993,23 @@ func (cpdb *FileCheckpointsDB) save() error {
 	return nil
 }
 
-func (cpdb *FileCheckpointsDB) Initialize(ctx context.Context, dbInfo map[string]*TidbDBInfo) error {
+func (cpdb *FileCheckpointsDB) Initialize(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta) error {
 	cpdb.lock.Lock()
 	defer cpdb.lock.Unlock()
 
 	if cpdb.checkpoints.Checkpoints == nil {
 		cpdb.checkpoints.Checkpoints = make(map[string]*TableCheckpointModel)
 	}
+	if len(cpdb.checkpoints.TaskId) == 0 {
+		// see MySQLCheckpointsDB.Initialize for why existing checkpoints use the nil ID.
+		if len(cpdb.checkpoints.Checkpoints) == 0 {
+			cpdb.checkpoints.TaskId = uuid.NewV4().Bytes()
+		} else {
+			cpdb.checkpoints.TaskId = uuid.Nil.Bytes()
+		}
+	}
 
-	for _, db := range dbInfo {
+	for _, db := range dbMetas {
 		for _, table := range db.Tables {
 			tableName := common.UniqueTable(db.Name, table.Name)
 			if _, ok := cpdb.checkpoints.Checkpoints[tableName]; !ok {
@@ -679,6 +1024,17 @@ func (cpdb *FileCheckpointsDB) Initialize(ctx context.Context, dbInfo map[string
 	return errors.Trace(cpdb.save())
 }
 
+func (cpdb *FileCheckpointsDB) TaskID(context.Context) (uuid.UUID, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if len(cpdb.checkpoints.TaskId) == 0 {
+		return uuid.Nil, nil
+	}
+	taskID, err := uuid.FromBytes(cpdb.checkpoints.TaskId)
+	return taskID, errors.Trace(err)
+}
+
 func (cpdb *FileCheckpointsDB) Close() error {
 	cpdb.lock.Lock()
 	defer cpdb.lock.Unlock()
@@ -686,6 +1042,18 @@ func (cpdb *FileCheckpointsDB) Close() error {
 	return errors.Trace(cpdb.save())
 }
 
+// kvChecksum returns the checksum persisted in the kvc_* fields.
+func (m *ChunkCheckpointModel) kvChecksum() verify.KVChecksum {
+	return verify.MakeKVChecksum(m.KvcBytes, m.KvcKvs, m.KvcChecksum)
+}
+
+// setKVChecksum persists the checksum into the kvc_* fields.
+func (m *ChunkCheckpointModel) setKVChecksum(checksum *verify.KVChecksum) {
+	m.KvcBytes = checksum.SumSize()
+	m.KvcKvs = checksum.SumKVS()
+	m.KvcChecksum = checksum.Sum()
+}
+
 func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableCheckpoint, error) {
 	cpdb.lock.Lock()
 	defer cpdb.lock.Unlock()
@@ -693,9 +1061,11 @@ func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableC
 	tableModel := cpdb.checkpoints.Checkpoints[tableName]
 
 	cp := &TableCheckpoint{
-		Status:    CheckpointStatus(tableModel.Status),
-		AllocBase: tableModel.AllocBase,
-		Engines:   make([]*EngineCheckpoint, 0, len(tableModel.Engines)),
+		Status:                CheckpointStatus(tableModel.Status),
+		AllocBase:             tableModel.AllocBase,
+		Engines:               make([]*EngineCheckpoint, 0, len(tableModel.Engines)),
+		TiFlashReplicaCount:   tableModel.TiflashReplicaCount,
+		TiFlashLocationLabels: tableModel.TiflashLocationLabels,
 	}
 
 	for _, engineModel := range tableModel.Engines {
@@ -703,6 +1073,12 @@ func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableC
 			Status: CheckpointStatus(engineModel.Status),
 			Chunks: make([]*ChunkCheckpoint, 0, len(engineModel.Chunks)),
 		}
+		if len(engineModel.Uuid) > 0 {
+			var err error
+			if engine.UUID, err = uuid.FromBytes(engineModel.Uuid); err != nil {
+				return nil, errors.Annotatef(err, "invalid UUID of engine %d of %s", len(cp.Engines), tableName)
+			}
+		}
 
 		for _, chunkModel := range engineModel.Chunks {
 			engine.Chunks = append(engine.Chunks, &ChunkCheckpoint{
@@ -718,7 +1094,10 @@ func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableC
 					PrevRowIDMax: chunkModel.PrevRowidMax,
 					RowIDMax:     chunkModel.RowidMax,
 				},
-				Checksum: verify.MakeKVChecksum(chunkModel.KvcBytes, chunkModel.KvcKvs, chunkModel.KvcChecksum),
+				Checksum:    chunkModel.kvChecksum(),
+				FileSize:    chunkModel.FileSize,
+				FileModTime: chunkModel.FileMtime,
+				Quarantined: chunkModel.Quarantined,
 			})
 		}
 
@@ -746,6 +1125,9 @@ func (cpdb *FileCheckpointsDB) InsertEngineCheckpoints(_ context.Context, tableN
 
 	for engineID, engine := range checkpoints {
 		engineModel := tableModel.Engines[engineID]
+		if !uuid.Equal(engine.UUID, uuid.Nil) {
+			engineModel.Uuid = engine.UUID.Bytes()
+		}
 		for _, value := range engine.Chunks {
 			key := value.Key.String()
 			chunk, ok := engineModel.Chunks[key]
@@ -755,6 +1137,8 @@ func (cpdb *FileCheckpointsDB) InsertEngineCheckpoints(_ context.Context, tableN
 					Offset:             value.Key.Offset,
 					Columns:            value.Columns,
 					ShouldIncludeRowId: value.ShouldIncludeRowID,
+					FileSize:           value.FileSize,
+					FileMtime:          value.FileModTime,
 				}
 				engineModel.Chunks[key] = chunk
 			}
@@ -762,16 +1146,15 @@ func (cpdb *FileCheckpointsDB) InsertEngineCheckpoints(_ context.Context, tableN
 			chunk.EndOffset = value.Chunk.EndOffset
 			chunk.PrevRowidMax = value.Chunk.PrevRowIDMax
 			chunk.RowidMax = value.Chunk.RowIDMax
-			chunk.KvcBytes = value.Checksum.SumSize()
-			chunk.KvcKvs = value.Checksum.SumKVS()
-			chunk.KvcChecksum = value.Checksum.Sum()
+			chunk.Quarantined = value.Quarantined
+			chunk.setKVChecksum(&value.Checksum)
 		}
 	}
 
 	return errors.Trace(cpdb.save())
 }
 
-func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) {
+func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) error {
 	cpdb.lock.Lock()
 	defer cpdb.lock.Unlock()
 
@@ -783,6 +1166,10 @@ func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoin
 		if cpd.hasRebase {
 			tableModel.AllocBase = cpd.allocBase
 		}
+		if cpd.hasTiFlashReplica {
+			tableModel.TiflashReplicaCount = cpd.tiflashReplica.count
+			tableModel.TiflashLocationLabels = cpd.tiflashReplica.locationLabels
+		}
 		for engineID, engineDiff := range cpd.engines {
 			engineModel := tableModel.Engines[engineID]
 			if engineDiff.hasStatus {
@@ -793,16 +1180,13 @@ func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoin
 				chunkModel := engineModel.Chunks[key.String()]
 				chunkModel.Pos = diff.pos
 				chunkModel.PrevRowidMax = diff.rowID
-				chunkModel.KvcBytes = diff.checksum.SumSize()
-				chunkModel.KvcKvs = diff.checksum.SumKVS()
-				chunkModel.KvcChecksum = diff.checksum.Sum()
+				chunkModel.Quarantined = diff.quarantined
+				chunkModel.setKVChecksum(&diff.checksum)
 			}
 		}
 	}
 
-	if err := cpdb.save(); err != nil {
-		common.AppLogger.Errorf("failed to save checkpoint: %v", err)
-	}
+	return errors.Trace(cpdb.save())
 }
 
 // Management functions ----------------------------------------------------------------------------
@@ -848,9 +1232,10 @@ func (cpdb *MySQLCheckpointsDB) RemoveCheckpoint(ctx context.Context, tableName
 		arg = tableName
 	}
 
-	deleteChunkQuery := fmt.Sprintf(deleteChunkFmt, cpdb.schema, checkpointTableNameChunk, checkpointTableNameTable)
-	deleteEngineQuery := fmt.Sprintf(deleteEngineFmt, cpdb.schema, checkpointTableNameEngine, checkpointTableNameTable)
-	deleteTableQuery := fmt.Sprintf(deleteTableFmt, cpdb.schema, checkpointTableNameTable)
+	deleteChunkQuery := fmt.Sprintf(deleteChunkFmt, cpdb.schema, cpdb.tablePrefix+checkpointTableNameChunk, cpdb.tablePrefix+checkpointTableNameTable)
+	deleteEngineQuery := fmt.Sprintf(deleteEngineFmt, cpdb.schema, cpdb.tablePrefix+checkpointTableNameEngine, cpdb.tablePrefix+checkpointTableNameTable)
+	deleteTableQuery := fmt.Sprintf(deleteTableFmt, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
+	deleteTaskQuery := fmt.Sprintf("DELETE FROM %s.%s WHERE node_id = ?", cpdb.schema, cpdb.tablePrefix+checkpointTableNameTask)
 	err := common.TransactWithRetry(ctx, cpdb.db, fmt.Sprintf("(remove checkpoints of %s)", tableName), func(c context.Context, tx *sql.Tx) error {
 		if _, e := tx.ExecContext(c, deleteChunkQuery, arg); e != nil {
 			return errors.Trace(e)
@@ -861,11 +1246,56 @@ func (cpdb *MySQLCheckpointsDB) RemoveCheckpoint(ctx context.Context, tableName
 		if _, e := tx.ExecContext(c, deleteTableQuery, arg); e != nil {
 			return errors.Trace(e)
 		}
+		// removing all checkpoints ends the task, the next run starts a new one.
+		if tableName == "all" {
+			if _, e := tx.ExecContext(c, deleteTaskQuery, nodeID); e != nil {
+				return errors.Trace(e)
+			}
+		}
 		return nil
 	})
 	return errors.Trace(err)
 }
 
+// DropIfEmpty drops the checkpoint tables if no task has checkpoints in them,
+// and then the schema if it has no other tables.
+func (cpdb *MySQLCheckpointsDB) DropIfEmpty(ctx context.Context) error {
+	var count int
+	query := fmt.Sprintf("SELECT count(*) FROM %s.%s", cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable)
+	if err := common.QueryRowWithRetry(ctx, cpdb.db, query, &count); err != nil {
+		return errors.Trace(err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, name := range []string{checkpointTableNameChunk, checkpointTableNameEngine, checkpointTableNameTable, checkpointTableNameTask} {
+		query := fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", cpdb.schema, cpdb.tablePrefix+name)
+		if err := common.ExecWithRetry(ctx, cpdb.db, "(drop checkpoints table)", query); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	query = fmt.Sprintf("SHOW TABLES IN %s", cpdb.schema)
+	var tableCount int
+	err := common.TransactWithRetry(ctx, cpdb.db, "(count tables in checkpoints schema)", func(c context.Context, tx *sql.Tx) error {
+		rows, e := tx.QueryContext(c, query)
+		if e != nil {
+			return errors.Trace(e)
+		}
+		defer rows.Close()
+		tableCount = 0
+		for rows.Next() {
+			tableCount++
+		}
+		return errors.Trace(rows.Err())
+	})
+	if err != nil || tableCount > 0 {
+		return errors.Trace(err)
+	}
+	return errors.Trace(common.ExecWithRetry(ctx, cpdb.db, "(drop checkpoints database)", "DROP DATABASE IF EXISTS "+cpdb.schema))
+}
+
 func (cpdb *MySQLCheckpointsDB) IgnoreErrorCheckpoint(ctx context.Context, tableName string) error {
 	var (
 		colName string
@@ -878,10 +1308,10 @@ func (cpdb *MySQLCheckpointsDB) IgnoreErrorCheckpoint(ctx context.Context, table
 	}
 	engineQuery := fmt.Sprintf(`
 		UPDATE %s.%s SET status = %d WHERE %s = ? AND status <= %d;
-	`, cpdb.schema, checkpointTableNameEngine, CheckpointStatusLoaded, colName, CheckpointStatusMaxInvalid)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameEngine, CheckpointStatusLoaded, colName, CheckpointStatusMaxInvalid)
 	tableQuery := fmt.Sprintf(`
 		UPDATE %s.%s SET status = %d WHERE %s = ? AND status <= %d;
-	`, cpdb.schema, checkpointTableNameTable, CheckpointStatusLoaded, colName, CheckpointStatusMaxInvalid)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable, CheckpointStatusLoaded, colName, CheckpointStatusMaxInvalid)
 
 	err := common.TransactWithRetry(ctx, cpdb.db, fmt.Sprintf("(ignore error checkpoints for %s)", tableName), func(c context.Context, tx *sql.Tx) error {
 		if _, e := tx.ExecContext(c, engineQuery, arg); e != nil {
@@ -917,16 +1347,16 @@ func (cpdb *MySQLCheckpointsDB) DestroyErrorCheckpoint(ctx context.Context, tabl
 		LEFT JOIN %[1]s.%[5]s e ON t.table_name = e.table_name
 		WHERE t.%[2]s = ? AND t.status <= %[3]d
 		GROUP BY t.table_name;
-	`, cpdb.schema, conditionColumn, CheckpointStatusMaxInvalid, checkpointTableNameTable, checkpointTableNameEngine)
+	`, cpdb.schema, conditionColumn, CheckpointStatusMaxInvalid, cpdb.tablePrefix+checkpointTableNameTable, cpdb.tablePrefix+checkpointTableNameEngine)
 	deleteChunkQuery := fmt.Sprintf(`
 		DELETE FROM %[1]s.%[4]s WHERE table_name IN (SELECT table_name FROM %[1]s.%[5]s WHERE %[2]s = ? AND status <= %[3]d)
-	`, cpdb.schema, conditionColumn, CheckpointStatusMaxInvalid, checkpointTableNameChunk, checkpointTableNameTable)
+	`, cpdb.schema, conditionColumn, CheckpointStatusMaxInvalid, cpdb.tablePrefix+checkpointTableNameChunk, cpdb.tablePrefix+checkpointTableNameTable)
 	deleteEngineQuery := fmt.Sprintf(`
 		DELETE FROM %[1]s.%[4]s WHERE table_name IN (SELECT table_name FROM %[1]s.%[5]s WHERE %[2]s = ? AND status <= %[3]d)
-	`, cpdb.schema, conditionColumn, CheckpointStatusMaxInvalid, checkpointTableNameEngine, checkpointTableNameTable)
+	`, cpdb.schema, conditionColumn, CheckpointStatusMaxInvalid, cpdb.tablePrefix+checkpointTableNameEngine, cpdb.tablePrefix+checkpointTableNameTable)
 	deleteTableQuery := fmt.Sprintf(`
 		DELETE FROM %s.%s WHERE %s = ? AND status <= %d
-	`, cpdb.schema, checkpointTableNameTable, conditionColumn, CheckpointStatusMaxInvalid)
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable, conditionColumn, CheckpointStatusMaxInvalid)
 
 	var targetTables []DestroyedTableCheckpoint
 
@@ -977,10 +1407,12 @@ func (cpdb *MySQLCheckpointsDB) DumpTables(ctx context.Context, writer io.Writer
 			hex(hash) AS hash,
 			status,
 			alloc_base,
+			tiflash_replica_count,
+			tiflash_location_labels,
 			create_time,
 			update_time
 		FROM %s.%s;
-	`, cpdb.schema, checkpointTableNameTable))
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameTable))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -995,10 +1427,11 @@ func (cpdb *MySQLCheckpointsDB) DumpEngines(ctx context.Context, writer io.Write
 			table_name,
 			engine_id,
 			status,
+			hex(engine_uuid) AS engine_uuid,
 			create_time,
 			update_time
 		FROM %s.%s;
-	`, cpdb.schema, checkpointTableNameEngine))
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameEngine))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -1021,10 +1454,11 @@ func (cpdb *MySQLCheckpointsDB) DumpChunks(ctx context.Context, writer io.Writer
 			kvc_bytes,
 			kvc_kvs,
 			kvc_checksum,
+			quarantined,
 			create_time,
 			update_time
 		FROM %s.%s;
-	`, cpdb.schema, checkpointTableNameChunk))
+	`, cpdb.schema, cpdb.tablePrefix+checkpointTableNameChunk))
 	if err != nil {
 		return errors.Trace(err)
 	}
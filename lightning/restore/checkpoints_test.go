@@ -0,0 +1,346 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/satori/go.uuid"
+
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+var _ = Suite(&checkpointsSuite{})
+
+type checkpointsSuite struct{}
+
+func (s *checkpointsSuite) TestFileCheckpointsTaskID(c *C) {
+	ctx := context.Background()
+	path := filepath.Join(c.MkDir(), "cp.pb")
+	dbMetas := []*mydump.MDDatabaseMeta{{
+		Name:   "db",
+		Tables: []*mydump.MDTableMeta{{DB: "db", Name: "t"}},
+	}}
+
+	cpdb := NewFileCheckpointsDB(path)
+	taskID, err := cpdb.TaskID(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(uuid.Equal(taskID, uuid.Nil), IsTrue)
+
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	taskID, err = cpdb.TaskID(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(uuid.Equal(taskID, uuid.Nil), IsFalse)
+	c.Assert(cpdb.Close(), IsNil)
+
+	// the same task is resumed after reopening the checkpoints.
+	cpdb = NewFileCheckpointsDB(path)
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	resumedTaskID, err := cpdb.TaskID(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(resumedTaskID, Equals, taskID)
+
+	// removing all checkpoints starts a new task.
+	c.Assert(cpdb.RemoveCheckpoint(ctx, "all"), IsNil)
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	newTaskID, err := cpdb.TaskID(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(newTaskID, Not(Equals), taskID)
+}
+
+func (s *checkpointsSuite) TestEngineCheckpointIsFresh(c *C) {
+	cp := &EngineCheckpoint{
+		Status: CheckpointStatusLoaded,
+		Chunks: []*ChunkCheckpoint{{
+			Key:   ChunkCheckpointKey{Path: "/tmp/a.sql", Offset: 0},
+			Chunk: mydump.Chunk{Offset: 0, EndOffset: 100},
+		}},
+	}
+	c.Assert(cp.isFresh(), IsTrue)
+
+	cp.Chunks[0].Chunk.Offset = 50
+	c.Assert(cp.isFresh(), IsFalse)
+
+	cp.Chunks[0].Chunk.Offset = 0
+	cp.Status = CheckpointStatusAllWritten
+	c.Assert(cp.isFresh(), IsFalse)
+}
+
+func (s *checkpointsSuite) TestMergeFromOlderDiff(c *C) {
+	key1 := ChunkCheckpointKey{Path: "/tmp/a.sql", Offset: 0}
+	key2 := ChunkCheckpointKey{Path: "/tmp/b.sql", Offset: 0}
+
+	older := NewTableCheckpointDiff()
+	(&StatusCheckpointMerger{EngineID: -1, Status: CheckpointStatusAllWritten}).MergeInto(older)
+	(&RebaseCheckpointMerger{AllocBase: 100}).MergeInto(older)
+	(&ChunkCheckpointMerger{EngineID: 0, Key: key1, Pos: 10}).MergeInto(older)
+	(&ChunkCheckpointMerger{EngineID: 0, Key: key2, Pos: 20}).MergeInto(older)
+	(&TiFlashReplicaCheckpointMerger{Count: 2, LocationLabels: "zone,host"}).MergeInto(older)
+
+	newer := NewTableCheckpointDiff()
+	(&RebaseCheckpointMerger{AllocBase: 50}).MergeInto(newer)
+	(&ChunkCheckpointMerger{EngineID: 0, Key: key1, Pos: 30}).MergeInto(newer)
+
+	newer.mergeFrom(older)
+	c.Assert(newer.hasStatus, IsTrue)
+	c.Assert(newer.status, Equals, CheckpointStatusAllWritten)
+	c.Assert(newer.allocBase, Equals, int64(100))
+	c.Assert(newer.engines[0].chunks[key1].pos, Equals, int64(30))
+	c.Assert(newer.engines[0].chunks[key2].pos, Equals, int64(20))
+	c.Assert(newer.hasTiFlashReplica, IsTrue)
+	c.Assert(newer.tiflashReplica, Equals, tiflashReplica{count: 2, locationLabels: "zone,host"})
+}
+
+func (s *checkpointsSuite) TestChunkCheckpointCheckFile(c *C) {
+	path := filepath.Join(c.MkDir(), "db.t.sql")
+	c.Assert(ioutil.WriteFile(path, []byte("INSERT INTO t VALUES (1);"), 0644), IsNil)
+	info, err := os.Stat(path)
+	c.Assert(err, IsNil)
+
+	cp := &ChunkCheckpoint{Key: ChunkCheckpointKey{Path: "db.t.sql"}}
+	c.Assert(cp.checkFile(path), IsNil)
+
+	cp.FileSize = info.Size()
+	cp.FileModTime = info.ModTime().Unix()
+	c.Assert(cp.checkFile(path), IsNil)
+
+	cp.FileSize++
+	c.Assert(cp.checkFile(path), ErrorMatches, "cannot resume db.t.sql:0 from the checkpoint, the file was 26 bytes .* but is now 25 bytes .*")
+
+	c.Assert(cp.checkFile(path+".missing"), ErrorMatches, "cannot resume db.t.sql:0 from the checkpoint: .*")
+}
+
+func (s *checkpointsSuite) TestMissingDataFiles(c *C) {
+	dir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "db.t.1.sql"), []byte("INSERT INTO t VALUES (1);"), 0644), IsNil)
+
+	existing := &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: "db.t.1.sql"},
+		Chunk: mydump.Chunk{Offset: 0, EndOffset: 25},
+	}
+	missing := &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: "db.t.2.sql"},
+		Chunk: mydump.Chunk{Offset: 0, EndOffset: 25},
+	}
+	finished := &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: "db.t.3.sql"},
+		Chunk: mydump.Chunk{Offset: 25, EndOffset: 25},
+	}
+	cp := &TableCheckpoint{
+		Engines: []*EngineCheckpoint{
+			{Chunks: []*ChunkCheckpoint{existing, missing, finished}},
+		},
+	}
+
+	result, err := cp.missingDataFiles(dir)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, []*ChunkCheckpoint{missing})
+}
+
+func (s *checkpointsSuite) TestResetProgress(c *C) {
+	cp := &TableCheckpoint{
+		Status: CheckpointStatusChecksummed / 10,
+		Engines: []*EngineCheckpoint{
+			{
+				Status: CheckpointStatusImported,
+				Chunks: []*ChunkCheckpoint{{
+					Key:      ChunkCheckpointKey{Path: "db.t.2.sql"},
+					Chunk:    mydump.Chunk{Offset: 50, EndOffset: 50, PrevRowIDMax: 25, RowIDMax: 25},
+					Checksum: verify.MakeKVChecksum(10, 1, 2),
+				}},
+			},
+			{
+				Status: CheckpointStatusImported,
+				Chunks: []*ChunkCheckpoint{{
+					Key:      ChunkCheckpointKey{Path: "db.t.1.sql"},
+					Chunk:    mydump.Chunk{Offset: 30, EndOffset: 30, PrevRowIDMax: 15, RowIDMax: 15},
+					Checksum: verify.MakeKVChecksum(20, 3, 4),
+				}},
+			},
+		},
+	}
+	cp.resetProgress()
+
+	c.Assert(cp.Status, Equals, CheckpointStatusLoaded)
+	for _, engine := range cp.Engines {
+		c.Assert(engine.Status, Equals, CheckpointStatusLoaded)
+		c.Assert(engine.isFresh(), IsTrue)
+		c.Assert(engine.Chunks[0].Checksum.SumKVS(), Equals, uint64(0))
+	}
+	c.Assert(cp.Engines[0].Chunks[0].Chunk, Equals, mydump.Chunk{Offset: 0, EndOffset: 50, PrevRowIDMax: 15, RowIDMax: 25})
+	c.Assert(cp.Engines[1].Chunks[0].Chunk, Equals, mydump.Chunk{Offset: 0, EndOffset: 30, PrevRowIDMax: 0, RowIDMax: 15})
+}
+
+func (s *checkpointsSuite) TestLargestChunksFirst(c *C) {
+	cp := &EngineCheckpoint{
+		Chunks: []*ChunkCheckpoint{
+			{Chunk: mydump.Chunk{Offset: 0, EndOffset: 10}},
+			{Chunk: mydump.Chunk{Offset: 0, EndOffset: 30}},
+			{Chunk: mydump.Chunk{Offset: 25, EndOffset: 30}},
+			{Chunk: mydump.Chunk{Offset: 10, EndOffset: 40}},
+			{Chunk: mydump.Chunk{Offset: 0, EndOffset: 0}},
+		},
+	}
+	c.Assert(cp.largestChunksFirst(), DeepEquals, []int{1, 3, 0, 2, 4})
+}
+
+func (s *checkpointsSuite) TestChangedDataFiles(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "db.t.sql")
+	c.Assert(ioutil.WriteFile(path, []byte("INSERT INTO t VALUES (1);"), 0644), IsNil)
+	info, err := os.Stat(path)
+	c.Assert(err, IsNil)
+
+	chunk := &ChunkCheckpoint{
+		Key:         ChunkCheckpointKey{Path: "db.t.sql"},
+		FileSize:    info.Size(),
+		FileModTime: info.ModTime().Unix(),
+	}
+	cp := &TableCheckpoint{Engines: []*EngineCheckpoint{{Chunks: []*ChunkCheckpoint{chunk}}}}
+	c.Assert(cp.changedDataFiles(dir, []string{path}), Equals, "")
+
+	// older checkpoints record the full path.
+	chunk.Key.Path = path
+	c.Assert(cp.changedDataFiles(dir, []string{path}), Equals, "")
+
+	other := filepath.Join(dir, "db.t.1.sql")
+	c.Assert(cp.changedDataFiles(dir, []string{path, other}), Equals, "the table had 1 data files but now has 2")
+	c.Assert(cp.changedDataFiles(dir, []string{other}), Equals, "the data file "+other+" is new")
+
+	chunk.FileSize++
+	c.Assert(cp.changedDataFiles(dir, []string{path}), Matches, "cannot resume .* but is now 25 bytes .*")
+
+	chunk.FileSize = 0
+	chunk.FileModTime = 0
+	c.Assert(cp.changedDataFiles(dir, []string{path}), Matches, ".* created by an older version .*")
+}
+
+func (s *checkpointsSuite) TestFileCheckpointsFingerprint(c *C) {
+	ctx := context.Background()
+	dbMetas := []*mydump.MDDatabaseMeta{{
+		Name:   "db",
+		Tables: []*mydump.MDTableMeta{{DB: "db", Name: "t"}},
+	}}
+
+	cpdb := NewFileCheckpointsDB(filepath.Join(c.MkDir(), "cp.pb"))
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	err := cpdb.InsertEngineCheckpoints(ctx, "`db`.`t`", []*EngineCheckpoint{{
+		Status: CheckpointStatusLoaded,
+		Chunks: []*ChunkCheckpoint{{
+			Key:         ChunkCheckpointKey{Path: "db.t.sql"},
+			Chunk:       mydump.Chunk{EndOffset: 25},
+			FileSize:    25,
+			FileModTime: 1546300800,
+		}},
+	}})
+	c.Assert(err, IsNil)
+
+	cp, err := cpdb.Get(ctx, "`db`.`t`")
+	c.Assert(err, IsNil)
+	chunk := cp.Engines[0].Chunks[0]
+	c.Assert(chunk.Key.Path, Equals, "db.t.sql")
+	c.Assert(chunk.FileSize, Equals, int64(25))
+	c.Assert(chunk.FileModTime, Equals, int64(1546300800))
+}
+
+func (s *checkpointsSuite) TestFileCheckpointsTiFlashReplica(c *C) {
+	ctx := context.Background()
+	path := filepath.Join(c.MkDir(), "cp.pb")
+	dbMetas := []*mydump.MDDatabaseMeta{{
+		Name:   "db",
+		Tables: []*mydump.MDTableMeta{{DB: "db", Name: "t"}},
+	}}
+
+	cpdb := NewFileCheckpointsDB(path)
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	diff := NewTableCheckpointDiff()
+	(&TiFlashReplicaCheckpointMerger{Count: 2, LocationLabels: "zone,host"}).MergeInto(diff)
+	c.Assert(cpdb.Update(map[string]*TableCheckpointDiff{"`db`.`t`": diff}), IsNil)
+	c.Assert(cpdb.Close(), IsNil)
+
+	// the replica setting survives reopening the checkpoints.
+	cpdb = NewFileCheckpointsDB(path)
+	cp, err := cpdb.Get(ctx, "`db`.`t`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.TiFlashReplicaCount, Equals, uint64(2))
+	c.Assert(cp.TiFlashLocationLabels, Equals, "zone,host")
+}
+
+func (s *checkpointsSuite) TestFileCheckpointsEngineUUID(c *C) {
+	ctx := context.Background()
+	path := filepath.Join(c.MkDir(), "cp.pb")
+	dbMetas := []*mydump.MDDatabaseMeta{{
+		Name:   "db",
+		Tables: []*mydump.MDTableMeta{{DB: "db", Name: "t"}},
+	}}
+	engineUUID := uuid.NewV4()
+
+	cpdb := NewFileCheckpointsDB(path)
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	err := cpdb.InsertEngineCheckpoints(ctx, "`db`.`t`", []*EngineCheckpoint{
+		{Status: CheckpointStatusLoaded, UUID: engineUUID},
+		{Status: CheckpointStatusLoaded},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cpdb.Close(), IsNil)
+
+	cpdb = NewFileCheckpointsDB(path)
+	cp, err := cpdb.Get(ctx, "`db`.`t`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Engines, HasLen, 2)
+	c.Assert(cp.Engines[0].UUID, Equals, engineUUID)
+	c.Assert(cp.Engines[1].UUID, Equals, uuid.Nil)
+}
+
+func (s *checkpointsSuite) TestFileCheckpointsQuarantined(c *C) {
+	ctx := context.Background()
+	path := filepath.Join(c.MkDir(), "cp.pb")
+	dbMetas := []*mydump.MDDatabaseMeta{{
+		Name:   "db",
+		Tables: []*mydump.MDTableMeta{{DB: "db", Name: "t"}},
+	}}
+	key := ChunkCheckpointKey{Path: "db.t.sql"}
+
+	cpdb := NewFileCheckpointsDB(path)
+	c.Assert(cpdb.Initialize(ctx, dbMetas), IsNil)
+	err := cpdb.InsertEngineCheckpoints(ctx, "`db`.`t`", []*EngineCheckpoint{{
+		Status: CheckpointStatusLoaded,
+		Chunks: []*ChunkCheckpoint{{
+			Key:   key,
+			Chunk: mydump.Chunk{EndOffset: 100, RowIDMax: 10},
+		}},
+	}})
+	c.Assert(err, IsNil)
+	diff := NewTableCheckpointDiff()
+	(&ChunkCheckpointMerger{EngineID: 0, Key: key, Pos: 40, RowID: 4, Quarantined: true}).MergeInto(diff)
+	c.Assert(cpdb.Update(map[string]*TableCheckpointDiff{"`db`.`t`": diff}), IsNil)
+	c.Assert(cpdb.Close(), IsNil)
+
+	// the quarantined range survives reopening the checkpoints.
+	cpdb = NewFileCheckpointsDB(path)
+	cp, err := cpdb.Get(ctx, "`db`.`t`")
+	c.Assert(err, IsNil)
+	chunk := cp.Engines[0].Chunks[0]
+	c.Assert(chunk.Quarantined, IsTrue)
+	c.Assert(chunk.Chunk.Offset, Equals, int64(40))
+	c.Assert(chunk.Chunk.EndOffset, Equals, int64(100))
+
+	cp.resetProgress()
+	c.Assert(chunk.Quarantined, IsFalse)
+}
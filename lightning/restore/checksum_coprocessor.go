@@ -0,0 +1,195 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/store/tikv"
+	"github.com/pingcap/tidb/tablecodec"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+// checksumScanOn tells the coprocessor whether the range contains the rows
+// or the entries of an index.
+type checksumScanOn int32
+
+const (
+	checksumScanOnTable checksumScanOn = 0
+	checksumScanOnIndex checksumScanOn = 1
+)
+
+// checksumRequest and checksumResponse are the tipb.ChecksumRequest and
+// tipb.ChecksumResponse messages of the checksum coprocessor request, which
+// ADMIN CHECKSUM TABLE is built on. tipb is not a direct dependency, so the
+// messages are declared here. The algorithm field is omitted, since the only
+// algorithm is the default CRC64-XOR.
+type checksumRequest struct {
+	StartTs uint64         `protobuf:"varint,1,opt,name=start_ts,proto3"`
+	ScanOn  checksumScanOn `protobuf:"varint,2,opt,name=scan_on,proto3"`
+}
+
+func (m *checksumRequest) Reset() { *m = checksumRequest{} }
+func (m *checksumRequest) String() string {
+	return fmt.Sprintf("{start_ts:%d scan_on:%d}", m.StartTs, m.ScanOn)
+}
+func (*checksumRequest) ProtoMessage() {}
+
+type checksumResponse struct {
+	Checksum   uint64 `protobuf:"varint,1,opt,name=checksum,proto3"`
+	TotalKvs   uint64 `protobuf:"varint,2,opt,name=total_kvs,proto3"`
+	TotalBytes uint64 `protobuf:"varint,3,opt,name=total_bytes,proto3"`
+}
+
+func (m *checksumResponse) Reset() { *m = checksumResponse{} }
+func (m *checksumResponse) String() string {
+	return fmt.Sprintf("{checksum:%d total_kvs:%d total_bytes:%d}", m.Checksum, m.TotalKvs, m.TotalBytes)
+}
+func (*checksumResponse) ProtoMessage() {}
+
+func (m *checksumResponse) merge(other *checksumResponse) {
+	m.Checksum ^= other.Checksum
+	m.TotalKvs += other.TotalKvs
+	m.TotalBytes += other.TotalBytes
+}
+
+// checksumRange is a key range of a table to be checksummed.
+type checksumRange struct {
+	scanOn checksumScanOn
+	kv.KeyRange
+}
+
+// checksumRanges returns the ranges holding all the KV pairs of the table,
+// i.e. the rows and the public indexes of every physical table.
+func checksumRanges(tableInfo *model.TableInfo) []checksumRange {
+	physicalIDs := []int64{tableInfo.ID}
+	if pi := tableInfo.GetPartitionInfo(); pi != nil {
+		physicalIDs = physicalIDs[:0]
+		for _, def := range pi.Definitions {
+			physicalIDs = append(physicalIDs, def.ID)
+		}
+	}
+
+	var ranges []checksumRange
+	for _, id := range physicalIDs {
+		prefix := tablecodec.GenTableRecordPrefix(id)
+		ranges = append(ranges, checksumRange{
+			scanOn:   checksumScanOnTable,
+			KeyRange: kv.KeyRange{StartKey: prefix, EndKey: prefix.PrefixNext()},
+		})
+		for _, index := range tableInfo.Indices {
+			if index.State != model.StatePublic {
+				continue
+			}
+			prefix := kv.Key(tablecodec.EncodeTableIndexPrefix(id, index.ID))
+			ranges = append(ranges, checksumRange{
+				scanOn:   checksumScanOnIndex,
+				KeyRange: kv.KeyRange{StartKey: prefix, EndKey: prefix.PrefixNext()},
+			})
+		}
+	}
+	return ranges
+}
+
+// coprocessorChecksum computes the checksum of a table by sending the checksum
+// coprocessor requests to TiKV directly, the same way as ADMIN CHECKSUM TABLE
+// but without going through TiDB.
+type coprocessorChecksum struct {
+	store       kv.Storage
+	concurrency int
+}
+
+func newCoprocessorChecksum(pdAddr string, concurrency int) (*coprocessorChecksum, error) {
+	store, err := tikv.Driver{}.Open(fmt.Sprintf("tikv://%s?disableGC=true", pdAddr))
+	if err != nil {
+		return nil, errors.Annotate(err, "cannot connect to TiKV for the coprocessor checksum")
+	}
+	return &coprocessorChecksum{store: store, concurrency: concurrency}, nil
+}
+
+func (c *coprocessorChecksum) close() {
+	if c != nil {
+		c.store.Close()
+	}
+}
+
+// checksum computes the checksum of the table as of now.
+func (c *coprocessorChecksum) checksum(ctx context.Context, tableName string, tableInfo *TidbTableInfo) (*RemoteChecksum, error) {
+	timer := time.Now()
+	common.AppLogger.Infof("[%s] doing remote checksum through the coprocessor", tableName)
+
+	version, err := c.store.CurrentVersion()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var total checksumResponse
+	for _, r := range checksumRanges(tableInfo.core) {
+		resp, err := c.checksumRange(ctx, version.Ver, r)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		total.merge(resp)
+	}
+	common.AppLogger.Infof("[%s] do checksum takes %v", tableName, time.Since(timer))
+
+	return &RemoteChecksum{
+		Table:      tableInfo.Name,
+		Checksum:   total.Checksum,
+		TotalKVs:   total.TotalKvs,
+		TotalBytes: total.TotalBytes,
+	}, nil
+}
+
+func (c *coprocessorChecksum) checksumRange(ctx context.Context, startTS uint64, r checksumRange) (*checksumResponse, error) {
+	data, err := proto.Marshal(&checksumRequest{StartTs: startTS, ScanOn: r.scanOn})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	req := &kv.Request{
+		Tp:           kv.ReqTypeChecksum,
+		StartTs:      startTS,
+		Data:         data,
+		KeyRanges:    []kv.KeyRange{r.KeyRange},
+		Concurrency:  c.concurrency,
+		NotFillCache: true,
+	}
+	resp := c.store.GetClient().Send(ctx, req, kv.DefaultVars)
+	if resp == nil {
+		return nil, errors.New("the store does not support the checksum request")
+	}
+	defer resp.Close()
+
+	var total checksumResponse
+	for {
+		subset, err := resp.Next(ctx)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if subset == nil {
+			return &total, nil
+		}
+		var part checksumResponse
+		if err := proto.Unmarshal(subset.GetData(), &part); err != nil {
+			return nil, errors.Trace(err)
+		}
+		total.merge(&part)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"github.com/gogo/protobuf/proto"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+)
+
+var _ = Suite(&coprocessorChecksumSuite{})
+
+type coprocessorChecksumSuite struct{}
+
+func (s *coprocessorChecksumSuite) TestChecksumRanges(c *C) {
+	tableInfo := &model.TableInfo{
+		ID: 41,
+		Indices: []*model.IndexInfo{
+			{ID: 1, State: model.StatePublic},
+			{ID: 2, State: model.StateWriteReorganization},
+		},
+	}
+	ranges := checksumRanges(tableInfo)
+	c.Assert(ranges, HasLen, 2)
+	c.Assert(ranges[0].scanOn, Equals, checksumScanOnTable)
+	c.Assert(ranges[0].StartKey, DeepEquals, tablecodec.GenTableRecordPrefix(41))
+	c.Assert(ranges[0].EndKey, DeepEquals, tablecodec.GenTableRecordPrefix(41).PrefixNext())
+	c.Assert(ranges[1].scanOn, Equals, checksumScanOnIndex)
+	c.Assert(ranges[1].StartKey, DeepEquals, kv.Key(tablecodec.EncodeTableIndexPrefix(41, 1)))
+
+	tableInfo.Partition = &model.PartitionInfo{
+		Enable:      true,
+		Definitions: []model.PartitionDefinition{{ID: 42}, {ID: 43}},
+	}
+	ranges = checksumRanges(tableInfo)
+	c.Assert(ranges, HasLen, 4)
+	c.Assert(ranges[0].StartKey, DeepEquals, tablecodec.GenTableRecordPrefix(42))
+	c.Assert(ranges[1].StartKey, DeepEquals, kv.Key(tablecodec.EncodeTableIndexPrefix(42, 1)))
+	c.Assert(ranges[2].StartKey, DeepEquals, tablecodec.GenTableRecordPrefix(43))
+	c.Assert(ranges[3].StartKey, DeepEquals, kv.Key(tablecodec.EncodeTableIndexPrefix(43, 1)))
+}
+
+func (s *coprocessorChecksumSuite) TestChecksumResponse(c *C) {
+	data, err := proto.Marshal(&checksumResponse{Checksum: 0x1234, TotalKvs: 5, TotalBytes: 60})
+	c.Assert(err, IsNil)
+	var resp checksumResponse
+	c.Assert(proto.Unmarshal(data, &resp), IsNil)
+
+	total := checksumResponse{Checksum: 0x1030, TotalKvs: 1, TotalBytes: 10}
+	total.merge(&resp)
+	c.Assert(total, Equals, checksumResponse{Checksum: 0x0204, TotalKvs: 6, TotalBytes: 70})
+}
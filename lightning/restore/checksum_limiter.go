@@ -0,0 +1,115 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var (
+	checksumLoadBackoff    = 10 * time.Second
+	maxChecksumLoadBackoff = 2 * time.Minute
+)
+
+// checksumLimiter bounds the number of ADMIN CHECKSUM TABLE statements
+// running at the same time, and delays starting new ones while the cluster is
+// busy.
+//
+// PD does not report the CPU usage of the stores, so the read flow of the
+// hottest store in the hotspot statistics is taken as the load instead, which
+// is dominated by the coprocessor scans of the running checksums.
+type checksumLimiter struct {
+	workers     *worker.Pool
+	client      *http.Client
+	pdAddr      string
+	maxReadFlow int64
+}
+
+func newChecksumLimiter(ctx context.Context, cfg *config.Config) *checksumLimiter {
+	return &checksumLimiter{
+		workers:     worker.NewPool(ctx, cfg.PostRestore.ChecksumConcurrency, "checksum"),
+		client:      common.NewHTTPClient(10 * time.Second),
+		pdAddr:      cfg.TiDB.PdAddr,
+		maxReadFlow: cfg.PostRestore.ChecksumMaxReadFlow,
+	}
+}
+
+// acquire waits for a free checksum worker, then for the cluster load to drop
+// below the limit. The worker must be released after the checksum.
+func (l *checksumLimiter) acquire(ctx context.Context, tableName string) (*worker.Worker, error) {
+	w, err := l.workers.ApplyContext(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if l.maxReadFlow <= 0 {
+		return w, nil
+	}
+
+	backoff := checksumLoadBackoff
+	for {
+		flow, err := l.maxStoreReadFlow()
+		if err != nil {
+			// the load is only advisory, so PD being unreachable should not
+			// block the checksum.
+			common.AppLogger.Warnf("[%s] cannot get the read flow of the stores from PD, checksum without waiting: %v", tableName, err)
+			return w, nil
+		}
+		if flow <= l.maxReadFlow {
+			return w, nil
+		}
+
+		common.AppLogger.Infof("[%s] a TiKV store is reading %d bytes/s, above post-restore.checksum-max-read-flow, delaying checksum for %v", tableName, flow, backoff)
+		select {
+		case <-ctx.Done():
+			l.workers.Recycle(w)
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxChecksumLoadBackoff {
+			backoff = maxChecksumLoadBackoff
+		}
+	}
+}
+
+func (l *checksumLimiter) release(w *worker.Worker) {
+	l.workers.Recycle(w)
+}
+
+// maxStoreReadFlow returns the highest read flow among the stores in bytes
+// per second.
+func (l *checksumLimiter) maxStoreReadFlow() (int64, error) {
+	var stats struct {
+		BytesReadStats map[string]float64 `json:"bytes-read-stats"`
+	}
+	url := fmt.Sprintf("http://%s/pd/api/v1/hotspot/stores", l.pdAddr)
+	if err := common.GetJSON(l.client, url, &stats); err != nil {
+		return 0, errors.Trace(err)
+	}
+	var max float64
+	for _, flow := range stats.BytesReadStats {
+		if flow > max {
+			max = flow
+		}
+	}
+	return int64(max), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+var _ = Suite(&checksumLimiterSuite{})
+
+type checksumLimiterSuite struct{}
+
+func (s *checksumLimiterSuite) TestWaitForLoad(c *C) {
+	defer func(backoff time.Duration) { checksumLoadBackoff = backoff }(checksumLoadBackoff)
+	checksumLoadBackoff = time.Millisecond
+
+	flows := []int{300, 150, 50}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Assert(req.URL.Path, Equals, "/pd/api/v1/hotspot/stores")
+		fmt.Fprintf(w, `{"bytes-write-stats":{"1":1000},"bytes-read-stats":{"1":10,"2":%d}}`, flows[requests])
+		requests++
+	}))
+	defer server.Close()
+
+	cfg := config.NewConfig()
+	cfg.TiDB.PdAddr = strings.TrimPrefix(server.URL, "http://")
+	cfg.PostRestore.ChecksumConcurrency = 1
+	cfg.PostRestore.ChecksumMaxReadFlow = 100
+	limiter := newChecksumLimiter(context.Background(), cfg)
+
+	w, err := limiter.acquire(context.Background(), "`db`.`t`")
+	c.Assert(err, IsNil)
+	c.Assert(requests, Equals, 3)
+	limiter.release(w)
+}
+
+func (s *checksumLimiterSuite) TestConcurrencyAndCancel(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"bytes-read-stats":{"1":1000}}`))
+	}))
+	defer server.Close()
+
+	cfg := config.NewConfig()
+	cfg.TiDB.PdAddr = strings.TrimPrefix(server.URL, "http://")
+	cfg.PostRestore.ChecksumConcurrency = 1
+	limiter := newChecksumLimiter(context.Background(), cfg)
+
+	// without the read flow limit, only the concurrency is bounded.
+	w, err := limiter.acquire(context.Background(), "`db`.`a`")
+	c.Assert(err, IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, err = limiter.acquire(ctx, "`db`.`b`")
+	cancel()
+	c.Assert(err, NotNil)
+	limiter.release(w)
+
+	// a store too busy blocks until cancelled, releasing the worker.
+	limiter.maxReadFlow = 100
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, err = limiter.acquire(ctx, "`db`.`c`")
+	cancel()
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	limiter.maxReadFlow = 0
+	w, err = limiter.acquire(context.Background(), "`db`.`d`")
+	c.Assert(err, IsNil)
+	limiter.release(w)
+}
@@ -0,0 +1,453 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+// tableOptionValuePattern matches the value of a table option, which is either
+// a quoted string or a bare word.
+const tableOptionValuePattern = `('(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|[^\s'",;]+)`
+
+var (
+	engineOptionRegexp  = regexp.MustCompile(`(?i)\s*\bENGINE\s*=?\s*` + tableOptionValuePattern)
+	fulltextIndexRegexp = regexp.MustCompile(`(?i)^\s*FULLTEXT\b`)
+	collateRegexp       = regexp.MustCompile(`(?i)\bCOLLATE\s*=?\s*` + tableOptionValuePattern)
+	// mysqldump writes the attribute in a version comment, e.g.
+	// `/*!80023 INVISIBLE */`.
+	invisibleRegexp    = regexp.MustCompile(`(?i)\s*(/\*!\d*\s*INVISIBLE\s*\*/|\bINVISIBLE\b)`)
+	spatialIndexRegexp = regexp.MustCompile(`(?i)^\s*SPATIAL\b`)
+	// indexDefinitionRegexp matches the definitions which are not columns.
+	indexDefinitionRegexp = regexp.MustCompile(`(?i)^(PRIMARY|KEY|INDEX|UNIQUE|CONSTRAINT|FOREIGN|FULLTEXT|SPATIAL|CHECK|LIKE)\b`)
+	columnTypeRegexp      = regexp.MustCompile(`^\s+([A-Za-z]+)`)
+)
+
+type ddlRewriteRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// schemaDowngrader rewrites the clauses in the CREATE TABLE statements of the
+// schema files which TiDB does not support, and reports every modification.
+type schemaDowngrader struct {
+	stripEngine       bool
+	stripOptions      []*regexp.Regexp
+	dropFulltextIndex bool
+	stripInvisible    bool
+	// collations maps the lowercased unsupported collations.
+	collations map[string]string
+	rules      []ddlRewriteRule
+	// unsupportedTypes are the uppercased column types TiDB cannot store.
+	unsupportedTypes  map[string]struct{}
+	onUnsupportedType string
+}
+
+// unsupportedColumn is a column of a type which TiDB cannot store.
+type unsupportedColumn struct {
+	name       string
+	definition string
+}
+
+// newSchemaDowngrader creates a downgrader from the config. Returns nil if no
+// rewrite is enabled.
+func newSchemaDowngrader(cfg *config.DDLDowngrade) (*schemaDowngrader, error) {
+	d := &schemaDowngrader{
+		stripEngine:       cfg.StripEngine,
+		dropFulltextIndex: cfg.DropFulltextIndex,
+		stripInvisible:    cfg.StripInvisible,
+	}
+	if len(cfg.CollationMapping) > 0 {
+		d.collations = make(map[string]string, len(cfg.CollationMapping))
+		for from, to := range cfg.CollationMapping {
+			d.collations[strings.ToLower(from)] = to
+		}
+	}
+	for _, option := range cfg.StripOptions {
+		words := strings.Fields(option)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern := `(?i)\s*\b` + strings.Join(words, `\s+`) + `\s*=?\s*` + tableOptionValuePattern
+		d.stripOptions = append(d.stripOptions, regexp.MustCompile(pattern))
+	}
+	for _, rule := range cfg.Rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid DDL rewrite rule %q", rule.Pattern)
+		}
+		d.rules = append(d.rules, ddlRewriteRule{pattern: pattern, replacement: rule.Replacement})
+	}
+	if len(cfg.UnsupportedTypes) > 0 {
+		d.unsupportedTypes = make(map[string]struct{}, len(cfg.UnsupportedTypes))
+		for _, tp := range cfg.UnsupportedTypes {
+			d.unsupportedTypes[strings.ToUpper(tp)] = struct{}{}
+		}
+		d.onUnsupportedType = cfg.OnUnsupportedType
+	}
+
+	if !d.stripEngine && !d.dropFulltextIndex && !d.stripInvisible && len(d.stripOptions) == 0 && len(d.collations) == 0 && len(d.rules) == 0 && len(d.unsupportedTypes) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// downgrade rewrites the statement, and returns the descriptions of the
+// modifications made. A nil downgrader returns the statement unchanged.
+func (d *schemaDowngrader) downgrade(createTable string) (string, []string) {
+	if d == nil {
+		return createTable, nil
+	}
+	var report []string
+
+	if lparen, rparen := findCreateDefinitions(createTable); lparen >= 0 {
+		head, body, tail := createTable[:lparen+1], createTable[lparen+1:rparen], createTable[rparen:]
+
+		if d.dropFulltextIndex {
+			kept, dropped := dropDefinitions(splitTopLevel(body, ','), fulltextIndexRegexp.MatchString)
+			for _, def := range dropped {
+				report = append(report, fmt.Sprintf("dropped index %s", def))
+			}
+			body = strings.Join(kept, ",")
+		}
+
+		if d.stripInvisible {
+			body = removeUnquoted(body, invisibleRegexp, func(attr string, _ string) bool {
+				report = append(report, fmt.Sprintf("removed attribute %s", strings.TrimSpace(attr)))
+				return true
+			})
+		}
+
+		if d.stripEngine {
+			tail = removeUnquoted(tail, engineOptionRegexp, func(option string, value string) bool {
+				if strings.EqualFold(strings.Trim(value, "'\""), "InnoDB") {
+					return false
+				}
+				report = append(report, fmt.Sprintf("removed table option %s", strings.TrimSpace(option)))
+				return true
+			})
+		}
+		for _, re := range d.stripOptions {
+			tail = removeUnquoted(tail, re, func(option string, _ string) bool {
+				report = append(report, fmt.Sprintf("removed table option %s", strings.TrimSpace(option)))
+				return true
+			})
+		}
+
+		createTable = head + body + tail
+	}
+
+	if len(d.collations) > 0 {
+		createTable = replaceUnquoted(createTable, collateRegexp, func(clause string, value string) (string, bool) {
+			to, ok := d.collations[strings.ToLower(strings.Trim(value, "'\""))]
+			if !ok {
+				return "", false
+			}
+			report = append(report, fmt.Sprintf("replaced collation %s by %s", value, to))
+			return clause[:len(clause)-len(value)] + to, true
+		})
+	}
+
+	for _, rule := range d.rules {
+		rewritten := rule.pattern.ReplaceAllString(createTable, rule.replacement)
+		if rewritten != createTable {
+			report = append(report, fmt.Sprintf("applied rewrite rule %q", rule.pattern))
+			createTable = rewritten
+		}
+	}
+
+	return createTable, report
+}
+
+// findUnsupportedColumns returns the columns of the unsupported types in the
+// statement. With `on-unsupported-type = "null"`, the columns are replaced by
+// nullable LONGBLOB columns in the returned statement, the SPATIAL indexes are
+// dropped, and every modification is reported. Otherwise the statement is
+// returned unchanged.
+func (d *schemaDowngrader) findUnsupportedColumns(createTable string) (string, []unsupportedColumn, []string) {
+	if d == nil || len(d.unsupportedTypes) == 0 {
+		return createTable, nil, nil
+	}
+	lparen, rparen := findCreateDefinitions(createTable)
+	if lparen < 0 {
+		return createTable, nil, nil
+	}
+	head, body, tail := createTable[:lparen+1], createTable[lparen+1:rparen], createTable[rparen:]
+
+	defs := splitTopLevel(body, ',')
+	var columns []unsupportedColumn
+	for i, def := range defs {
+		trimmed := strings.TrimLeft(def, " \t\r\n")
+		name, rest := splitColumnName(trimmed)
+		if len(name) == 0 {
+			continue
+		}
+		m := columnTypeRegexp.FindStringSubmatch(rest)
+		if m == nil {
+			continue
+		}
+		if _, ok := d.unsupportedTypes[strings.ToUpper(m[1])]; !ok {
+			continue
+		}
+		columns = append(columns, unsupportedColumn{
+			name:       unquoteIdentifier(name),
+			definition: strings.TrimSpace(def),
+		})
+		if d.onUnsupportedType == config.UnsupportedTypeNull {
+			defs[i] = def[:len(def)-len(trimmed)] + name + " LONGBLOB NULL"
+		}
+	}
+	if len(columns) == 0 || d.onUnsupportedType != config.UnsupportedTypeNull {
+		return createTable, columns, nil
+	}
+
+	var report []string
+	kept, dropped := dropDefinitions(defs, spatialIndexRegexp.MatchString)
+	for _, def := range dropped {
+		report = append(report, fmt.Sprintf("dropped index %s", def))
+	}
+	for _, column := range columns {
+		report = append(report, fmt.Sprintf("replaced column %s by a nullable LONGBLOB column imported as NULL", column.definition))
+	}
+	return head + strings.Join(kept, ",") + tail, columns, report
+}
+
+// splitColumnName splits the column definition into the column name and the
+// rest. Returns an empty name if the definition is an index or a constraint.
+func splitColumnName(def string) (string, string) {
+	if len(def) == 0 {
+		return "", ""
+	}
+	if def[0] == '`' {
+		end := skipQuoted(def, 0)
+		return def[:end], def[end:]
+	}
+	if indexDefinitionRegexp.MatchString(def) {
+		return "", ""
+	}
+	end := strings.IndexAny(def, " \t\r\n")
+	if end < 0 {
+		return "", ""
+	}
+	return def[:end], def[end:]
+}
+
+// unsupportedTypesReport lists the tables and the columns not imported for
+// the unsupported column types, to be logged at the end of the task.
+type unsupportedTypesReport struct {
+	skippedTables []string
+	nullColumns   []string
+}
+
+func newUnsupportedTypesReport(localSchemas map[string]map[string]*localTableSchema) *unsupportedTypesReport {
+	report := &unsupportedTypesReport{}
+	for database, tables := range localSchemas {
+		for tableName, schema := range tables {
+			if len(schema.unsupportedColumns) == 0 {
+				continue
+			}
+			uniqueTable := common.UniqueTable(database, tableName)
+			definitions := make([]string, 0, len(schema.unsupportedColumns))
+			for _, column := range schema.unsupportedColumns {
+				definitions = append(definitions, column.definition)
+			}
+			if schema.skip {
+				report.skippedTables = append(report.skippedTables, uniqueTable+": "+strings.Join(definitions, ", "))
+				continue
+			}
+			for _, definition := range definitions {
+				report.nullColumns = append(report.nullColumns, uniqueTable+": "+definition)
+			}
+		}
+	}
+	sort.Strings(report.skippedTables)
+	sort.Strings(report.nullColumns)
+	return report
+}
+
+func (r *unsupportedTypesReport) emitLog() {
+	if r == nil {
+		return
+	}
+	var msg strings.Builder
+	if len(r.skippedTables) > 0 {
+		fmt.Fprintf(&msg, "Totally **%d** tables are skipped for the columns of types TiDB does not support.\n", len(r.skippedTables))
+		for _, table := range r.skippedTables {
+			fmt.Fprintf(&msg, "- %s\n", table)
+		}
+	}
+	if len(r.nullColumns) > 0 {
+		fmt.Fprintf(&msg, "Totally **%d** columns of types TiDB does not support are imported as NULL.\n", len(r.nullColumns))
+		for _, column := range r.nullColumns {
+			fmt.Fprintf(&msg, "- %s\n", column)
+		}
+	}
+	if msg.Len() > 0 {
+		common.AppLogger.Warn(msg.String())
+	}
+}
+
+// dropDefinitions removes the definitions matching drop, returning the kept
+// definitions and the trimmed dropped ones. The whitespace after the last
+// definition, before the closing parenthesis, is kept even if it is dropped.
+func dropDefinitions(defs []string, drop func(def string) bool) ([]string, []string) {
+	var dropped []string
+	kept := defs[:0]
+	for i, def := range defs {
+		if !drop(def) {
+			kept = append(kept, def)
+			continue
+		}
+		dropped = append(dropped, strings.TrimSpace(def))
+		if i == len(defs)-1 && len(kept) > 0 {
+			kept[len(kept)-1] += def[len(strings.TrimRight(def, " \t\r\n")):]
+		}
+	}
+	return kept, dropped
+}
+
+// removeUnquoted removes the matches of the regexp outside of quoted strings
+// and identifiers, if accepted by the callback. The first submatch of the
+// regexp is passed as the value.
+func removeUnquoted(s string, re *regexp.Regexp, accept func(match string, value string) bool) string {
+	return replaceUnquoted(s, re, func(match string, value string) (string, bool) {
+		return "", accept(match, value)
+	})
+}
+
+// replaceUnquoted replaces the matches of the regexp outside of quoted strings
+// and identifiers by the result of the callback, unless it returns false. The
+// first submatch of the regexp is passed as the value.
+func replaceUnquoted(s string, re *regexp.Regexp, replace func(match string, value string) (string, bool)) string {
+	quoted := quotedRanges(s)
+	isQuoted := func(pos int) bool {
+		for _, r := range quoted {
+			if r[0] <= pos && pos < r[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var buf strings.Builder
+	last := 0
+	for _, indices := range re.FindAllStringSubmatchIndex(s, -1) {
+		start := indices[0] + len(s[indices[0]:indices[1]]) - len(strings.TrimLeft(s[indices[0]:indices[1]], " \t\r\n"))
+		if isQuoted(start) {
+			continue
+		}
+		replacement, ok := replace(s[indices[0]:indices[1]], s[indices[2]:indices[3]])
+		if !ok {
+			continue
+		}
+		buf.WriteString(s[last:indices[0]])
+		buf.WriteString(replacement)
+		last = indices[1]
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+// quotedRanges returns the [start, end) ranges of the quoted strings and
+// identifiers in the SQL text.
+func quotedRanges(s string) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			end := skipQuoted(s, i)
+			ranges = append(ranges, [2]int{i, end})
+			i = end - 1
+		}
+	}
+	return ranges
+}
+
+// skipQuoted returns the position after the quoted string or identifier
+// starting at s[start]. Doubled quotes are treated as escaped, and so are
+// backslashes in strings.
+func skipQuoted(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// findCreateDefinitions returns the positions of the parentheses enclosing the
+// column and index definitions, or -1 if there is none.
+func findCreateDefinitions(s string) (lparen int, rparen int) {
+	lparen = -1
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(s, i) - 1
+		case '(':
+			if depth == 0 {
+				lparen = i
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && lparen >= 0 {
+				return lparen, i
+			}
+		}
+	}
+	return -1, -1
+}
+
+// splitTopLevel splits the SQL text at the separators outside of parentheses
+// and quotes.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			i = skipQuoted(s, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+var _ = Suite(&ddlDowngradeSuite{})
+
+type ddlDowngradeSuite struct{}
+
+func (s *ddlDowngradeSuite) TestDisabled(c *C) {
+	d, err := newSchemaDowngrader(&config.DDLDowngrade{})
+	c.Assert(err, IsNil)
+	c.Assert(d, IsNil)
+
+	stmt, report := d.downgrade("CREATE TABLE t (a int) ENGINE=MyISAM")
+	c.Assert(stmt, Equals, "CREATE TABLE t (a int) ENGINE=MyISAM")
+	c.Assert(report, HasLen, 0)
+}
+
+func (s *ddlDowngradeSuite) TestDowngrade(c *C) {
+	d, err := newSchemaDowngrader(&config.DDLDowngrade{
+		StripEngine:       true,
+		StripOptions:      []string{"ROW_FORMAT", "KEY_BLOCK_SIZE"},
+		DropFulltextIndex: true,
+		Rules:             []*config.DDLRewriteRule{{Pattern: `(?i)utf8mb4_0900_ai_ci`, Replacement: "utf8mb4_bin"}},
+	})
+	c.Assert(err, IsNil)
+
+	stmt, report := d.downgrade("CREATE TABLE `t` (\n" +
+		"  `id` int,\n" +
+		"  `engine` varchar(10) COMMENT 'ENGINE=x, ROW_FORMAT=y',\n" +
+		"  FULLTEXT KEY `ft` (`engine`),\n" +
+		"  KEY `k` (`id`)\n" +
+		") ENGINE=MyISAM DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci ROW_FORMAT=COMPACT COMMENT='ENGINE=MyISAM';")
+	c.Assert(stmt, Equals, "CREATE TABLE `t` (\n"+
+		"  `id` int,\n"+
+		"  `engine` varchar(10) COMMENT 'ENGINE=x, ROW_FORMAT=y',\n"+
+		"  KEY `k` (`id`)\n"+
+		") DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin COMMENT='ENGINE=MyISAM';")
+	c.Assert(report, DeepEquals, []string{
+		"dropped index FULLTEXT KEY `ft` (`engine`)",
+		"removed table option ENGINE=MyISAM",
+		"removed table option ROW_FORMAT=COMPACT",
+		`applied rewrite rule "(?i)utf8mb4_0900_ai_ci"`,
+	})
+
+	// InnoDB is kept, and options without "=" are recognized.
+	stmt, report = d.downgrade("CREATE TABLE t (a int, FULLTEXT (a)) ENGINE=InnoDB KEY_BLOCK_SIZE 8")
+	c.Assert(stmt, Equals, "CREATE TABLE t (a int) ENGINE=InnoDB")
+	c.Assert(report, DeepEquals, []string{"dropped index FULLTEXT (a)", "removed table option KEY_BLOCK_SIZE 8"})
+
+	// the line break before the closing parenthesis is kept.
+	stmt, report = d.downgrade("CREATE TABLE t (\n  a int,\n  FULLTEXT (a)\n)")
+	c.Assert(stmt, Equals, "CREATE TABLE t (\n  a int\n)")
+	c.Assert(report, DeepEquals, []string{"dropped index FULLTEXT (a)"})
+
+	stmt, report = d.downgrade("CREATE TABLE t LIKE u")
+	c.Assert(stmt, Equals, "CREATE TABLE t LIKE u")
+	c.Assert(report, HasLen, 0)
+}
+
+func (s *ddlDowngradeSuite) TestMySQL8(c *C) {
+	d, err := newSchemaDowngrader(&config.DDLDowngrade{
+		StripInvisible:   true,
+		CollationMapping: map[string]string{"UTF8MB4_0900_AI_CI": "utf8mb4_general_ci"},
+	})
+	c.Assert(err, IsNil)
+
+	stmt, report := d.downgrade("CREATE TABLE `t` (\n" +
+		"  `id` int,\n" +
+		"  `name` varchar(10) COLLATE utf8mb4_0900_ai_ci /*!80023 INVISIBLE */ COMMENT 'COLLATE utf8mb4_0900_ai_ci INVISIBLE',\n" +
+		"  `code` char(2) COLLATE utf8mb4_bin INVISIBLE,\n" +
+		"  KEY `k` (`id`) /*!80000 INVISIBLE */\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;")
+	c.Assert(stmt, Equals, "CREATE TABLE `t` (\n"+
+		"  `id` int,\n"+
+		"  `name` varchar(10) COLLATE utf8mb4_general_ci COMMENT 'COLLATE utf8mb4_0900_ai_ci INVISIBLE',\n"+
+		"  `code` char(2) COLLATE utf8mb4_bin,\n"+
+		"  KEY `k` (`id`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;")
+	c.Assert(report, DeepEquals, []string{
+		"removed attribute /*!80023 INVISIBLE */",
+		"removed attribute INVISIBLE",
+		"removed attribute /*!80000 INVISIBLE */",
+		"replaced collation utf8mb4_0900_ai_ci by utf8mb4_general_ci",
+		"replaced collation utf8mb4_0900_ai_ci by utf8mb4_general_ci",
+	})
+}
+
+func (s *ddlDowngradeSuite) TestInvalidRule(c *C) {
+	_, err := newSchemaDowngrader(&config.DDLDowngrade{
+		Rules: []*config.DDLRewriteRule{{Pattern: `(`}},
+	})
+	c.Assert(err, ErrorMatches, "invalid DDL rewrite rule .*")
+}
+
+func (s *ddlDowngradeSuite) TestUnsupportedColumns(c *C) {
+	createTable := "CREATE TABLE `t` (\n" +
+		"  `id` int,\n" +
+		"  `g` geometry NOT NULL,\n" +
+		"  p POINT,\n" +
+		"  `point` varchar(10),\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  SPATIAL KEY `sp` (`g`)\n" +
+		");"
+
+	d, err := newSchemaDowngrader(&config.DDLDowngrade{
+		UnsupportedTypes:  []string{"GEOMETRY", "point"},
+		OnUnsupportedType: config.UnsupportedTypeSkipTable,
+	})
+	c.Assert(err, IsNil)
+	stmt, columns, report := d.findUnsupportedColumns(createTable)
+	c.Assert(stmt, Equals, createTable)
+	c.Assert(columns, DeepEquals, []unsupportedColumn{
+		{name: "g", definition: "`g` geometry NOT NULL"},
+		{name: "p", definition: "p POINT"},
+	})
+	c.Assert(report, HasLen, 0)
+
+	d.onUnsupportedType = config.UnsupportedTypeNull
+	stmt, columns, report = d.findUnsupportedColumns(createTable)
+	c.Assert(stmt, Equals, "CREATE TABLE `t` (\n"+
+		"  `id` int,\n"+
+		"  `g` LONGBLOB NULL,\n"+
+		"  p LONGBLOB NULL,\n"+
+		"  `point` varchar(10),\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		");")
+	c.Assert(columns, HasLen, 2)
+	c.Assert(report, DeepEquals, []string{
+		"dropped index SPATIAL KEY `sp` (`g`)",
+		"replaced column `g` geometry NOT NULL by a nullable LONGBLOB column imported as NULL",
+		"replaced column p POINT by a nullable LONGBLOB column imported as NULL",
+	})
+
+	stmt, columns, report = d.findUnsupportedColumns("CREATE TABLE `u` (`id` int, `a` text)")
+	c.Assert(stmt, Equals, "CREATE TABLE `u` (`id` int, `a` text)")
+	c.Assert(columns, HasLen, 0)
+	c.Assert(report, HasLen, 0)
+}
+
+func (s *ddlDowngradeSuite) TestUnsupportedTypesReport(c *C) {
+	report := newUnsupportedTypesReport(map[string]map[string]*localTableSchema{
+		"db": {
+			"t1": {skip: true, unsupportedColumns: []unsupportedColumn{{name: "g", definition: "`g` geometry"}, {name: "p", definition: "`p` point"}}},
+			"t2": {unsupportedColumns: []unsupportedColumn{{name: "g", definition: "`g` geometry"}}},
+			"t3": {},
+		},
+	})
+	c.Assert(report.skippedTables, DeepEquals, []string{"`db`.`t1`: `g` geometry, `p` point"})
+	c.Assert(report.nullColumns, DeepEquals, []string{"`db`.`t2`: `g` geometry"})
+
+	c.Assert((&localTableSchema{skip: true, unsupportedColumns: []unsupportedColumn{{name: "g"}}}).nullColumns(), HasLen, 0)
+	c.Assert((&localTableSchema{unsupportedColumns: []unsupportedColumn{{name: "g"}}}).nullColumns(), DeepEquals, []string{"g"})
+}
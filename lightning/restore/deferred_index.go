@@ -0,0 +1,154 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	tmysql "github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+const identifierPattern = "(`(?:[^`]|``)+`|[^\\s`(),]+)"
+
+var (
+	// constraintSymbolRegexp matches the optional `CONSTRAINT [symbol]` prefix
+	// of an index definition.
+	constraintSymbolRegexp = regexp.MustCompile("(?i)^\\s*CONSTRAINT\\b(?:\\s*" + identifierPattern + ")?")
+	// secondaryIndexRegexp matches the start of a (unique) secondary index
+	// definition, and captures the index name.
+	secondaryIndexRegexp = regexp.MustCompile("(?i)^\\s*(?:UNIQUE\\b\\s*(?:(?:KEY|INDEX)\\b\\s*)?|(?:KEY|INDEX)\\b\\s*)" + identifierPattern + "?\\s*(?:USING\\s+\\w+\\s*)?\\(")
+	columnNameRegexp     = regexp.MustCompile("^\\s*" + identifierPattern)
+	autoIncrementRegexp  = regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`)
+)
+
+// deferredIndex is a secondary index removed from the CREATE TABLE statement,
+// to be added after the data are imported.
+type deferredIndex struct {
+	// name is the unquoted index name.
+	name string
+	// definition is the index definition in the CREATE TABLE statement.
+	definition string
+}
+
+func (idx *deferredIndex) addIndexStmt(tableName string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", tableName, strings.TrimSpace(idx.definition))
+}
+
+func unquoteIdentifier(ident string) string {
+	if len(ident) >= 2 && ident[0] == '`' {
+		return strings.Replace(ident[1:len(ident)-1], "``", "`", -1)
+	}
+	return ident
+}
+
+// splitSecondaryIndexes removes the named secondary indexes from the CREATE
+// TABLE statement, so the data can be imported without encoding the index
+// entries. The indexes on the AUTO_INCREMENT column are kept, since that
+// column must be indexed.
+func splitSecondaryIndexes(createTable string) (string, []deferredIndex) {
+	lparen, rparen := findCreateDefinitions(createTable)
+	if lparen < 0 {
+		return createTable, nil
+	}
+	defs := splitTopLevel(createTable[lparen+1:rparen], ',')
+
+	var autoIncColumn string
+	for _, def := range defs {
+		if name := columnNameRegexp.FindStringSubmatch(def); name != nil && containsUnquoted(def, autoIncrementRegexp) {
+			autoIncColumn = unquoteIdentifier(name[1])
+		}
+	}
+
+	var indexes []deferredIndex
+	kept := defs[:0]
+	for _, def := range defs {
+		rest := def
+		if loc := constraintSymbolRegexp.FindStringIndex(rest); loc != nil {
+			rest = rest[loc[1]:]
+		}
+		loc := secondaryIndexRegexp.FindStringSubmatchIndex(rest)
+		if loc == nil || loc[2] < 0 {
+			kept = append(kept, def)
+			continue
+		}
+		name := unquoteIdentifier(rest[loc[2]:loc[3]])
+		firstColumn := columnNameRegexp.FindStringSubmatch(rest[loc[1]:])
+		if strings.EqualFold(name, "USING") || (firstColumn != nil && len(autoIncColumn) > 0 && strings.EqualFold(unquoteIdentifier(firstColumn[1]), autoIncColumn)) {
+			kept = append(kept, def)
+			continue
+		}
+		indexes = append(indexes, deferredIndex{name: name, definition: def})
+	}
+	if len(indexes) == 0 {
+		return createTable, nil
+	}
+	return createTable[:lparen+1] + strings.Join(kept, ",") + createTable[rparen:], indexes
+}
+
+func containsUnquoted(s string, re *regexp.Regexp) bool {
+	quoted := quotedRanges(s)
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		inQuote := false
+		for _, r := range quoted {
+			if r[0] <= loc[0] && loc[0] < r[1] {
+				inQuote = true
+				break
+			}
+		}
+		if !inQuote {
+			return true
+		}
+	}
+	return false
+}
+
+// addDeferredIndexes adds the indexes removed from the table before the
+// import, skipping those already existing in the target.
+func (t *TableRestore) addDeferredIndexes(ctx context.Context, rc *RestoreController) error {
+	existing := make(map[string]struct{}, len(t.tableInfo.core.Indices))
+	for _, idx := range t.tableInfo.core.Indices {
+		existing[idx.Name.L] = struct{}{}
+	}
+
+	for _, idx := range t.tableInfo.deferredIndexes {
+		if _, ok := existing[strings.ToLower(idx.name)]; ok {
+			continue
+		}
+		query := idx.addIndexStmt(t.tableName)
+		w, err := rc.indexWorkers.ApplyContext(ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		timer := time.Now()
+		err = rc.tidbMgr.glue.ExecuteWithLog(ctx, query, query)
+		rc.indexWorkers.Recycle(w)
+		if merr, ok := errors.Cause(err).(*mysql.MySQLError); ok && merr.Number == tmysql.ErrDupKeyName {
+			// added in a previous run.
+			err = nil
+		}
+		if err != nil {
+			return errors.Annotatef(err, "%s", query)
+		}
+		common.AppLogger.Infof("[%s] add index %s takes %v", t.tableName, idx.name, time.Since(timer))
+	}
+	return nil
+}
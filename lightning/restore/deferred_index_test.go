@@ -0,0 +1,64 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&deferredIndexSuite{})
+
+type deferredIndexSuite struct{}
+
+func (s *deferredIndexSuite) TestSplitSecondaryIndexes(c *C) {
+	stmt, indexes := splitSecondaryIndexes("CREATE TABLE `t` (\n" +
+		"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+		"  `a` int COMMENT 'AUTO_INCREMENT',\n" +
+		"  `b` varchar(10),\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `ua` (`a`),\n" +
+		"  KEY `idx_id_b` (`id`,`b`),\n" +
+		"  KEY `idx_b` (`b`(5)) USING BTREE,\n" +
+		"  INDEX USING HASH (`a`),\n" +
+		"  CONSTRAINT `fk` FOREIGN KEY (`a`) REFERENCES `u` (`x`)\n" +
+		") ENGINE=InnoDB;")
+	// the primary key, the index on the AUTO_INCREMENT column, the unnamed
+	// index and the foreign key are kept.
+	c.Assert(stmt, Equals, "CREATE TABLE `t` (\n"+
+		"  `id` int NOT NULL AUTO_INCREMENT,\n"+
+		"  `a` int COMMENT 'AUTO_INCREMENT',\n"+
+		"  `b` varchar(10),\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_id_b` (`id`,`b`),\n"+
+		"  INDEX USING HASH (`a`),\n"+
+		"  CONSTRAINT `fk` FOREIGN KEY (`a`) REFERENCES `u` (`x`)\n"+
+		") ENGINE=InnoDB;")
+	c.Assert(indexes, HasLen, 2)
+	c.Assert(indexes[0].name, Equals, "ua")
+	c.Assert(indexes[0].addIndexStmt("`db`.`t`"), Equals, "ALTER TABLE `db`.`t` ADD UNIQUE KEY `ua` (`a`)")
+	c.Assert(indexes[1].name, Equals, "idx_b")
+	c.Assert(indexes[1].addIndexStmt("`db`.`t`"), Equals, "ALTER TABLE `db`.`t` ADD KEY `idx_b` (`b`(5)) USING BTREE")
+
+	stmt, indexes = splitSecondaryIndexes("CREATE TABLE t (a int, b int, KEY k(a), UNIQUE INDEX `u``x` USING BTREE (b))")
+	c.Assert(stmt, Equals, "CREATE TABLE t (a int, b int)")
+	c.Assert(indexes, HasLen, 2)
+	c.Assert(indexes[0].name, Equals, "k")
+	c.Assert(indexes[1].name, Equals, "u`x")
+
+	for _, createTable := range []string{"CREATE TABLE t (a int)", "CREATE TABLE t LIKE u"} {
+		stmt, indexes = splitSecondaryIndexes(createTable)
+		c.Assert(stmt, Equals, createTable)
+		c.Assert(indexes, HasLen, 0)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/kvencoder"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pingcap/tidb-lightning/lightning/backend"
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+const (
+	maxRedeliverTimes = 5
+	redeliverBackoff  = 3 * time.Second
+)
+
+// deliveredRange is a range of a data file whose KV pairs are delivered to an
+// engine as a single block.
+type deliveredRange struct {
+	key   ChunkCheckpointKey
+	start int64
+	end   int64
+}
+
+func (r *deliveredRange) String() string {
+	return fmt.Sprintf("%s:[%d, %d)", r.key.Path, r.start, r.end)
+}
+
+// engineDelivery accounts the blocks of KV pairs delivered to an opened engine
+// in this run and acknowledged by the backend.
+type engineDelivery struct {
+	lock sync.Mutex
+	// acked is the local checksum of the acknowledged blocks.
+	acked verify.KVChecksum
+}
+
+func newEngineDelivery() *engineDelivery {
+	return &engineDelivery{}
+}
+
+func (d *engineDelivery) ack(checksum *verify.KVChecksum) {
+	d.lock.Lock()
+	d.acked.Add(checksum)
+	d.lock.Unlock()
+}
+
+// verifyDelivered compares the checksum of the ranges acknowledged in this
+// run against the checksum of the KV pairs in the write streams the backend
+// closed without error. Both are computed by Lightning itself, so this is an
+// accounting check catching the KV pairs lost between encoding and delivery
+// (e.g. a range recorded as done whose stream has failed), not a verification
+// of the data received by the importer.
+func (d *engineDelivery) verifyDelivered(delivered verify.KVChecksum, tag string) error {
+	d.lock.Lock()
+	local := d.acked
+	d.lock.Unlock()
+
+	if local.Sum() != delivered.Sum() || local.SumKVS() != delivered.SumKVS() || local.SumSize() != delivered.SumSize() {
+		return common.ErrChecksumMismatch.Errorf(
+			"[%s] delivery accounting mismatch before import: acknowledged ranges (checksum=%d, kvs=%d, bytes=%d) vs closed write streams (checksum=%d, kvs=%d, bytes=%d)",
+			tag, local.Sum(), local.SumKVS(), local.SumSize(), delivered.Sum(), delivered.SumKVS(), delivered.SumSize(),
+		)
+	}
+	common.AppLogger.Infof("[%s] delivered %d KV pairs (%d bytes) matching the acknowledged ranges", tag, local.SumKVS(), local.SumSize())
+	return nil
+}
+
+// deliverKVs writes the KV pairs into the engine, split into batches small
+// enough for a single write request. It returns once all batches are
+// acknowledged by the backend. Every batch written counts as a progress of the
+// engine for the watchdog, so a large block on a slow importer is not taken
+// as stalled.
+func deliverKVs(ctx context.Context, b backend.Backend, tableName string, engineID int, totalKVs []kvenc.KvPair, watchdog *stallWatchdog, tag string, logger *log.Entry) error {
+	for _, kvs := range splitIntoDeliveryStreams(totalKVs, maxDeliverBytes) {
+		if ctx.Err() != nil {
+			// no need to send the rest once canceled.
+			return ctx.Err()
+		}
+		if err := b.WriteRows(ctx, tableName, engineID, kvs); err != nil {
+			logger.Warnf("failed to write %d KV pairs: %s", len(kvs), err.Error())
+			return errors.Trace(err)
+		}
+		watchdog.progress(tag, "sent")
+	}
+	return nil
+}
+
+// redeliverKVs retries delivering a block whose write was not acknowledged
+// because the importer became unavailable (e.g. restarted), reopening the
+// engine before every attempt. Only this block is sent again: the blocks
+// acknowledged before are kept by the importer, and every other block in
+// flight is retried by its own deliverer. After Lightning itself restarts,
+// the unacknowledged blocks are delivered again from the chunk checkpoints.
+func redeliverKVs(
+	ctx context.Context,
+	b backend.Backend,
+	tableName string,
+	engineID int,
+	r deliveredRange,
+	totalKVs []kvenc.KvPair,
+	err error,
+	watchdog *stallWatchdog,
+	tag string,
+	logger *log.Entry,
+) error {
+	for i := 0; i < maxRedeliverTimes && common.IsUnavailableError(err); i++ {
+		logger.Warnf("importer unavailable, redelivering %s (#%d): %v", &r, i+1, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(redeliverBackoff):
+		}
+
+		if err = b.OpenEngine(ctx, tableName, engineID, false); err != nil {
+			continue
+		}
+		err = deliverKVs(ctx, b, tableName, engineID, totalKVs, watchdog, tag, logger)
+	}
+	return errors.Trace(err)
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+var _ = Suite(&deliverySuite{})
+
+type deliverySuite struct{}
+
+func (s *deliverySuite) TestEngineDelivery(c *C) {
+	delivery := newEngineDelivery()
+	c.Assert(delivery.verifyDelivered(verify.MakeKVChecksum(0, 0, 0), "`db`.`t`:0"), IsNil)
+
+	checksum := verify.MakeKVChecksum(10, 1, 0x1234)
+	delivery.ack(&checksum)
+	delivery.ack(&checksum)
+	c.Assert(delivery.acked, Equals, verify.MakeKVChecksum(20, 2, 0))
+	c.Assert(delivery.verifyDelivered(verify.MakeKVChecksum(20, 2, 0), "`db`.`t`:0"), IsNil)
+	c.Assert(delivery.verifyDelivered(checksum, "`db`.`t`:0"), ErrorMatches, ".*delivery accounting mismatch.*")
+
+	r := deliveredRange{key: ChunkCheckpointKey{Path: "/tmp/a.sql"}, start: 100, end: 200}
+	c.Assert(r.String(), Equals, "/tmp/a.sql:[100, 200)")
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/kv"
+)
+
+// encoderPool keeps the idle KV encoders of a table for reuse by its chunks.
+// Creating an encoder sets up a new TiDB session and executes the CREATE
+// TABLE statement, which is costly on tables with thousands of chunks. The
+// encoders share the allocator of the table, which only records the largest
+// row ID since the row IDs of each chunk are assigned from its own range.
+type encoderPool struct {
+	lock       sync.Mutex
+	idle       []*kv.TableKVEncoder
+	newEncoder func() (*kv.TableKVEncoder, error)
+}
+
+func newEncoderPool(newEncoder func() (*kv.TableKVEncoder, error)) *encoderPool {
+	return &encoderPool{newEncoder: newEncoder}
+}
+
+// get takes an idle encoder, or creates a new one if there is none.
+func (p *encoderPool) get() (*kv.TableKVEncoder, error) {
+	p.lock.Lock()
+	if n := len(p.idle); n > 0 {
+		encoder := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.lock.Unlock()
+		return encoder, nil
+	}
+	p.lock.Unlock()
+
+	encoder, err := p.newEncoder()
+	return encoder, errors.Trace(err)
+}
+
+// put returns the encoder to the pool. An encoder which has failed should be
+// closed instead, since its session may be left in a bad state.
+func (p *encoderPool) put(encoder *kv.TableKVEncoder) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.idle = append(p.idle, encoder)
+}
+
+// close closes all the idle encoders.
+func (p *encoderPool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, encoder := range p.idle {
+		if err := encoder.Close(); err != nil {
+			common.AppLogger.Errorf("close kv encoder failed: %v", errors.ErrorStack(err))
+		}
+	}
+	p.idle = nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/kv"
+)
+
+var _ = Suite(&encoderPoolSuite{})
+
+type encoderPoolSuite struct{}
+
+func (s *encoderPoolSuite) TestEncoderPool(c *C) {
+	created := 0
+	pool := newEncoderPool(func() (*kv.TableKVEncoder, error) {
+		created++
+		return kv.NewTableKVEncoder("db", "t", 1, "", kv.NewPanickingAllocator(0))
+	})
+	defer pool.close()
+
+	first, err := pool.get()
+	c.Assert(err, IsNil)
+	second, err := pool.get()
+	c.Assert(err, IsNil)
+	c.Assert(created, Equals, 2)
+
+	pool.put(first)
+	reused, err := pool.get()
+	c.Assert(err, IsNil)
+	c.Assert(reused, Equals, first)
+	c.Assert(created, Equals, 2)
+
+	pool.put(reused)
+	pool.put(second)
+}
@@ -26,6 +26,7 @@ const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 type CheckpointsModel struct {
 	// key is table_name
 	Checkpoints          map[string]*TableCheckpointModel `protobuf:"bytes,1,rep,name=checkpoints" json:"checkpoints,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value"`
+	TaskId               []byte                           `protobuf:"bytes,2,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                         `json:"-"`
 	XXX_sizecache        int32                            `json:"-"`
 }
@@ -64,12 +65,14 @@ func (m *CheckpointsModel) XXX_DiscardUnknown() {
 var xxx_messageInfo_CheckpointsModel proto.InternalMessageInfo
 
 type TableCheckpointModel struct {
-	Hash                 []byte                   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
-	Status               uint32                   `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
-	AllocBase            int64                    `protobuf:"varint,4,opt,name=alloc_base,json=allocBase,proto3" json:"alloc_base,omitempty"`
-	Engines              []*EngineCheckpointModel `protobuf:"bytes,6,rep,name=engines" json:"engines,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                 `json:"-"`
-	XXX_sizecache        int32                    `json:"-"`
+	Hash                  []byte                   `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Status                uint32                   `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	AllocBase             int64                    `protobuf:"varint,4,opt,name=alloc_base,json=allocBase,proto3" json:"alloc_base,omitempty"`
+	Engines               []*EngineCheckpointModel `protobuf:"bytes,6,rep,name=engines" json:"engines,omitempty"`
+	TiflashReplicaCount   uint64                   `protobuf:"varint,7,opt,name=tiflash_replica_count,json=tiflashReplicaCount,proto3" json:"tiflash_replica_count,omitempty"`
+	TiflashLocationLabels string                   `protobuf:"bytes,8,opt,name=tiflash_location_labels,json=tiflashLocationLabels,proto3" json:"tiflash_location_labels,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{}                 `json:"-"`
+	XXX_sizecache         int32                    `json:"-"`
 }
 
 func (m *TableCheckpointModel) Reset()         { *m = TableCheckpointModel{} }
@@ -109,6 +112,7 @@ type EngineCheckpointModel struct {
 	Status uint32 `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
 	// key is "$path:$offset"
 	Chunks               map[string]*ChunkCheckpointModel `protobuf:"bytes,2,rep,name=chunks" json:"chunks,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value"`
+	Uuid                 []byte                           `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                         `json:"-"`
 	XXX_sizecache        int32                            `json:"-"`
 }
@@ -158,6 +162,9 @@ type ChunkCheckpointModel struct {
 	KvcBytes             uint64   `protobuf:"varint,9,opt,name=kvc_bytes,json=kvcBytes,proto3" json:"kvc_bytes,omitempty"`
 	KvcKvs               uint64   `protobuf:"varint,10,opt,name=kvc_kvs,json=kvcKvs,proto3" json:"kvc_kvs,omitempty"`
 	KvcChecksum          uint64   `protobuf:"fixed64,11,opt,name=kvc_checksum,json=kvcChecksum,proto3" json:"kvc_checksum,omitempty"`
+	FileSize             int64    `protobuf:"varint,12,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	FileMtime            int64    `protobuf:"varint,13,opt,name=file_mtime,json=fileMtime,proto3" json:"file_mtime,omitempty"`
+	Quarantined          bool     `protobuf:"varint,14,opt,name=quarantined,proto3" json:"quarantined,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
@@ -246,6 +253,12 @@ func (m *CheckpointsModel) MarshalTo(dAtA []byte) (int, error) {
 			}
 		}
 	}
+	if len(m.TaskId) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(len(m.TaskId)))
+		i += copy(dAtA[i:], m.TaskId)
+	}
 	return i, nil
 }
 
@@ -292,6 +305,17 @@ func (m *TableCheckpointModel) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
+	if m.TiflashReplicaCount != 0 {
+		dAtA[i] = 0x38
+		i++
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.TiflashReplicaCount))
+	}
+	if len(m.TiflashLocationLabels) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(len(m.TiflashLocationLabels)))
+		i += copy(dAtA[i:], m.TiflashLocationLabels)
+	}
 	return i, nil
 }
 
@@ -343,6 +367,12 @@ func (m *EngineCheckpointModel) MarshalTo(dAtA []byte) (int, error) {
 			}
 		}
 	}
+	if len(m.Uuid) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(len(m.Uuid)))
+		i += copy(dAtA[i:], m.Uuid)
+	}
 	return i, nil
 }
 
@@ -424,6 +454,26 @@ func (m *ChunkCheckpointModel) MarshalTo(dAtA []byte) (int, error) {
 		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(m.KvcChecksum))
 		i += 8
 	}
+	if m.FileSize != 0 {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.FileSize))
+	}
+	if m.FileMtime != 0 {
+		dAtA[i] = 0x68
+		i++
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.FileMtime))
+	}
+	if m.Quarantined {
+		dAtA[i] = 0x70
+		i++
+		if m.Quarantined {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
 	return i, nil
 }
 
@@ -452,6 +502,10 @@ func (m *CheckpointsModel) Size() (n int) {
 			n += mapEntrySize + 1 + sovFileCheckpoints(uint64(mapEntrySize))
 		}
 	}
+	l = len(m.TaskId)
+	if l > 0 {
+		n += 1 + l + sovFileCheckpoints(uint64(l))
+	}
 	return n
 }
 
@@ -474,6 +528,13 @@ func (m *TableCheckpointModel) Size() (n int) {
 			n += 1 + l + sovFileCheckpoints(uint64(l))
 		}
 	}
+	if m.TiflashReplicaCount != 0 {
+		n += 1 + sovFileCheckpoints(uint64(m.TiflashReplicaCount))
+	}
+	l = len(m.TiflashLocationLabels)
+	if l > 0 {
+		n += 1 + l + sovFileCheckpoints(uint64(l))
+	}
 	return n
 }
 
@@ -496,6 +557,10 @@ func (m *EngineCheckpointModel) Size() (n int) {
 			n += mapEntrySize + 1 + sovFileCheckpoints(uint64(mapEntrySize))
 		}
 	}
+	l = len(m.Uuid)
+	if l > 0 {
+		n += 1 + l + sovFileCheckpoints(uint64(l))
+	}
 	return n
 }
 
@@ -537,6 +602,15 @@ func (m *ChunkCheckpointModel) Size() (n int) {
 	if m.KvcChecksum != 0 {
 		n += 9
 	}
+	if m.FileSize != 0 {
+		n += 1 + sovFileCheckpoints(uint64(m.FileSize))
+	}
+	if m.FileMtime != 0 {
+		n += 1 + sovFileCheckpoints(uint64(m.FileMtime))
+	}
+	if m.Quarantined {
+		n += 2
+	}
 	return n
 }
 
@@ -705,6 +779,37 @@ func (m *CheckpointsModel) Unmarshal(dAtA []byte) error {
 			}
 			m.Checkpoints[mapkey] = mapvalue
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TaskId", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthFileCheckpoints
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TaskId = append(m.TaskId[:0], dAtA[iNdEx:postIndex]...)
+			if m.TaskId == nil {
+				m.TaskId = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileCheckpoints(dAtA[iNdEx:])
@@ -855,6 +960,54 @@ func (m *TableCheckpointModel) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TiflashReplicaCount", wireType)
+			}
+			m.TiflashReplicaCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TiflashReplicaCount |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TiflashLocationLabels", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFileCheckpoints
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TiflashLocationLabels = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileCheckpoints(dAtA[iNdEx:])
@@ -1047,6 +1200,37 @@ func (m *EngineCheckpointModel) Unmarshal(dAtA []byte) error {
 			}
 			m.Chunks[mapkey] = mapvalue
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Uuid", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthFileCheckpoints
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Uuid = append(m.Uuid[:0], dAtA[iNdEx:postIndex]...)
+			if m.Uuid == nil {
+				m.Uuid = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileCheckpoints(dAtA[iNdEx:])
@@ -1320,6 +1504,64 @@ func (m *ChunkCheckpointModel) Unmarshal(dAtA []byte) error {
 			}
 			m.KvcChecksum = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
 			iNdEx += 8
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FileSize", wireType)
+			}
+			m.FileSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FileSize |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FileMtime", wireType)
+			}
+			m.FileMtime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FileMtime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Quarantined", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Quarantined = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileCheckpoints(dAtA[iNdEx:])
@@ -1451,40 +1693,40 @@ func init() {
 }
 
 var fileDescriptor_file_checkpoints_168275cfec5db5bf = []byte{
-	// 550 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0xcf, 0x6e, 0xd3, 0x3e,
-	0x1c, 0x9f, 0x9b, 0x2d, 0x6d, 0xbf, 0xe9, 0xef, 0xa7, 0xca, 0x5a, 0x87, 0x55, 0xb4, 0x2a, 0x54,
-	0x1c, 0x22, 0x21, 0x52, 0x18, 0x17, 0xb4, 0x63, 0xcb, 0x0e, 0x13, 0x9a, 0x40, 0x16, 0x5c, 0xb8,
-	0x44, 0xf9, 0xe3, 0x26, 0x51, 0xd2, 0xb8, 0x8a, 0x93, 0x6c, 0x7d, 0x0b, 0x24, 0x1e, 0x84, 0x27,
-	0xe0, 0xbe, 0x23, 0x0f, 0xc0, 0x01, 0xca, 0x8b, 0x20, 0x3b, 0x99, 0x9a, 0x4d, 0x15, 0xe2, 0xf6,
-	0xfd, 0xfc, 0xf1, 0xc7, 0xce, 0xc7, 0x31, 0x58, 0x69, 0x1c, 0x46, 0x45, 0x16, 0x67, 0xe1, 0x2c,
-	0x67, 0xa2, 0xe0, 0x39, 0x9b, 0x2d, 0xe3, 0x94, 0x39, 0x7e, 0xc4, 0xfc, 0x64, 0xcd, 0xe3, 0xac,
-	0x10, 0xf6, 0x3a, 0xe7, 0x05, 0x1f, 0x3f, 0x0f, 0xe3, 0x22, 0x2a, 0x3d, 0xdb, 0xe7, 0xab, 0x59,
-	0xc8, 0x43, 0x3e, 0x53, 0xb4, 0x57, 0x2e, 0x15, 0x52, 0x40, 0x4d, 0xb5, 0x7d, 0xfa, 0x15, 0xc1,
-	0x70, 0xb1, 0x0b, 0xb9, 0xe2, 0x01, 0x4b, 0xf1, 0x1b, 0x30, 0x5a, 0xc1, 0x04, 0x99, 0x9a, 0x65,
-	0x9c, 0x4d, 0xed, 0x87, 0xbe, 0x36, 0x71, 0x91, 0x15, 0xf9, 0x86, 0xb6, 0x97, 0x8d, 0x3f, 0xde,
-	0x4b, 0x56, 0x06, 0x3c, 0x04, 0x2d, 0x61, 0x1b, 0x82, 0x4c, 0x64, 0xf5, 0xa9, 0x1c, 0xf1, 0x33,
-	0x38, 0xaa, 0xdc, 0xb4, 0x64, 0xa4, 0x63, 0x22, 0xcb, 0x38, 0x1b, 0xd9, 0x1f, 0x5c, 0x2f, 0x65,
-	0xbb, 0x85, 0x6a, 0x27, 0x5a, 0x7b, 0xce, 0x3b, 0xaf, 0xd1, 0xf4, 0x0b, 0x82, 0xe3, 0x7d, 0x1e,
-	0x8c, 0xe1, 0x30, 0x72, 0x45, 0xa4, 0xc2, 0x07, 0x54, 0xcd, 0xf8, 0x04, 0x74, 0x51, 0xb8, 0x45,
-	0x29, 0x88, 0x66, 0x22, 0xeb, 0x3f, 0xda, 0x20, 0x7c, 0x0a, 0xe0, 0xa6, 0x29, 0xf7, 0x1d, 0xcf,
-	0x15, 0x8c, 0x1c, 0x9a, 0xc8, 0xd2, 0x68, 0x5f, 0x31, 0x73, 0x57, 0x30, 0xfc, 0x02, 0xba, 0x2c,
-	0x0b, 0xe3, 0x8c, 0x09, 0xa2, 0xab, 0x8f, 0x3f, 0xb1, 0x2f, 0x14, 0x7e, 0x78, 0xae, 0x3b, 0xdb,
-	0xf4, 0x1b, 0x82, 0xd1, 0x5e, 0x4b, 0xeb, 0x08, 0xe8, 0xde, 0x11, 0xce, 0x41, 0xf7, 0xa3, 0x32,
-	0x4b, 0x04, 0xe9, 0x34, 0xfd, 0xee, 0x5d, 0x6f, 0x2f, 0x94, 0xa9, 0xee, 0xb7, 0x59, 0x31, 0x7e,
-	0x0f, 0x46, 0x8b, 0xfe, 0x97, 0x56, 0x95, 0xfd, 0x2f, 0xad, 0xfe, 0xe8, 0xc0, 0xf1, 0x3e, 0x8f,
-	0x6c, 0x75, 0xed, 0x16, 0x51, 0x13, 0xae, 0x66, 0xf9, 0x49, 0x7c, 0xb9, 0x14, 0xac, 0x50, 0xf1,
-	0x1a, 0x6d, 0x10, 0x26, 0xd0, 0xf5, 0x79, 0x5a, 0xae, 0xb2, 0xba, 0xee, 0x01, 0xbd, 0x83, 0xf8,
-	0x25, 0x8c, 0x44, 0xc4, 0xcb, 0x34, 0x70, 0xe2, 0xcc, 0x4f, 0xcb, 0x80, 0x39, 0x39, 0xbf, 0x76,
-	0xe2, 0x40, 0x55, 0xdf, 0xa3, 0xb8, 0x16, 0x2f, 0x6b, 0x8d, 0xf2, 0xeb, 0xcb, 0x40, 0x5e, 0x11,
-	0xcb, 0x02, 0xa7, 0xd9, 0xe8, 0xa8, 0xbe, 0x22, 0x96, 0x05, 0xef, 0xea, 0xbd, 0x86, 0xa0, 0xad,
-	0xb9, 0xbc, 0x1e, 0xc9, 0xcb, 0x11, 0x3f, 0x85, 0xff, 0xd7, 0x39, 0xab, 0x64, 0x72, 0x1c, 0x38,
-	0x2b, 0xf7, 0x86, 0x74, 0x95, 0x38, 0x90, 0x2c, 0x95, 0xe4, 0x95, 0x7b, 0x83, 0x1f, 0x43, 0x7f,
-	0x67, 0xe8, 0x29, 0x43, 0x2f, 0x6f, 0x89, 0x49, 0xe5, 0x3b, 0xde, 0xa6, 0x60, 0x82, 0xf4, 0x4d,
-	0x64, 0x1d, 0xd2, 0x5e, 0x52, 0xf9, 0x73, 0x89, 0xf1, 0x23, 0xe8, 0x4a, 0x31, 0xa9, 0x04, 0x01,
-	0x25, 0xe9, 0x49, 0xe5, 0xbf, 0xad, 0x04, 0x7e, 0x02, 0x03, 0x29, 0xa8, 0x7f, 0x5f, 0x94, 0x2b,
-	0x62, 0x98, 0xc8, 0xd2, 0xa9, 0x91, 0x54, 0xfe, 0xa2, 0xa1, 0xe6, 0xa7, 0xb7, 0xbf, 0x26, 0x07,
-	0xb7, 0xdb, 0x09, 0xfa, 0xbe, 0x9d, 0xa0, 0x9f, 0xdb, 0x09, 0xfa, 0xfc, 0x7b, 0x72, 0xf0, 0xa9,
-	0xdb, 0xbc, 0x65, 0x4f, 0x57, 0x8f, 0xf1, 0xd5, 0x9f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x5b, 0x0d,
-	0x43, 0xc6, 0xe7, 0x03, 0x00, 0x00,
+	// 552 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8d, 0x53, 0x4d, 0x6b, 0xdb, 0x40,
+	0x10, 0x8d, 0x2c, 0x47, 0xb6, 0x47, 0x6a, 0x09, 0x4b, 0x9c, 0x0a, 0x97, 0x98, 0xd4, 0xf4, 0x60,
+	0x08, 0xb5, 0x9b, 0xf4, 0x52, 0x72, 0x74, 0x9a, 0x83, 0x29, 0xa1, 0x65, 0x69, 0x2f, 0xbd, 0x08,
+	0x59, 0x5a, 0x4b, 0x42, 0xb2, 0x56, 0x68, 0x57, 0x4a, 0xfc, 0x2f, 0x02, 0xfd, 0x3d, 0xbd, 0xfb,
+	0xd8, 0x1f, 0xd0, 0x43, 0x3f, 0xfe, 0x48, 0x77, 0x57, 0x0a, 0x56, 0x82, 0x29, 0x3d, 0x2c, 0xcc,
+	0xbc, 0xf7, 0x66, 0x66, 0xf7, 0xed, 0x2e, 0x8c, 0x93, 0x28, 0x08, 0x79, 0x1a, 0xa5, 0xc1, 0x34,
+	0x27, 0x8c, 0xd3, 0x9c, 0x4c, 0x97, 0x51, 0x42, 0x1c, 0x2f, 0x24, 0x5e, 0x9c, 0xd1, 0x28, 0xe5,
+	0x6c, 0x92, 0xe5, 0x94, 0xd3, 0xc1, 0xab, 0x20, 0xe2, 0x61, 0xb1, 0x98, 0x78, 0x74, 0x35, 0x0d,
+	0x68, 0x40, 0xa7, 0x0a, 0x5e, 0x14, 0x4b, 0x95, 0xa9, 0x44, 0x45, 0x95, 0x7c, 0xb4, 0xd1, 0xe0,
+	0xe0, 0x72, 0xdb, 0xe4, 0x9a, 0xfa, 0x24, 0x41, 0xef, 0xc0, 0x6c, 0x34, 0xb6, 0xb5, 0x13, 0x7d,
+	0x6c, 0x9e, 0x8f, 0x26, 0x8f, 0x75, 0x4d, 0xe0, 0x2a, 0xe5, 0xf9, 0x1a, 0x37, 0xcb, 0xd0, 0x33,
+	0xe8, 0x70, 0x97, 0xc5, 0x4e, 0xe4, 0xdb, 0xad, 0x13, 0x6d, 0x6c, 0x61, 0x43, 0xa6, 0x73, 0x7f,
+	0xf0, 0xf9, 0xc1, 0x48, 0x55, 0x89, 0x0e, 0x40, 0x8f, 0xc9, 0x5a, 0x8c, 0xd2, 0xc6, 0x3d, 0x2c,
+	0x43, 0x74, 0x0a, 0xfb, 0xa5, 0x9b, 0x14, 0x44, 0x15, 0x9b, 0xe7, 0xfd, 0xc9, 0x27, 0x77, 0x91,
+	0x90, 0x6d, 0xa1, 0xda, 0x02, 0xae, 0x34, 0x17, 0xad, 0xb7, 0xda, 0xe8, 0xab, 0x06, 0x87, 0xbb,
+	0x34, 0x08, 0x41, 0x3b, 0x74, 0x59, 0xa8, 0x9a, 0x5b, 0x58, 0xc5, 0xe8, 0x08, 0x0c, 0xc6, 0x5d,
+	0x5e, 0x30, 0x5b, 0x17, 0xe8, 0x13, 0x5c, 0x67, 0xe8, 0x18, 0xc0, 0x4d, 0x12, 0xea, 0x39, 0x0b,
+	0x97, 0x11, 0xbb, 0x2d, 0x38, 0x1d, 0xf7, 0x14, 0x32, 0x13, 0x00, 0x7a, 0x0d, 0x1d, 0x92, 0x06,
+	0x51, 0x4a, 0x98, 0x6d, 0x28, 0x57, 0x8e, 0x26, 0x57, 0x2a, 0x7f, 0xbc, 0xaf, 0x7b, 0xd9, 0xe8,
+	0x9b, 0x06, 0xfd, 0x9d, 0x92, 0xc6, 0x16, 0xb4, 0x07, 0x5b, 0xb8, 0x00, 0xc3, 0x0b, 0x8b, 0x34,
+	0x66, 0xe2, 0xe4, 0x95, 0xf1, 0x3b, 0xeb, 0x85, 0xfb, 0x52, 0x54, 0x19, 0x5f, 0x57, 0x0c, 0x3e,
+	0x82, 0xd9, 0x80, 0xff, 0xc7, 0x55, 0x25, 0xff, 0x87, 0xab, 0x3f, 0x5a, 0x70, 0xb8, 0x4b, 0x23,
+	0x5d, 0xcd, 0x5c, 0x1e, 0xd6, 0xcd, 0x55, 0x2c, 0x8f, 0x44, 0x97, 0x4b, 0x46, 0xb8, 0x6a, 0xaf,
+	0xe3, 0x3a, 0x43, 0x36, 0x74, 0x3c, 0x9a, 0x14, 0xab, 0xb4, 0xb2, 0xdb, 0xc2, 0xf7, 0x29, 0x3a,
+	0x83, 0x3e, 0x0b, 0x69, 0x91, 0xf8, 0x4e, 0x94, 0x7a, 0x49, 0xe1, 0x13, 0x27, 0xa7, 0x37, 0xf2,
+	0xc9, 0x48, 0xeb, 0xbb, 0x18, 0x55, 0xe4, 0xbc, 0xe2, 0x30, 0xbd, 0x99, 0xfb, 0xf2, 0x8a, 0x48,
+	0xea, 0x3b, 0xf5, 0xa0, 0xfd, 0xea, 0x8a, 0x04, 0xf2, 0xa1, 0x9a, 0x25, 0xce, 0x9c, 0x51, 0x79,
+	0x3d, 0x12, 0x97, 0x21, 0x7a, 0x09, 0x4f, 0xb3, 0x9c, 0x94, 0xb2, 0x73, 0xe4, 0x3b, 0x2b, 0xf7,
+	0xd6, 0xee, 0x28, 0xd2, 0x92, 0x28, 0x96, 0xe0, 0xb5, 0x7b, 0x8b, 0x9e, 0x43, 0x6f, 0x2b, 0xe8,
+	0x2a, 0x41, 0x37, 0x6f, 0x90, 0x71, 0x29, 0x1e, 0xc5, 0x9a, 0x8b, 0x9b, 0xef, 0x09, 0xb2, 0x8d,
+	0xbb, 0x02, 0x98, 0xc9, 0x5c, 0x3e, 0x74, 0x49, 0xc6, 0x25, 0xb3, 0x41, 0x51, 0x86, 0x48, 0xdf,
+	0x97, 0x0c, 0xbd, 0x00, 0x4b, 0x12, 0xea, 0x53, 0xb0, 0x62, 0x65, 0x9b, 0x82, 0x35, 0xb0, 0x29,
+	0xb0, 0xcb, 0x1a, 0x9a, 0x1d, 0x6f, 0x7e, 0x0d, 0xf7, 0x36, 0xbf, 0x87, 0xda, 0x77, 0xb1, 0x7e,
+	0x8a, 0x75, 0xf7, 0x67, 0xb8, 0xf7, 0xa5, 0x53, 0x7f, 0xf2, 0x85, 0xa1, 0x7e, 0xe9, 0x9b, 0xbf,
+	0x07, 0x72, 0xaa, 0xbb, 0x00, 0x04, 0x00, 0x00,
 }
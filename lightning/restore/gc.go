@@ -0,0 +1,123 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/glue"
+)
+
+// gcLifeTimeBackupName is the row of mysql.tidb recording the original
+// tikv_gc_life_time while it is raised, so that it can be set back by the
+// next run if Lightning crashes during the checksum.
+const gcLifeTimeBackupName = "tidb_lightning_gc_life_time_backup"
+
+// gcLifeTimeManager raises the tikv_gc_life_time while any checksum is
+// running, since ADMIN CHECKSUM TABLE of a huge table takes longer than the
+// default GC life time. The concurrent checksums share the raised value, and
+// the original value is set back after the last one finishes.
+type gcLifeTimeManager struct {
+	lock     sync.Mutex
+	g        glue.Glue
+	refCount int
+	// oriGCLifeTime is the value to set back, empty if it was not raised.
+	oriGCLifeTime string
+}
+
+func newGCLifeTimeManager(g glue.Glue) *gcLifeTimeManager {
+	return &gcLifeTimeManager{g: g}
+}
+
+// addRef raises the tikv_gc_life_time to defaultGCLifeTime, unless another
+// checksum is running or it is already long enough.
+func (m *gcLifeTimeManager) addRef(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.refCount == 0 {
+		ori, err := ObtainGCLifeTime(ctx, m.g)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if needIncreaseGCLifeTime(ori) {
+			// a backup left by a crashed run has the original value, keep it.
+			query := "INSERT IGNORE INTO mysql.tidb (VARIABLE_NAME, VARIABLE_VALUE, COMMENT) VALUES (?, ?, 'tikv_gc_life_time before the checksum of TiDB Lightning')"
+			if err := m.g.ExecuteWithLog(ctx, query, "(backup GC lifetime)", gcLifeTimeBackupName, ori); err != nil {
+				return errors.Annotatef(err, "%s", query)
+			}
+			if err := UpdateGCLifeTime(ctx, m.g, defaultGCLifeTime.String()); err != nil {
+				return errors.Trace(err)
+			}
+			m.oriGCLifeTime = ori
+		}
+	}
+	m.refCount++
+	return nil
+}
+
+// removeRef sets back the original tikv_gc_life_time after the last checksum
+// finishes. This is done even if the context is canceled.
+func (m *gcLifeTimeManager) removeRef() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.refCount--
+	if m.refCount > 0 || len(m.oriGCLifeTime) == 0 {
+		return
+	}
+	ctx := context.Background()
+	if err := restoreGCLifeTime(ctx, m.g, m.oriGCLifeTime); err != nil {
+		common.AppLogger.Errorf("cannot set tikv_gc_life_time back to %s, please set it manually: %v", m.oriGCLifeTime, errors.ErrorStack(err))
+	}
+	m.oriGCLifeTime = ""
+}
+
+func needIncreaseGCLifeTime(ori string) bool {
+	if len(ori) == 0 {
+		return true
+	}
+	duration, err := time.ParseDuration(ori)
+	return err != nil || duration < defaultGCLifeTime
+}
+
+func restoreGCLifeTime(ctx context.Context, g glue.Glue, ori string) error {
+	if err := UpdateGCLifeTime(ctx, g, ori); err != nil {
+		return errors.Trace(err)
+	}
+	query := "DELETE FROM mysql.tidb WHERE VARIABLE_NAME = ?"
+	err := g.ExecuteWithLog(ctx, query, "(remove GC lifetime backup)", gcLifeTimeBackupName)
+	return errors.Annotatef(err, "%s", query)
+}
+
+// recoverGCLifeTime sets back the tikv_gc_life_time raised by a previous run
+// which crashed during the checksum.
+func (rc *RestoreController) recoverGCLifeTime(ctx context.Context) error {
+	g := rc.tidbMgr.glue
+	query := "SELECT COALESCE(MAX(VARIABLE_VALUE), '') FROM mysql.tidb WHERE VARIABLE_NAME = '" + gcLifeTimeBackupName + "'"
+	ori, err := g.ObtainStringWithLog(ctx, query, "(obtain GC lifetime backup)")
+	if err != nil {
+		return errors.Annotatef(err, "%s", query)
+	}
+	if len(ori) == 0 {
+		return nil
+	}
+	common.AppLogger.Warnf("tikv_gc_life_time was raised by a previous run which did not finish, setting it back to %s", ori)
+	return errors.Trace(restoreGCLifeTime(ctx, g, ori))
+}
@@ -0,0 +1,30 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&gcSuite{})
+
+type gcSuite struct{}
+
+func (s *gcSuite) TestNeedIncreaseGCLifeTime(c *C) {
+	c.Assert(needIncreaseGCLifeTime(""), IsTrue)
+	c.Assert(needIncreaseGCLifeTime("10m0s"), IsTrue)
+	c.Assert(needIncreaseGCLifeTime("not a duration"), IsTrue)
+	c.Assert(needIncreaseGCLifeTime("100h0m0s"), IsFalse)
+	c.Assert(needIncreaseGCLifeTime("720h"), IsFalse)
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/glue"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+const (
+	historyTableNameTask  = "task_history"
+	historyTableNameTable = "table_history"
+
+	historyStatusRunning   = "running"
+	historyStatusSucceeded = "succeeded"
+	historyStatusFailed    = "failed"
+
+	// historyWriteTimeout limits recording the outcomes, which must not be
+	// cut short by the cancellation of a failed import.
+	historyWriteTimeout = 30 * time.Second
+)
+
+// historyRecorder records the import history into the target TiDB. Failing to
+// record the history never fails the import itself. A nil recorder records
+// nothing.
+type historyRecorder struct {
+	glue   glue.Glue
+	schema string
+	host   string
+}
+
+func newHistoryRecorder(ctx context.Context, g glue.Glue, schemaName string) (*historyRecorder, error) {
+	var escapedSchemaName strings.Builder
+	common.WriteMySQLIdentifier(&escapedSchemaName, schemaName)
+	schema := escapedSchemaName.String()
+
+	err := g.ExecuteWithLog(ctx, fmt.Sprintf(`
+		CREATE DATABASE IF NOT EXISTS %s;
+	`, schema), "(create history database)")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = g.ExecuteWithLog(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s.%s (
+			task_id varchar(36) NOT NULL PRIMARY KEY,
+			host varchar(255) NOT NULL,
+			source_dir varchar(2048) NOT NULL,
+			status varchar(16) NOT NULL,
+			error text NULL,
+			start_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			end_time timestamp NULL
+		);
+	`, schema, historyTableNameTask), "(create task history table)")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = g.ExecuteWithLog(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s.%s (
+			task_id varchar(36) NOT NULL,
+			table_name varchar(261) NOT NULL,
+			status varchar(16) NOT NULL,
+			kvc_bytes bigint unsigned NOT NULL DEFAULT 0,
+			kvc_kvs bigint unsigned NOT NULL DEFAULT 0,
+			kvc_checksum bigint unsigned NOT NULL DEFAULT 0,
+			error text NULL,
+			end_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY(task_id, table_name)
+		);
+	`, schema, historyTableNameTable), "(create table history table)")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	host, _ := os.Hostname()
+	return &historyRecorder{glue: g, schema: schema, host: host}, nil
+}
+
+func historyStatus(err error) (string, sql.NullString) {
+	if err != nil {
+		return historyStatusFailed, sql.NullString{String: err.Error(), Valid: true}
+	}
+	return historyStatusSucceeded, sql.NullString{}
+}
+
+func (h *historyRecorder) startTask(ctx context.Context, taskID string, sourceDir string) {
+	if h == nil {
+		return
+	}
+	err := h.glue.ExecuteWithLog(ctx, fmt.Sprintf(`
+		INSERT INTO %s.%s (task_id, host, source_dir, status) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE host = VALUES(host), status = VALUES(status), error = NULL, end_time = NULL;
+	`, h.schema, historyTableNameTask), "(record task start)", taskID, h.host, sourceDir, historyStatusRunning)
+	if err != nil {
+		common.AppLogger.Warnf("failed to record the start of task %s into history: %v", taskID, err)
+	}
+}
+
+func (h *historyRecorder) finishTable(taskID string, tableName string, checksum *verify.KVChecksum, restoreErr error) {
+	if h == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), historyWriteTimeout)
+	defer cancel()
+	status, errMsg := historyStatus(restoreErr)
+	err := h.glue.ExecuteWithLog(ctx, fmt.Sprintf(`
+		REPLACE INTO %s.%s (task_id, table_name, status, kvc_bytes, kvc_kvs, kvc_checksum, error) VALUES (?, ?, ?, ?, ?, ?, ?);
+	`, h.schema, historyTableNameTable), "(record table outcome)",
+		taskID, tableName, status, checksum.SumSize(), checksum.SumKVS(), checksum.Sum(), errMsg,
+	)
+	if err != nil {
+		common.AppLogger.Warnf("[%s] failed to record the outcome into history: %v", tableName, err)
+	}
+}
+
+func (h *historyRecorder) finishTask(taskID string, restoreErr error) {
+	if h == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), historyWriteTimeout)
+	defer cancel()
+	status, errMsg := historyStatus(restoreErr)
+	err := h.glue.ExecuteWithLog(ctx, fmt.Sprintf(`
+		UPDATE %s.%s SET status = ?, error = ?, end_time = CURRENT_TIMESTAMP WHERE task_id = ?;
+	`, h.schema, historyTableNameTask), "(record task end)", status, errMsg, taskID)
+	if err != nil {
+		common.AppLogger.Warnf("failed to record the end of task %s into history: %v", taskID, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+const (
+	eventTaskStarted    = "task-started"
+	eventTableFinished  = "table-finished"
+	eventChecksumFailed = "checksum-failed"
+	eventTaskCompleted  = "task-completed"
+
+	maxPendingEvents = 1024
+)
+
+// notifyEvent is the JSON object sent to the webhook.
+type notifyEvent struct {
+	Event  string    `json:"event"`
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	TaskID string    `json:"task_id,omitempty"`
+	Table  string    `json:"table,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// webhookNotifier POSTs the events to the webhook in the background, in the
+// order they are emitted. A nil notifier discards all events.
+type webhookNotifier struct {
+	url    string
+	host   string
+	client *http.Client
+	events chan *notifyEvent
+	wg     sync.WaitGroup
+}
+
+func newWebhookNotifier(cfg *config.Notify) *webhookNotifier {
+	if len(cfg.WebhookURL) == 0 {
+		return nil
+	}
+	host, _ := os.Hostname()
+	n := &webhookNotifier{
+		url:    cfg.WebhookURL,
+		host:   host,
+		client: common.NewHTTPClient(cfg.Timeout.Duration),
+		events: make(chan *notifyEvent, maxPendingEvents),
+	}
+	n.wg.Add(1)
+	go n.run()
+	return n
+}
+
+func (n *webhookNotifier) run() {
+	defer n.wg.Done()
+	for event := range n.events {
+		if err := n.post(event); err != nil {
+			common.AppLogger.Warnf("failed to send %s event to webhook: %v", event.Event, err)
+		}
+	}
+}
+
+func (n *webhookNotifier) post(event *notifyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// notify queues an event without blocking. The event is dropped if the
+// webhook cannot keep up.
+func (n *webhookNotifier) notify(event, taskID, table string, err error) {
+	if n == nil {
+		return
+	}
+	e := &notifyEvent{
+		Event:  event,
+		Time:   time.Now(),
+		Host:   n.host,
+		TaskID: taskID,
+		Table:  table,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	select {
+	case n.events <- e:
+	default:
+		common.AppLogger.Warnf("too many pending webhook events, dropped %s event", event)
+	}
+}
+
+// close waits until all queued events are sent.
+func (n *webhookNotifier) close() {
+	if n == nil {
+		return
+	}
+	close(n.events)
+	n.wg.Wait()
+}
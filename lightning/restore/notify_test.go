@@ -0,0 +1,64 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+var _ = Suite(&notifySuite{})
+
+type notifySuite struct{}
+
+func (s *notifySuite) TestWebhookNotifier(c *C) {
+	var received []notifyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Assert(req.Method, Equals, http.MethodPost)
+		var event notifyEvent
+		c.Assert(json.NewDecoder(req.Body).Decode(&event), IsNil)
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	notifier := newWebhookNotifier(&config.Notify{
+		WebhookURL: server.URL,
+		Timeout:    config.Duration{Duration: time.Second},
+	})
+	notifier.notify(eventTaskStarted, "", "", nil)
+	notifier.notify(eventTableFinished, "task", "`db`.`t`", errors.New("some error"))
+	notifier.close()
+
+	c.Assert(received, HasLen, 2)
+	c.Assert(received[0].Event, Equals, eventTaskStarted)
+	c.Assert(received[1].Event, Equals, eventTableFinished)
+	c.Assert(received[1].TaskID, Equals, "task")
+	c.Assert(received[1].Table, Equals, "`db`.`t`")
+	c.Assert(received[1].Error, Equals, "some error")
+}
+
+func (s *notifySuite) TestDisabledNotifier(c *C) {
+	notifier := newWebhookNotifier(&config.Notify{})
+	c.Assert(notifier, IsNil)
+	// must not panic.
+	notifier.notify(eventTaskStarted, "", "", nil)
+	notifier.close()
+}
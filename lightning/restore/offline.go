@@ -0,0 +1,421 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cznic/mathutil"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	kvenc "github.com/pingcap/tidb/util/kvencoder"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/kv"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+const (
+	offlineManifestName    = "manifest.json"
+	offlineManifestVersion = 1
+)
+
+// offlineManifest describes the KV files written in the offline mode. Each KV
+// file contains the pairs of a chunk sorted by key, every key and value
+// prefixed by its length as an unsigned varint.
+type offlineManifest struct {
+	Version int             `json:"version"`
+	Tables  []*offlineTable `json:"tables"`
+}
+
+type offlineTable struct {
+	Database    string `json:"database"`
+	Table       string `json:"table"`
+	TableID     int64  `json:"table-id"`
+	CreateTable string `json:"create-table"`
+	// AutoIncrement is the value AUTO_INCREMENT should be set to after the
+	// files are ingested.
+	AutoIncrement int64          `json:"auto-increment"`
+	KVs           uint64         `json:"kvs"`
+	Bytes         uint64         `json:"bytes"`
+	Checksum      uint64         `json:"checksum"`
+	Files         []*offlineFile `json:"files"`
+}
+
+type offlineFile struct {
+	Name     string `json:"name"`
+	KVs      uint64 `json:"kvs"`
+	Bytes    uint64 `json:"bytes"`
+	Checksum uint64 `json:"checksum"`
+}
+
+type offlineConverter struct {
+	cfg           *config.Config
+	sqlMode       mysql.SQLMode
+	regionWorkers *worker.Pool
+	ioWorkers     *worker.Pool
+}
+
+// RunOffline encodes the data source into sorted KV files in the offline
+// output directory, without connecting to TiDB, PD or tikv-importer. The table
+// schemas are taken from the schema files, and the table IDs are assigned
+// from offline.table-id-base.
+func RunOffline(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta, cfg *config.Config) error {
+	timer := time.Now()
+	if cfg.Mydumper.NoSchema {
+		return common.ErrInvalidConfig.Errorf("the offline mode reads the table schemas from the schema files, mydumper.no-schema must be false")
+	}
+	sqlMode, err := mysql.GetSQLMode(mysql.FormatSQLModeStr(cfg.TiDB.SQLMode))
+	if err != nil {
+		return common.ErrInvalidConfig.Annotatef(err, "invalid sql-mode %q", cfg.TiDB.SQLMode)
+	}
+	downgrader, err := newSchemaDowngrader(&cfg.Mydumper.DDLDowngrade)
+	if err != nil {
+		return common.ErrInvalidConfig.Wrap(err)
+	}
+	localSchemas, err := parseTableSchemas(dbMetas, downgrader, false)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.MkdirAll(cfg.Offline.OutputDir, 0755); err != nil {
+		return errors.Annotatef(err, "cannot create offline.output-dir %s", cfg.Offline.OutputDir)
+	}
+
+	oc := &offlineConverter{
+		cfg:           cfg,
+		sqlMode:       sqlMode,
+		regionWorkers: worker.NewPool(ctx, cfg.App.RegionConcurrency, "region"),
+		ioWorkers:     worker.NewPool(ctx, cfg.App.IOConcurrency, "io"),
+	}
+	manifest := offlineManifest{Version: offlineManifestVersion}
+	p := parser.New()
+	tableID := cfg.Offline.TableIDBase
+	for _, dbMeta := range dbMetas {
+		for _, tableMeta := range dbMeta.Tables {
+			schema := localSchemas[dbMeta.Name][tableMeta.Name]
+			if schema.skip {
+				continue
+			}
+			table, err := oc.convertTable(ctx, p, tableMeta, schema, tableID)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			manifest.Tables = append(manifest.Tables, table)
+			tableID++
+		}
+	}
+
+	content, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	manifestPath := filepath.Join(cfg.Offline.OutputDir, offlineManifestName)
+	if err := ioutil.WriteFile(manifestPath, content, 0644); err != nil {
+		return errors.Annotatef(err, "cannot write %s", manifestPath)
+	}
+	newUnsupportedTypesReport(localSchemas).emitLog()
+	common.ProgressLogger.Infof("the whole procedure takes %v, %d tables written into %s", time.Since(timer), len(manifest.Tables), cfg.Offline.OutputDir)
+	return nil
+}
+
+func (oc *offlineConverter) convertTable(
+	ctx context.Context,
+	p *parser.Parser,
+	tableMeta *mydump.MDTableMeta,
+	schema *localTableSchema,
+	tableID int64,
+) (*offlineTable, error) {
+	tableName := common.UniqueTable(tableMeta.DB, tableMeta.Name)
+	common.ProgressLogger.Infof("[%s] convert table start", tableName)
+	timer := time.Now()
+
+	core, err := offlineTableInfo(p, schema.createTableStmt, tableID)
+	if err != nil {
+		return nil, errors.Annotatef(err, "[%s] cannot build the table info from %s", tableName, tableMeta.SchemaFile)
+	}
+	tableInfo := &TidbTableInfo{
+		ID:              tableID,
+		Name:            tableMeta.Name,
+		Columns:         len(core.Columns),
+		Indices:         len(core.Indices),
+		CreateTableStmt: schema.createTableStmt,
+		core:            core,
+		nullColumns:     schema.nullColumns(),
+	}
+	dbInfo := &TidbDBInfo{
+		Name:   tableMeta.DB,
+		Tables: map[string]*TidbTableInfo{tableMeta.Name: tableInfo},
+	}
+	cp := &TableCheckpoint{Status: CheckpointStatusLoaded}
+	t, err := NewTableRestore(tableName, tableMeta, dbInfo, tableInfo, cp, oc.cfg.TiDB.SQLMode)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer t.Close()
+
+	if err := t.populateChunks(oc.cfg, cp, 0); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			cp.AllocBase = mathutil.MaxInt64(cp.AllocBase, chunk.Chunk.RowIDMax)
+		}
+	}
+	t.alloc.Rebase(tableID, cp.AllocBase, false)
+
+	var (
+		wg       sync.WaitGroup
+		chunkErr common.OnceError
+		filesMu  sync.Mutex
+		files    = make(map[string]*offlineFile)
+	)
+	for engineID, engine := range cp.Engines {
+		for chunkIndex, chunk := range engine.Chunks {
+			if chunkErr.Get() != nil {
+				break
+			}
+			name := fmt.Sprintf("%s.%s.%d.%d.kv", tableMeta.DB, tableMeta.Name, engineID, chunkIndex)
+			path := resolveDataFilePath(oc.cfg.Mydumper.SourceDir, chunk.Key.Path)
+			cr, err := newChunkRestore(chunkIndex, chunk, path, oc.cfg.Mydumper.ReadBlockSize, oc.cfg.Mydumper.ReadAhead, oc.sqlMode, t.assignableColumns(),
+				oc.cfg.Mydumper.FindFixedWidthRule(tableMeta.DB, tableMeta.Name),
+				oc.cfg.Mydumper.FindProjectionRule(tableMeta.DB, tableMeta.Name),
+				oc.cfg.Mydumper.FindFilterRule(tableMeta.DB, tableMeta.Name),
+				t.transformRules(&oc.cfg.Mydumper),
+				tableMeta.DataFileDigests[path], oc.ioWorkers)
+			if err != nil {
+				chunkErr.Set(tableName, err)
+				break
+			}
+
+			w, err := oc.regionWorkers.ApplyFair(ctx, tableName)
+			if err != nil {
+				cr.close()
+				chunkErr.Set(tableName, err)
+				break
+			}
+			wg.Add(1)
+			go func(w *worker.Worker, cr *chunkRestore, name string) {
+				defer func() {
+					cr.close()
+					wg.Done()
+					oc.regionWorkers.Recycle(w)
+				}()
+				checksum, err := oc.convertChunk(ctx, t, cr, filepath.Join(oc.cfg.Offline.OutputDir, name))
+				if err != nil {
+					chunkErr.Set(fmt.Sprintf("%s] [%s", tableName, &cr.chunk.Key), err)
+					return
+				}
+				filesMu.Lock()
+				files[name] = &offlineFile{Name: name, KVs: checksum.SumKVS(), Bytes: checksum.SumSize(), Checksum: checksum.Sum()}
+				filesMu.Unlock()
+			}(w, cr, name)
+		}
+	}
+	wg.Wait()
+	if err := chunkErr.Get(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	table := &offlineTable{
+		Database:      tableMeta.DB,
+		Table:         tableMeta.Name,
+		TableID:       tableID,
+		CreateTable:   schema.createTableStmt,
+		AutoIncrement: t.alloc.Base() + 1,
+	}
+	var total verify.KVChecksum
+	for _, file := range files {
+		table.Files = append(table.Files, file)
+		sum := verify.MakeKVChecksum(file.Bytes, file.KVs, file.Checksum)
+		total.Add(&sum)
+	}
+	sort.Slice(table.Files, func(i, j int) bool { return table.Files[i].Name < table.Files[j].Name })
+	table.KVs, table.Bytes, table.Checksum = total.SumKVS(), total.SumSize(), total.Sum()
+
+	common.ProgressLogger.Infof("[%s] convert table completed, takes %v, %d KV pairs (%d bytes) in %d files",
+		tableName, time.Since(timer), table.KVs, table.Bytes, len(table.Files))
+	return table, nil
+}
+
+// convertChunk encodes the whole chunk and writes the KV pairs into the file
+// sorted by key.
+func (oc *offlineConverter) convertChunk(ctx context.Context, t *TableRestore, cr *chunkRestore, path string) (*verify.KVChecksum, error) {
+	kvEncoder, err := kv.NewTableKVEncoder(t.dbInfo.Name, t.tableInfo.Name, t.tableInfo.ID, oc.cfg.TiDB.SQLMode, t.alloc)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the KV pairs retain the memory of the encoder, so it must only be closed
+	// after they are written.
+	defer kvEncoder.Close()
+
+	var kvs []kvenc.KvPair
+	var buffer bytes.Buffer
+	for cr.parser.Pos() < cr.chunk.Chunk.EndOffset {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		endOffset := mathutil.MinInt64(cr.chunk.Chunk.EndOffset, cr.parser.Pos()+oc.cfg.Mydumper.ReadBlockSize)
+		blockStartOffset := cr.parser.Pos()
+		buffer.Reset()
+	readLoop:
+		for cr.parser.Pos() < endOffset {
+			err := cr.parser.ReadRow()
+			switch errors.Cause(err) {
+			case nil:
+				cr.appendLastRow(t, &buffer, oc.cfg.Mydumper.OnDuplicate)
+			case io.EOF:
+				cr.chunk.Chunk.EndOffset = cr.parser.Pos()
+				break readLoop
+			default:
+				return nil, common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", cr.chunk.Key.Path, cr.parser.Pos())
+			}
+		}
+		if buffer.Len() == 0 {
+			continue
+		}
+		buffer.WriteByte(';')
+
+		blockKVs, _, err := kvEncoder.SQL2KV(buffer.String())
+		if err == nil {
+			err = checkKVSize(blockKVs, oc.cfg.TikvImporter.MaxKVSize)
+		}
+		if err != nil {
+			if common.RedactInfoLog {
+				return nil, common.ErrEncodeKV.Errorf("failed to encode %s [%d, %d): %s", cr.chunk.Key.Path, blockStartOffset, cr.parser.Pos(), common.RedactValues(err.Error()))
+			}
+			return nil, common.ErrEncodeKV.Annotatef(err, "failed to encode %s [%d, %d)", cr.chunk.Key.Path, blockStartOffset, cr.parser.Pos())
+		}
+		kvs = append(kvs, blockKVs...)
+	}
+
+	if cr.digest != nil {
+		if err := cr.digest.Verify(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i].Key, kvs[j].Key) < 0 })
+	checksum := verify.NewKVChecksum(0)
+	checksum.Update(kvs)
+	if err := writeKVFile(path, kvs); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return checksum, nil
+}
+
+// writeKVFile writes the KV pairs into a new file, prefixing every key and
+// value with its length. The file only appears at the path when it is
+// completely written.
+func writeKVFile(path string, kvs []kvenc.KvPair) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w := bufio.NewWriter(file)
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, pair := range kvs {
+		n := binary.PutUvarint(lenBuf[:], uint64(len(pair.Key)))
+		w.Write(lenBuf[:n])
+		w.Write(pair.Key)
+		n = binary.PutUvarint(lenBuf[:], uint64(len(pair.Val)))
+		w.Write(lenBuf[:n])
+		w.Write(pair.Val)
+	}
+	// the bufio.Writer keeps the first error, which is reported by Flush.
+	err = w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return errors.Annotatef(err, "cannot write %s", path)
+	}
+	return errors.Trace(os.Rename(tmpPath, path))
+}
+
+// offlineTableInfo builds the parts of the table info the encoding depends on
+// from the CREATE TABLE statement, since there is no target to fetch it from.
+func offlineTableInfo(p *parser.Parser, createTable string, tableID int64) (*model.TableInfo, error) {
+	stmt, err := p.ParseOneStmt(createTable, "", "")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	createStmt, ok := stmt.(*ast.CreateTableStmt)
+	if !ok || createStmt.ReferTable != nil {
+		return nil, errors.New("only CREATE TABLE statements with the column definitions are supported in the offline mode")
+	}
+
+	tbl := &model.TableInfo{ID: tableID, Name: createStmt.Table.Name}
+	var primaryKeys []string
+	for i, col := range createStmt.Cols {
+		column := &model.ColumnInfo{ID: int64(i + 1), Offset: i, Name: col.Name.Name, FieldType: *col.Tp}
+		for _, opt := range col.Options {
+			switch opt.Tp {
+			case ast.ColumnOptionPrimaryKey:
+				primaryKeys = append(primaryKeys, col.Name.Name.L)
+			case ast.ColumnOptionGenerated:
+				// only whether the column is generated matters here, the
+				// expression is evaluated by the encoder.
+				column.GeneratedExprString = "?"
+				column.GeneratedStored = opt.Stored
+			}
+		}
+		tbl.Columns = append(tbl.Columns, column)
+	}
+	for _, constraint := range createStmt.Constraints {
+		if constraint.Tp == ast.ConstraintPrimaryKey {
+			for _, key := range constraint.Keys {
+				primaryKeys = append(primaryKeys, key.Column.Name.L)
+			}
+		}
+	}
+
+	if len(primaryKeys) == 1 {
+		for _, column := range tbl.Columns {
+			if column.Name.L != primaryKeys[0] {
+				continue
+			}
+			switch column.Tp {
+			case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong:
+				tbl.PKIsHandle = true
+			}
+		}
+	}
+	return tbl, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	kvenc "github.com/pingcap/tidb/util/kvencoder"
+)
+
+var _ = Suite(&offlineSuite{})
+
+type offlineSuite struct{}
+
+func (s *offlineSuite) TestOfflineTableInfo(c *C) {
+	p := parser.New()
+
+	tbl, err := offlineTableInfo(p, "CREATE TABLE t (id BIGINT PRIMARY KEY, a INT, b INT AS (a + 1))", 42)
+	c.Assert(err, IsNil)
+	c.Assert(tbl.ID, Equals, int64(42))
+	c.Assert(tbl.PKIsHandle, IsTrue)
+	c.Assert(tbl.Columns, HasLen, 3)
+	c.Assert(tbl.Columns[1].IsGenerated(), IsFalse)
+	c.Assert(tbl.Columns[2].IsGenerated(), IsTrue)
+
+	tbl, err = offlineTableInfo(p, "CREATE TABLE t (id VARCHAR(10), a INT, PRIMARY KEY (id))", 1)
+	c.Assert(err, IsNil)
+	c.Assert(tbl.PKIsHandle, IsFalse)
+
+	tbl, err = offlineTableInfo(p, "CREATE TABLE t (id INT, a INT, PRIMARY KEY (id, a))", 1)
+	c.Assert(err, IsNil)
+	c.Assert(tbl.PKIsHandle, IsFalse)
+
+	_, err = offlineTableInfo(p, "CREATE TABLE t LIKE u", 1)
+	c.Assert(err, ErrorMatches, "only CREATE TABLE statements with the column definitions .*")
+}
+
+func (s *offlineSuite) TestWriteKVFile(c *C) {
+	dir, err := ioutil.TempDir("", "lightning-offline")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	kvs := []kvenc.KvPair{
+		{Key: []byte("a"), Val: []byte("1")},
+		{Key: []byte("bc"), Val: []byte{}},
+		{Key: []byte("d"), Val: make([]byte, 300)},
+	}
+	path := filepath.Join(dir, "db.t.0.0.kv")
+	c.Assert(writeKVFile(path, kvs), IsNil)
+	_, err = os.Stat(path + ".tmp")
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	file, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer file.Close()
+	r := bufio.NewReader(file)
+	readBytes := func() []byte {
+		n, err := binary.ReadUvarint(r)
+		c.Assert(err, IsNil)
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		c.Assert(err, IsNil)
+		return b
+	}
+	for _, pair := range kvs {
+		c.Assert(readBytes(), DeepEquals, pair.Key)
+		c.Assert(readBytes(), DeepEquals, pair.Val)
+	}
+	_, err = r.ReadByte()
+	c.Assert(err, Equals, io.EOF)
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/glue"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+const (
+	// privilegeStageSchema is the schema the privilege tables of the dump
+	// are loaded into before being merged into the mysql schema.
+	privilegeStageSchema = "lightning_privilege_stage"
+	// maxPrivilegeInsertSize is the maximum size of an INSERT statement
+	// loading the privilege tables into the stage.
+	maxPrivilegeInsertSize = 1 << 20
+)
+
+// privilegeAccount is an account appearing in the privilege tables.
+type privilegeAccount struct {
+	user   string
+	host   string
+	exists bool
+}
+
+func (a *privilegeAccount) String() string {
+	return fmt.Sprintf("'%s'@'%s'", a.user, a.host)
+}
+
+// SetPrivilegeTables sets the privilege tables in the mysql schema of the
+// dump, which are restored according to post-restore.privileges.
+func (rc *RestoreController) SetPrivilegeTables(tables []*mydump.MDTableMeta) {
+	rc.privilegeTables = tables
+}
+
+// restorePrivileges merges the accounts and their privileges in the dump into
+// the target. The privilege tables of the dump are first loaded into a stage
+// schema, so the accounts can be compared against the existing ones with SQL,
+// and then copied into the mysql schema.
+func (rc *RestoreController) restorePrivileges(ctx context.Context) error {
+	mode := rc.cfg.PostRestore.Privileges
+	if len(rc.privilegeTables) == 0 {
+		return nil
+	}
+	if mode == config.PrivilegesSkip {
+		common.AppLogger.Infof("[privileges] ignoring %d privilege tables in the dump, see post-restore.privileges", len(rc.privilegeTables))
+		return nil
+	}
+	// mysql.user is the last of mydump.PrivilegeTables.
+	if rc.privilegeTables[len(rc.privilegeTables)-1].Name != "user" {
+		common.AppLogger.Warnf("[privileges] ignoring the privilege tables since mysql.user is not in the dump")
+		return nil
+	}
+
+	g := rc.tidbMgr.glue
+	stage := "`" + privilegeStageSchema + "`"
+	if err := g.ExecuteWithLog(ctx, "DROP DATABASE IF EXISTS "+stage, "drop privilege stage"); err != nil {
+		return errors.Trace(err)
+	}
+	if err := g.ExecuteWithLog(ctx, "CREATE DATABASE "+stage, "create privilege stage"); err != nil {
+		return errors.Trace(err)
+	}
+	defer func() {
+		if err := g.ExecuteWithLog(ctx, "DROP DATABASE IF EXISTS "+stage, "drop privilege stage"); err != nil {
+			common.AppLogger.Warnf("[privileges] failed to drop %s: %v", stage, err)
+		}
+	}()
+
+	for _, tableMeta := range rc.privilegeTables {
+		stageTable := common.UniqueTable(privilegeStageSchema, tableMeta.Name)
+		query := fmt.Sprintf("CREATE TABLE %s LIKE %s", stageTable, common.UniqueTable("mysql", tableMeta.Name))
+		if err := g.ExecuteWithLog(ctx, query, "create privilege stage table"); err != nil {
+			return errors.Trace(err)
+		}
+		for _, path := range tableMeta.DataFiles {
+			if err := stagePrivilegeFile(ctx, g, stageTable, path, rc.cfg.Mydumper.ReadBlockSize, rc.ioWorkers); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		// the account Lightning connects as is never touched, so it cannot
+		// lock itself out.
+		query = fmt.Sprintf("DELETE FROM %s WHERE User = ?", stageTable)
+		if err := g.ExecuteWithLog(ctx, query, "exclude own account", rc.cfg.TiDB.User); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	accounts, err := stagedAccounts(ctx, g)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	replace := rc.cfg.PostRestore.PrivilegeConflict == config.PrivilegeConflictReplace
+	verb := "adding"
+	if mode == config.PrivilegesDryRun {
+		verb = "would add"
+	}
+	for _, account := range accounts {
+		switch {
+		case !account.exists:
+			common.AppLogger.Infof("[privileges] %s account %s", verb, &account)
+		case replace:
+			common.AppLogger.Infof("[privileges] %s account %s replacing the existing one", verb, &account)
+		default:
+			common.AppLogger.Infof("[privileges] keeping the existing account %s", &account)
+		}
+	}
+	if mode == config.PrivilegesDryRun {
+		common.AppLogger.Infof("[privileges] dry run, the target is not changed")
+		return nil
+	}
+
+	conflicts := common.UniqueTable("mysql", "user")
+	if replace {
+		conflicts = common.UniqueTable(privilegeStageSchema, "user")
+	}
+	for _, tableMeta := range rc.privilegeTables {
+		stageTable := common.UniqueTable(privilegeStageSchema, tableMeta.Name)
+		targetTable := common.UniqueTable("mysql", tableMeta.Name)
+		// with "keep" the rows of the existing accounts are dropped from the
+		// stage, with "replace" from the target.
+		deleteFrom := stageTable
+		if replace {
+			deleteFrom = targetTable
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE (User, Host) IN (SELECT User, Host FROM %s)", deleteFrom, conflicts)
+		if err := g.ExecuteWithLog(ctx, query, "remove conflicting privileges"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, tableMeta := range rc.privilegeTables {
+		query := fmt.Sprintf(
+			"INSERT INTO %s SELECT * FROM %s",
+			common.UniqueTable("mysql", tableMeta.Name), common.UniqueTable(privilegeStageSchema, tableMeta.Name),
+		)
+		if err := g.ExecuteWithLog(ctx, query, "merge privileges"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := g.ExecuteWithLog(ctx, "FLUSH PRIVILEGES", "flush privileges"); err != nil {
+		return errors.Trace(err)
+	}
+	common.AppLogger.Infof("[privileges] merged %d accounts", len(accounts))
+	return nil
+}
+
+// stagePrivilegeFile loads the INSERT statements of the data file into the
+// stage table.
+func stagePrivilegeFile(ctx context.Context, g glue.Glue, stageTable string, path string, blockBufSize int64, ioWorkers *worker.Pool) error {
+	file, err := mydump.OpenDataFile(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer file.Close()
+
+	parser := mydump.NewChunkParser(file, blockBufSize, ioWorkers)
+	var buffer bytes.Buffer
+	var columns []byte
+	flush := func() error {
+		if buffer.Len() == 0 {
+			return nil
+		}
+		query := fmt.Sprintf("INSERT INTO %s %s VALUES %s", stageTable, columns, buffer.Bytes())
+		buffer.Reset()
+		return errors.Trace(g.ExecuteWithLog(ctx, query, "stage privileges"))
+	}
+
+	for {
+		err := parser.ReadRow()
+		if errors.Cause(err) == io.EOF {
+			break
+		}
+		if err != nil {
+			return common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", path, parser.Pos())
+		}
+		// the rows of a single statement share the column list.
+		if !bytes.Equal(columns, parser.Columns()) || buffer.Len() > maxPrivilegeInsertSize {
+			if err := flush(); err != nil {
+				return errors.Trace(err)
+			}
+			columns = append(columns[:0], parser.Columns()...)
+		}
+		if buffer.Len() > 0 {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(parser.LastRow().Row)
+	}
+	return errors.Trace(flush())
+}
+
+// stagedAccounts returns the accounts in the staged mysql.user table, and
+// whether each of them exists in the target.
+func stagedAccounts(ctx context.Context, g glue.Glue) ([]privilegeAccount, error) {
+	query := fmt.Sprintf(`
+		SELECT s.User, s.Host, u.User IS NOT NULL
+		FROM %s s LEFT JOIN mysql.user u ON s.User = u.User AND s.Host = u.Host
+		ORDER BY s.User, s.Host
+	`, common.UniqueTable(privilegeStageSchema, "user"))
+	rows, err := g.GetDB().QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Annotate(err, "query staged accounts")
+	}
+	defer rows.Close()
+
+	var accounts []privilegeAccount
+	for rows.Next() {
+		var account privilegeAccount
+		if err := rows.Scan(&account.user, &account.host, &account.exists); err != nil {
+			return nil, errors.Trace(err)
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, errors.Trace(rows.Err())
+}
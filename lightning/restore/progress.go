@@ -0,0 +1,185 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/metric"
+)
+
+const (
+	phaseWriting        = "writing"
+	phaseImportQueued   = "waiting to import"
+	phaseImporting      = "importing"
+	phasePostProcessing = "post-processing"
+	phaseAddingIndex    = "adding indexes"
+	phaseTiFlashSync    = "syncing TiFlash"
+
+	// maxProgressTasks is the maximum number of tasks listed in the display.
+	maxProgressTasks = 10
+	// progressRefreshInterval is how often the display is redrawn.
+	progressRefreshInterval = time.Second
+)
+
+// progressSnapshot is the overall progress of the restore task.
+type progressSnapshot struct {
+	finishedChunks  float64
+	estimatedChunks float64
+	completedTables float64
+	totalTables     float64
+	// speed is the rate of reading the data source, in MiB/s.
+	speed float64
+	// remaining describes the estimated remaining time.
+	remaining string
+}
+
+func readProgress(elapsed time.Duration) *progressSnapshot {
+	p := &progressSnapshot{
+		finishedChunks:  metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished)),
+		estimatedChunks: metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated)),
+		completedTables: metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStateCompleted, metric.TableResultSuccess)),
+		totalTables:     metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess)),
+	}
+	nanoseconds := float64(elapsed.Nanoseconds())
+	// Note: a speed of 28 MiB/s roughly corresponds to 100 GiB/hour.
+	p.speed = metric.ReadHistogramSum(metric.BlockReadBytesHistogram) / (1048576e-9 * nanoseconds)
+	if p.finishedChunks >= p.estimatedChunks {
+		p.remaining = ", post-processing"
+	} else if p.finishedChunks > 0 {
+		remainNanoseconds := (p.estimatedChunks/p.finishedChunks - 1) * nanoseconds
+		p.remaining = fmt.Sprintf(", remaining %s", time.Duration(remainNanoseconds).Round(time.Second))
+	}
+	return p
+}
+
+func (p *progressSnapshot) String() string {
+	return fmt.Sprintf(
+		"%.0f/%.0f chunks (%.1f%%), %.0f/%.0f tables (%.1f%%), speed %.2f MiB/s%s",
+		p.finishedChunks, p.estimatedChunks, p.finishedChunks/p.estimatedChunks*100,
+		p.completedTables, p.totalTables, p.completedTables/p.totalTables*100,
+		p.speed,
+		p.remaining,
+	)
+}
+
+type progressTask struct {
+	tag   string
+	phase string
+	since time.Time
+}
+
+// progressDisplay renders a live view of the progress and the engines and
+// tables being processed on the terminal. It is only enabled when the logs
+// go to a rotated file and stdout is a terminal, so the display is not mixed with
+// the log lines. A nil display shows nothing.
+type progressDisplay struct {
+	out   io.Writer
+	mu    sync.Mutex
+	tasks map[string]*progressTask
+	// lines is the number of lines drawn last time, to be erased on redraw.
+	lines int
+}
+
+func newProgressDisplay(cfg *config.Lightning) *progressDisplay {
+	if !cfg.ProgressUI || !cfg.LogsToFile() || !isTerminal(os.Stdout) {
+		return nil
+	}
+	return &progressDisplay{
+		out:   os.Stdout,
+		tasks: make(map[string]*progressTask),
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// setPhase records that the engine or table identified by tag has entered
+// the phase.
+func (d *progressDisplay) setPhase(tag string, phase string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.tasks[tag] = &progressTask{tag: tag, phase: phase, since: time.Now()}
+	d.mu.Unlock()
+}
+
+// done removes the engine or table identified by tag from the display.
+func (d *progressDisplay) done(tag string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	delete(d.tasks, tag)
+	d.mu.Unlock()
+}
+
+// render redraws the display with the overall progress and the tasks in
+// flight, longest running first.
+func (d *progressDisplay) render(p *progressSnapshot, now time.Time) {
+	d.mu.Lock()
+	tasks := make([]*progressTask, 0, len(d.tasks))
+	for _, task := range d.tasks {
+		tasks = append(tasks, task)
+	}
+	d.mu.Unlock()
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].since.Equal(tasks[j].since) {
+			return tasks[i].since.Before(tasks[j].since)
+		}
+		return tasks[i].tag < tasks[j].tag
+	})
+
+	var buf bytes.Buffer
+	d.erase(&buf)
+	fmt.Fprintf(&buf, "Progress: %s\n", p)
+	lines := 1
+	for i, task := range tasks {
+		if i == maxProgressTasks {
+			fmt.Fprintf(&buf, "  ... and %d more\n", len(tasks)-i)
+			lines++
+			break
+		}
+		fmt.Fprintf(&buf, "  %-40s %-18s %v\n", task.tag, task.phase, now.Sub(task.since).Round(time.Second))
+		lines++
+	}
+	d.lines = lines
+	d.out.Write(buf.Bytes())
+}
+
+// clear erases the display.
+func (d *progressDisplay) clear() {
+	var buf bytes.Buffer
+	d.erase(&buf)
+	d.lines = 0
+	d.out.Write(buf.Bytes())
+}
+
+func (d *progressDisplay) erase(buf *bytes.Buffer) {
+	if d.lines > 0 {
+		// move the cursor up to the first line drawn, and clear to the end
+		// of the screen.
+		fmt.Fprintf(buf, "\x1b[%dA\x1b[J", d.lines)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+var _ = Suite(&progressSuite{})
+
+type progressSuite struct{}
+
+func (s *progressSuite) TestDisabledDisplay(c *C) {
+	d := newProgressDisplay(&config.Lightning{ProgressUI: true})
+	c.Assert(d, IsNil)
+
+	d.setPhase("`db`.`t`:0", phaseWriting)
+	d.done("`db`.`t`:0")
+}
+
+func (s *progressSuite) TestRender(c *C) {
+	var out bytes.Buffer
+	d := &progressDisplay{out: &out, tasks: make(map[string]*progressTask)}
+	p := &progressSnapshot{
+		finishedChunks:  30,
+		estimatedChunks: 120,
+		completedTables: 1,
+		totalTables:     4,
+		speed:           12.5,
+		remaining:       ", remaining 3m0s",
+	}
+	now := time.Now()
+
+	d.setPhase("`db`.`t1`:0", phaseWriting)
+	d.tasks["`db`.`t1`:0"].since = now.Add(-90 * time.Second)
+	d.setPhase("`db`.`t2`", phasePostProcessing)
+	d.tasks["`db`.`t2`"].since = now.Add(-5 * time.Second)
+	d.render(p, now)
+	c.Assert(out.String(), Equals, "Progress: 30/120 chunks (25.0%), 1/4 tables (25.0%), speed 12.50 MiB/s, remaining 3m0s\n"+
+		fmt.Sprintf("  %-40s %-18s 1m30s\n", "`db`.`t1`:0", "writing")+
+		fmt.Sprintf("  %-40s %-18s 5s\n", "`db`.`t2`", "post-processing"))
+
+	// the previous lines are erased on redraw.
+	out.Reset()
+	d.done("`db`.`t1`:0")
+	d.render(p, now)
+	c.Assert(out.String(), Matches, "\x1b\\[3A\x1b\\[J(?s)Progress: .*\n  `db`.`t2` .*\n")
+
+	out.Reset()
+	d.clear()
+	c.Assert(out.String(), Equals, "\x1b[2A\x1b[J")
+}
@@ -21,7 +21,9 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -31,11 +33,15 @@ import (
 	"github.com/cznic/mathutil"
 	sstpb "github.com/pingcap/kvproto/pkg/import_sstpb"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb-lightning/lightning/backend"
 	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/glue"
 	"github.com/pingcap/tidb-lightning/lightning/kv"
 	"github.com/pingcap/tidb-lightning/lightning/metric"
 	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/numa"
 	verify "github.com/pingcap/tidb-lightning/lightning/verification"
 	"github.com/pingcap/tidb-lightning/lightning/worker"
 
@@ -43,6 +49,8 @@ import (
 	tidbcfg "github.com/pingcap/tidb/config"
 	"github.com/pingcap/tidb/meta/autoid"
 	"github.com/pingcap/tidb/util/kvencoder"
+	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -54,6 +62,21 @@ const (
 	defaultGCLifeTime = 100 * time.Hour
 )
 
+const (
+	// the checkpoint updates are retried with exponential backoff when the
+	// checkpoints DB is temporarily unavailable, and dropped after
+	// `maxCheckpointUpdateRetry` consecutive failures.
+	maxCheckpointUpdateRetry   = 8
+	checkpointUpdateBackoff    = time.Second
+	maxCheckpointUpdateBackoff = 30 * time.Second
+
+	// tableRetryBackoff is the initial wait before importing a failed table
+	// again, doubled after each retry.
+	tableRetryBackoff = 10 * time.Second
+	// chunkRetryBackoff is the wait before restoring a failed chunk again.
+	chunkRetryBackoff = 3 * time.Second
+)
+
 const (
 	compactStateIdle int32 = iota
 	compactStateDoing
@@ -94,68 +117,286 @@ func (es *errorSummaries) emitLog() {
 		fmt.Fprintf(&msg, "Totally **%d** tables failed to be imported.\n", errorCount)
 		for tableName, errorSummary := range es.summary {
 			fmt.Fprintf(&msg, "- [%s] [%s] %s\n", tableName, errorSummary.status.MetricName(), errorSummary.err.Error())
+			if hint := common.ErrorHint(errorSummary.err); len(hint) > 0 {
+				fmt.Fprintf(&msg, "  hint: %s\n", strings.Replace(hint, "<table>", tableName, -1))
+			}
 		}
 		common.AppLogger.Error(msg.String())
 	}
 }
 
+// FailedTable describes a table which failed to be imported.
+type FailedTable struct {
+	Table string `json:"table"`
+	// Phase is the step the table failed at, e.g. "imported" or "checksum".
+	Phase string `json:"phase"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+}
+
+func (es *errorSummaries) failedTables() []FailedTable {
+	es.Lock()
+	defer es.Unlock()
+	tables := make([]FailedTable, 0, len(es.summary))
+	for tableName, errorSummary := range es.summary {
+		table := FailedTable{
+			Table: tableName,
+			Phase: errorSummary.status.MetricName(),
+			Error: errorSummary.err.Error(),
+		}
+		if class := common.ClassOf(errorSummary.err); class != nil {
+			table.Code = class.Code()
+		}
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Table < tables[j].Table })
+	return tables
+}
+
+// forget removes the error of a table which succeeded after a retry.
+func (es *errorSummaries) forget(tableName string) {
+	es.Lock()
+	defer es.Unlock()
+	delete(es.summary, tableName)
+}
+
 func (es *errorSummaries) record(tableName string, err error, status CheckpointStatus) {
 	es.Lock()
 	defer es.Unlock()
 	es.summary[tableName] = errorSummary{status: status, err: err}
 }
 
+// quarantinedChunk is the range of a data file left unimported because the
+// chunk kept failing.
+type quarantinedChunk struct {
+	tableName string
+	path      string
+	start     int64
+	end       int64
+	// err is empty for the chunks quarantined in a previous run.
+	err string
+}
+
+type quarantinedChunks struct {
+	sync.Mutex
+	chunks map[string]map[ChunkCheckpointKey]quarantinedChunk
+}
+
+func (qc *quarantinedChunks) add(tableName string, chunk *ChunkCheckpoint, err error) {
+	qc.Lock()
+	defer qc.Unlock()
+	tableChunks, ok := qc.chunks[tableName]
+	if !ok {
+		tableChunks = make(map[ChunkCheckpointKey]quarantinedChunk)
+		qc.chunks[tableName] = tableChunks
+	}
+	q := quarantinedChunk{
+		tableName: tableName,
+		path:      chunk.Key.Path,
+		start:     chunk.Chunk.Offset,
+		end:       chunk.Chunk.EndOffset,
+	}
+	if err != nil {
+		q.err = err.Error()
+	}
+	tableChunks[chunk.Key] = q
+}
+
+// forget removes the quarantined chunks of a table imported again from scratch.
+func (qc *quarantinedChunks) forget(tableName string) {
+	qc.Lock()
+	defer qc.Unlock()
+	delete(qc.chunks, tableName)
+}
+
+// list returns the quarantined chunks sorted by table name, path and offset.
+func (qc *quarantinedChunks) list() []quarantinedChunk {
+	qc.Lock()
+	defer qc.Unlock()
+	var res []quarantinedChunk
+	for _, tableChunks := range qc.chunks {
+		for _, q := range tableChunks {
+			res = append(res, q)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].tableName != res[j].tableName {
+			return res[i].tableName < res[j].tableName
+		}
+		if res[i].path != res[j].path {
+			return res[i].path < res[j].path
+		}
+		return res[i].start < res[j].start
+	})
+	return res
+}
+
+func (qc *quarantinedChunks) emitLog() {
+	chunks := qc.list()
+	if len(chunks) == 0 {
+		return
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "Totally **%d** chunks are quarantined, the following ranges of the data files are not imported.\n", len(chunks))
+	for _, q := range chunks {
+		fmt.Fprintf(&msg, "- [%s] %s:[%d, %d)", q.tableName, q.path, q.start, q.end)
+		if len(q.err) > 0 {
+			fmt.Fprintf(&msg, " %s", q.err)
+		}
+		msg.WriteByte('\n')
+	}
+	common.AppLogger.Warn(msg.String())
+}
+
 type RestoreController struct {
-	cfg             *config.Config
-	dbMetas         []*mydump.MDDatabaseMeta
-	dbInfos         map[string]*TidbDBInfo
-	tableWorkers    *worker.Pool
-	regionWorkers   *worker.Pool
-	ioWorkers       *worker.Pool
-	importer        *kv.Importer
-	tidbMgr         *TiDBManager
-	postProcessLock sync.Mutex // a simple way to ensure post-processing is not concurrent without using complicated goroutines
-	alterTableLock  sync.Mutex
-	compactState    int32
-
-	errorSummaries errorSummaries
+	cfg            *config.Config
+	dbMetas        []*mydump.MDDatabaseMeta
+	schemas        *schemaCache
+	tableWorkers   *worker.Pool
+	regionWorkers  *worker.Pool
+	numaNodes      []numa.Node
+	ioWorkers      *worker.Pool
+	importWorkers  *worker.Pool
+	indexWorkers   *worker.Pool
+	checksums      *checksumLimiter
+	coprChecksum   *coprocessorChecksum
+	barriers       *tableBarriers
+	backend        backend.Backend
+	tidbMgr        *TiDBManager
+	sqlMode        mysql.SQLMode
+	alterTableLock sync.Mutex
+	compactState   int32
+
+	errorSummaries   errorSummaries
+	quarantines      quarantinedChunks
+	unsupportedTypes *unsupportedTypesReport
 
 	checkpointsDB CheckpointsDB
 	saveCpCh      chan saveCp
 	checkpointsWg sync.WaitGroup
+	// flushCheckpoints is closed by Wait() to flush the pending checkpoint
+	// updates without waiting for checkpoint.flush-interval.
+	flushCheckpoints chan struct{}
+	flushOnce        sync.Once
+
+	taskID   string
+	taskUUID uuid.UUID
+	notifier *webhookNotifier
+	history  *historyRecorder
+	watchdog *stallWatchdog
+	governor *sloGovernor
+	display  *progressDisplay
+	state    *restoreState
+	// schedulers is nil unless tidb.pause-schedulers is set.
+	schedulers *schedulerPauser
+	gcLifeTime *gcLifeTimeManager
+
+	privilegeTables []*mydump.MDTableMeta
 }
 
-func NewRestoreController(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta, cfg *config.Config) (*RestoreController, error) {
-	importer, err := kv.NewImporter(ctx, cfg.TikvImporter.Addr, cfg.TiDB.PdAddr)
+// NewRestoreController creates the controller of a restore task. The target
+// is accessed through the glue, or a new connection to the TiDB in the config
+// if the glue is nil.
+func NewRestoreController(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta, cfg *config.Config, g glue.Glue) (*RestoreController, error) {
+	b, err := backend.New(ctx, cfg.TikvImporter.Backend, cfg)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
+	if err := checkCheckpointsInTarget(cfg, dbMetas); err != nil {
+		return nil, errors.Trace(err)
+	}
 	cpdb, err := OpenCheckpointsDB(ctx, cfg)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	tidbMgr, err := NewTiDBManager(cfg.TiDB)
+	// the connections to TiDB are opened under the SQL mode, so it is
+	// validated first.
+	sqlMode, err := mysql.GetSQLMode(mysql.FormatSQLModeStr(cfg.TiDB.SQLMode))
+	if err != nil {
+		return nil, common.ErrInvalidConfig.Annotatef(err, "invalid sql-mode %q", cfg.TiDB.SQLMode)
+	}
+	if !sqlMode.HasStrictMode() {
+		common.AppLogger.Warnf("sql-mode %q is not strict, the values invalid for their columns (e.g. overlong strings, out-of-range numbers and invalid dates) are truncated or converted to zero without error, enable post-restore.validate-rows to detect them", cfg.TiDB.SQLMode)
+	}
+
+	var tidbMgr *TiDBManager
+	if g != nil {
+		tidbMgr = NewTiDBManagerWithGlue(g)
+	} else if tidbMgr, err = NewTiDBManager(cfg.TiDB); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	barriers, err := newTableBarriers(cfg.TableDependencies, dbMetas)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
+	var numaNodes []numa.Node
+	var nodeWeights []int
+	if cfg.App.NUMAAware {
+		numaNodes, err = numa.Nodes()
+		if err != nil {
+			common.AppLogger.Warnf("cannot discover NUMA nodes, workers will not be pinned: %v", err)
+			numaNodes = nil
+		}
+		for _, node := range numaNodes {
+			nodeWeights = append(nodeWeights, len(node.CPUs))
+		}
+		common.AppLogger.Infof("distribute region workers among %d NUMA nodes", len(numaNodes))
+	}
+
 	rc := &RestoreController{
 		cfg:           cfg,
 		dbMetas:       dbMetas,
 		tableWorkers:  worker.NewPool(ctx, cfg.App.TableConcurrency, "table"),
-		regionWorkers: worker.NewPool(ctx, cfg.App.RegionConcurrency, "region"),
+		regionWorkers: worker.NewNodePool(ctx, cfg.App.RegionConcurrency, "region", nodeWeights),
+		numaNodes:     numaNodes,
 		ioWorkers:     worker.NewPool(ctx, cfg.App.IOConcurrency, "io"),
-		importer:      importer,
+		importWorkers: worker.NewPool(ctx, cfg.App.ImportConcurrency, "import"),
+		indexWorkers:  worker.NewPool(ctx, cfg.PostRestore.IndexConcurrency, "index"),
+		checksums:     newChecksumLimiter(ctx, cfg),
+		barriers:      barriers,
+		watchdog:      newStallWatchdog(&cfg.Watchdog),
+		display:       newProgressDisplay(&cfg.App),
+		schemas:       newSchemaCache(tidbMgr, nil, worker.NewPool(ctx, cfg.App.SchemaConcurrency, "schema")),
+		backend:       b,
 		tidbMgr:       tidbMgr,
+		sqlMode:       sqlMode,
 
 		errorSummaries: errorSummaries{
 			summary: make(map[string]errorSummary),
 		},
+		quarantines: quarantinedChunks{
+			chunks: make(map[string]map[ChunkCheckpointKey]quarantinedChunk),
+		},
 
-		checkpointsDB: cpdb,
-		saveCpCh:      make(chan saveCp),
+		checkpointsDB:    cpdb,
+		saveCpCh:         make(chan saveCp),
+		flushCheckpoints: make(chan struct{}),
+		notifier:         newWebhookNotifier(&cfg.Notify),
+		state:            newRestoreState(),
+	}
+	rc.gcLifeTime = newGCLifeTimeManager(tidbMgr.glue)
+	rc.governor = newSLOGovernor(&cfg.SLO, rc.regionWorkers, rc.importWorkers)
+
+	if cfg.PostRestore.Checksum && cfg.PostRestore.ChecksumMethod == config.ChecksumCoprocessor {
+		rc.coprChecksum, err = newCoprocessorChecksum(cfg.TiDB.PdAddr, cfg.TiDB.DistSQLScanConcurrency)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if cfg.TiDB.PauseSchedulers {
+		rc.schedulers = newSchedulerPauser(cfg.TiDB.PdAddr, cfg.Cron.SwitchMode.Duration)
+	}
+
+	if cfg.History.Enable {
+		rc.history, err = newHistoryRecorder(ctx, tidbMgr.glue, cfg.History.Schema)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
 	}
 
 	return rc, nil
@@ -172,7 +413,7 @@ func OpenCheckpointsDB(ctx context.Context, cfg *config.Config) (CheckpointsDB,
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
-		cpdb, err := NewMySQLCheckpointsDB(ctx, db, cfg.Checkpoint.Schema)
+		cpdb, err := NewMySQLCheckpointsDB(ctx, db, cfg.Checkpoint.Schema, cfg.Checkpoint.TablePrefix)
 		if err != nil {
 			db.Close()
 			return nil, errors.Trace(err)
@@ -188,20 +429,34 @@ func OpenCheckpointsDB(ctx context.Context, cfg *config.Config) (CheckpointsDB,
 }
 
 func (rc *RestoreController) Wait() {
+	rc.flushOnce.Do(func() { close(rc.flushCheckpoints) })
 	rc.checkpointsWg.Wait()
 }
 
+// FailedTables returns the tables which failed to be imported, sorted by name.
+func (rc *RestoreController) FailedTables() []FailedTable {
+	return rc.errorSummaries.failedTables()
+}
+
+// State returns the state of the tables being restored, sorted by name.
+func (rc *RestoreController) State() []TableState {
+	return rc.state.snapshot()
+}
+
 func (rc *RestoreController) Close() {
-	rc.importer.Close()
+	rc.backend.Close()
 	rc.tidbMgr.Close()
+	rc.coprChecksum.close()
 }
 
 func (rc *RestoreController) Run(ctx context.Context) error {
 	timer := time.Now()
 	opts := []func(context.Context) error{
 		rc.checkRequirements,
+		rc.recoverGCLifeTime,
 		rc.restoreSchema,
 		rc.restoreTables,
+		rc.restorePrivileges,
 		rc.fullCompact,
 		rc.switchToNormalMode,
 		rc.cleanCheckpoints,
@@ -224,59 +479,153 @@ outside:
 		}
 	}
 
-	common.AppLogger.Infof("the whole procedure takes %v", time.Since(timer))
+	common.ProgressLogger.Infof("the whole procedure takes %v", time.Since(timer))
 
 	rc.errorSummaries.emitLog()
+	rc.quarantines.emitLog()
+	rc.unsupportedTypes.emitLog()
+
+	if len(rc.taskID) > 0 {
+		rc.history.finishTask(rc.taskID, err)
+	}
+	rc.notifier.notify(eventTaskCompleted, rc.taskID, "", err)
+	rc.notifier.close()
 
 	return errors.Trace(err)
 }
 
-func (rc *RestoreController) restoreSchema(ctx context.Context) error {
-	tidbMgr, err := NewTiDBManager(rc.cfg.TiDB)
-	if err != nil {
-		return errors.Trace(err)
+// skipUnsupportedTables removes the tables skipped for the columns of the
+// unsupported types from the tables to be created and restored.
+func (rc *RestoreController) skipUnsupportedTables(localSchemas map[string]map[string]*localTableSchema) {
+	for _, dbMeta := range rc.dbMetas {
+		tables := dbMeta.Tables[:0]
+		for _, tableMeta := range dbMeta.Tables {
+			schema := localSchemas[dbMeta.Name][tableMeta.Name]
+			if schema == nil || !schema.skip {
+				tables = append(tables, tableMeta)
+				continue
+			}
+			delete(localSchemas[dbMeta.Name], tableMeta.Name)
+			rc.barriers.finish(common.UniqueTable(dbMeta.Name, tableMeta.Name), errTableSkipped)
+		}
+		dbMeta.Tables = tables
 	}
-	defer tidbMgr.Close()
+}
 
+func (rc *RestoreController) restoreSchema(ctx context.Context) error {
+	var localSchemas map[string]map[string]*localTableSchema
+	var err error
 	if !rc.cfg.Mydumper.NoSchema {
-		for _, dbMeta := range rc.dbMetas {
-			timer := time.Now()
-			common.AppLogger.Infof("restore table schema for `%s`", dbMeta.Name)
-			tablesSchema := make(map[string]string)
-			for _, tblMeta := range dbMeta.Tables {
-				tablesSchema[tblMeta.Name] = tblMeta.GetSchema()
-			}
-			err = tidbMgr.InitSchema(ctx, dbMeta.Name, tablesSchema)
-			if err != nil {
-				return errors.Errorf("db schema failed to init : %v", err)
-			}
-			common.AppLogger.Infof("restore table schema for `%s` takes %v", dbMeta.Name, time.Since(timer))
+		var downgrader *schemaDowngrader
+		downgrader, err = newSchemaDowngrader(&rc.cfg.Mydumper.DDLDowngrade)
+		if err != nil {
+			return common.ErrInvalidConfig.Wrap(err)
+		}
+		// validate all schema files before executing any DDL.
+		localSchemas, err = parseTableSchemas(rc.dbMetas, downgrader, rc.cfg.PostRestore.DeferIndex)
+		if err != nil {
+			return errors.Trace(err)
 		}
+		rc.unsupportedTypes = newUnsupportedTypesReport(localSchemas)
+		rc.skipUnsupportedTables(localSchemas)
+
+		timer := time.Now()
+		common.AppLogger.Infof("restore table schemas of %d databases", len(localSchemas))
+		err = rc.tidbMgr.InitSchemas(ctx, localSchemas, rc.schemas.workers)
+		if err != nil {
+			return errors.Errorf("db schema failed to init : %v", err)
+		}
+		common.AppLogger.Infof("restore table schemas takes %v", time.Since(timer))
 	}
-	dbInfos, err := tidbMgr.LoadSchemaInfo(ctx, rc.dbMetas)
+	// the table infos are fetched lazily when each table is being restored.
+	rc.schemas.localSchemas = localSchemas
+
+	// Load new checkpoints
+	err = rc.checkpointsDB.Initialize(ctx, rc.dbMetas)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	rc.dbInfos = dbInfos
-
-	// Load new checkpoints
-	err = rc.checkpointsDB.Initialize(ctx, dbInfos)
+	if rc.cfg.Checkpoint.DeltaImport {
+		if err = rc.resetChangedTables(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	taskID, err := rc.checkpointsDB.TaskID(ctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if uuid.Equal(taskID, uuid.Nil) {
+		// without persistent checkpoints every run is a new task in the
+		// history and the notifications, and its engines must not collide
+		// with those of another run importing tables of the same names.
+		taskID = uuid.NewV4()
+	}
+	if b, ok := rc.backend.(interface{ SetTaskID(uuid.UUID) }); ok {
+		b.SetTaskID(taskID)
+	}
+	rc.taskUUID = taskID
+	rc.taskID = taskID.String()
+	common.AppLogger.Infof("restore task %s", rc.taskID)
+	rc.history.startTask(ctx, rc.taskID, rc.cfg.Mydumper.SourceDir)
+	rc.notifier.notify(eventTaskStarted, rc.taskID, "", nil)
 
-	go rc.listenCheckpointUpdates(&rc.checkpointsWg)
+	go rc.listenCheckpointUpdates(ctx, &rc.checkpointsWg)
 
 	// Estimate the number of chunks for progress reporting
 	rc.estimateChunkCountIntoMetrics()
 	return nil
 }
 
+// resetChangedTables prepares a delta import. A table completed in a previous
+// run is truncated and imported again from scratch if its data files have
+// changed since, and is skipped otherwise.
+func (rc *RestoreController) resetChangedTables(ctx context.Context) error {
+	reset := 0
+	for _, dbMeta := range rc.dbMetas {
+		for _, tableMeta := range dbMeta.Tables {
+			tableName := common.UniqueTable(dbMeta.Name, tableMeta.Name)
+			cp, err := rc.checkpointsDB.Get(ctx, tableName)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if cp.Status < CheckpointStatusAnalyzeSkipped {
+				// not completed, resumed as usual.
+				continue
+			}
+			reason := cp.changedDataFiles(rc.cfg.Mydumper.SourceDir, tableMeta.DataFiles)
+			if len(reason) == 0 {
+				common.AppLogger.Infof("[%s] data files unchanged since the last run, skipped", tableName)
+				continue
+			}
+
+			common.AppLogger.Infof("[%s] importing again since %s", tableName, reason)
+			// the checkpoint is removed before truncating, so if we fail in
+			// between, the table is imported again instead of being skipped
+			// as completed with its data lost.
+			if err := rc.checkpointsDB.RemoveCheckpoint(ctx, tableName); err != nil {
+				return errors.Trace(err)
+			}
+			query := "TRUNCATE TABLE " + tableName
+			if err := rc.tidbMgr.glue.ExecuteWithLog(ctx, query, "truncate changed table"); err != nil {
+				return errors.Trace(err)
+			}
+			reset++
+		}
+	}
+	if reset == 0 {
+		return nil
+	}
+	common.AppLogger.Infof("delta import: %d tables changed since the last run", reset)
+	// recreate the checkpoints of the removed tables.
+	return errors.Trace(rc.checkpointsDB.Initialize(ctx, rc.dbMetas))
+}
+
 func (rc *RestoreController) estimateChunkCountIntoMetrics() {
 	estimatedChunkCount := 0
 	for _, dbMeta := range rc.dbMetas {
 		for _, tableMeta := range dbMeta.Tables {
 			estimatedChunkCount += len(tableMeta.DataFiles)
+			metric.RecordTableCount(metric.TableStatePending, nil)
 		}
 	}
 	metric.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated).Add(float64(estimatedChunkCount))
@@ -296,25 +645,82 @@ func (rc *RestoreController) saveStatusCheckpoint(tableName string, engineID int
 	}
 
 	metric.RecordTableCount(statusIfSucceed.MetricName(), err)
+	if engineID == -1 {
+		rc.state.setStatus(tableName, merger.Status)
+	}
 	rc.saveCpCh <- saveCp{tableName: tableName, merger: merger}
 }
 
 // listenCheckpointUpdates will combine several checkpoints together to reduce database load.
-func (rc *RestoreController) listenCheckpointUpdates(wg *sync.WaitGroup) {
+// The checkpoints are written at most once per `checkpoint.flush-interval`.
+func (rc *RestoreController) listenCheckpointUpdates(ctx context.Context, wg *sync.WaitGroup) {
 	var lock sync.Mutex
 	coalesed := make(map[string]*TableCheckpointDiff)
 
 	hasCheckpoint := make(chan struct{}, 1)
 
+	// sleep waits for d, but returns early when the task is cancelled or
+	// finished, so the pending updates are flushed without delaying shutdown.
+	sleep := func(d time.Duration) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-rc.flushCheckpoints:
+		}
+	}
+
 	go func() {
+		failures := 0
+		backoff := checkpointUpdateBackoff
+		var lastFlush time.Time
 		for range hasCheckpoint {
+			// let the updates accumulate, so that chunks finishing in quick
+			// succession are written in a single transaction.
+			if wait := rc.cfg.Checkpoint.FlushInterval.Duration - time.Since(lastFlush); wait > 0 {
+				sleep(wait)
+			}
+			lastFlush = time.Now()
+
 			lock.Lock()
 			cpd := coalesed
 			coalesed = make(map[string]*TableCheckpointDiff)
 			lock.Unlock()
 
 			if len(cpd) > 0 {
-				rc.checkpointsDB.Update(cpd)
+				err := rc.checkpointsDB.Update(cpd)
+				switch {
+				case err == nil:
+					failures = 0
+					backoff = checkpointUpdateBackoff
+				case failures >= maxCheckpointUpdateRetry:
+					common.AppLogger.Errorf("failed to save checkpoint after %d retries, dropping %d table updates: %v", failures, len(cpd), err)
+					failures = 0
+					backoff = checkpointUpdateBackoff
+				default:
+					failures++
+					common.AppLogger.Warnf("failed to save checkpoint, will retry in %v (%d/%d): %v", backoff, failures, maxCheckpointUpdateRetry, err)
+					sleep(backoff)
+					if backoff *= 2; backoff > maxCheckpointUpdateBackoff {
+						backoff = maxCheckpointUpdateBackoff
+					}
+
+					// put the failed updates back, behind those arrived in the meantime.
+					lock.Lock()
+					for tableName, older := range cpd {
+						if newer, ok := coalesed[tableName]; ok {
+							newer.mergeFrom(older)
+						} else {
+							coalesed[tableName] = older
+						}
+					}
+					if len(hasCheckpoint) == 0 {
+						wg.Add(1)
+						hasCheckpoint <- struct{}{}
+					}
+					lock.Unlock()
+				}
 			}
 			wg.Done()
 		}
@@ -360,9 +766,42 @@ func (rc *RestoreController) runPeriodicActions(ctx context.Context, stop <-chan
 		logProgressTicker.Stop()
 	}()
 
+	var stallCheckCh <-chan time.Time
+	if rc.watchdog != nil {
+		stallCheckTicker := time.NewTicker(rc.watchdog.checkInterval())
+		defer stallCheckTicker.Stop()
+		stallCheckCh = stallCheckTicker.C
+	}
+
+	var governorCh <-chan time.Time
+	if rc.governor != nil {
+		governorTicker := time.NewTicker(rc.cfg.SLO.Interval.Duration)
+		defer governorTicker.Stop()
+		governorCh = governorTicker.C
+	}
+
+	var displayCh <-chan time.Time
+	if rc.display != nil {
+		displayTicker := time.NewTicker(progressRefreshInterval)
+		defer func() {
+			displayTicker.Stop()
+			rc.display.clear()
+		}()
+		displayCh = displayTicker.C
+	}
+
 	rc.switchToImportMode(ctx)
+	if rc.schedulers != nil {
+		// the schedulers are resumed as soon as the periodic actions stop,
+		// which happens when the tables are restored or failed.
+		defer rc.schedulers.resume(ctx)
+		if err := rc.schedulers.pause(ctx); err != nil {
+			common.AppLogger.Warnf("cannot pause the PD schedulers, continuing without pausing: %v", err)
+		}
+	}
 
 	start := time.Now()
+	lastUsage := common.ReadResourceUsage()
 
 	for {
 		select {
@@ -376,32 +815,30 @@ func (rc *RestoreController) runPeriodicActions(ctx context.Context, stop <-chan
 		case <-switchModeTicker.C:
 			// periodically switch to import mode, as requested by TiKV 3.0
 			rc.switchToImportMode(ctx)
+			if rc.schedulers != nil {
+				rc.schedulers.renew(ctx)
+			}
+
+		case now := <-stallCheckCh:
+			rc.watchdog.check(now)
+
+		case <-governorCh:
+			rc.governor.adjust()
+
+		case now := <-displayCh:
+			rc.display.render(readProgress(now.Sub(start)), now)
 
 		case <-logProgressTicker.C:
 			// log the current progress periodically, so OPS will know that we're still working
-			nanoseconds := float64(time.Since(start).Nanoseconds())
-			estimated := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated))
-			finished := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished))
-			totalTables := metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
-			completedTables := metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStateCompleted, metric.TableResultSuccess))
-			bytesRead := metric.ReadHistogramSum(metric.BlockReadBytesHistogram)
-
-			var remaining string
-			if finished >= estimated {
-				remaining = ", post-processing"
-			} else if finished > 0 {
-				remainNanoseconds := (estimated/finished - 1) * nanoseconds
-				remaining = fmt.Sprintf(", remaining %s", time.Duration(remainNanoseconds).Round(time.Second))
-			}
+			progress := readProgress(time.Since(start))
 
-			// Note: a speed of 28 MiB/s roughly corresponds to 100 GiB/hour.
-			common.AppLogger.Infof(
-				"progress: %.0f/%.0f chunks (%.1f%%), %.0f/%.0f tables (%.1f%%), speed %.2f MiB/s%s",
-				finished, estimated, finished/estimated*100,
-				completedTables, totalTables, completedTables/totalTables*100,
-				bytesRead/(1048576e-9*nanoseconds),
-				remaining,
-			)
+			// record the resource usage too, to investigate OOM kills afterwards.
+			usage := common.ReadResourceUsage()
+			cpuPercent := usage.CPUPercent(&lastUsage)
+			lastUsage = usage
+
+			common.ProgressLogger.Infof("progress: %s; %s, cpu %.1f%%", progress, &usage, cpuPercent)
+			rc.state.logDebug()
 		}
 	}
 }
@@ -410,59 +847,214 @@ func (rc *RestoreController) restoreTables(ctx context.Context) error {
 	timer := time.Now()
 	var wg sync.WaitGroup
 
+	// a table failing aborts the other tables, instead of letting them run
+	// to the end of a task which has failed anyway.
+	ctx, abort := context.WithCancel(ctx)
+	defer abort()
+
 	var restoreErr common.OnceError
 
 	stopPeriodicActions := make(chan struct{}, 1)
 	go rc.runPeriodicActions(ctx, stopPeriodicActions)
+	// no-op if all tables have been launched and finished.
+	defer rc.barriers.abort()
 
 	for _, dbMeta := range rc.dbMetas {
-		dbInfo, ok := rc.dbInfos[dbMeta.Name]
-		if !ok {
-			common.AppLogger.Errorf("database %s not found in rc.dbInfos", dbMeta.Name)
-			continue
-		}
 		for _, tableMeta := range dbMeta.Tables {
-			tableInfo, ok := dbInfo.Tables[tableMeta.Name]
-			if !ok {
-				return errors.Errorf("table info %s not found", tableMeta.Name)
-			}
-
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
 			}
 
-			tableName := common.UniqueTable(dbInfo.Name, tableInfo.Name)
+			tableName := common.UniqueTable(dbMeta.Name, tableMeta.Name)
 			cp, err := rc.checkpointsDB.Get(ctx, tableName)
 			if cp.Status <= CheckpointStatusMaxInvalid {
-				return errors.Errorf("Checkpoint for %s has invalid status: %d", tableName, cp.Status)
+				return common.ErrCheckpointInvalid.Errorf("Checkpoint for %s has invalid status: %d", tableName, cp.Status)
 			}
 			if err != nil {
 				return errors.Trace(err)
 			}
-			tr, err := NewTableRestore(tableName, tableMeta, dbInfo, tableInfo, cp)
-			if err != nil {
-				return errors.Trace(err)
-			}
 
 			wg.Add(1)
-			go func(t *TableRestore, cp *TableCheckpoint) {
+			go func(tableName string, tableMeta *mydump.MDTableMeta, cp *TableCheckpoint) {
 				defer wg.Done()
-				err := t.restoreTable(ctx, rc, cp)
+				err := rc.barriers.wait(ctx, tableName)
+				if err == nil {
+					tableTimer := time.Now()
+					timing := new(tableTiming)
+					common.ProgressLogger.Infof("[%s] restore table start", tableName)
+					err = rc.restoreTableWithRetry(ctx, tableName, tableMeta, cp, timing)
+					if err == nil {
+						common.ProgressLogger.Infof("[%s] restore table completed, takes %v (%s)", tableName, time.Since(tableTimer), timing)
+						timing.observe()
+					}
+				}
+				rc.barriers.finish(tableName, err)
 				metric.RecordTableCount("completed", err)
-				restoreErr.Set(t.tableName, err)
-			}(tr, cp)
+				rc.notifier.notify(eventTableFinished, rc.taskID, tableName, err)
+				checksum := cp.localChecksum()
+				rc.history.finishTable(rc.taskID, tableName, &checksum, err)
+				restoreErr.Set(tableName, err)
+				if err != nil && !common.IsContextCanceledError(err) {
+					abort()
+				}
+			}(tableName, tableMeta, cp)
 		}
 	}
 
 	wg.Wait()
 	stopPeriodicActions <- struct{}{}
-	common.AppLogger.Infof("restore all tables data takes %v", time.Since(timer))
+	common.ProgressLogger.Infof("restore all tables data takes %v", time.Since(timer))
 
 	return errors.Trace(restoreErr.Get())
 }
 
+// restoreTableWithRetry restores the table, and imports it again from scratch
+// up to `app.table-retry` times if it fails at the import or checksum phase.
+func (rc *RestoreController) restoreTableWithRetry(
+	ctx context.Context,
+	tableName string,
+	tableMeta *mydump.MDTableMeta,
+	cp *TableCheckpoint,
+	timing *tableTiming,
+) error {
+	err := rc.restoreTable(ctx, tableName, tableMeta, cp, timing)
+	backoff := tableRetryBackoff
+	for retry := 1; retry <= rc.cfg.App.TableRetry && isRetryableTableError(err); retry++ {
+		// importing again would clean up the engines of the failed import.
+		if rc.cfg.KeepEnginesOnFailure && common.ErrImportEngine.Equal(err) {
+			common.AppLogger.Warnf("[%s] restore table failed, the engines are kept in importer for inspection: %v", tableName, err)
+			break
+		}
+		common.AppLogger.Warnf("[%s] restore table failed, importing again from scratch after %v (%d/%d): %v",
+			tableName, backoff, retry, rc.cfg.App.TableRetry, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		if err := rc.resetTable(ctx, tableName, tableMeta, cp); err != nil {
+			return errors.Trace(err)
+		}
+		err = rc.restoreTable(ctx, tableName, tableMeta, cp, timing)
+	}
+	if err == nil {
+		rc.errorSummaries.forget(tableName)
+	}
+	return errors.Trace(err)
+}
+
+// isRetryableTableError returns whether the table failed at the import or
+// checksum phase, which is often caused by transient errors in the cluster.
+func isRetryableTableError(err error) bool {
+	return err != nil && (common.ErrImportEngine.Equal(err) || common.ErrChecksumMismatch.Equal(err))
+}
+
+// resetTable discards all data of a failed table, so that it can be imported
+// again from scratch.
+func (rc *RestoreController) resetTable(
+	ctx context.Context,
+	tableName string,
+	tableMeta *mydump.MDTableMeta,
+	cp *TableCheckpoint,
+) error {
+	for engineID := range cp.Engines {
+		err := rc.backend.CloseEngine(ctx, tableName, engineID)
+		if err == nil {
+			err = rc.backend.CleanupEngine(ctx, tableName, engineID)
+		}
+		if err != nil {
+			common.AppLogger.Warnf("[%s:%d] failed to clean up engine: %v", tableName, engineID, err)
+		}
+	}
+
+	query := "TRUNCATE TABLE " + tableName
+	if err := rc.tidbMgr.glue.ExecuteWithLog(ctx, query, "truncate failed table"); err != nil {
+		return errors.Trace(err)
+	}
+	rc.schemas.forgetTable(tableMeta.DB, tableMeta.Name)
+	rc.quarantines.forget(tableName)
+
+	cp.resetProgress()
+	rc.saveCpCh <- saveCp{
+		tableName: tableName,
+		merger:    &StatusCheckpointMerger{EngineID: -1, Status: CheckpointStatusLoaded},
+	}
+	for engineID, engine := range cp.Engines {
+		rc.saveCpCh <- saveCp{
+			tableName: tableName,
+			merger:    &StatusCheckpointMerger{EngineID: engineID, Status: CheckpointStatusLoaded},
+		}
+		for _, chunk := range engine.Chunks {
+			rc.saveCpCh <- saveCp{
+				tableName: tableName,
+				merger: &ChunkCheckpointMerger{
+					EngineID: engineID,
+					Key:      chunk.Key,
+					Checksum: chunk.Checksum,
+					Pos:      chunk.Chunk.Offset,
+					RowID:    chunk.Chunk.PrevRowIDMax,
+				},
+			}
+		}
+	}
+	return nil
+}
+
+func (rc *RestoreController) restoreTable(
+	ctx context.Context,
+	tableName string,
+	tableMeta *mydump.MDTableMeta,
+	cp *TableCheckpoint,
+	timing *tableTiming,
+) error {
+	dbInfo, tableInfo, err := rc.schemas.getTableInfo(ctx, tableMeta.DB, tableMeta.Name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tr, err := NewTableRestore(tableName, tableMeta, dbInfo, tableInfo, cp, rc.cfg.TiDB.SQLMode)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer tr.Close()
+	tr.timing = timing
+	return errors.Trace(tr.restoreTable(ctx, rc, cp))
+}
+
+// reconcileMissingDataFiles handles the unfinished chunks in the checkpoint
+// whose data files no longer exist according to `checkpoint.on-missing-file`.
+// The skipped chunks are only marked finished in memory, so that they are
+// reported again when resuming another time.
+func (t *TableRestore) reconcileMissingDataFiles(cfg *config.Config, cp *TableCheckpoint) error {
+	missing, err := cp.missingDataFiles(cfg.Mydumper.SourceDir)
+	if err != nil || len(missing) == 0 {
+		return errors.Trace(err)
+	}
+
+	paths := make([]string, 0, len(missing))
+	for _, chunk := range missing {
+		paths = append(paths, chunk.Key.Path)
+	}
+	if cfg.Checkpoint.OnMissingFile != config.MissingFileSkip {
+		return common.ErrInvalidSource.Errorf(
+			"[%s] the checkpoint references %d missing data files %v, "+
+				"either restore these files (e.g. re-dump the table), "+
+				"set checkpoint.on-missing-file = \"skip\" to import the table without them, "+
+				"or run tidb-lightning-ctl --checkpoint-remove='%s' and truncate the table to import it again from scratch",
+			t.tableName, len(paths), paths, t.tableName,
+		)
+	}
+
+	common.AppLogger.Warnf("[%s] skipping %d chunks of the missing data files %v, the table will be incomplete", t.tableName, len(paths), paths)
+	for _, chunk := range missing {
+		chunk.Chunk.Offset = chunk.Chunk.EndOffset
+	}
+	return nil
+}
+
 func (t *TableRestore) restoreTable(
 	ctx context.Context,
 	rc *RestoreController,
@@ -473,10 +1065,33 @@ func (t *TableRestore) restoreTable(
 	// no need to do anything if the chunks are already populated
 	if len(cp.Engines) > 0 {
 		common.AppLogger.Infof("[%s] reusing %d engines and %d chunks from checkpoint", t.tableName, len(cp.Engines), cp.CountChunks())
+		if err := t.reconcileMissingDataFiles(rc.cfg, cp); err != nil {
+			return errors.Trace(err)
+		}
+		for _, engine := range cp.Engines {
+			for _, chunk := range engine.Chunks {
+				if chunk.Quarantined {
+					common.AppLogger.Warnf("[%s] [%s] skipping the chunk quarantined in a previous run, range [%d, %d) is not imported",
+						t.tableName, &chunk.Key, chunk.Chunk.Offset, chunk.Chunk.EndOffset)
+					rc.quarantines.add(t.tableName, chunk, nil)
+				}
+			}
+		}
 	} else if cp.Status < CheckpointStatusAllWritten {
-		if err := t.populateChunks(rc.cfg, cp); err != nil {
+		kvSizeRatio := 0.0
+		if rc.cfg.Mydumper.SampleRows > 0 {
+			var err error
+			kvSizeRatio, err = t.sampleKVSizeRatio(rc, rc.cfg.Mydumper.SampleRows)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err := t.populateChunks(rc.cfg, cp, kvSizeRatio); err != nil {
 			return errors.Trace(err)
 		}
+		for engineID, engine := range cp.Engines {
+			engine.UUID = kv.EngineUUID(rc.taskUUID, t.tableName, engineID)
+		}
 		if err := rc.checkpointsDB.InsertEngineCheckpoints(ctx, t.tableName, cp.Engines); err != nil {
 			return errors.Trace(err)
 		}
@@ -497,9 +1112,15 @@ func (t *TableRestore) restoreTable(
 		}
 	}
 
+	rc.state.startTable(t.tableName, cp)
+
 	// 2. Restore engines (if still needed)
 
 	if cp.Status < CheckpointStatusImported {
+		if err := t.prepareTiFlashReplica(ctx, rc, cp); err != nil {
+			return errors.Trace(err)
+		}
+
 		timer := time.Now()
 
 		var wg sync.WaitGroup
@@ -520,19 +1141,46 @@ func (t *TableRestore) restoreTable(
 			// Note: We still need tableWorkers to control the concurrency of tables.
 			// In the future, we will investigate more about
 			// the difference between restoring tables concurrently and restoring tables one by one.
-			restoreWorker := rc.tableWorkers.Apply()
+			restoreWorker, err := rc.tableWorkers.ApplyContext(ctx)
+			if err != nil {
+				wg.Done()
+				engineErr.Set(t.tableName, err)
+				break
+			}
 
 			go func(w *worker.Worker, eid int, ecp *EngineCheckpoint) {
 				defer wg.Done()
 				tag := fmt.Sprintf("%s:%d", t.tableName, eid)
-
-				closedEngine, err := t.restoreEngine(ctx, rc, eid, ecp)
+				rc.display.setPhase(tag, phaseWriting)
+				defer rc.display.done(tag)
+				rc.state.setEngine(t.tableName, eid, engineRunning)
+				defer rc.state.setEngine(t.tableName, eid, engineFinished)
+
+				err := t.restoreEngine(ctx, rc, eid, ecp)
+				for retry := 1; errors.Cause(err) == errEngineStalled && retry <= maxStalledEngineRetry; retry++ {
+					common.AppLogger.Warnf("[%s] engine stalled, writing again from the last progress (%d/%d)", tag, retry, maxStalledEngineRetry)
+					err = t.restoreEngine(ctx, rc, eid, ecp)
+				}
 				rc.tableWorkers.Recycle(w)
 				if err != nil {
 					engineErr.Set(tag, err)
 					return
 				}
-				if err := t.importEngine(ctx, closedEngine, rc, eid, ecp); err != nil {
+
+				// the table worker is released, so the next engine can be
+				// encoded while this one waits in the import queue.
+				queueTimer := time.Now()
+				rc.display.setPhase(tag, phaseImportQueued)
+				importWorker, err := rc.importWorkers.ApplyContext(ctx)
+				if err != nil {
+					engineErr.Set(tag, err)
+					return
+				}
+				defer rc.importWorkers.Recycle(importWorker)
+				common.AppLogger.Infof("[%s] waited %v in the import queue", tag, time.Since(queueTimer))
+				rc.display.setPhase(tag, phaseImporting)
+
+				if err := t.importEngine(ctx, rc, eid, ecp); err != nil {
 					engineErr.Set(tag, err)
 				}
 			}(restoreWorker, engineID, engine)
@@ -550,39 +1198,154 @@ func (t *TableRestore) restoreTable(
 
 	// 3. Post-process
 
+	rc.display.setPhase(t.tableName, phasePostProcessing)
+	defer rc.display.done(t.tableName)
 	return errors.Trace(t.postProcess(ctx, rc, cp))
 }
 
+// engineLogger returns the logger of the pipeline of an engine, whose messages
+// carry the table, the engine ID and the engine UUID on tikv-importer.
+func (t *TableRestore) engineLogger(rc *RestoreController, engineID int) *log.Entry {
+	return common.AppLogger.WithFields(log.Fields{
+		"table":       t.tableName,
+		"engine":      engineID,
+		"engine-uuid": kv.EngineUUID(rc.taskUUID, t.tableName, engineID),
+	})
+}
+
 func (t *TableRestore) restoreEngine(
 	ctx context.Context,
 	rc *RestoreController,
 	engineID int,
 	cp *EngineCheckpoint,
-) (*kv.ClosedEngine, error) {
+) error {
 	if cp.Status >= CheckpointStatusClosed {
-		closedEngine, err := rc.importer.UnsafeCloseEngine(ctx, t.tableName, engineID)
-		return closedEngine, errors.Trace(err)
+		return nil
+	}
+
+	tag := fmt.Sprintf("%s:%d", t.tableName, engineID)
+	// waiting for the disk quota is not a stall, so it happens before the
+	// engine is watched.
+	if b, ok := rc.backend.(interface {
+		WaitDiskQuota(context.Context, string) error
+	}); ok && cp.isFresh() {
+		if err := b.WaitDiskQuota(ctx, tag); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	engineCtx, unwatch := rc.watchdog.watch(ctx, tag)
+	err := t.writeEngine(engineCtx, rc, engineID, cp)
+	if unwatch() && err != nil && ctx.Err() == nil {
+		return errors.Annotate(errEngineStalled, tag)
+	}
+	return errors.Trace(err)
+}
+
+// retryChunk restores the failed chunk again from its checkpoint, up to
+// `lightning.chunk-retry` times. The KV pairs of the failed attempts not yet
+// acknowledged are not in the checkpoint, and thus are encoded again.
+func (t *TableRestore) retryChunk(
+	ctx context.Context,
+	rc *RestoreController,
+	engineID int,
+	delivery *engineDelivery,
+	cr *chunkRestore,
+	err error,
+	openChunk func(int, *ChunkCheckpoint) (*chunkRestore, error),
+) error {
+	for retry := 1; retry <= rc.cfg.App.ChunkRetry && err != nil; retry++ {
+		if common.IsContextCanceledError(err) || ctx.Err() != nil {
+			break
+		}
+		cr.logger.Warnf("restore chunk failed, restoring again from offset %d after %v (%d/%d): %v",
+			cr.chunk.Chunk.Offset, chunkRetryBackoff, retry, rc.cfg.App.ChunkRetry, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(chunkRetryBackoff):
+		}
+
+		var retryCr *chunkRestore
+		retryCr, err = openChunk(cr.index, cr.chunk)
+		if err != nil {
+			continue
+		}
+		err = retryCr.restore(ctx, t, engineID, delivery, rc)
+		retryCr.close()
+	}
+	return errors.Trace(err)
+}
+
+// quarantineChunk gives up the rest of the failed chunk, and records the
+// unimported range in the checkpoint so that the chunk is skipped on resume.
+func (t *TableRestore) quarantineChunk(rc *RestoreController, engineID int, cr *chunkRestore, err error) {
+	cr.logger.Errorf("restore chunk failed, quarantined, range [%d, %d) is not imported: %v",
+		cr.chunk.Chunk.Offset, cr.chunk.Chunk.EndOffset, err)
+	cr.chunk.Quarantined = true
+	rc.quarantines.add(t.tableName, cr.chunk, err)
+	rc.saveCpCh <- saveCp{
+		tableName: t.tableName,
+		merger: &ChunkCheckpointMerger{
+			EngineID:    engineID,
+			Key:         cr.chunk.Key,
+			Checksum:    cr.chunk.Checksum,
+			Pos:         cr.chunk.Chunk.Offset,
+			RowID:       cr.chunk.Chunk.PrevRowIDMax,
+			Quarantined: true,
+		},
 	}
+}
 
+// writeEngine writes all chunks of the engine and closes it.
+func (t *TableRestore) writeEngine(
+	ctx context.Context,
+	rc *RestoreController,
+	engineID int,
+	cp *EngineCheckpoint,
+) error {
 	timer := time.Now()
+	logger := t.engineLogger(rc, engineID)
 
-	engine, err := rc.importer.OpenEngine(ctx, t.tableName, engineID)
-	if err != nil {
-		return nil, errors.Trace(err)
+	if err := rc.backend.OpenEngine(ctx, t.tableName, engineID, cp.isFresh()); err != nil {
+		return errors.Trace(err)
 	}
+	// the engine may be written again in the same process after a stall, so
+	// only the KV pairs since now are compared against the backend.
+	localBefore := cp.checksum()
+	remoteBefore := rc.backend.Checksum(t.tableName, engineID)
 
 	var wg sync.WaitGroup
 	var chunkErr common.OnceError
+	delivery := newEngineDelivery()
+
+	fixedWidth := rc.cfg.Mydumper.FindFixedWidthRule(t.tableMeta.DB, t.tableMeta.Name)
+	projection := rc.cfg.Mydumper.FindProjectionRule(t.tableMeta.DB, t.tableMeta.Name)
+	filter := rc.cfg.Mydumper.FindFilterRule(t.tableMeta.DB, t.tableMeta.Name)
+	transforms := t.transformRules(&rc.cfg.Mydumper)
+	// openChunk opens the data file of the chunk at its checkpoint offset.
+	openChunk := func(chunkIndex int, chunk *ChunkCheckpoint) (*chunkRestore, error) {
+		path := resolveDataFilePath(rc.cfg.Mydumper.SourceDir, chunk.Key.Path)
+		if err := chunk.checkFile(path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		cr, err := newChunkRestore(chunkIndex, chunk, path, rc.cfg.Mydumper.ReadBlockSize, rc.cfg.Mydumper.ReadAhead, rc.sqlMode, t.assignableColumns(), fixedWidth, projection, filter, transforms, t.tableMeta.DataFileDigests[path], rc.ioWorkers)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		cr.logger = chunkLogger(logger, chunk)
+		return cr, nil
+	}
 
 	// Restore table data
-	for chunkIndex, chunk := range cp.Chunks {
-		if chunk.Chunk.Offset >= chunk.Chunk.EndOffset {
+	for _, chunkIndex := range cp.largestChunksFirst() {
+		chunk := cp.Chunks[chunkIndex]
+		if chunk.Quarantined || chunk.Chunk.Offset >= chunk.Chunk.EndOffset {
 			continue
 		}
 
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return ctx.Err()
 		default:
 		}
 
@@ -596,13 +1359,21 @@ func (t *TableRestore) restoreEngine(
 		// 	3. load kvs data (into kv deliver server)
 		// 	4. flush kvs data (into tikv node)
 
-		cr, err := newChunkRestore(chunkIndex, chunk, rc.cfg.Mydumper.ReadBlockSize, rc.ioWorkers)
+		cr, err := openChunk(chunkIndex, chunk)
 		if err != nil {
-			return nil, errors.Trace(err)
+			return errors.Trace(err)
 		}
 		metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending).Inc()
 
-		restoreWorker := rc.regionWorkers.Apply()
+		rc.state.addChunks(t.tableName, 1, 0)
+		restoreWorker, err := rc.regionWorkers.ApplyFair(ctx, t.tableName)
+		if err != nil {
+			rc.state.addChunks(t.tableName, -1, 0)
+			cr.close()
+			chunkErr.Set(t.tableName, err)
+			break
+		}
+		rc.state.addChunks(t.tableName, -1, 1)
 		wg.Add(1)
 		go func(w *worker.Worker, cr *chunkRestore) {
 			// Restore a chunk.
@@ -610,14 +1381,30 @@ func (t *TableRestore) restoreEngine(
 				cr.close()
 				wg.Done()
 				rc.regionWorkers.Recycle(w)
+				rc.state.addChunks(t.tableName, 0, -1)
 			}()
+			if w.Node >= 0 {
+				unpin, err := rc.numaNodes[w.Node].Pin()
+				if err != nil {
+					cr.logger.Warnf("failed to pin worker to NUMA node %d: %v", rc.numaNodes[w.Node].ID, err)
+				} else {
+					defer unpin()
+				}
+			}
 			metric.ChunkCounter.WithLabelValues(metric.ChunkStateRunning).Inc()
-			err := cr.restore(ctx, t, engineID, engine, rc)
+			err := cr.restore(ctx, t, engineID, delivery, rc)
+			if err != nil {
+				err = t.retryChunk(ctx, rc, engineID, delivery, cr, err, openChunk)
+			}
 			if err == nil {
 				metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished).Inc()
 				return
 			}
 			metric.ChunkCounter.WithLabelValues(metric.ChunkStateFailed).Inc()
+			if rc.cfg.App.QuarantineChunks && !common.IsContextCanceledError(err) && ctx.Err() == nil {
+				t.quarantineChunk(rc, engineID, cr, err)
+				return
+			}
 			tag := fmt.Sprintf("%s:%d] [%s", t.tableName, engineID, &cr.chunk.Key)
 			chunkErr.Set(tag, err)
 		}(restoreWorker, cr)
@@ -634,25 +1421,53 @@ func (t *TableRestore) restoreEngine(
 		totalSQLSize += chunk.Chunk.EndOffset
 	}
 
-	common.AppLogger.Infof("[%s:%d] encode kv data and write takes %v (read %d, written %d)", t.tableName, engineID, dur, totalSQLSize, totalKVSize)
-	err = chunkErr.Get()
+	logger.Infof("encode kv data and write takes %v (read %d, written %d)", dur, totalSQLSize, totalKVSize)
+	tag := fmt.Sprintf("%s:%d", t.tableName, engineID)
+	var remote, local verify.KVChecksum
+	err := chunkErr.Get()
+	if err == nil {
+		remote, err = checksumSince(rc.backend.Checksum(t.tableName, engineID), remoteBefore)
+	}
+	if err == nil {
+		err = delivery.verifyDelivered(remote, tag)
+	}
+	if err == nil {
+		local, err = checksumSince(cp.checksum(), localBefore)
+	}
+	if err == nil {
+		err = verifyEngineSize(local, remote, tag)
+	}
 	rc.saveStatusCheckpoint(t.tableName, engineID, err, CheckpointStatusAllWritten)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return errors.Trace(err)
 	}
 
-	closedEngine, err := engine.Close(ctx)
+	closeTimer := time.Now()
+	err = rc.backend.CloseEngine(ctx, t.tableName, engineID)
+	t.timing.add(stepClose, time.Since(closeTimer))
 	rc.saveStatusCheckpoint(t.tableName, engineID, err, CheckpointStatusClosed)
 	if err != nil {
-		common.AppLogger.Errorf("[kv-deliver] flush stage with error (step = close) : %s", errors.ErrorStack(err))
-		return nil, errors.Trace(err)
+		logger.Errorf("flush stage with error (step = close) : %s", errors.ErrorStack(err))
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// verifyEngineSize compares the KV pairs recorded in the chunk checkpoints
+// against those acknowledged by the backend, so a delivery bug or a data loss
+// in the backend fails the engine before it is ingested.
+func verifyEngineSize(recorded verify.KVChecksum, delivered verify.KVChecksum, tag string) error {
+	if recorded.SumKVS() != delivered.SumKVS() || recorded.SumSize() != delivered.SumSize() {
+		return common.ErrChecksumMismatch.Errorf(
+			"[%s] engine size mismatch before import: checkpoints recorded %d KV pairs (%d bytes) vs backend acknowledged %d KV pairs (%d bytes)",
+			tag, recorded.SumKVS(), recorded.SumSize(), delivered.SumKVS(), delivered.SumSize(),
+		)
 	}
-	return closedEngine, nil
+	return nil
 }
 
 func (t *TableRestore) importEngine(
 	ctx context.Context,
-	closedEngine *kv.ClosedEngine,
 	rc *RestoreController,
 	engineID int,
 	cp *EngineCheckpoint,
@@ -664,11 +1479,10 @@ func (t *TableRestore) importEngine(
 	// 1. close engine, then calling import
 	// FIXME: flush is an asynchronous operation, what if flush failed?
 
-	// the lock ensures the import() step will not be concurrent.
-	rc.postProcessLock.Lock()
-	err := t.importKV(ctx, closedEngine)
+	// the number of concurrent imports is bounded by rc.importWorkers, which
+	// the caller holds during this call.
+	err := t.importKV(ctx, rc.backend, engineID, t.engineLogger(rc, engineID))
 	// gofail: var SlowDownImport struct{}
-	rc.postProcessLock.Unlock()
 	rc.saveStatusCheckpoint(t.tableName, engineID, err, CheckpointStatusImported)
 	if err != nil {
 		return errors.Trace(err)
@@ -690,12 +1504,12 @@ func (t *TableRestore) importEngine(
 }
 
 func (t *TableRestore) postProcess(ctx context.Context, rc *RestoreController, cp *TableCheckpoint) error {
-	setSessionConcurrencyVars(ctx, rc.tidbMgr.db, rc.cfg.TiDB)
+	setSessionConcurrencyVars(ctx, rc.tidbMgr.glue, rc.cfg.TiDB)
 
 	// 3. alter table set auto_increment
 	if cp.Status < CheckpointStatusAlteredAutoInc {
 		rc.alterTableLock.Lock()
-		err := t.restoreTableMeta(ctx, rc.tidbMgr.db)
+		err := t.restoreTableMeta(ctx, rc.tidbMgr.glue)
 		rc.alterTableLock.Unlock()
 		rc.saveStatusCheckpoint(t.tableName, -1, err, CheckpointStatusAlteredAutoInc)
 		if err != nil {
@@ -707,28 +1521,73 @@ func (t *TableRestore) postProcess(ctx context.Context, rc *RestoreController, c
 		}
 	}
 
-	// 4. do table checksum
+	// 4. add back the TiFlash replicas removed before import
+	if cp.Status < CheckpointStatusChecksumSkipped {
+		if err := t.restoreTiFlashReplica(ctx, rc, cp); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// 5. do table checksum, and compare the sampled rows with the source
 	if cp.Status < CheckpointStatusChecksummed {
+		status := CheckpointStatusChecksummed
+		var err error
 		if !rc.cfg.PostRestore.Checksum {
 			common.AppLogger.Infof("[%s] Skip checksum.", t.tableName)
-			rc.saveStatusCheckpoint(t.tableName, -1, nil, CheckpointStatusChecksumSkipped)
+			status = CheckpointStatusChecksumSkipped
 		} else {
-			err := t.compareChecksum(ctx, rc.tidbMgr.db, cp)
-			rc.saveStatusCheckpoint(t.tableName, -1, err, CheckpointStatusChecksummed)
+			w, acquireErr := rc.checksums.acquire(ctx, t.tableName)
+			if acquireErr != nil {
+				return errors.Trace(acquireErr)
+			}
+			err = t.compareChecksum(ctx, rc, cp)
+			rc.checksums.release(w)
 			if err != nil {
 				common.AppLogger.Errorf("[%s] checksum failed: %v", t.tableName, err.Error())
-				return errors.Trace(err)
 			}
 		}
+		if err == nil {
+			err = t.validateSampledRows(ctx, rc, cp)
+			if err != nil {
+				common.AppLogger.Errorf("[%s] validate sampled rows failed: %v", t.tableName, err.Error())
+			}
+		}
+		rc.saveStatusCheckpoint(t.tableName, -1, err, status)
+		if err != nil {
+			rc.notifier.notify(eventChecksumFailed, rc.taskID, t.tableName, err)
+			return errors.Trace(err)
+		}
+	}
+
+	// 6. add the indexes deferred until the data are imported
+	if cp.Status < CheckpointStatusAnalyzeSkipped && len(t.tableInfo.deferredIndexes) > 0 {
+		rc.display.setPhase(t.tableName, phaseAddingIndex)
+		err := t.addDeferredIndexes(ctx, rc)
+		rc.display.setPhase(t.tableName, phasePostProcessing)
+		if err != nil {
+			common.AppLogger.Errorf("[%s] add index failed: %v", t.tableName, err.Error())
+			return errors.Trace(err)
+		}
+	}
+
+	// 7. wait for the TiFlash replicas to catch up
+	if cp.Status < CheckpointStatusAnalyzeSkipped && rc.cfg.PostRestore.TiFlashReplica == config.TiFlashReplicaWait {
+		rc.display.setPhase(t.tableName, phaseTiFlashSync)
+		err := t.waitTiFlashReplica(ctx, rc)
+		rc.display.setPhase(t.tableName, phasePostProcessing)
+		if err != nil {
+			common.AppLogger.Errorf("[%s] wait for TiFlash replicas failed: %v", t.tableName, err.Error())
+			return errors.Trace(err)
+		}
 	}
 
-	// 5. do table analyze
+	// 8. do table analyze
 	if cp.Status < CheckpointStatusAnalyzed {
-		if !rc.cfg.PostRestore.Analyze {
-			common.AppLogger.Infof("[%s] Skip analyze.", t.tableName)
+		if reason := t.analyzeSkipReason(rc.cfg, cp); reason != "" {
+			common.AppLogger.Infof("[%s] Skip analyze, %s.", t.tableName, reason)
 			rc.saveStatusCheckpoint(t.tableName, -1, nil, CheckpointStatusAnalyzeSkipped)
 		} else {
-			err := t.analyzeTable(ctx, rc.tidbMgr.db)
+			err := t.analyzeTable(ctx, rc.tidbMgr.glue)
 			rc.saveStatusCheckpoint(t.tableName, -1, err, CheckpointStatusAnalyzed)
 			if err != nil {
 				common.AppLogger.Errorf("[%s] analyze failed: %v", t.tableName, err.Error())
@@ -759,7 +1618,11 @@ func (rc *RestoreController) fullCompact(ctx context.Context) error {
 }
 
 func (rc *RestoreController) doCompact(ctx context.Context, level int32) error {
-	return errors.Trace(rc.importer.Compact(ctx, level))
+	b, ok := rc.backend.(backend.ClusterBackend)
+	if !ok {
+		return nil
+	}
+	return errors.Trace(b.Compact(ctx, level))
 }
 
 func (rc *RestoreController) switchToImportMode(ctx context.Context) {
@@ -772,7 +1635,11 @@ func (rc *RestoreController) switchToNormalMode(ctx context.Context) error {
 }
 
 func (rc *RestoreController) switchTiKVMode(ctx context.Context, mode sstpb.SwitchMode) {
-	if err := rc.importer.SwitchMode(ctx, mode); err != nil {
+	b, ok := rc.backend.(backend.ClusterBackend)
+	if !ok {
+		return
+	}
+	if err := b.SwitchMode(ctx, mode); err != nil {
 		common.AppLogger.Warnf("cannot switch to %s mode: %v", mode.String(), err)
 	}
 }
@@ -783,7 +1650,7 @@ func (rc *RestoreController) checkRequirements(_ context.Context) error {
 		return nil
 	}
 
-	client := &http.Client{}
+	client := common.NewHTTPClient(0)
 	if err := rc.checkTiDBVersion(client); err != nil {
 		return errors.Trace(err)
 	}
@@ -820,7 +1687,7 @@ func extractTiDBVersion(version string) (*semver.Version, error) {
 }
 
 func (rc *RestoreController) checkTiDBVersion(client *http.Client) error {
-	url := fmt.Sprintf("http://%s:%d/status", rc.cfg.TiDB.Host, rc.cfg.TiDB.StatusPort)
+	url := fmt.Sprintf("http://%s/status", common.JoinHostPort(rc.cfg.TiDB.Host, rc.cfg.TiDB.StatusPort))
 	var status struct{ Version string }
 	err := common.GetJSON(client, url, &status)
 	if err != nil {
@@ -900,35 +1767,194 @@ func (rc *RestoreController) cleanCheckpoints(ctx context.Context) error {
 	}
 	timer := time.Now()
 	err := rc.checkpointsDB.RemoveCheckpoint(ctx, "all")
+	// the checkpoint tables should not be left behind in the target.
+	if cpdb, ok := rc.checkpointsDB.(*MySQLCheckpointsDB); ok && err == nil && rc.cfg.CheckpointsInTarget() {
+		err = cpdb.DropIfEmpty(ctx)
+	}
 	common.AppLogger.Infof("clean checkpoints takes %v", time.Since(timer))
 	return errors.Trace(err)
 }
 
+// checkCheckpointsInTarget refuses to store the checkpoints in the target
+// TiDB if the checkpoint schema is also imported, since the import would
+// overwrite the checkpoints, and the checkpoint writes would break the
+// checksum of the imported tables.
+func checkCheckpointsInTarget(cfg *config.Config, dbMetas []*mydump.MDDatabaseMeta) error {
+	if !cfg.CheckpointsInTarget() {
+		return nil
+	}
+	for _, dbMeta := range dbMetas {
+		if strings.EqualFold(dbMeta.Name, cfg.Checkpoint.Schema) {
+			return common.ErrInvalidConfig.Errorf(
+				"checkpoint.schema %q is stored in the target TiDB and also imported from the data source, choose another schema",
+				cfg.Checkpoint.Schema,
+			)
+		}
+	}
+	return nil
+}
+
 type chunkRestore struct {
-	parser *mydump.ChunkParser
+	parser mydump.Parser
+	file   mydump.DataFile
+	// digest verifies the data file against the checksum manifest, or is
+	// nil if there is no manifest.
+	digest *mydump.DigestReader
 	index  int
 	chunk  *ChunkCheckpoint
+	// logger carries the table, the engine and the position of the chunk.
+	logger *log.Entry
 }
 
-func newChunkRestore(index int, chunk *ChunkCheckpoint, blockBufSize int64, ioWorkers *worker.Pool) (*chunkRestore, error) {
-	reader, err := os.Open(chunk.Key.Path)
+func newChunkRestore(
+	index int,
+	chunk *ChunkCheckpoint,
+	path string,
+	blockBufSize int64,
+	readAhead int64,
+	sqlMode mysql.SQLMode,
+	columnNames []string,
+	fixedWidth *config.FixedWidthRule,
+	projection *config.ProjectionRule,
+	filter *config.FilterRule,
+	transforms []*config.TransformRule,
+	digest []byte,
+	ioWorkers *worker.Pool,
+) (*chunkRestore, error) {
+	file, err := mydump.OpenDataFile(path)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	parser := mydump.NewChunkParser(reader, blockBufSize, ioWorkers)
+	if readAhead > 0 {
+		file = mydump.NewPrefetchReader(file, blockBufSize, readAhead)
+	}
+
+	var reader io.Reader = file
+	var digestReader *mydump.DigestReader
+	if digest != nil {
+		digestReader = mydump.NewDigestReader(file, path, digest)
+		reader = digestReader
+	}
+
+	sourceReader := mydump.LookupSourceReader(path)
+	if sourceReader == nil {
+		file.Close()
+		return nil, errors.Errorf("unknown format of data file %s", path)
+	}
+	parser, err := sourceReader.NewParser(reader, &mydump.SourceOptions{
+		Path:         path,
+		BlockBufSize: blockBufSize,
+		SQLMode:      sqlMode,
+		ColumnNames:  columnNames,
+		FixedWidth:   fixedWidth,
+		IOWorkers:    ioWorkers,
+	})
+	if err != nil {
+		file.Close()
+		return nil, errors.Trace(err)
+	}
 
-	reader.Seek(chunk.Chunk.Offset, io.SeekStart)
+	// parsers which are not seekable skip to the offset by themselves.
+	if chunk.Chunk.Offset > 0 && sourceReader.Seekable() {
+		if digestReader != nil {
+			// the skipped part is needed to compute the digest.
+			err = digestReader.Skip(chunk.Chunk.Offset)
+		} else {
+			_, err = file.Seek(chunk.Chunk.Offset, io.SeekStart)
+		}
+		if err != nil {
+			file.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	if projection != nil {
+		projectionParser := mydump.NewProjectionParser(parser, projection)
+		projectionParser.SetSQLMode(sqlMode)
+		parser = projectionParser
+	}
+	if filter != nil {
+		filterParser, err := mydump.NewFilterParser(parser, filter, columnNames)
+		if err != nil {
+			file.Close()
+			return nil, common.ErrInvalidConfig.Wrap(err)
+		}
+		filterParser.SetSQLMode(sqlMode)
+		parser = filterParser
+	}
+	if len(transforms) > 0 {
+		transformParser := mydump.NewTransformParser(parser, transforms, columnNames)
+		transformParser.SetSQLMode(sqlMode)
+		parser = transformParser
+	}
 	parser.SetPos(chunk.Chunk.Offset, chunk.Chunk.PrevRowIDMax)
 
 	return &chunkRestore{
 		parser: parser,
+		file:   file,
+		digest: digestReader,
 		index:  index,
 		chunk:  chunk,
+		logger: chunkLogger(log.NewEntry(common.AppLogger), chunk),
 	}, nil
 }
 
+// chunkLogger binds the position of the chunk to the logger, so the history
+// of a chunk can be found by grepping `path=... offset=...`.
+func chunkLogger(logger *log.Entry, chunk *ChunkCheckpoint) *log.Entry {
+	return logger.WithFields(log.Fields{
+		"path":   chunk.Key.Path,
+		"offset": chunk.Key.Offset,
+	})
+}
+
 func (cr *chunkRestore) close() {
-	cr.parser.Reader().(*os.File).Close()
+	cr.file.Close()
+}
+
+// checkKVSize ensures no KV pair exceeds the size TiKV can accept.
+func checkKVSize(kvs []kvenc.KvPair, maxKVSize int64) error {
+	for _, pair := range kvs {
+		if size := int64(len(pair.Key) + len(pair.Val)); size > maxKVSize {
+			return common.ErrEncodeKV.Errorf("KV pair of %d bytes exceeds tikv-importer.max-kv-size (%d bytes)", size, maxKVSize)
+		}
+	}
+	return nil
+}
+
+// insertStatement returns the start of the statement encoding the rows with
+// the duplicate policy.
+func insertStatement(onDuplicate string) string {
+	switch onDuplicate {
+	case config.DupeReplace:
+		return " REPLACE INTO "
+	case config.DupeIgnore:
+		return " INSERT IGNORE INTO "
+	default:
+		return " INSERT INTO "
+	}
+}
+
+// appendLastRow appends the row just read by the parser to the INSERT
+// statement in the buffer, starting the statement if the buffer is empty.
+func (cr *chunkRestore) appendLastRow(t *TableRestore, buffer *bytes.Buffer, onDuplicate string) {
+	if buffer.Len() == 0 {
+		buffer.WriteString(insertStatement(onDuplicate))
+		buffer.WriteString(t.tableName)
+		if cr.chunk.Columns == nil {
+			t.initializeColumns(cr.parser.Columns(), cr.chunk)
+		}
+		buffer.Write(cr.chunk.Columns)
+		buffer.WriteString(" VALUES ")
+	} else {
+		buffer.WriteByte(',')
+	}
+	lastRow := cr.parser.LastRow()
+	if cr.chunk.ShouldIncludeRowID {
+		buffer.Write(lastRow.Row[:len(lastRow.Row)-1])
+		fmt.Fprintf(buffer, ",%d)", lastRow.RowID)
+	} else {
+		buffer.Write(lastRow.Row)
+	}
 }
 
 type TableRestore struct {
@@ -938,7 +1964,9 @@ type TableRestore struct {
 	tableInfo *TidbTableInfo
 	tableMeta *mydump.MDTableMeta
 	encoder   kvenc.KvEncoder
+	encoders  *encoderPool
 	alloc     autoid.Allocator
+	timing    *tableTiming
 }
 
 func NewTableRestore(
@@ -947,6 +1975,7 @@ func NewTableRestore(
 	dbInfo *TidbDBInfo,
 	tableInfo *TidbTableInfo,
 	cp *TableCheckpoint,
+	sqlMode string,
 ) (*TableRestore, error) {
 	idAlloc := kv.NewPanickingAllocator(cp.AllocBase)
 	encoder, err := kvenc.New(dbInfo.Name, idAlloc)
@@ -965,22 +1994,30 @@ func NewTableRestore(
 		tableInfo: tableInfo,
 		tableMeta: tableMeta,
 		encoder:   encoder,
-		alloc:     idAlloc,
+		encoders: newEncoderPool(func() (*kv.TableKVEncoder, error) {
+			return kv.NewTableKVEncoder(dbInfo.Name, tableInfo.Name, tableInfo.ID, sqlMode, idAlloc)
+		}),
+		alloc: idAlloc,
 	}, nil
 }
 
 func (tr *TableRestore) Close() {
+	tr.encoders.close()
 	tr.encoder.Close()
 	common.AppLogger.Infof("[%s] restore done", tr.tableName)
 }
 
 var tidbRowIDColumnRegex = regexp.MustCompile(fmt.Sprintf("`%[1]s`|(?i:\\b%[1]s\\b)", model.ExtraHandleName))
 
-func (t *TableRestore) populateChunks(cfg *config.Config, cp *TableCheckpoint) error {
+// populateChunks splits the data files into engines and chunks. If the ratio
+// between the KV size and the source size has been sampled, the batch size
+// applies to the estimated KV size of each engine.
+func (t *TableRestore) populateChunks(cfg *config.Config, cp *TableCheckpoint, kvSizeRatio float64) error {
 	common.AppLogger.Infof("[%s] load chunks", t.tableName)
 	timer := time.Now()
 
-	chunks, err := mydump.MakeTableRegions(t.tableMeta, t.tableInfo.Columns, cfg.Mydumper.BatchSize, cfg.Mydumper.BatchImportRatio, cfg.App.TableConcurrency)
+	batchSize := kvBatchSize(cfg.Mydumper.BatchSize, kvSizeRatio)
+	chunks, err := mydump.MakeTableRegions(t.tableMeta, t.tableInfo.Columns, batchSize, cfg.Mydumper.BatchImportRatio, cfg.App.TableConcurrency)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -991,18 +2028,94 @@ func (t *TableRestore) populateChunks(cfg *config.Config, cp *TableCheckpoint) e
 		}
 		cp.Engines[chunk.EngineID].Chunks = append(cp.Engines[chunk.EngineID].Chunks, &ChunkCheckpoint{
 			Key: ChunkCheckpointKey{
-				Path:   chunk.File,
+				Path:   relativeDataFilePath(cfg.Mydumper.SourceDir, chunk.File),
 				Offset: chunk.Chunk.Offset,
 			},
-			Columns: nil,
-			Chunk:   chunk.Chunk,
+			Columns:     nil,
+			Chunk:       chunk.Chunk,
+			FileSize:    chunk.FileSize,
+			FileModTime: chunk.FileModTime,
 		})
 	}
 
 	common.AppLogger.Infof("[%s] load %d engines and %d chunks takes %v", t.tableName, len(cp.Engines), len(chunks), time.Since(timer))
+	if len(cp.Engines) > 1 {
+		common.AppLogger.Infof("[%s] engine batch sizes (bytes of source data): %s", t.tableName, engineBatchSizes(cp.Engines, 1))
+	}
+	if kvSizeRatio > 0 {
+		// the importer keeps the KV pairs of an engine on disk until it is
+		// imported and cleaned up.
+		common.AppLogger.Infof("[%s] estimated importer disk usage %d bytes, engine KV sizes: %s",
+			t.tableName, int64(float64(cp.sourceSize())*kvSizeRatio), engineBatchSizes(cp.Engines, kvSizeRatio))
+	}
 	return nil
 }
 
+// relativeDataFilePath returns the path of the data file relative to the data
+// source directory, which is stored in the checkpoints so that the task can be
+// resumed on another machine mounting the same dump elsewhere.
+func relativeDataFilePath(sourceDir string, path string) string {
+	if rel, err := filepath.Rel(sourceDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// resolveDataFilePath returns the path to open the data file in a checkpoint.
+// Checkpoints created by older versions store the full path.
+func resolveDataFilePath(sourceDir string, path string) string {
+	if filepath.IsAbs(path) || mydump.IsRemotePath(path) {
+		return path
+	}
+	return mydump.JoinPath(sourceDir, path)
+}
+
+// engineBatchSizes summarizes the size of each engine batch for logging, to
+// verify the non-uniform schedule computed from `batch-import-ratio`. The
+// source sizes are multiplied by the ratio.
+func engineBatchSizes(engines []*EngineCheckpoint, ratio float64) string {
+	var buf strings.Builder
+	for engineID, engine := range engines {
+		size := int64(0)
+		for _, chunk := range engine.Chunks {
+			size += chunk.Chunk.EndOffset - chunk.Chunk.Offset
+		}
+		if engineID > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%d:%d", engineID, int64(float64(size)*ratio))
+	}
+	return buf.String()
+}
+
+// assignableColumns returns the names of the columns which a data file may
+// provide values for.
+func (t *TableRestore) assignableColumns() []string {
+	names := make([]string, 0, len(t.tableInfo.core.Columns))
+	for _, columnInfo := range t.tableInfo.core.Columns {
+		if columnInfo.IsGenerated() {
+			continue
+		}
+		names = append(names, columnInfo.Name.O)
+	}
+	return names
+}
+
+// transformRules returns the configured transforms of the table's columns,
+// plus those importing the columns of unsupported types as NULL.
+func (t *TableRestore) transformRules(cfg *config.MydumperRuntime) []*config.TransformRule {
+	rules := cfg.FindTransformRules(t.tableMeta.DB, t.tableMeta.Name)
+	for _, column := range t.tableInfo.nullColumns {
+		rules = append(rules, &config.TransformRule{
+			Schema: t.tableMeta.DB,
+			Table:  t.tableMeta.Name,
+			Column: column,
+			Expr:   "NULL",
+		})
+	}
+	return rules
+}
+
 func (t *TableRestore) initializeColumns(columns []byte, ccp *ChunkCheckpoint) {
 	shouldIncludeRowID := !t.tableInfo.core.PKIsHandle && !tidbRowIDColumnRegex.Match(columns)
 	if shouldIncludeRowID {
@@ -1029,10 +2142,10 @@ func (t *TableRestore) initializeColumns(columns []byte, ccp *ChunkCheckpoint) {
 	ccp.ShouldIncludeRowID = shouldIncludeRowID
 }
 
-func (tr *TableRestore) restoreTableMeta(ctx context.Context, db *sql.DB) error {
+func (tr *TableRestore) restoreTableMeta(ctx context.Context, g glue.Glue) error {
 	timer := time.Now()
 
-	err := AlterAutoIncrement(ctx, db, tr.tableMeta.DB, tr.tableMeta.Name, tr.alloc.Base()+1)
+	err := AlterAutoIncrement(ctx, g, tr.tableMeta.DB, tr.tableMeta.Name, tr.alloc.Base()+1)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -1040,39 +2153,48 @@ func (tr *TableRestore) restoreTableMeta(ctx context.Context, db *sql.DB) error
 	return nil
 }
 
-func (tr *TableRestore) importKV(ctx context.Context, closedEngine *kv.ClosedEngine) error {
-	common.AppLogger.Infof("[%s] flush kv deliver ...", tr.tableName)
+func (tr *TableRestore) importKV(ctx context.Context, b backend.Backend, engineID int, logger *log.Entry) error {
+	logger.Info("flush kv deliver ...")
 
 	start := time.Now()
 
-	err := closedEngine.Import(ctx)
+	err := b.ImportEngine(ctx, tr.tableName, engineID)
 	if err != nil {
 		if !common.IsContextCanceledError(err) {
-			common.AppLogger.Errorf("[%s] failed to flush kvs : %s", tr.tableName, err.Error())
+			logger.Errorf("failed to flush kvs : %s", err.Error())
 		}
 		return errors.Trace(err)
 	}
-	closedEngine.Cleanup(ctx)
+	if err := b.CleanupEngine(ctx, tr.tableName, engineID); err != nil {
+		logger.Warnf("failed to clean up engine: %v", err)
+	}
 
 	dur := time.Since(start)
+	tr.timing.add(stepImport, dur)
 	metric.ImportSecondsHistogram.Observe(dur.Seconds())
-	common.AppLogger.Infof("[%s] kv deliver all flushed, takes %v", tr.tableName, dur)
+	logger.Infof("kv deliver all flushed, takes %v", dur)
 
 	return nil
 }
 
 // do checksum for each table.
-func (tr *TableRestore) compareChecksum(ctx context.Context, db *sql.DB, cp *TableCheckpoint) error {
-	var localChecksum verify.KVChecksum
-	for _, engine := range cp.Engines {
-		for _, chunk := range engine.Chunks {
-			localChecksum.Add(&chunk.Checksum)
-		}
-	}
+func (tr *TableRestore) compareChecksum(ctx context.Context, rc *RestoreController, cp *TableCheckpoint) error {
+	localChecksum := cp.localChecksum()
 
 	start := time.Now()
-	remoteChecksum, err := DoChecksum(ctx, db, tr.tableName)
+	if err := rc.gcLifeTime.addRef(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	var remoteChecksum *RemoteChecksum
+	var err error
+	if rc.coprChecksum != nil {
+		remoteChecksum, err = rc.coprChecksum.checksum(ctx, tr.tableName, tr.tableInfo)
+	} else {
+		remoteChecksum, err = DoChecksum(ctx, rc.tidbMgr.glue, tr.tableName)
+	}
+	rc.gcLifeTime.removeRef()
 	dur := time.Since(start)
+	tr.timing.add(stepChecksum, dur)
 	metric.ChecksumSecondsHistogram.Observe(dur.Seconds())
 	if err != nil {
 		return errors.Trace(err)
@@ -1081,7 +2203,7 @@ func (tr *TableRestore) compareChecksum(ctx context.Context, db *sql.DB, cp *Tab
 	if remoteChecksum.Checksum != localChecksum.Sum() ||
 		remoteChecksum.TotalKVs != localChecksum.SumKVS() ||
 		remoteChecksum.TotalBytes != localChecksum.SumSize() {
-		return errors.Errorf("checksum mismatched remote vs local => (checksum: %d vs %d) (total_kvs: %d vs %d) (total_bytes:%d vs %d)",
+		return common.ErrChecksumMismatch.Errorf("checksum mismatched remote vs local => (checksum: %d vs %d) (total_kvs: %d vs %d) (total_bytes:%d vs %d)",
 			remoteChecksum.Checksum, localChecksum.Sum(),
 			remoteChecksum.TotalKVs, localChecksum.SumKVS(),
 			remoteChecksum.TotalBytes, localChecksum.SumSize(),
@@ -1092,14 +2214,45 @@ func (tr *TableRestore) compareChecksum(ctx context.Context, db *sql.DB, cp *Tab
 	return nil
 }
 
-func (tr *TableRestore) analyzeTable(ctx context.Context, db *sql.DB) error {
+// systemSchemas are the schemas never analyzed, even if the dump contains them.
+var systemSchemas = map[string]struct{}{
+	"mysql":              {},
+	"information_schema": {},
+	"performance_schema": {},
+	"metrics_schema":     {},
+}
+
+func isSystemSchema(schema string) bool {
+	_, ok := systemSchemas[strings.ToLower(schema)]
+	return ok
+}
+
+// analyzeSkipReason returns why the table should not be analyzed, or an empty
+// string if it should be.
+func (tr *TableRestore) analyzeSkipReason(cfg *config.Config, cp *TableCheckpoint) string {
+	localChecksum := cp.localChecksum()
+	switch {
+	case !cfg.PostRestore.Analyze:
+		return "disabled by post-restore.analyze"
+	case isSystemSchema(tr.tableMeta.DB):
+		return "system table"
+	case localChecksum.SumKVS() == 0:
+		// nothing is imported, so the statistics are unchanged.
+		return "no data imported"
+	default:
+		return ""
+	}
+}
+
+func (tr *TableRestore) analyzeTable(ctx context.Context, g glue.Glue) error {
 	timer := time.Now()
 	common.AppLogger.Infof("[%s] analyze", tr.tableName)
 	query := fmt.Sprintf("ANALYZE TABLE %s", tr.tableName)
-	err := common.ExecWithRetry(ctx, db, query, query)
+	err := g.ExecuteWithLog(ctx, query, query)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	tr.timing.add(stepAnalyze, time.Since(timer))
 	common.AppLogger.Infof("[%s] analyze takes %v", tr.tableName, time.Since(timer))
 	return nil
 }
@@ -1117,13 +2270,13 @@ func (c *RemoteChecksum) String() string {
 	return fmt.Sprintf("[%s] remote_checksum=%d, total_kvs=%d, total_bytes=%d", common.UniqueTable(c.Schema, c.Table), c.Checksum, c.TotalKVs, c.TotalBytes)
 }
 
-func setSessionConcurrencyVars(ctx context.Context, db *sql.DB, dsn config.DBStore) {
-	err := common.ExecWithRetry(ctx, db, "(set session concurrency variables)", `SET
+func setSessionConcurrencyVars(ctx context.Context, g glue.Glue, dsn config.DBStore) {
+	err := g.ExecuteWithLog(ctx, `SET
 		SESSION tidb_build_stats_concurrency = ?,
 		SESSION tidb_distsql_scan_concurrency = ?,
 		SESSION tidb_index_serial_scan_concurrency = ?,
 		SESSION tidb_checksum_table_concurrency = ?;
-	`, dsn.BuildStatsConcurrency, dsn.DistSQLScanConcurrency, dsn.IndexSerialScanConcurrency, dsn.ChecksumTableConcurrency)
+	`, "(set session concurrency variables)", dsn.BuildStatsConcurrency, dsn.DistSQLScanConcurrency, dsn.IndexSerialScanConcurrency, dsn.ChecksumTableConcurrency)
 	if err != nil {
 		common.AppLogger.Warnf("failed to set session concurrency variables: %s", err.Error())
 	}
@@ -1131,21 +2284,11 @@ func setSessionConcurrencyVars(ctx context.Context, db *sql.DB, dsn config.DBSto
 
 // DoChecksum do checksum for tables.
 // table should be in <db>.<table>, format.  e.g. foo.bar
-func DoChecksum(ctx context.Context, db *sql.DB, table string) (*RemoteChecksum, error) {
+// The tikv_gc_life_time should be long enough for the checksum, see
+// gcLifeTimeManager.
+func DoChecksum(ctx context.Context, g glue.Glue, table string) (*RemoteChecksum, error) {
 	timer := time.Now()
 
-	ori, err := increaseGCLifeTime(ctx, db)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	// set it back finally
-	defer func() {
-		err = UpdateGCLifeTime(ctx, db, ori)
-		if err != nil && !common.IsContextCanceledError(err) {
-			common.AppLogger.Errorf("[%s] update tikv_gc_life_time error %v", table, errors.ErrorStack(err))
-		}
-	}()
-
 	// ADMIN CHECKSUM TABLE <table>,<table>  example.
 	// 	mysql> admin checksum table test.t;
 	// +---------+------------+---------------------+-----------+-------------+
@@ -1157,7 +2300,7 @@ func DoChecksum(ctx context.Context, db *sql.DB, table string) (*RemoteChecksum,
 	cs := RemoteChecksum{}
 	common.AppLogger.Infof("[%s] doing remote checksum", table)
 	query := fmt.Sprintf("ADMIN CHECKSUM TABLE %s", table)
-	err = common.QueryRowWithRetry(ctx, db, query, &cs.Schema, &cs.Table, &cs.Checksum, &cs.TotalKVs, &cs.TotalBytes)
+	err := common.QueryRowWithRetry(ctx, g.GetDB(), query, &cs.Schema, &cs.Table, &cs.Checksum, &cs.TotalKVs, &cs.TotalBytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -1166,37 +2309,6 @@ func DoChecksum(ctx context.Context, db *sql.DB, table string) (*RemoteChecksum,
 	return &cs, nil
 }
 
-func increaseGCLifeTime(ctx context.Context, db *sql.DB) (oriGCLifeTime string, err error) {
-	// checksum command usually takes a long time to execute,
-	// so here need to increase the gcLifeTime for single transaction.
-	oriGCLifeTime, err = ObtainGCLifeTime(ctx, db)
-	if err != nil {
-		return "", errors.Trace(err)
-	}
-
-	var increaseGCLifeTime bool
-	if oriGCLifeTime != "" {
-		ori, err := time.ParseDuration(oriGCLifeTime)
-		if err != nil {
-			return "", errors.Trace(err)
-		}
-		if ori < defaultGCLifeTime {
-			increaseGCLifeTime = true
-		}
-	} else {
-		increaseGCLifeTime = true
-	}
-
-	if increaseGCLifeTime {
-		err = UpdateGCLifeTime(ctx, db, defaultGCLifeTime.String())
-		if err != nil {
-			return "", errors.Trace(err)
-		}
-	}
-
-	return oriGCLifeTime, nil
-}
-
 ////////////////////////////////////////////////////////////////
 
 const (
@@ -1226,25 +2338,24 @@ func (cr *chunkRestore) restore(
 	ctx context.Context,
 	t *TableRestore,
 	engineID int,
-	engine *kv.OpenedEngine,
+	delivery *engineDelivery,
 	rc *RestoreController,
-) error {
-	// Create the encoder.
-	kvEncoder, err := kv.NewTableKVEncoder(
-		t.dbInfo.Name,
-		t.tableInfo.Name,
-		t.tableInfo.ID,
-		rc.cfg.TiDB.SQLMode,
-		t.alloc,
-	)
+) (err error) {
+	tag := fmt.Sprintf("%s:%d", t.tableName, engineID)
+
+	// Take an encoder from the pool of the table, and only return it after
+	// the KV pairs are all delivered.
+	kvEncoder, err := t.encoders.get()
 	if err != nil {
 		return errors.Trace(err)
 	}
 	defer func() {
-		closeErr := kvEncoder.Close()
-		kvEncoder = nil
-		if closeErr != nil {
-			common.AppLogger.Errorf("restore chunk task err %v", errors.ErrorStack(closeErr))
+		if err == nil {
+			t.encoders.put(kvEncoder)
+			return
+		}
+		if closeErr := kvEncoder.Close(); closeErr != nil {
+			cr.logger.Errorf("restore chunk task err %v", errors.ErrorStack(closeErr))
 		}
 	}()
 
@@ -1252,6 +2363,7 @@ func (cr *chunkRestore) restore(
 	readTotalDur := time.Duration(0)
 	encodeTotalDur := time.Duration(0)
 	deliverTotalDur := time.Duration(0)
+	waitTotalDur := time.Duration(0)
 
 	var block struct {
 		cond            *sync.Cond
@@ -1260,9 +2372,24 @@ func (cr *chunkRestore) restore(
 		localChecksum   verify.KVChecksum
 		chunkOffset     int64
 		chunkRowID      int64
+		// deliverStopped is set when the deliverer has failed, so the encoder
+		// must not wait for it to consume the KV pairs.
+		deliverStopped bool
 	}
 	block.cond = sync.NewCond(new(sync.Mutex))
 	deliverCompleteCh := make(chan error, 1)
+	defer func() {
+		// stop the deliverer if the encoding is aborted. the KV pairs not yet
+		// delivered are dropped, and written again from the checkpoint.
+		block.cond.L.Lock()
+		if !block.encodeCompleted {
+			block.encodeCompleted = true
+			metric.DeliverQueueKVsGauge.Sub(float64(len(block.totalKVs)))
+			block.totalKVs = nil
+			block.cond.Signal()
+		}
+		block.cond.L.Unlock()
+	}()
 
 	go func() {
 		for {
@@ -1271,8 +2398,11 @@ func (cr *chunkRestore) restore(
 				block.cond.Wait()
 			}
 			b := block
+			metric.DeliverQueueKVsGauge.Sub(float64(len(b.totalKVs)))
 			block.totalKVs = nil
 			block.localChecksum = verify.MakeKVChecksum(0, 0, 0)
+			// the encoder may fill the next block while this one is delivered.
+			block.cond.Signal()
 			block.cond.L.Unlock()
 
 			if b.encodeCompleted && len(b.totalKVs) == 0 {
@@ -1282,41 +2412,30 @@ func (cr *chunkRestore) restore(
 
 			// kv -> deliver ( -> tikv )
 			start := time.Now()
-			stream, err := engine.NewWriteStream(ctx)
-			if err != nil {
-				deliverCompleteCh <- errors.Trace(err)
-				return
-			}
-
-			for _, kvs := range splitIntoDeliveryStreams(b.totalKVs, maxDeliverBytes) {
-				if e := stream.Put(kvs); e != nil {
-					if err != nil {
-						common.AppLogger.Warnf("failed to put write stream: %s", e.Error())
-					} else {
-						err = e
-					}
-				}
+			r := deliveredRange{key: cr.chunk.Key, start: cr.chunk.Chunk.Offset, end: b.chunkOffset}
+			err := deliverKVs(ctx, rc.backend, t.tableName, engineID, b.totalKVs, rc.watchdog, tag, cr.logger)
+			if common.IsUnavailableError(err) {
+				err = redeliverKVs(ctx, rc.backend, t.tableName, engineID, r, b.totalKVs, err, rc.watchdog, tag, cr.logger)
 			}
 			b.totalKVs = nil
-
-			block.cond.Signal()
-			if e := stream.Close(); e != nil {
-				if err != nil {
-					common.AppLogger.Warnf("[%s:%d] failed to close write stream: %s", t.tableName, engineID, e.Error())
-				} else {
-					err = e
-				}
+			if err == nil {
+				delivery.ack(&b.localChecksum)
+				rc.watchdog.progress(tag, "delivered")
 			}
 			deliverDur := time.Since(start)
 			deliverTotalDur += deliverDur
+			t.timing.add(stepDeliver, deliverDur)
 			metric.BlockDeliverSecondsHistogram.Observe(deliverDur.Seconds())
 			metric.BlockDeliverBytesHistogram.Observe(float64(b.localChecksum.SumSize()))
 
 			if err != nil {
 				if !common.IsContextCanceledError(err) {
-					common.AppLogger.Errorf("[%s:%d] kv deliver failed = %v", t.tableName, engineID, err)
+					cr.logger.Errorf("kv deliver failed = %v", err)
 				}
-				// TODO : retry ~
+				block.cond.L.Lock()
+				block.deliverStopped = true
+				block.cond.Signal()
+				block.cond.L.Unlock()
 				deliverCompleteCh <- errors.Trace(err)
 				return
 			}
@@ -1350,6 +2469,10 @@ func (cr *chunkRestore) restore(
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case err := <-deliverCompleteCh:
+			// the deliverer only completes early when it has failed, so there
+			// is no point in encoding the rest of the chunk.
+			return errors.Trace(err)
 		default:
 		}
 
@@ -1360,47 +2483,31 @@ func (cr *chunkRestore) restore(
 
 		buffer.Reset()
 		start := time.Now()
+		blockStartOffset := cr.parser.Pos()
 
-		var sep byte = ' '
 	readLoop:
 		for cr.parser.Pos() < endOffset {
 			readRowStartTime := time.Now()
 			err := cr.parser.ReadRow()
 			switch errors.Cause(err) {
 			case nil:
-				buffer.WriteByte(sep)
-				if sep == ' ' {
-					buffer.WriteString("INSERT INTO ")
-					buffer.WriteString(t.tableName)
-					if cr.chunk.Columns == nil {
-						t.initializeColumns(cr.parser.Columns, cr.chunk)
-					}
-					buffer.Write(cr.chunk.Columns)
-					buffer.WriteString(" VALUES ")
-					sep = ','
-				}
 				metric.ChunkParserReadRowSecondsHistogram.Observe(time.Since(readRowStartTime).Seconds())
-				lastRow := cr.parser.LastRow()
-				if cr.chunk.ShouldIncludeRowID {
-					buffer.Write(lastRow.Row[:len(lastRow.Row)-1])
-					fmt.Fprintf(&buffer, ",%d)", lastRow.RowID)
-				} else {
-					buffer.Write(lastRow.Row)
-				}
+				cr.appendLastRow(t, &buffer, rc.cfg.Mydumper.OnDuplicate)
 			case io.EOF:
 				cr.chunk.Chunk.EndOffset = cr.parser.Pos()
 				break readLoop
 			default:
-				return errors.Trace(err)
+				return common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", cr.chunk.Key.Path, cr.parser.Pos())
 			}
 		}
-		if sep != ',' { // quick and dirty way to check if `buffer` actually contained any values
+		if buffer.Len() == 0 {
 			continue
 		}
 		buffer.WriteByte(';')
 
 		readDur := time.Since(start)
 		readTotalDur += readDur
+		t.timing.add(stepRead, readDur)
 		metric.BlockReadSecondsHistogram.Observe(readDur.Seconds())
 		metric.BlockReadBytesHistogram.Observe(float64(buffer.Len()))
 
@@ -1409,23 +2516,45 @@ func (cr *chunkRestore) restore(
 		kvs, _, err := kvEncoder.SQL2KV(buffer.String())
 		encodeDur := time.Since(start)
 		encodeTotalDur += encodeDur
+		t.timing.add(stepEncode, encodeDur)
 		metric.BlockEncodeSecondsHistogram.Observe(encodeDur.Seconds())
 
-		common.AppLogger.Debugf("len(kvs) %d, len(sql) %d", len(kvs), buffer.Len())
+		cr.logger.Debugf("len(kvs) %d, len(sql) %d", len(kvs), buffer.Len())
+		if err == nil {
+			err = checkKVSize(kvs, rc.cfg.TikvImporter.MaxKVSize)
+		}
 		if err != nil {
-			common.AppLogger.Errorf("kv encode failed = %s\n", err.Error())
-			return errors.Trace(err)
+			msg := common.RedactValues(err.Error())
+			cr.logger.Errorf("kv encode failed in [%d, %d) = %s", blockStartOffset, cr.parser.Pos(), msg)
+			if common.RedactInfoLog {
+				return common.ErrEncodeKV.Errorf("failed to encode %s [%d, %d): %s", cr.chunk.Key.Path, blockStartOffset, cr.parser.Pos(), msg)
+			}
+			return common.ErrEncodeKV.Annotatef(err, "failed to encode %s [%d, %d)", cr.chunk.Key.Path, blockStartOffset, cr.parser.Pos())
 		}
 
 		block.cond.L.Lock()
-		for len(block.totalKVs) > len(kvs)*maxKVQueueSize {
+		start = time.Now()
+		var blockedWarn *time.Timer
+		for len(block.totalKVs) > len(kvs)*maxKVQueueSize && !block.deliverStopped {
 			// ^ hack to create a back-pressure preventing sending too many KV pairs at once
 			// this happens when delivery is slower than encoding.
 			// note that the KV pairs will retain the memory buffer backing the KV encoder
 			// and thus blow up the memory usage and will easily cause lightning to go OOM.
+			if blockedWarn == nil && rc.cfg.Watchdog.DeliverBlockedWarn.Duration > 0 {
+				blockedWarn = time.AfterFunc(rc.cfg.Watchdog.DeliverBlockedWarn.Duration, func() {
+					cr.logger.Warnf("encoding blocked for over %v waiting for the KV pairs to be delivered, the importer is the bottleneck", rc.cfg.Watchdog.DeliverBlockedWarn.Duration)
+				})
+			}
 			block.cond.Wait()
 		}
+		if blockedWarn != nil {
+			blockedWarn.Stop()
+		}
+		waitDur := time.Since(start)
+		waitTotalDur += waitDur
+		metric.BlockDeliverWaitSecondsHistogram.Observe(waitDur.Seconds())
 		block.totalKVs = append(block.totalKVs, kvs...)
+		metric.DeliverQueueKVsGauge.Add(float64(len(kvs)))
 		block.localChecksum.Update(kvs)
 		block.chunkOffset = cr.parser.Pos()
 		block.chunkRowID = cr.parser.LastRow().RowID
@@ -1433,6 +2562,14 @@ func (cr *chunkRestore) restore(
 		block.cond.L.Unlock()
 	}
 
+	// fail the chunk, and thus the engine before it is imported, if the file
+	// does not match the checksum manifest.
+	if cr.digest != nil {
+		if err := cr.digest.Verify(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	block.cond.L.Lock()
 	block.encodeCompleted = true
 	block.cond.Signal()
@@ -1441,10 +2578,9 @@ func (cr *chunkRestore) restore(
 	select {
 	case err := <-deliverCompleteCh:
 		if err == nil {
-			common.AppLogger.Infof(
-				"[%s:%d] restore chunk #%d (%s) takes %v (read: %v, encode: %v, deliver: %v)",
-				t.tableName, engineID, cr.index, &cr.chunk.Key, time.Since(timer),
-				readTotalDur, encodeTotalDur, deliverTotalDur,
+			cr.logger.Infof(
+				"restore chunk #%d takes %v (read: %v, encode: %v, deliver: %v, wait for deliver: %v)",
+				cr.index, time.Since(timer), readTotalDur, encodeTotalDur, deliverTotalDur, waitTotalDur,
 			)
 		}
 		return errors.Trace(err)
@@ -15,7 +15,12 @@ package restore
 
 import (
 	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+	"github.com/pingcap/tidb/util/kvencoder"
 )
 
 var _ = Suite(&restoreSuite{})
@@ -43,7 +48,7 @@ func (s *restoreSuite) TestNewTableRestore(c *C) {
 	for _, tc := range testCases {
 		tableInfo := dbInfo.Tables[tc.name]
 		tableName := common.UniqueTable("mockdb", tableInfo.Name)
-		tr, err := NewTableRestore(tableName, nil, dbInfo, tableInfo, &TableCheckpoint{})
+		tr, err := NewTableRestore(tableName, nil, dbInfo, tableInfo, &TableCheckpoint{}, "")
 		if tc.errRegexp != "" {
 			c.Assert(err, ErrorMatches, tc.errRegexp)
 		} else {
@@ -52,3 +57,100 @@ func (s *restoreSuite) TestNewTableRestore(c *C) {
 		}
 	}
 }
+
+func (s *restoreSuite) TestEngineBatchSizes(c *C) {
+	engines := []*EngineCheckpoint{
+		{Chunks: []*ChunkCheckpoint{
+			{Chunk: mydump.Chunk{Offset: 0, EndOffset: 100}},
+			{Chunk: mydump.Chunk{Offset: 0, EndOffset: 50}},
+		}},
+		{Chunks: []*ChunkCheckpoint{
+			{Chunk: mydump.Chunk{Offset: 0, EndOffset: 300}},
+		}},
+	}
+	c.Assert(engineBatchSizes(engines, 1), Equals, "0:150, 1:300")
+	c.Assert(engineBatchSizes(engines, 2.5), Equals, "0:375, 1:750")
+	c.Assert((&TableCheckpoint{Engines: engines}).sourceSize(), Equals, int64(450))
+}
+
+func (s *restoreSuite) TestKVBatchSize(c *C) {
+	c.Assert(kvBatchSize(1000, 0), Equals, int64(1000))
+	c.Assert(kvBatchSize(1000, 2.5), Equals, int64(400))
+	c.Assert(kvBatchSize(1000, 0.5), Equals, int64(2000))
+	c.Assert(kvBatchSize(1, 10), Equals, int64(1))
+}
+
+func (s *restoreSuite) TestCheckKVSize(c *C) {
+	kvs := []kvenc.KvPair{
+		{Key: []byte("key1"), Val: []byte("small")},
+		{Key: []byte("key2"), Val: make([]byte, 100)},
+	}
+	c.Assert(checkKVSize(kvs, 104), IsNil)
+	err := checkKVSize(kvs, 103)
+	c.Assert(err, ErrorMatches, ".*KV pair of 104 bytes exceeds tikv-importer.max-kv-size \\(103 bytes\\)")
+	c.Assert(common.ErrEncodeKV.Equal(err), IsTrue)
+}
+
+func (s *restoreSuite) TestAnalyzeSkipReason(c *C) {
+	cfg := config.NewConfig()
+	cfg.PostRestore.Analyze = true
+	written := &TableCheckpoint{Engines: []*EngineCheckpoint{{Chunks: []*ChunkCheckpoint{
+		{Checksum: verify.MakeKVChecksum(10, 1, 2)},
+	}}}}
+	empty := &TableCheckpoint{}
+
+	tr := &TableRestore{tableMeta: &mydump.MDTableMeta{DB: "db", Name: "t"}}
+	c.Assert(tr.analyzeSkipReason(cfg, written), Equals, "")
+	c.Assert(tr.analyzeSkipReason(cfg, empty), Equals, "no data imported")
+
+	sysTr := &TableRestore{tableMeta: &mydump.MDTableMeta{DB: "MySQL", Name: "user"}}
+	c.Assert(sysTr.analyzeSkipReason(cfg, written), Equals, "system table")
+
+	cfg.PostRestore.Analyze = false
+	c.Assert(tr.analyzeSkipReason(cfg, written), Equals, "disabled by post-restore.analyze")
+}
+
+func (s *restoreSuite) TestVerifyEngineSize(c *C) {
+	before := verify.MakeKVChecksum(100, 10, 0x1234)
+	after := verify.MakeKVChecksum(300, 25, 0x1234^0x5678)
+	recorded, err := checksumSince(after, before)
+	c.Assert(err, IsNil)
+	c.Assert(recorded, Equals, verify.MakeKVChecksum(200, 15, 0x5678))
+	_, err = checksumSince(before, after)
+	c.Assert(err, ErrorMatches, `cannot remove \{bytes:300 kvs:25 .*\} from \{bytes:100 kvs:10 .*\}`)
+
+	c.Assert(verifyEngineSize(recorded, verify.MakeKVChecksum(200, 15, 0x5678), "t:0"), IsNil)
+	err = verifyEngineSize(recorded, verify.MakeKVChecksum(180, 14, 0x5678), "t:0")
+	c.Assert(err, ErrorMatches, `.*\[t:0\] engine size mismatch before import: checkpoints recorded 15 KV pairs \(200 bytes\) vs backend acknowledged 14 KV pairs \(180 bytes\)`)
+	c.Assert(common.ErrChecksumMismatch.Equal(err), IsTrue)
+}
+
+func (s *restoreSuite) TestQuarantinedChunks(c *C) {
+	qc := quarantinedChunks{chunks: make(map[string]map[ChunkCheckpointKey]quarantinedChunk)}
+	qc.add("`db`.`b`", &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: "db.b.sql"},
+		Chunk: mydump.Chunk{Offset: 10, EndOffset: 50},
+	}, nil)
+	qc.add("`db`.`a`", &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: "db.a.2.sql"},
+		Chunk: mydump.Chunk{Offset: 0, EndOffset: 30},
+	}, errors.New("encode failed"))
+	chunk := &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: "db.a.1.sql"},
+		Chunk: mydump.Chunk{Offset: 5, EndOffset: 20},
+	}
+	// the same chunk is only listed once.
+	qc.add("`db`.`a`", chunk, errors.New("deliver failed"))
+	qc.add("`db`.`a`", chunk, errors.New("deliver failed"))
+
+	c.Assert(qc.list(), DeepEquals, []quarantinedChunk{
+		{tableName: "`db`.`a`", path: "db.a.1.sql", start: 5, end: 20, err: "deliver failed"},
+		{tableName: "`db`.`a`", path: "db.a.2.sql", start: 0, end: 30, err: "encode failed"},
+		{tableName: "`db`.`b`", path: "db.b.sql", start: 10, end: 50},
+	})
+
+	qc.forget("`db`.`a`")
+	c.Assert(qc.list(), DeepEquals, []quarantinedChunk{
+		{tableName: "`db`.`b`", path: "db.b.sql", start: 10, end: 50},
+	})
+}
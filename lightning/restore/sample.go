@@ -0,0 +1,112 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/kv"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	verify "github.com/pingcap/tidb-lightning/lightning/verification"
+)
+
+// maxSampleBytes limits how much source data is read from each table while
+// sampling, so that tables with huge rows do not delay the startup.
+const maxSampleBytes = 4 * 1024 * 1024
+
+// sampleKVSizeRatio encodes the first rows of the table, and returns the ratio
+// between the size of the KV pairs produced and the size of the source data
+// read. Returns 0 if the table has no data.
+func (t *TableRestore) sampleKVSizeRatio(rc *RestoreController, rows int) (float64, error) {
+	if len(t.tableMeta.DataFiles) == 0 {
+		return 0, nil
+	}
+	timer := time.Now()
+
+	path := t.tableMeta.DataFiles[0]
+	size, _, err := mydump.StatDataFile(path)
+	if err != nil {
+		return 0, errors.Annotatef(err, "cannot stat %s", path)
+	}
+	chunk := &ChunkCheckpoint{
+		Key:   ChunkCheckpointKey{Path: path},
+		Chunk: mydump.Chunk{EndOffset: size},
+	}
+	fixedWidth := rc.cfg.Mydumper.FindFixedWidthRule(t.tableMeta.DB, t.tableMeta.Name)
+	projection := rc.cfg.Mydumper.FindProjectionRule(t.tableMeta.DB, t.tableMeta.Name)
+	filter := rc.cfg.Mydumper.FindFilterRule(t.tableMeta.DB, t.tableMeta.Name)
+	transforms := t.transformRules(&rc.cfg.Mydumper)
+	cr, err := newChunkRestore(0, chunk, path, rc.cfg.Mydumper.ReadBlockSize, rc.cfg.Mydumper.ReadAhead, rc.sqlMode, t.assignableColumns(), fixedWidth, projection, filter, transforms, nil, rc.ioWorkers)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer cr.close()
+
+	var buffer bytes.Buffer
+	sampled := 0
+readLoop:
+	for sampled < rows && cr.parser.Pos() < maxSampleBytes {
+		err := cr.parser.ReadRow()
+		switch errors.Cause(err) {
+		case nil:
+			cr.appendLastRow(t, &buffer, rc.cfg.Mydumper.OnDuplicate)
+			sampled++
+		case io.EOF:
+			break readLoop
+		default:
+			return 0, common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", path, cr.parser.Pos())
+		}
+	}
+	if sampled == 0 || cr.parser.Pos() <= 0 {
+		return 0, nil
+	}
+	buffer.WriteByte(';')
+
+	// a separate allocator, so the sample does not consume the row IDs.
+	kvEncoder, err := kv.NewTableKVEncoder(t.dbInfo.Name, t.tableInfo.Name, t.tableInfo.ID, rc.cfg.TiDB.SQLMode, kv.NewPanickingAllocator(0))
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer kvEncoder.Close()
+	kvs, _, err := kvEncoder.SQL2KV(buffer.String())
+	if err != nil {
+		return 0, common.ErrEncodeKV.Annotatef(err, "failed to encode the sampled rows of %s", path)
+	}
+
+	var checksum verify.KVChecksum
+	checksum.Update(kvs)
+	kvSize := checksum.SumSize()
+	ratio := float64(kvSize) / float64(cr.parser.Pos())
+	common.AppLogger.Infof("[%s] sampled %d rows (%d bytes) into %d bytes of KV pairs, ratio %.3f, takes %v",
+		t.tableName, sampled, cr.parser.Pos(), kvSize, ratio, time.Since(timer))
+	return ratio, nil
+}
+
+// kvBatchSize converts the batch size limiting the estimated KV size of each
+// engine into the size of the source data.
+func kvBatchSize(batchSize int64, kvSizeRatio float64) int64 {
+	if kvSizeRatio <= 0 {
+		return batchSize
+	}
+	size := int64(float64(batchSize) / kvSizeRatio)
+	if size <= 0 {
+		return 1
+	}
+	return size
+}
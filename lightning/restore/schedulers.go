@@ -0,0 +1,136 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+// pausedSchedulers are the PD schedulers moving the regions around, which
+// fight the ingestion of the SST files.
+var pausedSchedulers = []string{
+	"balance-leader-scheduler",
+	"balance-region-scheduler",
+	"balance-hot-region-scheduler",
+}
+
+// schedulerLeaseFactor is the number of renewal intervals a pause lasts, so
+// that a late renewal does not resume the schedulers.
+const schedulerLeaseFactor = 3
+
+// schedulerPauser pauses the PD schedulers while the tables are restored.
+//
+// The schedulers are paused for a lease which is renewed periodically, so
+// they are resumed by PD itself if Lightning crashes. The region merge cannot
+// be paused that way, so the previous merge-schedule-limit is recorded and
+// set back when done, or needs to be set back manually after a crash.
+type schedulerPauser struct {
+	client *http.Client
+	pdAddr string
+	lease  time.Duration
+
+	paused     []string
+	mergeLimit *uint64
+}
+
+func newSchedulerPauser(pdAddr string, renewInterval time.Duration) *schedulerPauser {
+	return &schedulerPauser{
+		client: common.NewHTTPClient(10 * time.Second),
+		pdAddr: pdAddr,
+		lease:  schedulerLeaseFactor * renewInterval,
+	}
+}
+
+func (p *schedulerPauser) pauseScheduler(name string, delay time.Duration) error {
+	url := fmt.Sprintf("http://%s/pd/api/v1/schedulers/%s", p.pdAddr, name)
+	return errors.Trace(common.PostJSON(p.client, url, map[string]int64{"delay": int64(delay.Seconds())}))
+}
+
+func (p *schedulerPauser) setMergeLimit(limit uint64) error {
+	url := fmt.Sprintf("http://%s/pd/api/v1/config", p.pdAddr)
+	return errors.Trace(common.PostJSON(p.client, url, map[string]uint64{"merge-schedule-limit": limit}))
+}
+
+// pause pauses the schedulers running on PD and disables the region merge.
+func (p *schedulerPauser) pause(_ context.Context) error {
+	var running []string
+	if err := common.GetJSON(p.client, fmt.Sprintf("http://%s/pd/api/v1/schedulers", p.pdAddr), &running); err != nil {
+		return errors.Trace(err)
+	}
+	isRunning := make(map[string]bool, len(running))
+	for _, name := range running {
+		isRunning[name] = true
+	}
+	for _, name := range pausedSchedulers {
+		if !isRunning[name] {
+			continue
+		}
+		if err := p.pauseScheduler(name, p.lease); err != nil {
+			return errors.Trace(err)
+		}
+		p.paused = append(p.paused, name)
+	}
+
+	var schedule struct {
+		MergeScheduleLimit uint64 `json:"merge-schedule-limit"`
+	}
+	if err := common.GetJSON(p.client, fmt.Sprintf("http://%s/pd/api/v1/config/schedule", p.pdAddr), &schedule); err != nil {
+		return errors.Trace(err)
+	}
+	if schedule.MergeScheduleLimit > 0 {
+		if err := p.setMergeLimit(0); err != nil {
+			return errors.Trace(err)
+		}
+		p.mergeLimit = &schedule.MergeScheduleLimit
+	}
+
+	common.AppLogger.Infof("paused PD schedulers %v for %v, merge-schedule-limit was %d",
+		p.paused, p.lease, schedule.MergeScheduleLimit)
+	return nil
+}
+
+// renew extends the lease of the paused schedulers.
+func (p *schedulerPauser) renew(_ context.Context) {
+	for _, name := range p.paused {
+		if err := p.pauseScheduler(name, p.lease); err != nil {
+			common.AppLogger.Warnf("cannot renew the pause of PD scheduler %s: %v", name, err)
+		}
+	}
+}
+
+// resume resumes the paused schedulers and sets back the merge-schedule-limit.
+func (p *schedulerPauser) resume(_ context.Context) {
+	for _, name := range p.paused {
+		if err := p.pauseScheduler(name, 0); err != nil {
+			common.AppLogger.Warnf("cannot resume PD scheduler %s, it will resume after %v: %v", name, p.lease, err)
+		}
+	}
+	p.paused = nil
+
+	if p.mergeLimit != nil {
+		if err := p.setMergeLimit(*p.mergeLimit); err != nil {
+			common.AppLogger.Errorf("cannot set back the merge-schedule-limit of PD to %d, please set it manually: %v", *p.mergeLimit, err)
+		} else {
+			common.AppLogger.Infof("resumed PD schedulers, merge-schedule-limit is set back to %d", *p.mergeLimit)
+		}
+		p.mergeLimit = nil
+	}
+}
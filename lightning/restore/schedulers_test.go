@@ -0,0 +1,67 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&schedulersSuite{})
+
+type schedulersSuite struct{}
+
+func (s *schedulersSuite) TestSchedulerPauser(c *C) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/pd/api/v1/schedulers":
+			w.Write([]byte(`["balance-leader-scheduler","balance-region-scheduler","label-scheduler"]`))
+		case req.Method == http.MethodGet && req.URL.Path == "/pd/api/v1/config/schedule":
+			w.Write([]byte(`{"merge-schedule-limit":8,"max-merge-region-size":20}`))
+		case req.Method == http.MethodPost:
+			var body map[string]int64
+			c.Assert(json.NewDecoder(req.Body).Decode(&body), IsNil)
+			encoded, err := json.Marshal(body)
+			c.Assert(err, IsNil)
+			requests = append(requests, req.URL.Path+" "+string(encoded))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	pauser := newSchedulerPauser(strings.TrimPrefix(server.URL, "http://"), time.Minute)
+	c.Assert(pauser.pause(ctx), IsNil)
+	pauser.renew(ctx)
+	pauser.resume(ctx)
+
+	c.Assert(requests, DeepEquals, []string{
+		`/pd/api/v1/schedulers/balance-leader-scheduler {"delay":180}`,
+		`/pd/api/v1/schedulers/balance-region-scheduler {"delay":180}`,
+		`/pd/api/v1/config {"merge-schedule-limit":0}`,
+		`/pd/api/v1/schedulers/balance-leader-scheduler {"delay":180}`,
+		`/pd/api/v1/schedulers/balance-region-scheduler {"delay":180}`,
+		`/pd/api/v1/schedulers/balance-leader-scheduler {"delay":0}`,
+		`/pd/api/v1/schedulers/balance-region-scheduler {"delay":0}`,
+		`/pd/api/v1/config {"merge-schedule-limit":8}`,
+	})
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+// sloHeadroom is the fraction of slo.max-latency below which the concurrency
+// is raised again.
+const sloHeadroom = 0.8
+
+// sloGovernor adjusts the concurrency of restoring the chunks and importing
+// the engines by the latency of the online workload: the concurrency is
+// halved while the latency exceeds the objective, and raised by one when the
+// latency is well below it, up to the configured concurrency. A nil governor
+// adjusts nothing.
+type sloGovernor struct {
+	cfg    config.SLO
+	client *http.Client
+	pools  []governedPool
+}
+
+type governedPool struct {
+	name string
+	pool *worker.Pool
+	max  int
+}
+
+func newSLOGovernor(cfg *config.SLO, regionWorkers *worker.Pool, importWorkers *worker.Pool) *sloGovernor {
+	if len(cfg.PrometheusAddr) == 0 {
+		return nil
+	}
+	return &sloGovernor{
+		cfg:    *cfg,
+		client: common.NewHTTPClient(10 * time.Second),
+		pools: []governedPool{
+			{name: "region", pool: regionWorkers, max: regionWorkers.Limit()},
+			{name: "import", pool: importWorkers, max: importWorkers.Limit()},
+		},
+	}
+}
+
+// adjust queries the latency, and changes the concurrency accordingly. The
+// concurrency is kept if Prometheus cannot be queried.
+func (g *sloGovernor) adjust() {
+	latency, err := g.queryLatency()
+	if err != nil {
+		common.AppLogger.Warnf("cannot query the latency from Prometheus, keeping the concurrency: %v", err)
+		return
+	}
+
+	maxLatency := g.cfg.MaxLatency.Duration
+	for _, p := range g.pools {
+		limit := p.pool.Limit()
+		newLimit := limit
+		switch {
+		case latency > maxLatency:
+			newLimit = limit / 2
+			if newLimit < 1 {
+				newLimit = 1
+			}
+		case float64(latency) < float64(maxLatency)*sloHeadroom && limit < p.max:
+			newLimit = limit + 1
+		}
+		if newLimit != limit {
+			p.pool.SetLimit(newLimit)
+			common.AppLogger.Infof("online latency %v (slo.max-latency %v), %s concurrency changed from %d to %d",
+				latency, maxLatency, p.name, limit, newLimit)
+		}
+	}
+}
+
+// queryLatency evaluates slo.latency-query, which should return a single
+// value in seconds.
+func (g *sloGovernor) queryLatency() (time.Duration, error) {
+	var resp struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result []struct {
+				Value []interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	u := fmt.Sprintf("http://%s/api/v1/query?query=%s", g.cfg.PrometheusAddr, url.QueryEscape(g.cfg.LatencyQuery))
+	if err := common.GetJSON(g.client, u, &resp); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if resp.Status != "success" || len(resp.Data.Result) == 0 || len(resp.Data.Result[0].Value) != 2 {
+		return 0, errors.Errorf("unexpected result of slo.latency-query, status %s with %d series", resp.Status, len(resp.Data.Result))
+	}
+	text, ok := resp.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, errors.Errorf("unexpected value %v of slo.latency-query", resp.Data.Result[0].Value[1])
+	}
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		// e.g. no queries in the range of the rate.
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
+)
+
+var _ = Suite(&sloGovernorSuite{})
+
+type sloGovernorSuite struct{}
+
+func (s *sloGovernorSuite) TestAdjust(c *C) {
+	latencies := []string{"0.8", "0.7", "0.3", "0.45", "NaN"}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Assert(req.URL.Path, Equals, "/api/v1/query")
+		c.Assert(req.URL.Query().Get("query"), Equals, "p99_latency")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1560000000,"%s"]}]}}`, latencies[requests])
+		requests++
+	}))
+	defer server.Close()
+
+	cfg := &config.SLO{
+		PrometheusAddr: strings.TrimPrefix(server.URL, "http://"),
+		LatencyQuery:   "p99_latency",
+		MaxLatency:     config.Duration{Duration: 500 * time.Millisecond},
+	}
+	regionWorkers := worker.NewPool(context.Background(), 5, "region")
+	importWorkers := worker.NewPool(context.Background(), 2, "import")
+	governor := newSLOGovernor(cfg, regionWorkers, importWorkers)
+
+	expected := []struct{ region, imp int }{
+		{2, 1}, // 0.8s: halved
+		{1, 1}, // 0.7s: halved, at least 1
+		{2, 2}, // 0.3s: raised by one
+		{2, 2}, // 0.45s: within the headroom, kept
+		{3, 2}, // no samples: raised by one, up to the config
+	}
+	for _, e := range expected {
+		governor.adjust()
+		c.Assert(regionWorkers.Limit(), Equals, e.region)
+		c.Assert(importWorkers.Limit(), Equals, e.imp)
+	}
+
+	c.Assert(newSLOGovernor(&config.SLO{}, regionWorkers, importWorkers), IsNil)
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+)
+
+// TableState is the state of a table in the restore controller, exposed for
+// diagnosing stuck imports.
+type TableState struct {
+	Table string `json:"table"`
+	// Status is the last checkpoint status saved for the table.
+	Status string `json:"status"`
+	// PendingEngines are the engines not yet started.
+	PendingEngines []int `json:"pending-engines"`
+	// RunningEngines are the engines being written or imported.
+	RunningEngines []int `json:"running-engines"`
+	// QueuedChunks is the number of chunks waiting for a region worker.
+	QueuedChunks int `json:"queued-chunks"`
+	// RunningChunks is the number of chunks being encoded and delivered.
+	RunningChunks int `json:"running-chunks"`
+}
+
+type engineRunState int
+
+const (
+	enginePending engineRunState = iota
+	engineRunning
+	engineFinished
+)
+
+type tableRunState struct {
+	status        CheckpointStatus
+	engines       map[int]engineRunState
+	queuedChunks  int
+	runningChunks int
+}
+
+// restoreState tracks the tables, engines and chunks being processed by the
+// restore controller. A nil state tracks nothing.
+type restoreState struct {
+	lock   sync.Mutex
+	tables map[string]*tableRunState
+}
+
+func newRestoreState() *restoreState {
+	return &restoreState{tables: make(map[string]*tableRunState)}
+}
+
+// table must be called with the lock held.
+func (s *restoreState) table(tableName string) *tableRunState {
+	t, ok := s.tables[tableName]
+	if !ok {
+		t = &tableRunState{engines: make(map[int]engineRunState)}
+		s.tables[tableName] = t
+	}
+	return t
+}
+
+// startTable records the engines of the table loaded from the checkpoint.
+func (s *restoreState) startTable(tableName string, cp *TableCheckpoint) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	t := s.table(tableName)
+	t.status = cp.Status
+	t.engines = make(map[int]engineRunState, len(cp.Engines))
+	for engineID, engine := range cp.Engines {
+		if engine.Status >= CheckpointStatusImported {
+			t.engines[engineID] = engineFinished
+		} else {
+			t.engines[engineID] = enginePending
+		}
+	}
+	t.queuedChunks = 0
+	t.runningChunks = 0
+}
+
+func (s *restoreState) setStatus(tableName string, status CheckpointStatus) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.table(tableName).status = status
+	s.lock.Unlock()
+}
+
+func (s *restoreState) setEngine(tableName string, engineID int, state engineRunState) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.table(tableName).engines[engineID] = state
+	s.lock.Unlock()
+}
+
+// addChunks adjusts the number of queued and running chunks of the table.
+func (s *restoreState) addChunks(tableName string, queued int, running int) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	t := s.table(tableName)
+	t.queuedChunks += queued
+	t.runningChunks += running
+	s.lock.Unlock()
+}
+
+// snapshot returns the state of the tables, sorted by name.
+func (s *restoreState) snapshot() []TableState {
+	if s == nil {
+		return []TableState{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := make([]TableState, 0, len(s.tables))
+	for tableName, t := range s.tables {
+		state := TableState{
+			Table:          tableName,
+			Status:         checkpointStatusName(t.status),
+			PendingEngines: []int{},
+			RunningEngines: []int{},
+			QueuedChunks:   t.queuedChunks,
+			RunningChunks:  t.runningChunks,
+		}
+		for engineID, engine := range t.engines {
+			switch engine {
+			case enginePending:
+				state.PendingEngines = append(state.PendingEngines, engineID)
+			case engineRunning:
+				state.RunningEngines = append(state.RunningEngines, engineID)
+			}
+		}
+		sort.Ints(state.PendingEngines)
+		sort.Ints(state.RunningEngines)
+		res = append(res, state)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Table < res[j].Table })
+	return res
+}
+
+// checkpointStatusName is the metric name of the status, distinguishing the
+// invalid statuses by the step failed.
+func checkpointStatusName(status CheckpointStatus) string {
+	if status <= CheckpointStatusMaxInvalid {
+		return "failed at " + (status * 10).MetricName()
+	}
+	return status.MetricName()
+}
+
+// logDebug dumps the state into the debug log.
+func (s *restoreState) logDebug() {
+	if s == nil || common.GetLevel() < log.DebugLevel {
+		return
+	}
+	state, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return
+	}
+	common.AppLogger.Debugf("restore state: %s", state)
+}
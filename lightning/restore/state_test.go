@@ -0,0 +1,59 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&stateSuite{})
+
+type stateSuite struct{}
+
+func (s *stateSuite) TestRestoreState(c *C) {
+	state := newRestoreState()
+	state.startTable("`db`.`t`", &TableCheckpoint{
+		Status: CheckpointStatusLoaded,
+		Engines: []*EngineCheckpoint{
+			{Status: CheckpointStatusImported},
+			{Status: CheckpointStatusLoaded},
+			{Status: CheckpointStatusAllWritten},
+		},
+	})
+	state.setEngine("`db`.`t`", 1, engineRunning)
+	state.addChunks("`db`.`t`", 3, 0)
+	state.addChunks("`db`.`t`", -1, 1)
+	state.setStatus("`db`.`a`", CheckpointStatusChecksummed/10)
+
+	c.Assert(state.snapshot(), DeepEquals, []TableState{
+		{
+			Table:          "`db`.`a`",
+			Status:         "failed at checksum",
+			PendingEngines: []int{},
+			RunningEngines: []int{},
+		},
+		{
+			Table:          "`db`.`t`",
+			Status:         "pending",
+			PendingEngines: []int{2},
+			RunningEngines: []int{1},
+			QueuedChunks:   2,
+			RunningChunks:  1,
+		},
+	})
+
+	var nilState *restoreState
+	nilState.addChunks("`db`.`t`", 1, 0)
+	c.Assert(nilState.snapshot(), HasLen, 0)
+}
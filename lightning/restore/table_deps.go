@@ -0,0 +1,176 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+// tableBarrier is closed when the table is completed, successfully or not.
+type tableBarrier struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+var (
+	errTableNotStarted = errors.New("table is not started")
+	errTableSkipped    = errors.New("table is skipped")
+)
+
+// tableBarriers orders the tables by the declared dependencies. A table waits
+// until all tables it depends on are completed, while the independent tables
+// are still restored in parallel. A nil tableBarriers imposes no order.
+type tableBarriers struct {
+	// deps are the tables each table depends on, in the unique table names.
+	deps     map[string][]string
+	barriers map[string]*tableBarrier
+}
+
+// newTableBarriers builds the dependency graph of the tables in the data
+// source. Returns nil if no dependencies are declared.
+func newTableBarriers(deps []*config.TableDependency, dbMetas []*mydump.MDDatabaseMeta) (*tableBarriers, error) {
+	if len(deps) == 0 {
+		return nil, nil
+	}
+
+	// the table names in the config are matched case-insensitively.
+	tables := make(map[string]string)
+	for _, dbMeta := range dbMetas {
+		for _, tableMeta := range dbMeta.Tables {
+			tables[strings.ToLower(common.UniqueTable(dbMeta.Name, tableMeta.Name))] = common.UniqueTable(dbMeta.Name, tableMeta.Name)
+		}
+	}
+	lookup := func(schema string, table string) (string, error) {
+		name, ok := tables[strings.ToLower(common.UniqueTable(schema, table))]
+		if !ok {
+			return "", common.ErrInvalidConfig.Errorf("table-dependency refers to %s which is not in the data source", common.UniqueTable(schema, table))
+		}
+		return name, nil
+	}
+
+	b := &tableBarriers{
+		deps:     make(map[string][]string),
+		barriers: make(map[string]*tableBarrier, len(tables)),
+	}
+	for _, dep := range deps {
+		tableName, err := lookup(dep.Schema, dep.Table)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, after := range dep.After {
+			dot := strings.IndexByte(after, '.')
+			afterName, err := lookup(after[:dot], after[dot+1:])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			b.deps[tableName] = append(b.deps[tableName], afterName)
+		}
+	}
+	if err := b.checkCycle(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	for _, tableName := range tables {
+		b.barriers[tableName] = &tableBarrier{done: make(chan struct{})}
+	}
+	return b, nil
+}
+
+// checkCycle ensures the dependencies form a DAG, so no table waits forever.
+func (b *tableBarriers) checkCycle() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var visit func(tableName string, path []string) error
+	visit = func(tableName string, path []string) error {
+		switch states[tableName] {
+		case visiting:
+			return common.ErrInvalidConfig.Errorf("table-dependency has a cycle: %s -> %s", strings.Join(path, " -> "), tableName)
+		case visited:
+			return nil
+		}
+		states[tableName] = visiting
+		for _, dep := range b.deps[tableName] {
+			if err := visit(dep, append(path, tableName)); err != nil {
+				return err
+			}
+		}
+		states[tableName] = visited
+		return nil
+	}
+	for tableName := range b.deps {
+		if err := visit(tableName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait blocks until all tables the table depends on are completed. Returns an
+// error if any of them has failed.
+func (b *tableBarriers) wait(ctx context.Context, tableName string) error {
+	if b == nil {
+		return nil
+	}
+	for _, dep := range b.deps[tableName] {
+		barrier := b.barriers[dep]
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-barrier.done:
+		}
+		if barrier.err != nil {
+			return errors.Errorf("%s is not restored because its dependency %s has failed", tableName, dep)
+		}
+	}
+	return nil
+}
+
+// finish marks the table as completed, releasing the tables depending on it.
+func (b *tableBarriers) finish(tableName string, err error) {
+	if b == nil {
+		return
+	}
+	b.barriers[tableName].finish(err)
+}
+
+// abort releases the tables waiting for the tables which have never been
+// started, e.g. when the restore is interrupted before launching all tables.
+func (b *tableBarriers) abort() {
+	if b == nil {
+		return
+	}
+	for _, barrier := range b.barriers {
+		barrier.finish(errTableNotStarted)
+	}
+}
+
+func (barrier *tableBarrier) finish(err error) {
+	barrier.once.Do(func() {
+		barrier.err = err
+		close(barrier.done)
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+var _ = Suite(&tableDepsSuite{})
+
+type tableDepsSuite struct{}
+
+var tableDepsDBMetas = []*mydump.MDDatabaseMeta{{
+	Name: "db",
+	Tables: []*mydump.MDTableMeta{
+		{DB: "db", Name: "dim1"},
+		{DB: "db", Name: "dim2"},
+		{DB: "db", Name: "fact"},
+	},
+}}
+
+func (s *tableDepsSuite) TestNoDependencies(c *C) {
+	b, err := newTableBarriers(nil, tableDepsDBMetas)
+	c.Assert(err, IsNil)
+	c.Assert(b, IsNil)
+
+	c.Assert(b.wait(context.Background(), "`db`.`fact`"), IsNil)
+	b.finish("`db`.`fact`", nil)
+	b.abort()
+}
+
+func (s *tableDepsSuite) TestInvalidDependencies(c *C) {
+	_, err := newTableBarriers([]*config.TableDependency{
+		{Schema: "db", Table: "fact", After: []string{"db.missing"}},
+	}, tableDepsDBMetas)
+	c.Assert(err, ErrorMatches, ".*table-dependency refers to `db`.`missing` which is not in the data source")
+
+	_, err = newTableBarriers([]*config.TableDependency{
+		{Schema: "db", Table: "fact", After: []string{"db.dim1"}},
+		{Schema: "db", Table: "dim1", After: []string{"db.dim2"}},
+		{Schema: "db", Table: "dim2", After: []string{"DB.Fact"}},
+	}, tableDepsDBMetas)
+	c.Assert(err, ErrorMatches, ".*table-dependency has a cycle: .*")
+}
+
+func (s *tableDepsSuite) TestWait(c *C) {
+	ctx := context.Background()
+	b, err := newTableBarriers([]*config.TableDependency{
+		{Schema: "db", Table: "fact", After: []string{"db.dim1", "db.dim2"}},
+	}, tableDepsDBMetas)
+	c.Assert(err, IsNil)
+
+	// the independent tables do not wait.
+	c.Assert(b.wait(ctx, "`db`.`dim1`"), IsNil)
+	c.Assert(b.wait(ctx, "`db`.`dim2`"), IsNil)
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- b.wait(ctx, "`db`.`fact`")
+	}()
+	b.finish("`db`.`dim1`", nil)
+	select {
+	case <-waited:
+		c.Fatal("fact should wait for dim2")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.finish("`db`.`dim2`", nil)
+	c.Assert(<-waited, IsNil)
+}
+
+func (s *tableDepsSuite) TestDependencyFailed(c *C) {
+	ctx := context.Background()
+	b, err := newTableBarriers([]*config.TableDependency{
+		{Schema: "db", Table: "fact", After: []string{"db.dim1"}},
+	}, tableDepsDBMetas)
+	c.Assert(err, IsNil)
+
+	b.finish("`db`.`dim1`", errors.New("checksum mismatched"))
+	c.Assert(b.wait(ctx, "`db`.`fact`"), ErrorMatches, "`db`.`fact` is not restored because its dependency `db`.`dim1` has failed")
+
+	// the tables never started also release their dependents.
+	b, err = newTableBarriers([]*config.TableDependency{
+		{Schema: "db", Table: "fact", After: []string{"db.dim2"}},
+	}, tableDepsDBMetas)
+	c.Assert(err, IsNil)
+	b.abort()
+	c.Assert(b.wait(ctx, "`db`.`fact`"), NotNil)
+}
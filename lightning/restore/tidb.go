@@ -15,25 +15,29 @@ package restore
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"net/http"
-	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb-lightning/lightning/common"
 	"github.com/pingcap/tidb-lightning/lightning/config"
-	"github.com/pingcap/tidb-lightning/lightning/metric"
+	"github.com/pingcap/tidb-lightning/lightning/glue"
 	"github.com/pingcap/tidb-lightning/lightning/mydump"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
 )
 
 type TiDBManager struct {
-	db      *sql.DB
-	client  *http.Client
-	baseURL *url.URL
+	glue glue.Glue
+	// ownsGlue is whether the glue is opened by the manager, and thus should
+	// be closed with it.
+	ownsGlue bool
 }
 
 type TidbDBInfo struct {
@@ -48,51 +52,216 @@ type TidbTableInfo struct {
 	Indices         int
 	CreateTableStmt string
 	core            *model.TableInfo
+	// deferredIndexes are to be added after the data are imported.
+	deferredIndexes []deferredIndex
+	// nullColumns are the columns of unsupported types imported as NULL.
+	nullColumns []string
 }
 
-func NewTiDBManager(dsn config.DBStore) (*TiDBManager, error) {
-	db, err := common.ConnectDB(dsn.Host, dsn.Port, dsn.User, dsn.Psw)
-	if err != nil {
-		return nil, errors.Trace(err)
+// localTableSchema is the CREATE TABLE statement of a table extracted from the
+// schema file in the data source.
+type localTableSchema struct {
+	createTableStmt string
+	// number of columns defined in the statement, or -1 if unknown.
+	columns int
+	// columnDefs are the columns defined in the statement.
+	columnDefs []localColumn
+	// deferredIndexes are the secondary indexes removed from createTableStmt.
+	deferredIndexes []deferredIndex
+	// likeSchema and likeTable name the table copied by
+	// `CREATE TABLE ... LIKE`. likeSchema is empty if the name is unqualified.
+	likeSchema string
+	likeTable  string
+	// unsupportedColumns are the columns of the types TiDB does not support.
+	// They are replaced by nullable columns unless skip is true, in which
+	// case the table is not restored at all.
+	unsupportedColumns []unsupportedColumn
+	skip               bool
+}
+
+// localColumn is a column defined in the schema file, as far as it matters
+// for encoding the rows.
+type localColumn struct {
+	name     string
+	tp       byte
+	unsigned bool
+	// decimal is the scale, or negative if unspecified.
+	decimal int
+}
+
+// matches checks if the target table has the same columns as the schema
+// file, in which case the local CREATE TABLE statement encodes the rows just
+// like the target schema.
+func (s *localTableSchema) matches(tbl *model.TableInfo) bool {
+	if s.columns != len(tbl.Columns) || len(s.columnDefs) != len(tbl.Columns) {
+		return false
+	}
+	for i, col := range s.columnDefs {
+		target := tbl.Columns[i]
+		if !strings.EqualFold(col.name, target.Name.O) ||
+			col.tp != target.Tp ||
+			col.unsigned != mysql.HasUnsignedFlag(target.Flag) ||
+			(col.decimal >= 0 && col.decimal != target.Decimal) {
+			return false
+		}
 	}
+	return true
+}
+
+// nullColumns returns the names of the unsupported columns imported as NULL.
+func (s *localTableSchema) nullColumns() []string {
+	if s == nil || s.skip {
+		return nil
+	}
+	columns := make([]string, 0, len(s.unsupportedColumns))
+	for _, column := range s.unsupportedColumns {
+		columns = append(columns, column.name)
+	}
+	return columns
+}
 
-	u, err := url.Parse(fmt.Sprintf("http://%s:%d", dsn.Host, dsn.StatusPort))
+func NewTiDBManager(dsn config.DBStore) (*TiDBManager, error) {
+	g, err := glue.OpenExternalTiDBGlue(dsn)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	return &TiDBManager{glue: g, ownsGlue: true}, nil
+}
 
-	return &TiDBManager{
-		db:      db,
-		client:  &http.Client{},
-		baseURL: u,
-	}, nil
+// NewTiDBManagerWithGlue creates a manager accessing the target through the
+// glue supplied by the embedder. The glue is not closed with the manager.
+func NewTiDBManagerWithGlue(g glue.Glue) *TiDBManager {
+	return &TiDBManager{glue: g}
 }
 
 func (timgr *TiDBManager) Close() {
-	timgr.db.Close()
+	if timgr.ownsGlue {
+		timgr.glue.Close()
+	}
 }
 
-func (timgr *TiDBManager) InitSchema(ctx context.Context, database string, tablesSchema map[string]string) error {
-	createDatabase := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", database)
-	err := common.ExecWithRetry(ctx, timgr.db, createDatabase, createDatabase)
+// InitSchemas creates the databases and their tables, executing as many
+// statements at the same time as the workers of the pool. The tables of a
+// database are created after the database, and a table created by
+// `CREATE TABLE ... LIKE` another table of the data source is created after
+// that table.
+func (timgr *TiDBManager) InitSchemas(ctx context.Context, schemas map[string]map[string]*localTableSchema, pool *worker.Pool) error {
+	deps, err := schemaDependencies(schemas)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	useDB := fmt.Sprintf("USE `%s`", database)
-	err = common.ExecWithRetry(ctx, timgr.db, useDB, useDB)
-	if err != nil {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var createErr common.OnceError
+	// execute runs the statement once all the `after` channels are closed,
+	// and closes `done` when it returns.
+	execute := func(tag string, after []chan struct{}, done chan struct{}, stmt string) {
+		wg.Add(1)
+		go func() {
+			defer func() {
+				close(done)
+				wg.Done()
+			}()
+			for _, ch := range after {
+				select {
+				case <-ch:
+				case <-ctx.Done():
+					return
+				}
+			}
+			// a failed dependency cancels the context before it is done.
+			if ctx.Err() != nil {
+				return
+			}
+			w, err := pool.ApplyContext(ctx)
+			if err != nil {
+				return
+			}
+			defer pool.Recycle(w)
+
+			timer := time.Now()
+			if err := timgr.glue.ExecuteWithLog(ctx, stmt, stmt); err != nil {
+				createErr.Set(tag, err)
+				cancel()
+				return
+			}
+			common.AppLogger.Infof("%s takes %v", stmt, time.Since(timer))
+		}()
+	}
+
+	databases := make(map[string]chan struct{}, len(schemas))
+	tables := make(map[string]chan struct{})
+	for database, tablesSchema := range schemas {
+		databases[database] = make(chan struct{})
+		for tableName := range tablesSchema {
+			tables[strings.ToLower(common.UniqueTable(database, tableName))] = make(chan struct{})
+		}
+	}
+	for database, done := range databases {
+		createDatabase := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", database)
+		execute(database, nil, done, createDatabase)
+	}
+	for database, tablesSchema := range schemas {
+		for tableName, schema := range tablesSchema {
+			key := strings.ToLower(common.UniqueTable(database, tableName))
+			after := []chan struct{}{databases[database]}
+			if dep, ok := deps[key]; ok {
+				after = append(after, tables[dep])
+			}
+			createTable := createTableIfNotExistsStmt(qualifyCreateTableStmt(schema.createTableStmt, database))
+			execute(common.UniqueTable(database, tableName), after, tables[key], createTable)
+		}
+	}
+
+	wg.Wait()
+	if err := createErr.Get(); err != nil {
 		return errors.Trace(err)
 	}
+	return errors.Trace(ctx.Err())
+}
 
-	for _, sqlCreateTable := range tablesSchema {
-		timer := time.Now()
-		if err = safeCreateTable(ctx, timgr.db, sqlCreateTable); err != nil {
-			return errors.Trace(err)
+// schemaDependencies returns the table copied by each `CREATE TABLE ... LIKE`
+// statement, if that table is in the data source too. The tables are named by
+// their lower-cased unique names.
+func schemaDependencies(schemas map[string]map[string]*localTableSchema) (map[string]string, error) {
+	tables := make(map[string]struct{})
+	for database, tablesSchema := range schemas {
+		for tableName := range tablesSchema {
+			tables[strings.ToLower(common.UniqueTable(database, tableName))] = struct{}{}
+		}
+	}
+
+	deps := make(map[string]string)
+	for database, tablesSchema := range schemas {
+		for tableName, schema := range tablesSchema {
+			if len(schema.likeTable) == 0 {
+				continue
+			}
+			likeSchema := schema.likeSchema
+			if len(likeSchema) == 0 {
+				likeSchema = database
+			}
+			dep := strings.ToLower(common.UniqueTable(likeSchema, schema.likeTable))
+			if _, ok := tables[dep]; ok {
+				deps[strings.ToLower(common.UniqueTable(database, tableName))] = dep
+			}
 		}
-		common.AppLogger.Infof("%s takes %v", sqlCreateTable, time.Since(timer))
 	}
 
-	return nil
+	// every table copies at most one table, so a cycle is found by following
+	// the copied tables from each table.
+	for table := range deps {
+		dep, ok := deps[table]
+		for i := 0; ok && i < len(deps); i++ {
+			if dep == table {
+				return nil, errors.Errorf("the schema of table %s depends on itself through CREATE TABLE ... LIKE", table)
+			}
+			dep, ok = deps[dep]
+		}
+	}
+	return deps, nil
 }
 
 var createTableRegexp = regexp.MustCompile(`(?i)CREATE TABLE( IF NOT EXISTS)?`)
@@ -108,94 +277,344 @@ func createTableIfNotExistsStmt(createTable string) string {
 	return createTable
 }
 
-func safeCreateTable(ctx context.Context, db *sql.DB, createTable string) error {
-	createTable = createTableIfNotExistsStmt(createTable)
-	err := common.ExecWithRetry(ctx, db, createTable, createTable)
-	return errors.Trace(err)
+var (
+	createTableNameRegexp = regexp.MustCompile("(?i)CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?(`(?:[^`]|``)+`|[^\\s`(.]+)(\\s*\\.)?")
+	likeTableNameRegexp   = regexp.MustCompile("(?i)^\\s*\\(?\\s*LIKE\\s+(`(?:[^`]|``)+`|[^\\s`().]+)(\\s*\\.)?")
+)
+
+// qualifyCreateTableStmt prefixes the unqualified table names in the CREATE
+// TABLE (... LIKE) statement with the database, so the statement does not
+// depend on the current database of the session executing it.
+func qualifyCreateTableStmt(createTable string, database string) string {
+	var escapedDatabase strings.Builder
+	common.WriteMySQLIdentifier(&escapedDatabase, database)
+	escapedDatabase.WriteByte('.')
+
+	indices := createTableNameRegexp.FindStringSubmatchIndex(createTable)
+	if indices == nil || indices[4] >= 0 {
+		return createTable
+	}
+	before, name, after := createTable[:indices[2]], createTable[indices[2]:indices[3]], createTable[indices[3]:]
+	if indices = likeTableNameRegexp.FindStringSubmatchIndex(after); indices != nil && indices[4] < 0 {
+		after = after[:indices[2]] + escapedDatabase.String() + after[indices[2]:]
+	}
+	return before + escapedDatabase.String() + name + after
 }
 
-func (timgr *TiDBManager) getTables(schema string) ([]*model.TableInfo, error) {
-	baseURL := *timgr.baseURL
-	baseURL.Path = fmt.Sprintf("schema/%s", schema)
+// parseTableSchemas parses all table schema files using the TiDB parser. This
+// validates the schema files before any DDL is sent to the target, and allows
+// the statements to be reused for encoding without querying them back via
+// `SHOW CREATE TABLE`.
+//
+// The unsupported clauses are rewritten by the downgrader (if not nil) before
+// parsing, and every modification is logged. Tables with columns of the
+// unsupported types fail the whole call unless on-unsupported-type allows
+// skipping them, or importing the columns as NULL. If deferIndexes is true, the
+// secondary indexes are removed from the statements after parsing.
+//
+// The result is indexed by the database name and then the table name.
+func parseTableSchemas(dbMetas []*mydump.MDDatabaseMeta, downgrader *schemaDowngrader, deferIndexes bool) (map[string]map[string]*localTableSchema, error) {
+	p := parser.New()
+	result := make(map[string]map[string]*localTableSchema, len(dbMetas))
+	downgradedTables := 0
+	for _, dbMeta := range dbMetas {
+		tables := make(map[string]*localTableSchema, len(dbMeta.Tables))
+		for _, tblMeta := range dbMeta.Tables {
+			createTable, modifications := downgrader.downgrade(tblMeta.GetSchema())
+			createTable, unsupported, replacements := downgrader.findUnsupportedColumns(createTable)
+			modifications = append(modifications, replacements...)
+			for _, modification := range modifications {
+				common.AppLogger.Warnf("[%s] schema file %s downgraded: %s", common.UniqueTable(tblMeta.DB, tblMeta.Name), tblMeta.SchemaFile, modification)
+			}
+			if len(modifications) > 0 {
+				downgradedTables++
+			}
+
+			if len(unsupported) > 0 && downgrader.onUnsupportedType != config.UnsupportedTypeNull {
+				if downgrader.onUnsupportedType != config.UnsupportedTypeSkipTable {
+					return nil, common.ErrInvalidSource.Errorf(
+						"table %s has the column %s of a type TiDB does not support, set mydumper.ddl-downgrade.on-unsupported-type to %q or %q to import the rest",
+						common.UniqueTable(tblMeta.DB, tblMeta.Name), unsupported[0].definition,
+						config.UnsupportedTypeSkipTable, config.UnsupportedTypeNull,
+					)
+				}
+				common.AppLogger.Warnf("[%s] table skipped for the column %s of a type TiDB does not support", common.UniqueTable(tblMeta.DB, tblMeta.Name), unsupported[0].definition)
+				tables[tblMeta.Name] = &localTableSchema{unsupportedColumns: unsupported, skip: true}
+				continue
+			}
+
+			schema, err := parseTableSchema(p, tblMeta.Name, tblMeta.SchemaFile, createTable)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			schema.unsupportedColumns = unsupported
+			if deferIndexes {
+				schema.createTableStmt, schema.deferredIndexes = splitSecondaryIndexes(schema.createTableStmt)
+			}
+			tables[tblMeta.Name] = schema
+		}
+		result[dbMeta.Name] = tables
+	}
+	if downgradedTables > 0 {
+		common.AppLogger.Warnf("the schemas of %d tables have been downgraded, see the log above for the modifications", downgradedTables)
+	}
+	return result, nil
+}
 
-	var tables []*model.TableInfo
-	err := common.GetJSON(timgr.client, baseURL.String(), &tables)
+func parseTableSchema(p *parser.Parser, tableName string, schemaFile string, schema string) (*localTableSchema, error) {
+	stmt, err := p.ParseOneStmt(schema, "", "")
 	if err != nil {
-		return nil, errors.Annotatef(errors.Trace(err), "get tables for schema %s", schema)
+		return nil, errors.Annotatef(err, "failed to parse schema file %s", schemaFile)
 	}
-	return tables, nil
+	createTable, ok := stmt.(*ast.CreateTableStmt)
+	if !ok {
+		return nil, errors.Errorf("schema file %s does not contain a CREATE TABLE statement", schemaFile)
+	}
+	if !strings.EqualFold(createTable.Table.Name.O, tableName) {
+		return nil, errors.Errorf("schema file %s creates table `%s` instead of `%s`", schemaFile, createTable.Table.Name.O, tableName)
+	}
+
+	result := &localTableSchema{
+		createTableStmt: schema,
+		columns:         len(createTable.Cols),
+	}
+	for _, col := range createTable.Cols {
+		result.columnDefs = append(result.columnDefs, localColumn{
+			name:     col.Name.Name.O,
+			tp:       col.Tp.Tp,
+			unsigned: mysql.HasUnsignedFlag(col.Tp.Flag),
+			decimal:  col.Tp.Decimal,
+		})
+	}
+	if createTable.ReferTable != nil {
+		// CREATE TABLE ... LIKE ..., the columns are only known by the target.
+		result.columns = -1
+		result.likeSchema = createTable.ReferTable.Schema.O
+		result.likeTable = createTable.ReferTable.Name.O
+	}
+	return result, nil
 }
 
 func (timgr *TiDBManager) DropTable(ctx context.Context, tableName string) error {
 	query := "DROP TABLE " + tableName
-	return errors.Trace(common.ExecWithRetry(ctx, timgr.db, query, query))
+	return errors.Trace(timgr.glue.ExecuteWithLog(ctx, query, query))
 }
 
-func (timgr *TiDBManager) LoadSchemaInfo(ctx context.Context, schemas []*mydump.MDDatabaseMeta) (map[string]*TidbDBInfo, error) {
-	result := make(map[string]*TidbDBInfo, len(schemas))
-	for _, schema := range schemas {
-		tables, err := timgr.getTables(schema.Name)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
+// LoadTableInfos fetches the table infos of all tables in the schema from the
+// target, indexed by the lower-cased table names.
+func (timgr *TiDBManager) LoadTableInfos(ctx context.Context, schema string) (map[string]*model.TableInfo, error) {
+	tables, err := timgr.glue.GetTables(ctx, schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make(map[string]*model.TableInfo, len(tables))
+	for _, tbl := range tables {
+		result[tbl.Name.L] = tbl
+	}
+	return result, nil
+}
 
-		dbInfo := &TidbDBInfo{
-			Name:   schema.Name,
-			Tables: make(map[string]*TidbTableInfo),
+// newTableInfo builds the info of a single table from the target. The CREATE
+// TABLE statement is taken from `local` when available, and only queried from
+// the target if the existing table does not match the local definition.
+func (timgr *TiDBManager) newTableInfo(ctx context.Context, schema string, table string, tbl *model.TableInfo, local *localTableSchema) (*TidbTableInfo, error) {
+	if tbl.State != model.StatePublic {
+		return nil, errors.Errorf("table [%s.%s] state is not public", schema, table)
+	}
+
+	var (
+		createTableStmt string
+		deferredIndexes []deferredIndex
+		err             error
+	)
+	switch {
+	case local == nil || !local.matches(tbl):
+		if local != nil && local.columns >= 0 {
+			common.AppLogger.Warnf("[%s.%s] existing table differs from the schema file, using SHOW CREATE TABLE instead", schema, table)
 		}
+		createTableStmt, err = timgr.getCreateTableStmt(ctx, schema, table)
+	case hasAnyIndex(tbl, local.deferredIndexes):
+		// the deferred indexes already exist (e.g. resuming after some of
+		// them have been added), so the rows must be encoded with all indexes
+		// in the target.
+		createTableStmt, err = timgr.getCreateTableStmt(ctx, schema, table)
+		deferredIndexes = local.deferredIndexes
+	default:
+		createTableStmt = local.createTableStmt
+		deferredIndexes = local.deferredIndexes
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 
-		for _, tbl := range tables {
-			tableName := tbl.Name.String()
-			if tbl.State != model.StatePublic {
-				err := errors.Errorf("table [%s.%s] state is not public", schema, tableName)
-				metric.RecordTableCount(metric.TableStatePending, err)
-				return nil, err
-			}
-			createTableStmt, err := timgr.getCreateTableStmt(ctx, schema.Name, tableName)
-			metric.RecordTableCount(metric.TableStatePending, err)
-			if err != nil {
-				return nil, errors.Trace(err)
-			}
-			tableInfo := &TidbTableInfo{
-				ID:              tbl.ID,
-				Name:            tableName,
-				Columns:         len(tbl.Columns),
-				Indices:         len(tbl.Indices),
-				CreateTableStmt: createTableStmt,
-				core:            tbl,
+	return &TidbTableInfo{
+		ID:              tbl.ID,
+		Name:            table,
+		Columns:         len(tbl.Columns),
+		Indices:         len(tbl.Indices),
+		CreateTableStmt: createTableStmt,
+		core:            tbl,
+		deferredIndexes: deferredIndexes,
+		nullColumns:     local.nullColumns(),
+	}, nil
+}
+
+// hasAnyIndex checks if any of the indexes exists in the table.
+func hasAnyIndex(tbl *model.TableInfo, indexes []deferredIndex) bool {
+	for _, idx := range indexes {
+		for _, existing := range tbl.Indices {
+			if strings.EqualFold(existing.Name.O, idx.name) {
+				return true
 			}
-			dbInfo.Tables[tableName] = tableInfo
 		}
+	}
+	return false
+}
+
+// schemaCache lazily fetches the table infos from the target when a table of
+// the database is about to be restored, and caches the result. Dumps
+// containing tens of thousands of tables thus do not need to wait for all
+// schemas to be loaded before importing any data.
+type schemaCache struct {
+	timgr        *TiDBManager
+	localSchemas map[string]map[string]*localTableSchema
+	// limits the number of concurrent schema queries sent to the target.
+	workers *worker.Pool
+
+	lock         sync.Mutex
+	dbInfos      map[string]*TidbDBInfo
+	targetTables map[string]*targetTables
+}
 
-		result[schema.Name] = dbInfo
+// targetTables are the table infos of a database fetched from the target.
+type targetTables struct {
+	mu     sync.Mutex
+	loaded bool
+	tables map[string]*model.TableInfo
+	err    error
+}
+
+func newSchemaCache(
+	timgr *TiDBManager,
+	localSchemas map[string]map[string]*localTableSchema,
+	workers *worker.Pool,
+) *schemaCache {
+	return &schemaCache{
+		timgr:        timgr,
+		localSchemas: localSchemas,
+		workers:      workers,
+		dbInfos:      make(map[string]*TidbDBInfo),
+		targetTables: make(map[string]*targetTables),
 	}
-	return result, nil
+}
+
+// getTableInfo returns the table info of the given table, fetching the tables
+// of the database from the target if they are not cached yet.
+func (sc *schemaCache) getTableInfo(ctx context.Context, schema string, table string) (*TidbDBInfo, *TidbTableInfo, error) {
+	sc.lock.Lock()
+	dbInfo, ok := sc.dbInfos[schema]
+	if !ok {
+		dbInfo = &TidbDBInfo{
+			Name:   schema,
+			Tables: make(map[string]*TidbTableInfo),
+		}
+		sc.dbInfos[schema] = dbInfo
+	}
+	tableInfo, ok := dbInfo.Tables[table]
+	target, targetOk := sc.targetTables[schema]
+	if !targetOk {
+		target = &targetTables{}
+		sc.targetTables[schema] = target
+	}
+	sc.lock.Unlock()
+	if ok {
+		return dbInfo, tableInfo, nil
+	}
+
+	tables, err := sc.loadTargetTables(ctx, target, schema)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	tbl, ok := tables[strings.ToLower(table)]
+	if !ok {
+		return nil, nil, errors.Errorf("table %s does not exist in the target", common.UniqueTable(schema, table))
+	}
+
+	w, err := sc.workers.ApplyContext(ctx)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	tableInfo, err = sc.timgr.newTableInfo(ctx, schema, table, tbl, sc.localSchemas[schema][table])
+	sc.workers.Recycle(w)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	sc.lock.Lock()
+	dbInfo.Tables[table] = tableInfo
+	sc.lock.Unlock()
+	return dbInfo, tableInfo, nil
+}
+
+// loadTargetTables fetches the tables of the database from the target once.
+// The failures caused by canceling the context are not cached, so a later
+// call with a live context fetches the tables again.
+func (sc *schemaCache) loadTargetTables(ctx context.Context, target *targetTables, schema string) (map[string]*model.TableInfo, error) {
+	target.mu.Lock()
+	defer target.mu.Unlock()
+	if target.loaded {
+		return target.tables, target.err
+	}
+
+	w, err := sc.workers.ApplyContext(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	tables, err := sc.timgr.LoadTableInfos(ctx, schema)
+	sc.workers.Recycle(w)
+	if err != nil && ctx.Err() != nil {
+		return nil, errors.Trace(err)
+	}
+	target.loaded, target.tables, target.err = true, tables, err
+	return tables, err
+}
+
+// forgetTable drops the cached table info, so that it is fetched again by the
+// next getTableInfo. This is needed after truncating the table, which assigns
+// it a new table ID.
+func (sc *schemaCache) forgetTable(schema string, table string) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if dbInfo, ok := sc.dbInfos[schema]; ok {
+		delete(dbInfo.Tables, table)
+	}
+	delete(sc.targetTables, schema)
 }
 
 func (timgr *TiDBManager) getCreateTableStmt(ctx context.Context, schema, table string) (string, error) {
 	query := fmt.Sprintf("SHOW CREATE TABLE %s", common.UniqueTable(schema, table))
 	var tbl, createTable string
-	err := common.QueryRowWithRetry(ctx, timgr.db, query, &tbl, &createTable)
+	err := common.QueryRowWithRetry(ctx, timgr.glue.GetDB(), query, &tbl, &createTable)
 	return createTable, errors.Annotatef(err, "%s", query)
 }
 
-func ObtainGCLifeTime(ctx context.Context, db *sql.DB) (gcLifeTime string, err error) {
+func ObtainGCLifeTime(ctx context.Context, g glue.Glue) (string, error) {
 	query := "SELECT VARIABLE_VALUE FROM mysql.tidb WHERE VARIABLE_NAME = 'tikv_gc_life_time'"
-	err = common.QueryRowWithRetry(ctx, db, query, &gcLifeTime)
+	gcLifeTime, err := g.ObtainStringWithLog(ctx, query, "(obtain GC lifetime)")
 	return gcLifeTime, errors.Annotatef(err, "%s", query)
 }
 
-func UpdateGCLifeTime(ctx context.Context, db *sql.DB, gcLifeTime string) error {
+func UpdateGCLifeTime(ctx context.Context, g glue.Glue, gcLifeTime string) error {
 	query := "UPDATE mysql.tidb SET VARIABLE_VALUE = ? WHERE VARIABLE_NAME = 'tikv_gc_life_time'"
-	err := common.ExecWithRetry(ctx, db, query, query, gcLifeTime)
+	err := g.ExecuteWithLog(ctx, query, query, gcLifeTime)
 	return errors.Annotatef(err, "%s -- ? = %s", query, gcLifeTime)
 }
 
-func AlterAutoIncrement(ctx context.Context, db *sql.DB, schema string, table string, incr int64) error {
+func AlterAutoIncrement(ctx context.Context, g glue.Glue, schema string, table string, incr int64) error {
 	tableName := common.UniqueTable(schema, table)
 	query := fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT=%d", tableName, incr)
 	common.AppLogger.Infof("[%s.%s] %s", schema, table, query)
-	err := common.ExecWithRetry(ctx, db, query, query)
+	err := g.ExecuteWithLog(ctx, query, query)
 	if err != nil {
 		common.AppLogger.Errorf("query failed %v, you should do it manually, err %v", query, err)
 	}
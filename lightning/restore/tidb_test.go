@@ -14,9 +14,26 @@
 package restore
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/types"
+
+	"github.com/pingcap/tidb-lightning/lightning/glue"
+	"github.com/pingcap/tidb-lightning/lightning/worker"
 )
 
 var _ = Suite(&tidbSuite{})
@@ -81,3 +98,255 @@ func (s *tidbSuite) TestCreateTableIfNotExistsStmt(c *C) {
 		createTableIfNotExistsStmt("CREATE TABLE IF NOT EXISTS  `\xcc\xcc\xcc`(`\xdd\xdd\xdd` TINYINT(1));"),
 	)
 }
+
+func (s *tidbSuite) TestParseTableSchema(c *C) {
+	p := parser.New()
+
+	schema, err := parseTableSchema(p, "foo", "db.foo-schema.sql", "CREATE TABLE `foo`(`bar` TINYINT(1), `baz` TEXT);")
+	c.Assert(err, IsNil)
+	c.Assert(schema.createTableStmt, Equals, "CREATE TABLE `foo`(`bar` TINYINT(1), `baz` TEXT);")
+	c.Assert(schema.columns, Equals, 2)
+
+	// table names are case insensitive
+	schema, err = parseTableSchema(p, "Foo", "db.Foo-schema.sql", "create table FOO (bar int);")
+	c.Assert(err, IsNil)
+	c.Assert(schema.columns, Equals, 1)
+
+	schema, err = parseTableSchema(p, "foo", "db.foo-schema.sql", "CREATE TABLE foo LIKE bar;")
+	c.Assert(err, IsNil)
+	c.Assert(schema.columns, Equals, -1)
+	c.Assert(schema.likeSchema, Equals, "")
+	c.Assert(schema.likeTable, Equals, "bar")
+
+	schema, err = parseTableSchema(p, "foo", "db.foo-schema.sql", "CREATE TABLE foo LIKE other.bar;")
+	c.Assert(err, IsNil)
+	c.Assert(schema.likeSchema, Equals, "other")
+	c.Assert(schema.likeTable, Equals, "bar")
+
+	_, err = parseTableSchema(p, "foo", "db.foo-schema.sql", "CREATE TABLE `foo`(`bar` TINYINT(1)")
+	c.Assert(err, ErrorMatches, "(?s)failed to parse schema file db.foo-schema.sql.*")
+
+	_, err = parseTableSchema(p, "foo", "db.foo-schema.sql", "")
+	c.Assert(err, NotNil)
+
+	_, err = parseTableSchema(p, "foo", "db.foo-schema.sql", "DROP TABLE foo;")
+	c.Assert(err, ErrorMatches, "schema file db.foo-schema.sql does not contain a CREATE TABLE statement")
+
+	_, err = parseTableSchema(p, "foo", "db.foo-schema.sql", "CREATE TABLE bar (baz int);")
+	c.Assert(err, ErrorMatches, "schema file db.foo-schema.sql creates table `bar` instead of `foo`")
+}
+
+func (s *tidbSuite) TestLocalSchemaMatches(c *C) {
+	p := parser.New()
+	local, err := parseTableSchema(p, "t", "db.t-schema.sql", "CREATE TABLE t (a int unsigned, b decimal(10,2), c varchar(10));")
+	c.Assert(err, IsNil)
+	target := func(createTable string) *model.TableInfo {
+		tbl, err := offlineTableInfo(p, createTable, 1)
+		c.Assert(err, IsNil)
+		return tbl
+	}
+
+	// the lengths do not matter for encoding.
+	c.Assert(local.matches(target("CREATE TABLE t (A INT(10) UNSIGNED, b DECIMAL(10,2), c VARCHAR(20))")), IsTrue)
+	for _, createTable := range []string{
+		"CREATE TABLE t (a int unsigned, b decimal(10,2))",
+		"CREATE TABLE t (a int unsigned, c varchar(10), b decimal(10,2))",
+		"CREATE TABLE t (a int unsigned, x decimal(10,2), c varchar(10))",
+		"CREATE TABLE t (a bigint unsigned, b decimal(10,2), c varchar(10))",
+		"CREATE TABLE t (a int, b decimal(10,2), c varchar(10))",
+		"CREATE TABLE t (a int unsigned, b decimal(10,3), c varchar(10))",
+	} {
+		c.Assert(local.matches(target(createTable)), IsFalse, Commentf("target: %s", createTable))
+	}
+}
+
+func (s *tidbSuite) TestSchemaCache(c *C) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		c.Assert(req.URL.Path, Equals, "/schema/db")
+		json.NewEncoder(w).Encode([]*model.TableInfo{
+			{
+				ID:    1234,
+				Name:  model.NewCIStr("t"),
+				State: model.StatePublic,
+				Columns: []*model.ColumnInfo{
+					{Name: model.NewCIStr("a"), State: model.StatePublic, FieldType: types.FieldType{Tp: mysql.TypeLong}},
+				},
+			},
+			{
+				ID:    1235,
+				Name:  model.NewCIStr("U"),
+				State: model.StatePublic,
+				Columns: []*model.ColumnInfo{
+					{Name: model.NewCIStr("b"), State: model.StatePublic, FieldType: types.FieldType{Tp: mysql.TypeLong}},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL)
+	c.Assert(err, IsNil)
+	timgr := NewTiDBManagerWithGlue(glue.NewExternalTiDBGlue(nil, baseURL))
+
+	p := parser.New()
+	localT, err := parseTableSchema(p, "t", "db.t-schema.sql", "CREATE TABLE t (a int);")
+	c.Assert(err, IsNil)
+	localU, err := parseTableSchema(p, "u", "db.u-schema.sql", "CREATE TABLE u (b int);")
+	c.Assert(err, IsNil)
+	localSchemas := map[string]map[string]*localTableSchema{
+		"db": {"t": localT, "u": localU},
+	}
+	ctx := context.Background()
+	sc := newSchemaCache(timgr, localSchemas, worker.NewPool(ctx, 1, "test"))
+
+	dbInfo, tableInfo, err := sc.getTableInfo(ctx, "db", "t")
+	c.Assert(err, IsNil)
+	c.Assert(dbInfo.Name, Equals, "db")
+	c.Assert(tableInfo.ID, Equals, int64(1234))
+	c.Assert(tableInfo.Columns, Equals, 1)
+	c.Assert(tableInfo.CreateTableStmt, Equals, "CREATE TABLE t (a int);")
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(1))
+
+	// the second call should be served from the cache.
+	_, tableInfo2, err := sc.getTableInfo(ctx, "db", "t")
+	c.Assert(err, IsNil)
+	c.Assert(tableInfo2, Equals, tableInfo)
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(1))
+
+	// the other tables of the database are fetched together, and the table
+	// names are case insensitive.
+	_, tableInfo, err = sc.getTableInfo(ctx, "db", "u")
+	c.Assert(err, IsNil)
+	c.Assert(tableInfo.ID, Equals, int64(1235))
+	c.Assert(tableInfo.CreateTableStmt, Equals, "CREATE TABLE u (b int);")
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(1))
+
+	_, _, err = sc.getTableInfo(ctx, "db", "v")
+	c.Assert(err, ErrorMatches, "table `db`.`v` does not exist in the target")
+
+	// the failure of a canceled fetch is not cached.
+	pool := worker.NewPool(ctx, 1, "test")
+	sc = newSchemaCache(timgr, localSchemas, pool)
+	busy := pool.Apply()
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = sc.getTableInfo(canceledCtx, "db", "t")
+	c.Assert(errors.Cause(err), Equals, context.Canceled)
+	pool.Recycle(busy)
+	_, tableInfo, err = sc.getTableInfo(ctx, "db", "t")
+	c.Assert(err, IsNil)
+	c.Assert(tableInfo.ID, Equals, int64(1234))
+}
+
+func (s *tidbSuite) TestQualifyCreateTableStmt(c *C) {
+	c.Assert(
+		qualifyCreateTableStmt("CREATE TABLE `foo`(`bar` TINYINT(1));", "db"),
+		Equals,
+		"CREATE TABLE `db`.`foo`(`bar` TINYINT(1));",
+	)
+	c.Assert(
+		qualifyCreateTableStmt("/* comment */ create table if not exists foo (bar int);", "d`b"),
+		Equals,
+		"/* comment */ create table if not exists `d``b`.foo (bar int);",
+	)
+	c.Assert(
+		qualifyCreateTableStmt("CREATE TABLE `fo``o` LIKE bar;", "db"),
+		Equals,
+		"CREATE TABLE `db`.`fo``o` LIKE `db`.bar;",
+	)
+	c.Assert(
+		qualifyCreateTableStmt("CREATE TABLE foo (LIKE other.bar);", "db"),
+		Equals,
+		"CREATE TABLE `db`.foo (LIKE other.bar);",
+	)
+
+	// already qualified
+	c.Assert(
+		qualifyCreateTableStmt("CREATE TABLE `other` . `foo` (bar int);", "db"),
+		Equals,
+		"CREATE TABLE `other` . `foo` (bar int);",
+	)
+}
+
+// recordingGlue records the statements executed in order.
+type recordingGlue struct {
+	lock  sync.Mutex
+	stmts []string
+}
+
+func (g *recordingGlue) ExecuteWithLog(ctx context.Context, query string, purpose string, args ...interface{}) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.stmts = append(g.stmts, query)
+	return nil
+}
+
+func (g *recordingGlue) ObtainStringWithLog(context.Context, string, string) (string, error) {
+	return "", nil
+}
+
+func (g *recordingGlue) GetTables(context.Context, string) ([]*model.TableInfo, error) {
+	return nil, nil
+}
+
+func (g *recordingGlue) GetDB() *sql.DB {
+	return nil
+}
+
+func (g *recordingGlue) Close() {}
+
+func (g *recordingGlue) indexOf(c *C, substr string) int {
+	for i, stmt := range g.stmts {
+		if strings.Contains(stmt, substr) {
+			return i
+		}
+	}
+	c.Fatalf("statement containing %s not executed", substr)
+	return -1
+}
+
+func (s *tidbSuite) TestInitSchemas(c *C) {
+	g := &recordingGlue{}
+	timgr := NewTiDBManagerWithGlue(g)
+	schemas := map[string]map[string]*localTableSchema{
+		"a": {
+			"t1": {createTableStmt: "CREATE TABLE t1 (x int);"},
+			"t2": {createTableStmt: "CREATE TABLE t2 LIKE b.t3;", likeSchema: "b", likeTable: "t3"},
+		},
+		"b": {
+			"t3": {createTableStmt: "CREATE TABLE t3 LIKE T4;", likeTable: "T4"},
+			"t4": {createTableStmt: "CREATE TABLE t4 (y int);"},
+			"t5": {createTableStmt: "CREATE TABLE t5 LIKE missing;", likeTable: "missing"},
+		},
+	}
+	ctx := context.Background()
+	err := timgr.InitSchemas(ctx, schemas, worker.NewPool(ctx, 4, "test"))
+	c.Assert(err, IsNil)
+	c.Assert(g.stmts, HasLen, 7)
+
+	createA := g.indexOf(c, "CREATE DATABASE IF NOT EXISTS `a`")
+	createB := g.indexOf(c, "CREATE DATABASE IF NOT EXISTS `b`")
+	t1 := g.indexOf(c, "`a`.t1 ")
+	t2 := g.indexOf(c, "`a`.t2 ")
+	t3 := g.indexOf(c, "`b`.t3 ")
+	t4 := g.indexOf(c, "`b`.t4 ")
+	t5 := g.indexOf(c, "`b`.t5 ")
+	c.Assert(createA < t1 && createA < t2, IsTrue)
+	c.Assert(createB < t3 && createB < t4 && createB < t5, IsTrue)
+	c.Assert(t4 < t3 && t3 < t2, IsTrue)
+
+	// a cycle of CREATE TABLE ... LIKE is rejected before executing anything.
+	g = &recordingGlue{}
+	timgr = NewTiDBManagerWithGlue(g)
+	schemas = map[string]map[string]*localTableSchema{
+		"a": {
+			"t1": {createTableStmt: "CREATE TABLE t1 LIKE t2;", likeTable: "t2"},
+			"t2": {createTableStmt: "CREATE TABLE t2 LIKE t1;", likeTable: "t1"},
+		},
+	}
+	err = timgr.InitSchemas(ctx, schemas, worker.NewPool(ctx, 4, "test"))
+	c.Assert(err, ErrorMatches, "the schema of table `a`.`t[12]` depends on itself through CREATE TABLE ... LIKE")
+	c.Assert(g.stmts, HasLen, 0)
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	tmysql "github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+// tiflashSyncPollInterval is how often the TiFlash replicas are checked while
+// waiting for them to catch up.
+var tiflashSyncPollInterval = 10 * time.Second
+
+// tiflashReplica is the TiFlash replica setting of a table.
+type tiflashReplica struct {
+	count uint64
+	// locationLabels is a comma-separated list, as shown in
+	// information_schema.tiflash_replica.
+	locationLabels string
+}
+
+// alterTableStmt returns the statement applying the replica setting to the
+// table.
+func (r tiflashReplica) alterTableStmt(tableName string) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "ALTER TABLE %s SET TIFLASH REPLICA %d", tableName, r.count)
+	if r.count > 0 && len(r.locationLabels) > 0 {
+		buf.WriteString(" LOCATION LABELS ")
+		for i, label := range strings.Split(r.locationLabels, ",") {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteByte('\'')
+			buf.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(strings.TrimSpace(label)))
+			buf.WriteByte('\'')
+		}
+	}
+	return buf.String()
+}
+
+// tiflashReplicaStatus is the replica setting of a table and how far the
+// replicas have caught up.
+type tiflashReplicaStatus struct {
+	tiflashReplica
+	available bool
+	progress  float64
+}
+
+func (s *tiflashReplicaStatus) synced() bool {
+	return s.available && s.progress >= 1
+}
+
+// fetchTiFlashReplica queries the TiFlash replicas of the table. A table
+// without replicas, or a target TiDB not supporting TiFlash, reports a zero
+// replica count.
+func fetchTiFlashReplica(ctx context.Context, db *sql.DB, schema string, table string) (*tiflashReplicaStatus, error) {
+	var (
+		status    tiflashReplicaStatus
+		available int
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT REPLICA_COUNT, LOCATION_LABELS, AVAILABLE, PROGRESS
+		FROM information_schema.tiflash_replica
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+	`, schema, table).Scan(&status.count, &status.locationLabels, &available, &status.progress)
+	switch {
+	case err == sql.ErrNoRows || isUnknownTableError(err):
+		return &tiflashReplicaStatus{}, nil
+	case err != nil:
+		return nil, errors.Annotatef(err, "query TiFlash replica of %s", common.UniqueTable(schema, table))
+	}
+	status.available = available != 0
+	return &status, nil
+}
+
+func isUnknownTableError(err error) bool {
+	merr, ok := errors.Cause(err).(*mysql.MySQLError)
+	return ok && (merr.Number == tmysql.ErrNoSuchTable || merr.Number == tmysql.ErrUnknownTable)
+}
+
+// prepareTiFlashReplica runs before importing the table. In "reset" mode, the
+// replica setting is recorded in the checkpoint before setting the replica
+// count to 0, so it can still be restored if Lightning is restarted.
+func (t *TableRestore) prepareTiFlashReplica(ctx context.Context, rc *RestoreController, cp *TableCheckpoint) error {
+	db := rc.tidbMgr.glue.GetDB()
+	mode := rc.cfg.PostRestore.TiFlashReplica
+
+	if cp.TiFlashReplicaCount == 0 {
+		status, err := fetchTiFlashReplica(ctx, db, t.tableMeta.DB, t.tableMeta.Name)
+		if err != nil || status.count == 0 {
+			return errors.Trace(err)
+		}
+
+		switch mode {
+		case config.TiFlashReplicaIgnore:
+			common.AppLogger.Warnf(
+				"[%s] the table has %d TiFlash replicas, which will replay all imported data. "+
+					"consider setting post-restore.tiflash-replica to \"reset\"",
+				t.tableName, status.count,
+			)
+			return nil
+		case config.TiFlashReplicaWait:
+			common.AppLogger.Infof("[%s] the table has %d TiFlash replicas, will wait for them after import", t.tableName, status.count)
+			return nil
+		}
+
+		diff := NewTableCheckpointDiff()
+		merger := &TiFlashReplicaCheckpointMerger{Count: status.count, LocationLabels: status.locationLabels}
+		merger.MergeInto(diff)
+		if err := rc.checkpointsDB.Update(map[string]*TableCheckpointDiff{t.tableName: diff}); err != nil {
+			return errors.Trace(err)
+		}
+		cp.TiFlashReplicaCount = status.count
+		cp.TiFlashLocationLabels = status.locationLabels
+	}
+
+	if mode != config.TiFlashReplicaReset {
+		return nil
+	}
+	query := tiflashReplica{}.alterTableStmt(t.tableName)
+	common.AppLogger.Infof("[%s] removing %d TiFlash replicas during import", t.tableName, cp.TiFlashReplicaCount)
+	return errors.Annotatef(rc.tidbMgr.glue.ExecuteWithLog(ctx, query, query), "%s", query)
+}
+
+// restoreTiFlashReplica adds back the TiFlash replicas removed by
+// prepareTiFlashReplica.
+func (t *TableRestore) restoreTiFlashReplica(ctx context.Context, rc *RestoreController, cp *TableCheckpoint) error {
+	if cp.TiFlashReplicaCount == 0 {
+		return nil
+	}
+	replica := tiflashReplica{count: cp.TiFlashReplicaCount, locationLabels: cp.TiFlashLocationLabels}
+	query := replica.alterTableStmt(t.tableName)
+	common.AppLogger.Infof("[%s] %s", t.tableName, query)
+	err := rc.tidbMgr.glue.ExecuteWithLog(ctx, query, query)
+	if err != nil {
+		common.AppLogger.Errorf("[%s] failed to restore TiFlash replicas, you should do it manually: %s", t.tableName, query)
+	}
+	return errors.Annotatef(err, "%s", query)
+}
+
+// waitTiFlashReplica blocks until the TiFlash replicas of the table are
+// available and have caught up with the imported data.
+func (t *TableRestore) waitTiFlashReplica(ctx context.Context, rc *RestoreController) error {
+	db := rc.tidbMgr.glue.GetDB()
+	timer := time.Now()
+
+	ticker := time.NewTicker(tiflashSyncPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := fetchTiFlashReplica(ctx, db, t.tableMeta.DB, t.tableMeta.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if status.count == 0 || status.synced() {
+			break
+		}
+		common.AppLogger.Infof("[%s] waiting for %d TiFlash replicas, progress %.1f%%", t.tableName, status.count, status.progress*100)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	common.AppLogger.Infof("[%s] TiFlash replicas are in sync, waited %v", t.tableName, time.Since(timer))
+	return nil
+}
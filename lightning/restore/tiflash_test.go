@@ -0,0 +1,42 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&tiflashSuite{})
+
+type tiflashSuite struct{}
+
+func (s *tiflashSuite) TestAlterTableStmt(c *C) {
+	c.Assert(tiflashReplica{}.alterTableStmt("`db`.`t`"), Equals, "ALTER TABLE `db`.`t` SET TIFLASH REPLICA 0")
+	c.Assert(tiflashReplica{count: 0, locationLabels: "zone"}.alterTableStmt("`db`.`t`"), Equals, "ALTER TABLE `db`.`t` SET TIFLASH REPLICA 0")
+	c.Assert(tiflashReplica{count: 2}.alterTableStmt("`db`.`t`"), Equals, "ALTER TABLE `db`.`t` SET TIFLASH REPLICA 2")
+	c.Assert(
+		tiflashReplica{count: 2, locationLabels: "zone, host,it's"}.alterTableStmt("`db`.`t`"),
+		Equals,
+		"ALTER TABLE `db`.`t` SET TIFLASH REPLICA 2 LOCATION LABELS 'zone', 'host', 'it''s'",
+	)
+}
+
+func (s *tiflashSuite) TestReplicaSynced(c *C) {
+	status := &tiflashReplicaStatus{tiflashReplica: tiflashReplica{count: 1}, progress: 1}
+	c.Assert(status.synced(), IsFalse)
+	status.available = true
+	c.Assert(status.synced(), IsTrue)
+	status.progress = 0.5
+	c.Assert(status.synced(), IsFalse)
+}
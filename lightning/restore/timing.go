@@ -0,0 +1,73 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tidb-lightning/lightning/metric"
+)
+
+type timingStep int
+
+const (
+	stepRead timingStep = iota
+	stepEncode
+	stepDeliver
+	stepClose
+	stepImport
+	stepChecksum
+	stepAnalyze
+	numTimingSteps
+)
+
+var timingStepNames = [numTimingSteps]string{"read", "encode", "deliver", "close", "import", "checksum", "analyze"}
+
+// tableTiming is the time spent in each step of restoring a table, to explain
+// why a table took long. The read, encode and deliver steps are summed over
+// the chunks restored concurrently, so they may exceed the wall-clock time. A
+// nil timing records nothing.
+type tableTiming [numTimingSteps]int64
+
+func (t *tableTiming) add(step timingStep, d time.Duration) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t[step], int64(d))
+}
+
+func (t *tableTiming) get(step timingStep) time.Duration {
+	return time.Duration(atomic.LoadInt64(&t[step]))
+}
+
+// observe records the timing of a completed table into the metrics.
+func (t *tableTiming) observe() {
+	for step, name := range timingStepNames {
+		metric.TableStepSecondsHistogram.WithLabelValues(name).Observe(t.get(timingStep(step)).Seconds())
+	}
+}
+
+func (t *tableTiming) String() string {
+	var builder strings.Builder
+	for step, name := range timingStepNames {
+		if step > 0 {
+			builder.WriteString(", ")
+		}
+		fmt.Fprintf(&builder, "%s: %v", name, t.get(timingStep(step)).Round(time.Millisecond))
+	}
+	return builder.String()
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&timingSuite{})
+
+type timingSuite struct{}
+
+func (s *timingSuite) TestTableTiming(c *C) {
+	timing := new(tableTiming)
+	timing.add(stepRead, 2*time.Second)
+	timing.add(stepRead, 500*time.Millisecond)
+	timing.add(stepImport, time.Minute)
+	c.Assert(timing.String(), Equals, "read: 2.5s, encode: 0s, deliver: 0s, close: 0s, import: 1m0s, checksum: 0s, analyze: 0s")
+
+	var nilTiming *tableTiming
+	nilTiming.add(stepRead, time.Second)
+}
@@ -0,0 +1,325 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/mydump"
+)
+
+// sampledRow is a row read from the data source to be compared with TiDB.
+type sampledRow struct {
+	path string
+	// pos is the offset where the row ends.
+	pos     int64
+	row     []byte
+	columns []string
+	values  [][]byte
+}
+
+// validateSampledRows reads `post-restore.validate-rows` random rows from the
+// data files of the table, and checks that TiDB holds the same values in the
+// rows of the same primary key. This gives content-level assurance beyond the
+// checksum, which only proves that the same KV pairs have been imported. The
+// stored generated columns of these rows, which are computed by the encoder
+// rather than TiDB, are also compared with their re-evaluated expressions.
+func (t *TableRestore) validateSampledRows(ctx context.Context, rc *RestoreController, cp *TableCheckpoint) error {
+	rows := rc.cfg.PostRestore.ValidateRows
+	if rows <= 0 {
+		return nil
+	}
+	pkColumns := primaryKeyColumns(t.tableInfo.core)
+	if len(pkColumns) == 0 {
+		common.AppLogger.Infof("[%s] skip validating the sampled rows, the table has no primary key", t.tableName)
+		return nil
+	}
+	timer := time.Now()
+
+	samples, err := t.sampleRows(rc, cp, rows)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// the values are SQL literals written under tidb.sql-mode, so they must
+	// be parsed in a session of the same mode.
+	conn, err := rc.tidbMgr.glue.GetDB().Conn(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET SESSION sql_mode = ?", rc.cfg.TiDB.SQLMode); err != nil {
+		return errors.Annotate(err, "cannot set the sql_mode to validate the sampled rows")
+	}
+
+	validated := 0
+	for _, sample := range samples {
+		query, columns := t.validationQuery(sample, pkColumns)
+		if query == "" {
+			continue
+		}
+		matches := make([]sql.NullInt64, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range matches {
+			dest[i] = &matches[i]
+		}
+		err := conn.QueryRowContext(ctx, query).Scan(dest...)
+		switch {
+		case err == sql.ErrNoRows:
+			return common.ErrChecksumMismatch.Errorf("the row %s ending at %s:%d is missing in TiDB",
+				common.RedactValues(string(sample.row)), sample.path, sample.pos)
+		case err != nil:
+			return errors.Annotatef(err, "cannot validate the row ending at %s:%d", sample.path, sample.pos)
+		}
+
+		var mismatched []string
+		for i, match := range matches {
+			if !match.Valid || match.Int64 == 0 {
+				mismatched = append(mismatched, columns[i])
+			}
+		}
+		if len(mismatched) > 0 {
+			return common.ErrChecksumMismatch.Errorf("the row %s ending at %s:%d differs from TiDB in %s",
+				common.RedactValues(string(sample.row)), sample.path, sample.pos, strings.Join(mismatched, ", "))
+		}
+		validated++
+	}
+
+	common.AppLogger.Infof("[%s] validated %d of %d sampled rows (the rest lack a primary key column), takes %v",
+		t.tableName, validated, len(samples), time.Since(timer))
+	return nil
+}
+
+// primaryKeyColumns returns the names of the primary key columns of the
+// table, or nil if it has no primary key.
+func primaryKeyColumns(tableInfo *model.TableInfo) []string {
+	if tableInfo.PKIsHandle {
+		if pk := tableInfo.GetPkColInfo(); pk != nil {
+			return []string{pk.Name.O}
+		}
+	}
+	for _, index := range tableInfo.Indices {
+		if !index.Primary {
+			continue
+		}
+		names := make([]string, 0, len(index.Columns))
+		for _, column := range index.Columns {
+			names = append(names, column.Name.O)
+		}
+		return names
+	}
+	return nil
+}
+
+// sampleRows reads `rows` random rows from the chunks of the table. The chunks
+// are picked with the probability proportional to their size, and each picked
+// chunk is read through once.
+func (t *TableRestore) sampleRows(rc *RestoreController, cp *TableCheckpoint, rows int) ([]*sampledRow, error) {
+	var chunks []*ChunkCheckpoint
+	var totalSize int64
+	for _, engine := range cp.Engines {
+		for _, chunk := range engine.Chunks {
+			// the rows of the quarantined chunks are not all imported.
+			if chunk.Quarantined {
+				continue
+			}
+			if size := chunk.Chunk.EndOffset - chunk.Key.Offset; size > 0 {
+				chunks = append(chunks, chunk)
+				totalSize += size
+			}
+		}
+	}
+	if totalSize == 0 {
+		return nil, nil
+	}
+
+	counts := make(map[*ChunkCheckpoint]int)
+	for i := 0; i < rows; i++ {
+		r := rand.Int63n(totalSize)
+		for _, chunk := range chunks {
+			r -= chunk.Chunk.EndOffset - chunk.Key.Offset
+			if r < 0 {
+				counts[chunk]++
+				break
+			}
+		}
+	}
+
+	samples := make([]*sampledRow, 0, rows)
+	for chunk, count := range counts {
+		chunkSamples, err := t.sampleChunkRows(rc, chunk, count)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		samples = append(samples, chunkSamples...)
+	}
+	return samples, nil
+}
+
+// sampleChunkRows picks `count` random rows from the whole chunk by reservoir
+// sampling.
+func (t *TableRestore) sampleChunkRows(rc *RestoreController, chunk *ChunkCheckpoint, count int) ([]*sampledRow, error) {
+	path := chunk.Key.Path
+	// the chunk checkpoint has advanced to the end, so read from the start.
+	fullChunk := &ChunkCheckpoint{
+		Key:   chunk.Key,
+		Chunk: mydump.Chunk{Offset: chunk.Key.Offset, EndOffset: chunk.Chunk.EndOffset},
+	}
+	fixedWidth := rc.cfg.Mydumper.FindFixedWidthRule(t.tableMeta.DB, t.tableMeta.Name)
+	projection := rc.cfg.Mydumper.FindProjectionRule(t.tableMeta.DB, t.tableMeta.Name)
+	filter := rc.cfg.Mydumper.FindFilterRule(t.tableMeta.DB, t.tableMeta.Name)
+	transforms := t.transformRules(&rc.cfg.Mydumper)
+	tableColumns := t.assignableColumns()
+	cr, err := newChunkRestore(0, fullChunk, path, rc.cfg.Mydumper.ReadBlockSize, rc.cfg.Mydumper.ReadAhead, rc.sqlMode, tableColumns, fixedWidth, projection, filter, transforms, nil, rc.ioWorkers)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer cr.close()
+
+	samples := make([]*sampledRow, 0, count)
+	seen := 0
+	var lastColumns []byte
+	columns := tableColumns
+	for cr.parser.Pos() < fullChunk.Chunk.EndOffset {
+		err := cr.parser.ReadRow()
+		switch errors.Cause(err) {
+		case nil:
+		case io.EOF:
+			return samples, nil
+		default:
+			return nil, common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", path, cr.parser.Pos())
+		}
+
+		seen++
+		i := len(samples)
+		if i >= count {
+			if i = rand.Intn(seen); i >= count {
+				continue
+			}
+		}
+
+		// the row and the columns are only valid until the next read.
+		if parserColumns := cr.parser.Columns(); string(parserColumns) != string(lastColumns) {
+			lastColumns = append(lastColumns[:0], parserColumns...)
+			columns = tableColumns
+			if len(parserColumns) > 0 {
+				if columns, err = mydump.SplitColumns(parserColumns, rc.sqlMode); err != nil {
+					return nil, common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", path, cr.parser.Pos())
+				}
+			}
+		}
+		row := append([]byte(nil), cr.parser.LastRow().Row...)
+		values, err := mydump.SplitRow(row, rc.sqlMode)
+		if err != nil {
+			return nil, common.ErrInvalidSource.Annotatef(err, "failed to read %s at offset %d", path, cr.parser.Pos())
+		}
+		sample := &sampledRow{path: path, pos: cr.parser.Pos(), row: row, columns: columns, values: values}
+		if i < len(samples) {
+			samples[i] = sample
+		} else {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// validationQuery builds the statement selecting the row of the same primary
+// key as the sample, with whether each column equals the sampled value, and
+// whether each stored generated column equals its expression. Returns the
+// query and the description of each comparison, or an empty query if the
+// sample lacks any primary key column.
+func (t *TableRestore) validationQuery(sample *sampledRow, pkColumns []string) (string, []string) {
+	values := make(map[string][]byte, len(sample.columns))
+	for i, column := range sample.columns {
+		if i < len(sample.values) {
+			values[strings.ToLower(column)] = sample.values[i]
+		}
+	}
+	columnTypes := make(map[string]byte, len(t.tableInfo.core.Columns))
+	for _, columnInfo := range t.tableInfo.core.Columns {
+		columnTypes[columnInfo.Name.L] = columnInfo.Tp
+	}
+
+	var where strings.Builder
+	for i, column := range pkColumns {
+		value, ok := values[strings.ToLower(column)]
+		if !ok || strings.EqualFold(string(value), "NULL") {
+			return "", nil
+		}
+		if i > 0 {
+			where.WriteString(" AND ")
+		}
+		common.WriteMySQLIdentifier(&where, column)
+		where.WriteString(" = ")
+		where.Write(value)
+	}
+
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	columns := make([]string, 0, len(sample.columns))
+	for i, column := range sample.columns {
+		if i >= len(sample.values) {
+			break
+		}
+		value := sample.values[i]
+		switch columnTypes[strings.ToLower(column)] {
+		case mysql.TypeFloat:
+			// single precision values differ from the double literals.
+			continue
+		case mysql.TypeJSON:
+			value = []byte("CAST(" + string(value) + " AS JSON)")
+		}
+		if len(columns) > 0 {
+			query.WriteString(", ")
+		}
+		common.WriteMySQLIdentifier(&query, column)
+		query.WriteString(" <=> ")
+		query.Write(value)
+		columns = append(columns, column)
+	}
+	for _, columnInfo := range t.tableInfo.core.Columns {
+		// the virtual columns are evaluated by TiDB when read, so they always
+		// equal the expressions.
+		if !columnInfo.IsGenerated() || !columnInfo.GeneratedStored || columnInfo.Tp == mysql.TypeFloat {
+			continue
+		}
+		if len(columns) > 0 {
+			query.WriteString(", ")
+		}
+		common.WriteMySQLIdentifier(&query, columnInfo.Name.O)
+		query.WriteString(" <=> (")
+		query.WriteString(columnInfo.GeneratedExprString)
+		query.WriteString(")")
+		columns = append(columns, fmt.Sprintf("%s (generated as %s)", columnInfo.Name.O, columnInfo.GeneratedExprString))
+	}
+	if len(columns) == 0 {
+		return "", nil
+	}
+	query.WriteString(" FROM ")
+	query.WriteString(t.tableName)
+	query.WriteString(" WHERE ")
+	query.WriteString(where.String())
+	return query.String(), columns
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+)
+
+var _ = Suite(&validateSuite{})
+
+type validateSuite struct{}
+
+func newValidateColumn(name string, tp byte, flag uint) *model.ColumnInfo {
+	column := &model.ColumnInfo{Name: model.NewCIStr(name)}
+	column.Tp = tp
+	column.Flag = flag
+	return column
+}
+
+func newValidateGeneratedColumn(name string, tp byte, expr string, stored bool) *model.ColumnInfo {
+	column := newValidateColumn(name, tp, 0)
+	column.GeneratedExprString = expr
+	column.GeneratedStored = stored
+	return column
+}
+
+func (s *validateSuite) TestPrimaryKeyColumns(c *C) {
+	handle := &model.TableInfo{
+		PKIsHandle: true,
+		Columns: []*model.ColumnInfo{
+			newValidateColumn("a", mysql.TypeVarchar, 0),
+			newValidateColumn("ID", mysql.TypeLong, mysql.PriKeyFlag),
+		},
+	}
+	c.Assert(primaryKeyColumns(handle), DeepEquals, []string{"ID"})
+
+	composite := &model.TableInfo{
+		Indices: []*model.IndexInfo{
+			{Name: model.NewCIStr("u"), Unique: true, Columns: []*model.IndexColumn{{Name: model.NewCIStr("a")}}},
+			{Name: model.NewCIStr("PRIMARY"), Primary: true, Columns: []*model.IndexColumn{
+				{Name: model.NewCIStr("b")}, {Name: model.NewCIStr("c")},
+			}},
+		},
+	}
+	c.Assert(primaryKeyColumns(composite), DeepEquals, []string{"b", "c"})
+
+	c.Assert(primaryKeyColumns(&model.TableInfo{}), IsNil)
+}
+
+func (s *validateSuite) TestValidationQuery(c *C) {
+	tr := &TableRestore{
+		tableName: "`db`.`t`",
+		tableInfo: &TidbTableInfo{core: &model.TableInfo{
+			Columns: []*model.ColumnInfo{
+				newValidateColumn("id", mysql.TypeLong, mysql.PriKeyFlag),
+				newValidateColumn("f", mysql.TypeFloat, 0),
+				newValidateColumn("j", mysql.TypeJSON, 0),
+				newValidateColumn("s", mysql.TypeVarchar, 0),
+				newValidateGeneratedColumn("g", mysql.TypeLong, "`id` + 1", true),
+				newValidateGeneratedColumn("v", mysql.TypeLong, "`id` * 2", false),
+			},
+		}},
+	}
+
+	query, columns := tr.validationQuery(&sampledRow{
+		columns: []string{"ID", "f", "j", "s"},
+		values:  [][]byte{[]byte("7"), []byte("1.5"), []byte(`'{"a":1}'`), []byte("'x'")},
+	}, []string{"id"})
+	c.Assert(query, Equals, "SELECT `ID` <=> 7, `j` <=> CAST('{\"a\":1}' AS JSON), `s` <=> 'x', `g` <=> (`id` + 1) FROM `db`.`t` WHERE `id` = 7")
+	c.Assert(columns, DeepEquals, []string{"ID", "j", "s", "g (generated as `id` + 1)"})
+
+	// the primary key is missing or NULL, e.g. filled by AUTO_INCREMENT.
+	query, _ = tr.validationQuery(&sampledRow{
+		columns: []string{"s"},
+		values:  [][]byte{[]byte("'x'")},
+	}, []string{"id"})
+	c.Assert(query, Equals, "")
+	query, _ = tr.validationQuery(&sampledRow{
+		columns: []string{"id", "s"},
+		values:  [][]byte{[]byte("null"), []byte("'x'")},
+	}, []string{"id"})
+	c.Assert(query, Equals, "")
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/tidb-lightning/lightning/common"
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+const maxStalledEngineRetry = 3
+
+var errEngineStalled = errors.New("engine made no progress within the stall timeout")
+
+type engineActivity struct {
+	lastProgress time.Time
+	lastEvent    string
+	cancel       context.CancelFunc
+	stalled      bool
+}
+
+// stallWatchdog tracks the last progress of the engines being written, and
+// reports the engines which made no progress within the stall timeout. The
+// import step is not watched, since the Import RPC reports no progress until
+// it is done. A nil watchdog watches nothing.
+type stallWatchdog struct {
+	cfg     config.Watchdog
+	mu      sync.Mutex
+	engines map[string]*engineActivity
+}
+
+func newStallWatchdog(cfg *config.Watchdog) *stallWatchdog {
+	if cfg.StallTimeout.Duration <= 0 {
+		return nil
+	}
+	return &stallWatchdog{
+		cfg:     *cfg,
+		engines: make(map[string]*engineActivity),
+	}
+}
+
+// checkInterval is how often the watchdog should be checked.
+func (w *stallWatchdog) checkInterval() time.Duration {
+	interval := w.cfg.StallTimeout.Duration / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// watch starts watching the engine. The returned context is canceled when the
+// engine is found stalled and `retry-stalled-engine` is enabled. The returned
+// function stops watching and reports whether the engine was stalled.
+func (w *stallWatchdog) watch(ctx context.Context, tag string) (context.Context, func() bool) {
+	if w == nil {
+		return ctx, func() bool { return false }
+	}
+	engineCtx, cancel := context.WithCancel(ctx)
+	w.mu.Lock()
+	w.engines[tag] = &engineActivity{lastProgress: time.Now(), lastEvent: "opened", cancel: cancel}
+	w.mu.Unlock()
+
+	return engineCtx, func() bool {
+		w.mu.Lock()
+		activity := w.engines[tag]
+		delete(w.engines, tag)
+		w.mu.Unlock()
+		cancel()
+		return activity != nil && activity.stalled && w.cfg.RetryStalledEngine
+	}
+}
+
+// progress records that the engine has made some progress.
+func (w *stallWatchdog) progress(tag string, event string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	if activity, ok := w.engines[tag]; ok {
+		activity.lastProgress = time.Now()
+		activity.lastEvent = event
+		activity.stalled = false
+	}
+	w.mu.Unlock()
+}
+
+// check logs the engines which have stalled since the last check, and cancels
+// them if configured.
+func (w *stallWatchdog) check(now time.Time) []string {
+	if w == nil {
+		return nil
+	}
+	var stalledTags []string
+	w.mu.Lock()
+	for tag, activity := range w.engines {
+		idle := now.Sub(activity.lastProgress)
+		if activity.stalled || idle < w.cfg.StallTimeout.Duration {
+			continue
+		}
+		activity.stalled = true
+		stalledTags = append(stalledTags, tag)
+		common.AppLogger.Warnf("[%s] engine made no progress for %v, last event: %s", tag, idle.Round(time.Second), activity.lastEvent)
+		if w.cfg.RetryStalledEngine {
+			activity.cancel()
+		}
+	}
+	w.mu.Unlock()
+
+	if len(stalledTags) > 0 && w.cfg.DumpGoroutines {
+		var buf bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err == nil {
+			common.AppLogger.Warnf("goroutines when %d engines stalled:\n%s", len(stalledTags), buf.String())
+		}
+	}
+	sort.Strings(stalledTags)
+	return stalledTags
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+
+	"github.com/pingcap/tidb-lightning/lightning/config"
+)
+
+var _ = Suite(&watchdogSuite{})
+
+type watchdogSuite struct{}
+
+func (s *watchdogSuite) TestDisabledWatchdog(c *C) {
+	w := newStallWatchdog(&config.Watchdog{})
+	c.Assert(w, IsNil)
+
+	ctx := context.Background()
+	engineCtx, unwatch := w.watch(ctx, "`db`.`t`:0")
+	c.Assert(engineCtx, Equals, ctx)
+	w.progress("`db`.`t`:0", "delivered")
+	c.Assert(w.check(time.Now()), HasLen, 0)
+	c.Assert(unwatch(), IsFalse)
+}
+
+func (s *watchdogSuite) TestStalledEngine(c *C) {
+	w := newStallWatchdog(&config.Watchdog{
+		StallTimeout:       config.Duration{Duration: time.Minute},
+		RetryStalledEngine: true,
+	})
+	c.Assert(w.checkInterval(), Equals, 15*time.Second)
+
+	ctx1, unwatch1 := w.watch(context.Background(), "`db`.`t`:0")
+	ctx2, unwatch2 := w.watch(context.Background(), "`db`.`t`:1")
+
+	c.Assert(w.check(time.Now()), HasLen, 0)
+
+	w.progress("`db`.`t`:1", "delivered")
+	w.engines["`db`.`t`:0"].lastProgress = time.Now().Add(-2 * time.Minute)
+	c.Assert(w.check(time.Now()), DeepEquals, []string{"`db`.`t`:0"})
+	c.Assert(ctx1.Err(), Equals, context.Canceled)
+	c.Assert(ctx2.Err(), IsNil)
+
+	// a stalled engine is reported only once.
+	c.Assert(w.check(time.Now()), HasLen, 0)
+
+	c.Assert(unwatch1(), IsTrue)
+	c.Assert(unwatch2(), IsFalse)
+	c.Assert(w.engines, HasLen, 0)
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lightning
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb-lightning/lightning/restore"
+)
+
+// standaloneTaskID is the ID of the task run by a Lightning not serving the
+// control API.
+const standaloneTaskID = 0
+
+// runningTasks are the Lightning instances running a task, by the task ID,
+// whose state is served on the pprof port.
+var runningTasks = struct {
+	sync.Mutex
+	tasks map[int64]*Lightning
+}{tasks: make(map[int64]*Lightning)}
+
+func registerTask(id int64, l *Lightning) {
+	runningTasks.Lock()
+	runningTasks.tasks[id] = l
+	runningTasks.Unlock()
+}
+
+func unregisterTask(id int64) {
+	runningTasks.Lock()
+	delete(runningTasks.tasks, id)
+	runningTasks.Unlock()
+}
+
+// taskState is the response of the `/tasks/{id}/state` endpoint.
+type taskState struct {
+	ID     int64                `json:"id"`
+	Tables []restore.TableState `json:"tables"`
+}
+
+// restoreState returns the state of the restore controller, or false if the
+// instance is not restoring.
+func (l *Lightning) restoreState() ([]restore.TableState, bool) {
+	l.controllerLock.Lock()
+	defer l.controllerLock.Unlock()
+	if l.controller == nil {
+		return nil, false
+	}
+	return l.controller.State(), true
+}
+
+func (l *Lightning) setController(controller *restore.RestoreController) {
+	l.controllerLock.Lock()
+	l.controller = controller
+	l.controllerLock.Unlock()
+}
+
+// serveTaskState serves `/tasks/{id}/state`, the state of the tables being
+// restored by a running task, to diagnose stuck imports. The task ID is the
+// one assigned by the control API, or 0 without the control API.
+func serveTaskState(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/tasks/"), "/")
+	if len(parts) != 2 || parts[1] != "state" {
+		http.NotFound(w, req)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid task ID "+parts[0], http.StatusBadRequest)
+		return
+	}
+
+	runningTasks.Lock()
+	l, ok := runningTasks.tasks[id]
+	runningTasks.Unlock()
+	var tables []restore.TableState
+	if ok {
+		tables, ok = l.restoreState()
+	}
+	if !ok {
+		http.Error(w, "task "+parts[0]+" is not restoring", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&taskState{ID: id, Tables: tables})
+}
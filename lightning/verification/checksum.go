@@ -14,8 +14,12 @@
 package verification
 
 import (
+	"encoding/json"
+	"fmt"
 	"hash/crc64"
+	"sync"
 
+	"github.com/pingcap/errors"
 	kvec "github.com/pingcap/tidb/util/kvencoder"
 )
 
@@ -50,8 +54,8 @@ func (c *KVChecksum) Update(kvs []kvec.KvPair) {
 	)
 
 	for _, pair := range kvs {
-		sum = crc64.Update(0, ecmaTable, pair.Key)
-		sum = crc64.Update(sum, ecmaTable, pair.Val)
+		sum = updateECMA(0, pair.Key)
+		sum = updateECMA(sum, pair.Val)
 		checksum ^= sum
 		kvNum++
 		bytes += (len(pair.Key) + len(pair.Val))
@@ -68,6 +72,21 @@ func (c *KVChecksum) Add(other *KVChecksum) {
 	c.checksum ^= other.checksum
 }
 
+// Remove removes the KV pairs of `other` from the checksum, e.g. those of a
+// chunk rolled back to be encoded again. The checksum is self-inverse under
+// XOR, while the size and the number of KV pairs are subtracted. Returns an
+// error without modifying the checksum if `other` is larger than it, which
+// means `other` was never added.
+func (c *KVChecksum) Remove(other *KVChecksum) error {
+	if other.bytes > c.bytes || other.kvs > c.kvs {
+		return errors.Errorf("cannot remove %s from %s", other, c)
+	}
+	c.bytes -= other.bytes
+	c.kvs -= other.kvs
+	c.checksum ^= other.checksum
+	return nil
+}
+
 func (c *KVChecksum) Sum() uint64 {
 	return c.checksum
 }
@@ -79,3 +98,68 @@ func (c *KVChecksum) SumSize() uint64 {
 func (c *KVChecksum) SumKVS() uint64 {
 	return c.kvs
 }
+
+func (c KVChecksum) String() string {
+	return fmt.Sprintf("{bytes:%d kvs:%d checksum:%d}", c.bytes, c.kvs, c.checksum)
+}
+
+// kvChecksumJSON is the JSON form of KVChecksum.
+type kvChecksumJSON struct {
+	Bytes    uint64 `json:"bytes"`
+	KVs      uint64 `json:"kvs"`
+	Checksum uint64 `json:"checksum"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the checksum as
+// {"bytes":...,"kvs":...,"checksum":...}.
+func (c KVChecksum) MarshalJSON() ([]byte, error) {
+	return json.Marshal(kvChecksumJSON{Bytes: c.bytes, KVs: c.kvs, Checksum: c.checksum})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *KVChecksum) UnmarshalJSON(data []byte) error {
+	var v kvChecksumJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = MakeKVChecksum(v.Bytes, v.KVs, v.Checksum)
+	return nil
+}
+
+// SyncKVChecksum is a KVChecksum safe for concurrent use, e.g. accumulating
+// the KV pairs delivered by multiple goroutines. The zero value is an empty
+// checksum.
+type SyncKVChecksum struct {
+	mu       sync.Mutex
+	checksum KVChecksum
+}
+
+// Update adds the KV pairs to the checksum.
+func (c *SyncKVChecksum) Update(kvs []kvec.KvPair) {
+	// compute outside the lock, which is only held to merge the result.
+	var checksum KVChecksum
+	checksum.Update(kvs)
+	c.Add(&checksum)
+}
+
+// Add merges the KV pairs of `other` into the checksum.
+func (c *SyncKVChecksum) Add(other *KVChecksum) {
+	c.mu.Lock()
+	c.checksum.Add(other)
+	c.mu.Unlock()
+}
+
+// Remove removes the KV pairs of `other` from the checksum, see
+// KVChecksum.Remove.
+func (c *SyncKVChecksum) Remove(other *KVChecksum) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.checksum.Remove(other)
+}
+
+// Load returns a consistent snapshot of the checksum.
+func (c *SyncKVChecksum) Load() KVChecksum {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.checksum
+}
@@ -14,6 +14,9 @@
 package verification_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 
 	. "github.com/pingcap/check"
@@ -76,3 +79,59 @@ func (s *testKVChcksumSuite) TestChcksum(c *C) {
 	c.Assert(checksum.SumKVS(), Equals, uint64(len(kvs))<<1)
 	c.Assert(uint64NotEqual(checksum.Sum(), excpectChecksum), IsTrue)
 }
+
+func (s *testKVChcksumSuite) TestChecksumJSON(c *C) {
+	checksum := verification.MakeKVChecksum(123, 4, 0xfedcba9876543210)
+	c.Assert(checksum.String(), Equals, "{bytes:123 kvs:4 checksum:18364758544493064720}")
+
+	data, err := json.Marshal(checksum)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `{"bytes":123,"kvs":4,"checksum":18364758544493064720}`)
+
+	var decoded verification.KVChecksum
+	c.Assert(json.Unmarshal(data, &decoded), IsNil)
+	c.Assert(decoded, Equals, checksum)
+}
+
+func (s *testKVChcksumSuite) TestChecksumRemove(c *C) {
+	chunk1 := verification.MakeKVChecksum(10, 1, 0x1111)
+	chunk2 := verification.MakeKVChecksum(20, 3, 0x2222)
+	table := verification.MakeKVChecksum(0, 0, 0)
+	table.Add(&chunk1)
+	table.Add(&chunk2)
+
+	c.Assert(table.Remove(&chunk2), IsNil)
+	c.Assert(table, Equals, chunk1)
+
+	// removing more than the checksum has leaves it unchanged.
+	c.Assert(table.Remove(&chunk2), ErrorMatches, "cannot remove .*")
+	c.Assert(table, Equals, chunk1)
+
+	c.Assert(table.Remove(&chunk1), IsNil)
+	c.Assert(table, Equals, verification.MakeKVChecksum(0, 0, 0))
+}
+
+func (s *testKVChcksumSuite) TestSyncChecksum(c *C) {
+	var expected verification.KVChecksum
+	var checksum verification.SyncKVChecksum
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		kvs := []kvec.KvPair{
+			{Key: []byte(fmt.Sprintf("key%d", i)), Val: []byte("value")},
+			{Key: []byte(fmt.Sprintf("index%d", i)), Val: []byte{}},
+		}
+		expected.Update(kvs)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checksum.Update(kvs)
+		}()
+	}
+	wg.Wait()
+	c.Assert(checksum.Load(), Equals, expected)
+
+	extra := verification.MakeKVChecksum(100, 1, 0x1234)
+	checksum.Add(&extra)
+	c.Assert(checksum.Remove(&extra), IsNil)
+	c.Assert(checksum.Load(), Equals, expected)
+}
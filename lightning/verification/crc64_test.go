@@ -0,0 +1,60 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verification
+
+import (
+	"hash/crc64"
+	"math/rand"
+	"testing"
+
+	. "github.com/pingcap/check"
+)
+
+type testCRC64Suite struct{}
+
+var _ = Suite(&testCRC64Suite{})
+
+func (s *testCRC64Suite) TestUpdateECMA(c *C) {
+	rng := rand.New(rand.NewSource(0))
+	for n := 0; n < 200; n++ {
+		p := make([]byte, n)
+		rng.Read(p)
+		for _, crc := range []uint64{0, 0x123456789abcdef, ^uint64(0)} {
+			c.Assert(updateECMA(crc, p), Equals, crc64.Update(crc, ecmaTable, p), Commentf("length = %d, crc = %x", n, crc))
+		}
+	}
+}
+
+func benchmarkCRC64(b *testing.B, update func(uint64, []byte) uint64) {
+	// typical sizes of a record key and a short row value.
+	key := make([]byte, 19)
+	val := make([]byte, 40)
+	rand.Read(key)
+	rand.Read(val)
+	b.SetBytes(int64(len(key) + len(val)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		update(update(0, key), val)
+	}
+}
+
+func BenchmarkUpdateECMA(b *testing.B) {
+	benchmarkCRC64(b, updateECMA)
+}
+
+func BenchmarkStdlibCRC64(b *testing.B) {
+	benchmarkCRC64(b, func(crc uint64, p []byte) uint64 {
+		return crc64.Update(crc, ecmaTable, p)
+	})
+}
@@ -15,48 +15,240 @@ package worker
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/pingcap/tidb-lightning/lightning/metric"
 )
 
+// Pool is a set of workers, whose size can be changed by SetLimit. When there
+// are not enough workers, they are
+// shared fairly among the groups applying for them: a recycled worker is
+// handed to the waiting group holding the fewest workers, and to the longest
+// waiting applicant within the group. This prevents e.g. one huge table from
+// monopolizing all workers while small tables could have finished quickly.
 type Pool struct {
+	name string
+
+	lock    sync.Mutex
 	limit   int
-	workers chan *Worker
-	name    string
+	idle    []*Worker
+	waiters []*waiter
+	held    map[string]int
+	// size is the number of workers idle or in use, which exceeds the limit
+	// after it is lowered until enough workers are recycled.
+	size int
+	// retired are the workers removed by lowering the limit, which are
+	// reused when the limit is raised again.
+	retired []*Worker
+	nextID  int64
 }
 
 type Worker struct {
 	ID int64
+	// Node is the index of the NUMA node this worker is bound to, or -1 if the
+	// worker is not bound to any node.
+	Node int
+
+	group string
+}
+
+type waiter struct {
+	group string
+	ch    chan *Worker
 }
 
 func NewPool(ctx context.Context, limit int, name string) *Pool {
-	workers := make(chan *Worker, limit)
+	return NewNodePool(ctx, limit, name, nil)
+}
+
+// NewNodePool creates a pool whose workers are distributed among NUMA nodes
+// in proportion to the given weights, e.g. the number of CPUs of each node.
+// The workers of a pool created with empty weights are not bound to any node.
+func NewNodePool(ctx context.Context, limit int, name string, weights []int) *Pool {
+	nodes := distributeWorkers(limit, weights)
+	workers := make([]*Worker, 0, limit)
 	for i := 0; i < limit; i++ {
-		workers <- &Worker{ID: int64(i + 1)}
+		workers = append(workers, &Worker{ID: int64(i + 1), Node: nodes[i]})
 	}
 
 	metric.IdleWorkersGauge.WithLabelValues(name).Set(float64(limit))
 	return &Pool{
-		limit:   limit,
-		workers: workers,
-		name:    name,
+		limit:  limit,
+		name:   name,
+		idle:   workers,
+		held:   make(map[string]int),
+		size:   limit,
+		nextID: int64(limit),
 	}
 }
 
 func (pool *Pool) Apply() *Worker {
-	start := time.Now()
-	worker := <-pool.workers
-	metric.IdleWorkersGauge.WithLabelValues(pool.name).Set(float64(len(pool.workers)))
-	metric.ApplyWorkerSecondsHistogram.WithLabelValues(pool.name).Observe(time.Since(start).Seconds())
+	worker, _ := pool.ApplyFair(context.Background(), "")
 	return worker
 }
 
+// ApplyContext is like Apply, but gives up waiting for a free worker when
+// the context is canceled.
+func (pool *Pool) ApplyContext(ctx context.Context) (*Worker, error) {
+	return pool.ApplyFair(ctx, "")
+}
+
+// ApplyFair is like ApplyContext, but the worker is accounted to the group,
+// e.g. a table, so that the workers are shared fairly among the groups.
+func (pool *Pool) ApplyFair(ctx context.Context, group string) (*Worker, error) {
+	start := time.Now()
+	pool.lock.Lock()
+	if len(pool.idle) > 0 && len(pool.waiters) == 0 {
+		worker := pool.idle[0]
+		pool.idle = pool.idle[1:]
+		pool.assign(worker, group)
+		pool.lock.Unlock()
+		pool.observeApply(start)
+		return worker, nil
+	}
+	w := &waiter{group: group, ch: make(chan *Worker, 1)}
+	pool.waiters = append(pool.waiters, w)
+	pool.lock.Unlock()
+
+	select {
+	case worker := <-w.ch:
+		pool.observeApply(start)
+		return worker, nil
+	case <-ctx.Done():
+	}
+
+	pool.lock.Lock()
+	for i, other := range pool.waiters {
+		if other == w {
+			pool.waiters = append(pool.waiters[:i], pool.waiters[i+1:]...)
+			pool.lock.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	pool.lock.Unlock()
+	// a worker was handed over right before the cancellation.
+	pool.Recycle(<-w.ch)
+	return nil, ctx.Err()
+}
+
 func (pool *Pool) Recycle(worker *Worker) {
-	pool.workers <- worker
-	metric.IdleWorkersGauge.WithLabelValues(pool.name).Set(float64(len(pool.workers)))
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.held[worker.group]--
+	if pool.held[worker.group] <= 0 {
+		delete(pool.held, worker.group)
+	}
+	if pool.size > pool.limit {
+		pool.size--
+		pool.retired = append(pool.retired, worker)
+		return
+	}
+	pool.handOut(worker)
+}
+
+// handOut gives a worker not in use to a waiter, or keeps it idle.
+func (pool *Pool) handOut(worker *Worker) {
+	if len(pool.waiters) == 0 {
+		pool.idle = append(pool.idle, worker)
+		metric.IdleWorkersGauge.WithLabelValues(pool.name).Set(float64(len(pool.idle)))
+		return
+	}
+
+	// the waiters are in the order of arrival, so the first one of the group
+	// holding the fewest workers is picked.
+	best := 0
+	for i, w := range pool.waiters {
+		if pool.held[w.group] < pool.held[pool.waiters[best].group] {
+			best = i
+		}
+	}
+	w := pool.waiters[best]
+	pool.waiters = append(pool.waiters[:best], pool.waiters[best+1:]...)
+	pool.assign(worker, w.group)
+	w.ch <- worker
+}
+
+// Limit returns the number of workers.
+func (pool *Pool) Limit() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return pool.limit
+}
+
+// SetLimit changes the number of workers. When lowered, the idle workers are
+// removed at once, and the workers in use are removed as they are recycled.
+func (pool *Pool) SetLimit(limit int) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.limit = limit
+	for pool.size > limit && len(pool.idle) > 0 {
+		last := len(pool.idle) - 1
+		pool.retired = append(pool.retired, pool.idle[last])
+		pool.idle = pool.idle[:last]
+		pool.size--
+	}
+	for pool.size < limit {
+		var worker *Worker
+		if last := len(pool.retired) - 1; last >= 0 {
+			worker = pool.retired[last]
+			pool.retired = pool.retired[:last]
+		} else {
+			pool.nextID++
+			worker = &Worker{ID: pool.nextID, Node: -1}
+		}
+		pool.size++
+		pool.handOut(worker)
+	}
+	metric.IdleWorkersGauge.WithLabelValues(pool.name).Set(float64(len(pool.idle)))
 }
 
 func (pool *Pool) HasWorker() bool {
-	return len(pool.workers) > 0
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return len(pool.idle) > 0
+}
+
+// assign must be called with the lock held.
+func (pool *Pool) assign(worker *Worker, group string) {
+	worker.group = group
+	pool.held[group]++
+	metric.IdleWorkersGauge.WithLabelValues(pool.name).Set(float64(len(pool.idle)))
+}
+
+func (pool *Pool) observeApply(start time.Time) {
+	metric.ApplyWorkerSecondsHistogram.WithLabelValues(pool.name).Observe(time.Since(start).Seconds())
+}
+
+// distributeWorkers assigns a node to each of the `limit` workers, such that
+// the number of workers on each node is proportional to its weight.
+func distributeWorkers(limit int, weights []int) []int {
+	nodes := make([]int, limit)
+	totalWeight := 0
+	for _, weight := range weights {
+		totalWeight += weight
+	}
+	if totalWeight <= 0 {
+		for i := range nodes {
+			nodes[i] = -1
+		}
+		return nodes
+	}
+
+	// interleave the nodes, so the workers applied first spread evenly.
+	assigned := make([]int, len(weights))
+	for i := range nodes {
+		best := 0
+		for node, weight := range weights {
+			// pick the node which is furthest below its fair share.
+			if assigned[node]*totalWeight-weight*i < assigned[best]*totalWeight-weights[best]*i {
+				best = node
+			}
+		}
+		nodes[i] = best
+		assigned[best]++
+	}
+	return nodes
 }
@@ -16,6 +16,7 @@ package worker_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb-lightning/lightning/worker"
@@ -51,3 +52,108 @@ func (s *testWorkerPool) TestApplyRecycle(c *C) {
 
 	c.Assert(pool.HasWorker(), Equals, false)
 }
+
+func (s *testWorkerPool) TestApplyContext(c *C) {
+	pool := worker.NewPool(context.Background(), 1, "test")
+
+	w, err := pool.ApplyContext(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(w.ID, Equals, int64(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.ApplyContext(ctx)
+	c.Assert(err, Equals, context.Canceled)
+
+	pool.Recycle(w)
+	c.Assert(pool.HasWorker(), IsTrue)
+}
+
+func (s *testWorkerPool) TestNodePool(c *C) {
+	pool := worker.NewNodePool(context.Background(), 6, "test", []int{2, 1})
+
+	nodes := make([]int, 0, 6)
+	for i := 0; i < 6; i++ {
+		nodes = append(nodes, pool.Apply().Node)
+	}
+	c.Assert(nodes, DeepEquals, []int{0, 1, 0, 0, 1, 0})
+
+	pool = worker.NewPool(context.Background(), 2, "test")
+	c.Assert(pool.Apply().Node, Equals, -1)
+	c.Assert(pool.Apply().Node, Equals, -1)
+}
+
+func (s *testWorkerPool) TestApplyFair(c *C) {
+	ctx := context.Background()
+	pool := worker.NewPool(ctx, 2, "test")
+
+	w1, err := pool.ApplyFair(ctx, "big")
+	c.Assert(err, IsNil)
+	w2, err := pool.ApplyFair(ctx, "big")
+	c.Assert(err, IsNil)
+
+	// the big table queues up first, then the small one.
+	bigCh := make(chan *worker.Worker)
+	go func() {
+		w, _ := pool.ApplyFair(ctx, "big")
+		bigCh <- w
+	}()
+	time.Sleep(50 * time.Millisecond)
+	smallCh := make(chan *worker.Worker)
+	go func() {
+		w, _ := pool.ApplyFair(ctx, "small")
+		smallCh <- w
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the small table holds fewer workers, so it goes first.
+	pool.Recycle(w1)
+	c.Assert(<-smallCh, Equals, w1)
+	pool.Recycle(w2)
+	c.Assert(<-bigCh, Equals, w2)
+}
+
+func (s *testWorkerPool) TestApplyFairCanceled(c *C) {
+	pool := worker.NewPool(context.Background(), 1, "test")
+	w := pool.Apply()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		_, err := pool.ApplyFair(ctx, "t")
+		errCh <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	c.Assert(<-errCh, Equals, context.Canceled)
+
+	// the canceled applicant must not take the recycled worker.
+	pool.Recycle(w)
+	c.Assert(pool.HasWorker(), IsTrue)
+}
+
+func (s *testWorkerPool) TestSetLimit(c *C) {
+	pool := worker.NewPool(context.Background(), 3, "test")
+	w1, w2 := pool.Apply(), pool.Apply()
+
+	// the idle worker is removed at once, and one in use when recycled.
+	pool.SetLimit(1)
+	c.Assert(pool.Limit(), Equals, 1)
+	c.Assert(pool.HasWorker(), IsFalse)
+	pool.Recycle(w2)
+	c.Assert(pool.HasWorker(), IsFalse)
+	pool.Recycle(w1)
+	c.Assert(pool.Apply(), Equals, w1)
+
+	// the retired workers are reused first, and handed to the waiters.
+	waitCh := make(chan *worker.Worker)
+	go func() {
+		waitCh <- pool.Apply()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	pool.SetLimit(4)
+	c.Assert(<-waitCh, Equals, w2)
+	c.Assert(pool.Apply().ID, Equals, int64(3))
+	c.Assert(pool.Apply().ID, Equals, int64(4))
+	c.Assert(pool.HasWorker(), IsFalse)
+}
@@ -41,6 +41,6 @@ check_contains 'sum(k): 32'
 # Verify the log contains the expected messages at the last few lines
 tail -20 "$TEST_DIR/lightning-error-summary.log" > "$TEST_DIR/lightning-error-summary.tail"
 grep -Fq '[error] Totally **2** tables failed to be imported.' "$TEST_DIR/lightning-error-summary.tail"
-grep -Fq '[`error_summary`.`a`] [checksum] checksum mismatched' "$TEST_DIR/lightning-error-summary.tail"
-grep -Fq '[`error_summary`.`c`] [checksum] checksum mismatched' "$TEST_DIR/lightning-error-summary.tail"
+grep -Fq '[`error_summary`.`a`] [checksum] [ChecksumMismatch] checksum mismatched' "$TEST_DIR/lightning-error-summary.tail"
+grep -Fq '[`error_summary`.`c`] [checksum] [ChecksumMismatch] checksum mismatched' "$TEST_DIR/lightning-error-summary.tail"
 ! grep -Fq '[`error_summary`.`b`] [checksum] checksum mismatched' "$TEST_DIR/lightning-error-summary.tail"
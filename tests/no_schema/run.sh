@@ -16,6 +16,7 @@
 set -eu
 
 run_sql "DROP DATABASE IF EXISTS noschema;"
+run_sql "DROP DATABASE IF EXISTS lightning_metadata;"
 run_lightning schema_config
 run_sql "show databases"
 check_not_contains "noschema"
@@ -28,3 +29,9 @@ run_lightning
 
 run_sql "SELECT sum(x) FROM noschema.t;"
 check_contains 'sum(x): 120'
+
+# Check the import history
+run_sql "SELECT status, kvc_kvs FROM lightning_metadata.table_history WHERE table_name = '\`noschema\`.\`t\`';"
+check_contains 'status: succeeded'
+run_sql "SELECT count(*) FROM lightning_metadata.task_history WHERE status = 'succeeded';"
+check_contains 'count(*): 1'